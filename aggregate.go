@@ -0,0 +1,164 @@
+package specifications
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AggregateFunction is the function of an Aggregate.
+type AggregateFunction string
+
+const (
+	AggregateCount AggregateFunction = "COUNT"
+	AggregateSum   AggregateFunction = "SUM"
+	AggregateMin   AggregateFunction = "MIN"
+	AggregateMax   AggregateFunction = "MAX"
+	AggregateAvg   AggregateFunction = "AVG"
+	// AggregateStddev is the sample standard deviation.
+	AggregateStddev AggregateFunction = "STDDEV"
+)
+
+// AggregatePercentile is the continuous percentile at fraction, between 0
+// and 1, e.g. PERCENTILE_CONT(0.95) for the 95th percentile, interpolating
+// between values.
+func AggregatePercentile(fraction float64) AggregateFunction {
+	return AggregateFunction("PERCENTILE_CONT(" + strconv.FormatFloat(fraction, 'g', -1, 64) + ")")
+}
+
+// Percentile returns the fraction of a function of AggregatePercentile.
+func (f AggregateFunction) Percentile() (fraction float64, ok bool) {
+	arg, ok := strings.CutPrefix(string(f), "PERCENTILE_CONT(")
+	if !ok {
+		return 0, false
+	}
+	arg, ok = strings.CutSuffix(arg, ")")
+	if !ok {
+		return 0, false
+	}
+	fraction, err := strconv.ParseFloat(arg, 64)
+	if err != nil || !(fraction >= 0 && fraction <= 1) {
+		return 0, false
+	}
+	return fraction, true
+}
+
+// ParseAggregateFunction normalizes function, accepting only COUNT, SUM,
+// MIN, MAX, AVG, STDDEV and PERCENTILE_CONT of a fraction between 0 and 1,
+// case-insensitively. Visitors reject other functions with
+// ErrInvalidValue.
+func ParseAggregateFunction(function string) (AggregateFunction, error) {
+	switch f := AggregateFunction(strings.ToUpper(strings.TrimSpace(function))); f {
+	case AggregateCount, AggregateSum, AggregateMin, AggregateMax, AggregateAvg, AggregateStddev:
+		return f, nil
+	default:
+		if fraction, ok := f.Percentile(); ok {
+			return AggregatePercentile(fraction), nil
+		}
+	}
+	return "", fmt.Errorf("%w: aggregate function %q", ErrInvalidValue, function)
+}
+
+type aggregateSpec struct {
+	function AggregateFunction
+	spec     Specification
+}
+
+func (s *aggregateSpec) Accept(v SpecificationVisitor) {
+	v.VisitAggregate(s.function, s.spec)
+}
+
+// Aggregated applies function to the fields of spec: its conditions and
+// orderings are on the aggregated values of each group rather than on the
+// rows. Aggregate builds the common cases.
+func Aggregated(function AggregateFunction, spec Specification) Specification {
+	return &aggregateSpec{function: function, spec: spec}
+}
+
+// Aggregate is an aggregate function over a field, whose comparisons are
+// conditions on the groups formed by GroupBy, for use in Having:
+//
+//	spec := And(
+//		GroupBy("CustomerID"),
+//		Having(Sum("Amount").Gte(1000)),
+//		Count("OrderID").Desc(),
+//	)
+type Aggregate struct {
+	function AggregateFunction
+	field    string
+}
+
+// Count counts the non-NULL values of field, or the rows of each group
+// with field "*".
+func Count(field string) Aggregate {
+	return Aggregate{function: AggregateCount, field: field}
+}
+
+func Sum(field string) Aggregate {
+	return Aggregate{function: AggregateSum, field: field}
+}
+
+func Min(field string) Aggregate {
+	return Aggregate{function: AggregateMin, field: field}
+}
+
+func Max(field string) Aggregate {
+	return Aggregate{function: AggregateMax, field: field}
+}
+
+func Avg(field string) Aggregate {
+	return Aggregate{function: AggregateAvg, field: field}
+}
+
+// Stddev is the sample standard deviation of field.
+func Stddev(field string) Aggregate {
+	return Aggregate{function: AggregateStddev, field: field}
+}
+
+// Percentile is the continuous percentile of field at fraction, e.g. the
+// requests whose p95 latency is over 300ms:
+//
+//	Having(Percentile("LatencyMs", 0.95).Gt(300))
+//
+// Few databases support it, e.g. PostgreSQL.
+func Percentile(field string, fraction float64) Aggregate {
+	return Aggregate{function: AggregatePercentile(fraction), field: field}
+}
+
+func (a Aggregate) Eq(value interface{}) Specification {
+	return Aggregated(a.function, Equal(a.field, value))
+}
+
+func (a Aggregate) Ne(value interface{}) Specification {
+	return Aggregated(a.function, NotEqual(a.field, value))
+}
+
+func (a Aggregate) Gt(value interface{}) Specification {
+	return Aggregated(a.function, GreaterThan(a.field, value))
+}
+
+func (a Aggregate) Gte(value interface{}) Specification {
+	return Aggregated(a.function, GreaterThanOrEqual(a.field, value))
+}
+
+func (a Aggregate) Lt(value interface{}) Specification {
+	return Aggregated(a.function, LowerThan(a.field, value))
+}
+
+func (a Aggregate) Lte(value interface{}) Specification {
+	return Aggregated(a.function, LowerThanOrEqual(a.field, value))
+}
+
+func (a Aggregate) Between(low, high interface{}) Specification {
+	return Aggregated(a.function, Between(a.field, low, high))
+}
+
+// Asc orders the groups by the aggregated value in ascending order.
+func (a Aggregate) Asc() Specification {
+	return Aggregated(a.function, Asc(a.field))
+}
+
+// Desc orders the groups by the aggregated value in descending order.
+func (a Aggregate) Desc() Specification {
+	return Aggregated(a.function, Desc(a.field))
+}
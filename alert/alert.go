@@ -0,0 +1,248 @@
+// Package alert evaluates specifications periodically as alerting rules, in
+// place of ad-hoc cron SQL: each rule counts the entities matching its
+// specification and fires when the count crosses its threshold, notifying
+// when it starts or stops firing.
+//
+//	s := alert.NewScheduler(counter, alert.NotifierFunc(page))
+//	s.Register(alert.Rule{
+//		Name:     "failed-payments",
+//		Spec:     specifications.And(specifications.Equal("status", "failed"), specifications.WithinLast("updated_at", time.Hour)),
+//		Fires:    alert.Above(10),
+//		Interval: time.Minute,
+//		Jitter:   10 * time.Second,
+//	})
+//	err := s.Run(ctx)
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/thefabric-io/specifications"
+)
+
+var (
+	// ErrInvalidRule is returned when registering a rule without name,
+	// specification or positive interval, or with a name already
+	// registered.
+	ErrInvalidRule = errors.New("alert: invalid rule")
+	// ErrUnknownRule is returned when evaluating a rule that is not
+	// registered.
+	ErrUnknownRule = errors.New("alert: unknown rule")
+	// ErrOverlap is returned when evaluating a rule whose previous
+	// evaluation is still running.
+	ErrOverlap = errors.New("alert: evaluation already running")
+)
+
+// Counter returns the number of entities matching a specification, e.g.
+// with a COUNT query built by a sqlspec visitor. Rules checking for the
+// existence of entities may return 1 for any.
+type Counter interface {
+	Count(ctx context.Context, spec specifications.Specification) (int64, error)
+}
+
+// CounterFunc adapts a function to the Counter interface.
+type CounterFunc func(ctx context.Context, spec specifications.Specification) (int64, error)
+
+func (f CounterFunc) Count(ctx context.Context, spec specifications.Specification) (int64, error) {
+	return f(ctx, spec)
+}
+
+// Notifier receives the results of the evaluations in which a rule starts
+// or stops firing, or fails.
+type Notifier interface {
+	Notify(ctx context.Context, r Result)
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, r Result)
+
+func (f NotifierFunc) Notify(ctx context.Context, r Result) {
+	f(ctx, r)
+}
+
+// Rule is a specification evaluated periodically.
+type Rule struct {
+	Name string
+	Spec specifications.Specification
+	// Fires reports whether a count crosses the threshold of the rule. Nil
+	// fires on any matching entity.
+	Fires func(count int64) bool
+	// Interval is the time between the end of an evaluation and the start
+	// of the next one, so that evaluations of a rule never overlap.
+	Interval time.Duration
+	// Jitter is the maximum random delay added to each interval, so that
+	// rules registered together do not hit the database at once.
+	Jitter time.Duration
+}
+
+// Above fires when the count exceeds threshold.
+func Above(threshold int64) func(count int64) bool {
+	return func(count int64) bool { return count > threshold }
+}
+
+// Below fires when the count is under threshold, e.g. for rules on
+// expected activity.
+func Below(threshold int64) func(count int64) bool {
+	return func(count int64) bool { return count < threshold }
+}
+
+// Result is the outcome of an evaluation.
+type Result struct {
+	Rule  string
+	At    time.Time
+	Count int64
+	// Firing tells whether the rule fires. On errors, it is that of the
+	// previous evaluation.
+	Firing bool
+	// Changed is set when Firing differs from the previous evaluation, not
+	// firing before the first one.
+	Changed bool
+	Err     error
+}
+
+type rule struct {
+	Rule
+	running bool
+	last    Result
+}
+
+// Scheduler evaluates registered rules. It is safe for concurrent use.
+type Scheduler struct {
+	counter  Counter
+	notifier Notifier
+
+	mu    sync.Mutex
+	rules map[string]*rule
+	names []string
+}
+
+// NewScheduler returns a scheduler without rules, counting with counter and
+// notifying notifier, which may be nil, e.g. when results are only read
+// with Last.
+func NewScheduler(counter Counter, notifier Notifier) *Scheduler {
+	return &Scheduler{counter: counter, notifier: notifier, rules: map[string]*rule{}}
+}
+
+// Register adds r, evaluated by Run and Evaluate.
+func (s *Scheduler) Register(r Rule) error {
+	if r.Name == "" || r.Spec == nil || r.Interval <= 0 || r.Jitter < 0 {
+		return fmt.Errorf("%w: %q needs a name, a specification and a positive interval", ErrInvalidRule, r.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[r.Name]; ok {
+		return fmt.Errorf("%w: %s is already registered", ErrInvalidRule, r.Name)
+	}
+	s.rules[r.Name] = &rule{Rule: r}
+	s.names = append(s.names, r.Name)
+	return nil
+}
+
+// Run evaluates the rules registered when it is called, each after its
+// interval and jitter, until ctx is done, and returns ctx's error.
+// Evaluations still running when a rule is due again, e.g. started with
+// Evaluate, are not overlapped: the rule waits for its next interval.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	names := append([]string(nil), s.names...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, name)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (s *Scheduler) loop(ctx context.Context, name string) {
+	s.mu.Lock()
+	r := s.rules[name].Rule
+	s.mu.Unlock()
+
+	timer := time.NewTimer(delay(r))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		_, _ = s.Evaluate(ctx, name)
+		timer.Reset(delay(r))
+	}
+}
+
+func delay(r Rule) time.Duration {
+	if r.Jitter <= 0 {
+		return r.Interval
+	}
+	return r.Interval + rand.N(r.Jitter)
+}
+
+// Evaluate evaluates the rule name now, records its result for Last and
+// notifies it when the rule starts or stops firing, or fails. The error is
+// that of the evaluation, also in the result, ErrUnknownRule or
+// ErrOverlap.
+func (s *Scheduler) Evaluate(ctx context.Context, name string) (Result, error) {
+	s.mu.Lock()
+	r, ok := s.rules[name]
+	if !ok {
+		s.mu.Unlock()
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownRule, name)
+	}
+	if r.running {
+		s.mu.Unlock()
+		return Result{}, fmt.Errorf("%w: %s", ErrOverlap, name)
+	}
+	r.running = true
+	s.mu.Unlock()
+
+	result := Result{Rule: name, At: time.Now()}
+	count, err := s.counter.Count(ctx, r.Spec)
+	if err != nil {
+		result.Err = fmt.Errorf("alert: %s: %w", name, err)
+	} else {
+		result.Count = count
+		if r.Fires != nil {
+			result.Firing = r.Fires(count)
+		} else {
+			result.Firing = count > 0
+		}
+	}
+
+	s.mu.Lock()
+	if err != nil {
+		result.Firing = r.last.Firing
+	} else {
+		result.Changed = result.Firing != r.last.Firing
+	}
+	r.last, r.running = result, false
+	s.mu.Unlock()
+
+	if s.notifier != nil && (result.Changed || result.Err != nil) {
+		s.notifier.Notify(ctx, result)
+	}
+	return result, result.Err
+}
+
+// Last returns the result of the last evaluation of the rule name. ok is
+// false for unknown rules and rules not evaluated yet.
+func (s *Scheduler) Last(name string) (result Result, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rules[name]
+	if !ok || r.last.At.IsZero() {
+		return Result{}, false
+	}
+	return r.last, true
+}
@@ -0,0 +1,49 @@
+package specifications
+
+type arrayContainsSpec struct {
+	field  string
+	values []interface{}
+}
+
+func (s *arrayContainsSpec) Accept(v SpecificationVisitor) {
+	v.VisitArrayContains(s.field, s.values)
+}
+
+// ArrayContains matches entities whose array field contains every one of
+// values, like the PostgreSQL @> operator, e.g.
+//
+//	ArrayContains("Tags", "sale", "new")
+func ArrayContains(field string, values ...interface{}) Specification {
+	return &arrayContainsSpec{field: field, values: values}
+}
+
+type arrayOverlapsSpec struct {
+	field  string
+	values []interface{}
+}
+
+func (s *arrayOverlapsSpec) Accept(v SpecificationVisitor) {
+	v.VisitArrayOverlaps(s.field, s.values)
+}
+
+// ArrayOverlaps matches entities whose array field contains at least one
+// of values, like the PostgreSQL && operator.
+func ArrayOverlaps(field string, values ...interface{}) Specification {
+	return &arrayOverlapsSpec{field: field, values: values}
+}
+
+type equalAnySpec struct {
+	field  string
+	values []interface{}
+}
+
+func (s *equalAnySpec) Accept(v SpecificationVisitor) {
+	v.VisitEqualAny(s.field, s.values)
+}
+
+// EqualAny matches like In, but dialects with arrays bind values as a
+// single array, e.g. = ANY($1) in PostgreSQL, so the statement does not
+// grow with the number of values. Other visitors treat it as In.
+func EqualAny(field string, values ...interface{}) Specification {
+	return &equalAnySpec{field: field, values: values}
+}
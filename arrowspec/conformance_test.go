@@ -0,0 +1,105 @@
+package arrowspec_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/thefabric-io/specifications/arrowspec"
+	"github.com/thefabric-io/specifications/spectest"
+)
+
+// conformance returns ConformanceRows as a record batch with the columns
+// of the SQL fixture.
+func conformance(t *testing.T) arrow.Record {
+	t.Helper()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "score", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "tag", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	text := func(f *array.StringBuilder, s *string) {
+		if s == nil {
+			f.AppendNull()
+			return
+		}
+		f.Append(*s)
+	}
+	for _, r := range spectest.ConformanceRows() {
+		b.Field(0).(*array.Int64Builder).Append(r.ID)
+		text(b.Field(1).(*array.StringBuilder), r.Name)
+		if r.Score == nil {
+			b.Field(2).AppendNull()
+		} else {
+			b.Field(2).(*array.Int64Builder).Append(*r.Score)
+		}
+		text(b.Field(3).(*array.StringBuilder), r.Tag)
+	}
+	return b.NewRecord()
+}
+
+func ids(rec arrow.Record) []int64 {
+	return slices.Clone(rec.Column(0).(*array.Int64).Int64Values())
+}
+
+// TestConformance runs the unordered cases of the conformance corpus, as
+// ordering and pagination are left to the reader: Filter must return the
+// rows the SQL backends do, and RowGroups must keep the row group of each,
+// here one per row.
+func TestConformance(t *testing.T) {
+	ctx := context.Background()
+	rec := conformance(t)
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	props := parquet.NewWriterProperties(parquet.WithMaxRowGroupLength(1), parquet.WithStats(true))
+	w, err := pqarrow.NewFileWriter(rec.Schema(), &buf, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, c := range spectest.ConformanceCases() {
+		if c.Ordered {
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			out, err := arrowspec.Filter(ctx, rec, c.Spec, nil)
+			if err != nil {
+				t.Fatalf("filter: %v", err)
+			}
+			defer out.Release()
+			spectest.CheckConformance(t, c, ids(out))
+
+			groups, err := arrowspec.RowGroups(f.MetaData(), c.Spec, nil)
+			if err != nil {
+				t.Fatalf("row groups: %v", err)
+			}
+			for _, id := range c.Want {
+				if !slices.Contains(groups, int(id-1)) {
+					t.Errorf("%s: row group of ID %d pruned, kept %v", c.Name, id, groups)
+				}
+			}
+		})
+	}
+}
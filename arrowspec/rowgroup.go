@@ -0,0 +1,269 @@
+package arrowspec
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/scalar"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/thefabric-io/specifications"
+)
+
+// rowGroup is a row group of a Parquet file.
+type rowGroup struct {
+	meta   *metadata.RowGroupMetaData
+	schema *schema.Schema
+}
+
+// stats are the statistics of a column chunk of a row group.
+type stats struct {
+	rows int64
+	// nulls is the number of NULLs, -1 when unknown.
+	nulls int64
+	// min and max are nil when unknown or of a physical and logical type
+	// whose values are not compared.
+	min, max interface{}
+	// floating is set for floating-point columns, whose NaNs are not in
+	// min and max.
+	floating bool
+	// truncate, for temporal columns, truncates times to their precision,
+	// as when they are compared with the values of the column.
+	truncate func(t time.Time) time.Time
+}
+
+// stats returns the statistics of the leaf column named column.
+func (rg rowGroup) stats(column string) (stats, error) {
+	s := stats{rows: rg.meta.NumRows(), nulls: -1}
+	i := rg.schema.ColumnIndexByName(column)
+	if i < 0 {
+		return s, fmt.Errorf("%w: no column %q", specifications.ErrInvalidField, column)
+	}
+	if rg.schema.Column(i).MaxRepetitionLevel() > 0 {
+		// The values of repeated columns, in lists, are not those of rows.
+		return s, nil
+	}
+	chunk, err := rg.meta.ColumnChunk(i)
+	if err != nil {
+		return s, err
+	}
+	if set, err := chunk.StatsSet(); err != nil || !set {
+		return s, err
+	}
+	st, err := chunk.Statistics()
+	if err != nil || st == nil {
+		return s, err
+	}
+	if st.HasNullCount() {
+		s.nulls = st.NullCount()
+	}
+	if st.HasMinMax() {
+		s.bound(st)
+	}
+	return s, nil
+}
+
+// bound sets the minimum and maximum of s from st, as values compared by
+// compareValues, unless they are of other types.
+func (s *stats) bound(st metadata.TypedStatistics) {
+	logical := st.Descr().LogicalType()
+	switch st := st.(type) {
+	case *metadata.BooleanStatistics:
+		s.min, s.max = st.Min(), st.Max()
+	case *metadata.Int32Statistics:
+		if _, ok := logical.(schema.DateLogicalType); ok {
+			s.min, s.max = arrow.Date32(st.Min()).ToTime(), arrow.Date32(st.Max()).ToTime()
+			s.truncate = func(t time.Time) time.Time { return arrow.Date32FromTime(t).ToTime() }
+		} else if signed(logical) {
+			s.min, s.max = int64(st.Min()), int64(st.Max())
+		}
+	case *metadata.Int64Statistics:
+		if t, ok := logical.(schema.TimestampLogicalType); ok {
+			unit, ok := timeUnits[t.TimeUnit()]
+			if !ok {
+				return
+			}
+			s.min, s.max = arrow.Timestamp(st.Min()).ToTime(unit), arrow.Timestamp(st.Max()).ToTime(unit)
+			s.truncate = func(t time.Time) time.Time {
+				ts, _ := arrow.TimestampFromTime(t, unit)
+				return ts.ToTime(unit)
+			}
+		} else if signed(logical) {
+			s.min, s.max = st.Min(), st.Max()
+		}
+	case *metadata.Float32Statistics:
+		s.min, s.max, s.floating = float64(st.Min()), float64(st.Max()), true
+	case *metadata.Float64Statistics:
+		s.min, s.max, s.floating = st.Min(), st.Max(), true
+	case *metadata.ByteArrayStatistics:
+		if _, ok := logical.(schema.StringLogicalType); ok || logical.IsNone() {
+			s.min, s.max = string(st.Min()), string(st.Max())
+		}
+	}
+}
+
+// signed reports whether integers of the logical type are signed numbers.
+func signed(logical schema.LogicalType) bool {
+	if t, ok := logical.(schema.IntLogicalType); ok {
+		return t.IsSigned()
+	}
+	return logical.IsNone()
+}
+
+var timeUnits = map[schema.TimeUnitType]arrow.TimeUnit{
+	schema.TimeUnitMillis: arrow.Millisecond,
+	schema.TimeUnitMicros: arrow.Microsecond,
+	schema.TimeUnitNanos:  arrow.Nanosecond,
+}
+
+// comparisons tell, from the comparisons of the minimum and the maximum of
+// a column with a value, whether the compute function comparing the column
+// with the value may be true, and whether it may be false, on values
+// between them.
+var comparisons = map[string]func(min, max int) (mayTrue, mayFalse bool){
+	"equal":         func(min, max int) (bool, bool) { return min <= 0 && max >= 0, min != 0 || max != 0 },
+	"not_equal":     func(min, max int) (bool, bool) { return min != 0 || max != 0, min <= 0 && max >= 0 },
+	"greater":       func(min, max int) (bool, bool) { return max > 0, min <= 0 },
+	"greater_equal": func(min, max int) (bool, bool) { return max >= 0, min < 0 },
+	"less":          func(min, max int) (bool, bool) { return min < 0, max >= 0 },
+	"less_equal":    func(min, max int) (bool, bool) { return min <= 0, max > 0 },
+}
+
+// compare reports whether the comparison of the column with value by the
+// compute function fn may be true, and whether it may be false, on the
+// rows of the row group. Comparisons with NULL are neither.
+func (s stats) compare(fn string, value interface{}) (mayTrue, mayFalse bool) {
+	if value == nil || s.nulls == s.rows {
+		return false, false
+	}
+	if s.min == nil {
+		return true, true
+	}
+	if t, ok := value.(time.Time); ok && s.truncate != nil {
+		value = s.truncate(t)
+	}
+	lo, okl := compareValues(s.min, value)
+	hi, okh := compareValues(s.max, value)
+	if !okl || !okh {
+		return true, true
+	}
+	mayTrue, mayFalse = comparisons[fn](lo, hi)
+	if s.floating {
+		// Comparisons with NaN are false, but for not_equal.
+		mayTrue, mayFalse = mayTrue || fn == "not_equal", true
+	}
+	return mayTrue, mayFalse
+}
+
+// plain converts the supported values of conditions into int64, float64,
+// string, bool or time.Time.
+func plain(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case int:
+		return int64(x), true
+	case int8:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint:
+		return unsigned(uint64(x)), true
+	case uint8:
+		return int64(x), true
+	case uint16:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	case uint64:
+		return unsigned(x), true
+	case float32:
+		return float64(x), true
+	case float64, string, bool, time.Time:
+		return x, true
+	case []byte:
+		return string(x), true
+	}
+	return nil, false
+}
+
+func unsigned(u uint64) interface{} {
+	if u > math.MaxInt64 {
+		return float64(u)
+	}
+	return int64(u)
+}
+
+// compareValues compares a and b, and reports whether they are comparable.
+func compareValues(a, b interface{}) (int, bool) {
+	pa, oka := plain(a)
+	pb, okb := plain(b)
+	if !oka || !okb {
+		return 0, false
+	}
+	switch x := pa.(type) {
+	case int64:
+		switch y := pb.(type) {
+		case int64:
+			return cmp.Compare(x, y), true
+		case float64:
+			return cmp.Compare(float64(x), y), true
+		}
+	case float64:
+		switch y := pb.(type) {
+		case int64:
+			return cmp.Compare(x, float64(y)), true
+		case float64:
+			return cmp.Compare(x, y), true
+		}
+	case string:
+		if y, ok := pb.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := pb.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if y, ok := pb.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	}
+	return 0, false
+}
+
+// literal returns value as a scalar to compare with a column of type dt.
+func literal(value interface{}, dt arrow.DataType) (scalar.Scalar, error) {
+	switch x := value.(type) {
+	case nil:
+		return scalar.MakeNullScalar(dt), nil
+	case time.Time:
+		switch t := dt.(type) {
+		case *arrow.TimestampType:
+			ts, err := arrow.TimestampFromTime(x, t.Unit)
+			if err != nil {
+				return nil, err
+			}
+			return scalar.NewTimestampScalar(ts, t), nil
+		case *arrow.Date32Type:
+			return scalar.NewDate32Scalar(arrow.Date32FromTime(x)), nil
+		}
+		return nil, fmt.Errorf("time compared with a %s column", dt)
+	}
+	if _, ok := plain(value); !ok {
+		return nil, fmt.Errorf("unsupported %T value", value)
+	}
+	return scalar.MakeScalar(value), nil
+}
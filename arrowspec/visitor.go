@@ -0,0 +1,611 @@
+// Package arrowspec pushes specifications down to readers of Apache Arrow
+// record batches and Parquet files, such as data-lake scans.
+//
+// RowGroups selects the row groups of a Parquet file whose column
+// statistics do not rule out matching rows, so that only those are read,
+// e.g. with pqarrow's FileReader.GetRecordReader, and Filter evaluates the
+// conditions on the record batches read with Arrow compute kernels.
+// Conditions follow SQL semantics: comparisons never match NULLs.
+//
+// Only conditions are pushed down. Ordering, pagination, distinct, sampling
+// and grouping, including the conditions of Having, are left to the
+// reader: the rows selected may be more than those of the specification,
+// never fewer.
+package arrowspec
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/compute"
+	"github.com/apache/arrow-go/v18/arrow/compute/exec"
+	"github.com/apache/arrow-go/v18/arrow/scalar"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/thefabric-io/specifications"
+)
+
+// Visitor compiles the conditions of specifications into a filter of Arrow
+// record batches and of Parquet row groups. The field map translates domain
+// fields into column names, and unmapped fields are used as-is. Columns of
+// record batches are top-level ones, those of Parquet files leaf columns,
+// e.g. "address.city".
+type Visitor struct {
+	conditions []condition
+	fieldMap   map[string]string
+	empty      specifications.EmptyComposite
+	err        error
+}
+
+// condition is a visited condition.
+type condition struct {
+	// eval returns its value on each row of rec, NULL where it is unknown.
+	eval func(ctx context.Context, rec arrow.Record) (compute.Datum, error)
+	// prune reports whether it may be true, and whether it may be false, on
+	// some rows of rg.
+	prune func(rg rowGroup) (mayTrue, mayFalse bool, err error)
+}
+
+// Option configures a Visitor.
+type Option func(*Visitor)
+
+// WithEmptyComposite sets how And and Or without children are translated.
+// The default is specifications.EmptyCompositeSkip.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return func(v *Visitor) {
+		v.empty = mode
+	}
+}
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	v := &Visitor{fieldMap: fieldMap}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Filter returns the rows of rec matching spec. The caller releases the
+// returned record.
+func Filter(ctx context.Context, rec arrow.Record, spec specifications.Specification, fieldMap map[string]string) (arrow.Record, error) {
+	v := NewVisitor(fieldMap)
+	spec.Accept(v)
+	return v.Filter(ctx, rec)
+}
+
+// RowGroups returns the indices of the row groups of a Parquet file that
+// may hold rows matching spec.
+func RowGroups(md *metadata.FileMetaData, spec specifications.Specification, fieldMap map[string]string) ([]int, error) {
+	v := NewVisitor(fieldMap)
+	spec.Accept(v)
+	return v.RowGroups(md)
+}
+
+// child returns an empty visitor sharing v's configuration, used for
+// composite specifications.
+func (v *Visitor) child() *Visitor {
+	return NewVisitor(v.fieldMap, WithEmptyComposite(v.empty))
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (v *Visitor) Err() error {
+	return v.err
+}
+
+func (v *Visitor) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+func (v *Visitor) mapField(domainField string) string {
+	if domainField == "" {
+		v.fail(specifications.ErrInvalidField)
+	}
+	if column, ok := v.fieldMap[domainField]; ok {
+		return column
+	}
+	return domainField
+}
+
+func (v *Visitor) add(c condition) {
+	v.conditions = append(v.conditions, c)
+}
+
+// Filter returns the rows of rec for which the visited conditions hold, in
+// their order, or the first error reported while visiting or evaluating
+// them. The caller releases the returned record.
+func (v *Visitor) Filter(ctx context.Context, rec arrow.Record) (arrow.Record, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if len(v.conditions) == 0 {
+		rec.Retain()
+		return rec, nil
+	}
+	mask, err := conjunction(v.conditions).eval(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+	defer mask.Release()
+	var selection arrow.Array
+	switch m := mask.(type) {
+	case *compute.ArrayDatum:
+		selection = m.MakeArray()
+	case *compute.ScalarDatum:
+		if selection, err = scalar.MakeArrayFromScalar(m.Value, int(rec.NumRows()), exec.GetAllocator(ctx)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("arrowspec: unexpected %s filter", mask.Kind())
+	}
+	defer selection.Release()
+	return compute.FilterRecordBatch(ctx, rec, selection, compute.DefaultFilterOptions())
+}
+
+// RowGroups returns the indices of the row groups of md whose statistics do
+// not rule out rows matching the visited conditions, or the first error
+// reported while visiting them. Row groups without statistics are kept.
+func (v *Visitor) RowGroups(md *metadata.FileMetaData) ([]int, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	c := conjunction(v.conditions)
+	groups := []int{}
+	for i := 0; i < md.NumRowGroups(); i++ {
+		mayTrue, _, err := c.prune(rowGroup{meta: md.RowGroup(i), schema: md.Schema})
+		if err != nil {
+			return nil, err
+		}
+		if mayTrue {
+			groups = append(groups, i)
+		}
+	}
+	return groups, nil
+}
+
+// columnOf returns the column of rec named name.
+func columnOf(rec arrow.Record, name string) (arrow.Array, error) {
+	indices := rec.Schema().FieldIndices(name)
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("%w: no column %q", specifications.ErrInvalidField, name)
+	}
+	return rec.Column(indices[0]), nil
+}
+
+// comparison returns the condition comparing column with value by the
+// compute function fn, one of the keys of comparisons.
+func comparison(column string, fn string, value interface{}) condition {
+	return condition{
+		eval: func(ctx context.Context, rec arrow.Record) (compute.Datum, error) {
+			arr, err := columnOf(rec, column)
+			if err != nil {
+				return nil, err
+			}
+			lit, err := literal(value, arr.DataType())
+			if err != nil {
+				return nil, fmt.Errorf("%w: comparing %q with %v: %v", specifications.ErrInvalidValue, column, value, err)
+			}
+			out, err := compute.CallFunction(ctx, fn, nil, compute.NewDatumWithoutOwning(arr), compute.NewDatum(lit))
+			if err != nil {
+				return nil, fmt.Errorf("%w: comparing %q with %v: %v", specifications.ErrInvalidValue, column, value, err)
+			}
+			return out, nil
+		},
+		prune: func(rg rowGroup) (bool, bool, error) {
+			s, err := rg.stats(column)
+			if err != nil {
+				return false, false, err
+			}
+			mayTrue, mayFalse := s.compare(fn, value)
+			return mayTrue, mayFalse, nil
+		},
+	}
+}
+
+// constant returns the condition of constant value b.
+func constant(b bool) condition {
+	return condition{
+		eval: func(context.Context, arrow.Record) (compute.Datum, error) {
+			return compute.NewDatum(scalar.NewBooleanScalar(b)), nil
+		},
+		prune: func(rowGroup) (bool, bool, error) { return b, !b, nil },
+	}
+}
+
+// conjunction returns the condition holding when all of conditions hold,
+// with SQL's three-valued AND.
+func conjunction(conditions []condition) condition {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return condition{
+		eval: func(ctx context.Context, rec arrow.Record) (compute.Datum, error) {
+			return fold(ctx, rec, "and_kleene", true, conditions)
+		},
+		prune: func(rg rowGroup) (bool, bool, error) {
+			mayTrue, mayFalse := true, false
+			for _, c := range conditions {
+				t, f, err := c.prune(rg)
+				if err != nil {
+					return false, false, err
+				}
+				mayTrue, mayFalse = mayTrue && t, mayFalse || f
+			}
+			return mayTrue, mayFalse, nil
+		},
+	}
+}
+
+// disjunction returns the condition holding when any of conditions holds,
+// with SQL's three-valued OR.
+func disjunction(conditions []condition) condition {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return condition{
+		eval: func(ctx context.Context, rec arrow.Record) (compute.Datum, error) {
+			return fold(ctx, rec, "or_kleene", false, conditions)
+		},
+		prune: func(rg rowGroup) (bool, bool, error) {
+			mayTrue, mayFalse := false, true
+			for _, c := range conditions {
+				t, f, err := c.prune(rg)
+				if err != nil {
+					return false, false, err
+				}
+				mayTrue, mayFalse = mayTrue || t, mayFalse && f
+			}
+			return mayTrue, mayFalse, nil
+		},
+	}
+}
+
+// negation returns the condition holding when c does not, unknown where c
+// is.
+func negation(c condition) condition {
+	return condition{
+		eval: func(ctx context.Context, rec arrow.Record) (compute.Datum, error) {
+			d, err := c.eval(ctx, rec)
+			if err != nil {
+				return nil, err
+			}
+			defer d.Release()
+			return compute.CallFunction(ctx, "not", nil, d)
+		},
+		prune: func(rg rowGroup) (bool, bool, error) {
+			mayTrue, mayFalse, err := c.prune(rg)
+			return mayFalse, mayTrue, err
+		},
+	}
+}
+
+// fold combines the values of conditions on rec with the compute function
+// fn, starting from identity.
+func fold(ctx context.Context, rec arrow.Record, fn string, identity bool, conditions []condition) (compute.Datum, error) {
+	acc := compute.NewDatum(scalar.NewBooleanScalar(identity))
+	for _, c := range conditions {
+		d, err := c.eval(ctx, rec)
+		if err != nil {
+			acc.Release()
+			return nil, err
+		}
+		next, err := compute.CallFunction(ctx, fn, nil, acc, d)
+		acc.Release()
+		d.Release()
+		if err != nil {
+			return nil, err
+		}
+		acc = next
+	}
+	return acc, nil
+}
+
+func (v *Visitor) compare(field, fn string, value interface{}) {
+	v.add(comparison(v.mapField(field), fn, value))
+}
+
+func (v *Visitor) VisitEqual(field string, value interface{}) {
+	v.compare(field, "equal", value)
+}
+
+func (v *Visitor) VisitNotEqual(field string, value interface{}) {
+	v.compare(field, "not_equal", value)
+}
+
+func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
+	v.compare(field, "greater", value)
+}
+
+func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
+	v.compare(field, "greater_equal", value)
+}
+
+func (v *Visitor) VisitLowerThan(field string, value interface{}) {
+	v.compare(field, "less", value)
+}
+
+func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
+	v.compare(field, "less_equal", value)
+}
+
+func (v *Visitor) VisitBetween(field string, low, high interface{}) {
+	column := v.mapField(field)
+	v.add(conjunction([]condition{
+		comparison(column, "greater_equal", low),
+		comparison(column, "less_equal", high),
+	}))
+}
+
+// in returns the condition holding when column equals one of values, false
+// without values.
+func in(column string, values []interface{}) condition {
+	if len(values) == 0 {
+		return constant(false)
+	}
+	equals := make([]condition, len(values))
+	for i, value := range values {
+		equals[i] = comparison(column, "equal", value)
+	}
+	return disjunction(equals)
+}
+
+func (v *Visitor) VisitIn(field string, values []interface{}) {
+	v.add(in(v.mapField(field), values))
+}
+
+func (v *Visitor) VisitNotIn(field string, values []interface{}) {
+	v.add(negation(in(v.mapField(field), values)))
+}
+
+func (v *Visitor) VisitEqualAny(field string, values []interface{}) {
+	v.VisitIn(field, values)
+}
+
+// isNull returns the condition holding when column is NULL.
+func isNull(column string) condition {
+	return condition{
+		eval: func(ctx context.Context, rec arrow.Record) (compute.Datum, error) {
+			arr, err := columnOf(rec, column)
+			if err != nil {
+				return nil, err
+			}
+			return compute.CallFunction(ctx, "is_null", &compute.NullOptions{}, compute.NewDatumWithoutOwning(arr))
+		},
+		prune: func(rg rowGroup) (bool, bool, error) {
+			s, err := rg.stats(column)
+			if err != nil {
+				return false, false, err
+			}
+			if s.nulls < 0 {
+				return true, true, nil
+			}
+			return s.nulls > 0, s.nulls < s.rows, nil
+		},
+	}
+}
+
+func (v *Visitor) VisitIsNull(field string) {
+	v.add(isNull(v.mapField(field)))
+}
+
+func (v *Visitor) VisitIsNotNull(field string) {
+	v.add(negation(isNull(v.mapField(field))))
+}
+
+func (v *Visitor) VisitLike(field string, value interface{}) {
+	v.like(field, value, false)
+}
+
+func (v *Visitor) VisitILike(field string, value interface{}) {
+	v.like(field, value, true)
+}
+
+func (v *Visitor) VisitLikeEscaped(field string, pattern string) {
+	v.like(field, pattern, false)
+}
+
+func (v *Visitor) like(field string, value interface{}, caseInsensitive bool) {
+	pattern, ok := value.(string)
+	if !ok {
+		v.fail(fmt.Errorf("%w: LIKE pattern for %q must be a string", specifications.ErrInvalidValue, field))
+		return
+	}
+	v.match(field, specifications.LikeToRegexp(pattern), caseInsensitive)
+}
+
+func (v *Visitor) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	v.match(field, pattern, caseInsensitive)
+}
+
+// match adds the condition that the text of field matches the regular
+// expression pattern. Statistics cannot rule it out but on NULL columns.
+func (v *Visitor) match(field string, pattern string, caseInsensitive bool) {
+	flags := "(?s)"
+	if caseInsensitive {
+		flags = "(?is)"
+	}
+	re, err := regexp.Compile(flags + pattern)
+	if err != nil {
+		v.fail(fmt.Errorf("%w: pattern for %q: %v", specifications.ErrInvalidValue, field, err))
+		return
+	}
+	column := v.mapField(field)
+	v.add(condition{
+		eval: func(ctx context.Context, rec arrow.Record) (compute.Datum, error) {
+			arr, err := columnOf(rec, column)
+			if err != nil {
+				return nil, err
+			}
+			texts, ok := arr.(interface{ Value(i int) string })
+			if !ok {
+				return nil, fmt.Errorf("%w: matching the %s column %q", specifications.ErrInvalidValue, arr.DataType(), column)
+			}
+			b := array.NewBooleanBuilder(exec.GetAllocator(ctx))
+			defer b.Release()
+			b.Reserve(arr.Len())
+			for i := 0; i < arr.Len(); i++ {
+				if arr.IsNull(i) {
+					b.AppendNull()
+					continue
+				}
+				b.Append(re.MatchString(texts.Value(i)))
+			}
+			out := b.NewArray()
+			defer out.Release()
+			return compute.NewDatum(out), nil
+		},
+		prune: func(rg rowGroup) (bool, bool, error) {
+			s, err := rg.stats(column)
+			if err != nil {
+				return false, false, err
+			}
+			values := s.nulls != s.rows
+			return values, values, nil
+		},
+	})
+}
+
+// VisitAnd adds the conditions of specs in place, as they all must hold.
+func (v *Visitor) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(true)
+		return
+	}
+	for _, s := range specs {
+		s.Accept(v)
+	}
+}
+
+// VisitOr holds when a branch does; branches without conditions are
+// skipped.
+func (v *Visitor) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(false)
+		return
+	}
+	var branches []condition
+	for _, s := range specs {
+		sub := v.child()
+		s.Accept(sub)
+		if len(sub.conditions) > 0 {
+			branches = append(branches, conjunction(sub.conditions))
+		}
+		v.merge(sub)
+	}
+	if len(branches) > 0 {
+		v.add(disjunction(branches))
+	}
+}
+
+func (v *Visitor) VisitNot(spec specifications.Specification) {
+	sub := v.child()
+	spec.Accept(sub)
+	if len(sub.conditions) > 0 {
+		v.add(negation(conjunction(sub.conditions)))
+	}
+	v.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being its
+// logical identity.
+func (v *Visitor) visitEmpty(identity bool) {
+	switch v.empty {
+	case specifications.EmptyCompositeIdentity:
+		v.add(constant(identity))
+	case specifications.EmptyCompositeError:
+		v.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but conditions over from a sub-visitor.
+func (v *Visitor) merge(sub *Visitor) {
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+}
+
+// VisitLimit, VisitOffset, VisitOrder, VisitOrderNulls, VisitDistinct and
+// VisitSample are no-ops: they are left to the reader.
+func (v *Visitor) VisitLimit(limit int)                                                        {}
+func (v *Visitor) VisitOffset(offset int)                                                      {}
+func (v *Visitor) VisitOrder(field, direction string)                                          {}
+func (v *Visitor) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {}
+func (v *Visitor) VisitDistinct(fields []string)                                               {}
+func (v *Visitor) VisitSample(percent float64, method specifications.SampleMethod)             {}
+
+// VisitTextRank, VisitSimilarityOrder and VisitOrderByDistance are no-ops,
+// like VisitOrder.
+func (v *Visitor) VisitTextRank(field, query, language string)                  {}
+func (v *Visitor) VisitSimilarityOrder(field, value string)                     {}
+func (v *Visitor) VisitOrderByDistance(field string, from specifications.Point) {}
+
+// VisitGroupBy and VisitHaving are no-ops: the rows are grouped by the
+// reader, and the conditions of Having are on groups, not rows.
+func (v *Visitor) VisitGroupBy(fields []string)                  {}
+func (v *Visitor) VisitHaving(spec specifications.Specification) {}
+
+// VisitLock is a no-op: files are not locked, as in the memory evaluator.
+func (v *Visitor) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	if _, _, err := specifications.ParseLock(string(strength), string(wait)); err != nil {
+		v.fail(err)
+	}
+}
+
+// VisitAggregate is not supported outside of Having, whose conditions are
+// not pushed down.
+func (v *Visitor) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: aggregates in an Arrow filter", specifications.ErrUnsupported))
+}
+
+// VisitJSONContains, VisitJSONPathEqual and VisitJSONKeyExists are not
+// supported: Arrow has no kernels for JSON documents.
+func (v *Visitor) VisitJSONContains(field string, doc interface{}) {
+	v.fail(fmt.Errorf("%w: JSON containment in an Arrow filter", specifications.ErrUnsupported))
+}
+
+func (v *Visitor) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	v.fail(fmt.Errorf("%w: JSON path in an Arrow filter", specifications.ErrUnsupported))
+}
+
+func (v *Visitor) VisitJSONKeyExists(field, key string) {
+	v.fail(fmt.Errorf("%w: JSON key in an Arrow filter", specifications.ErrUnsupported))
+}
+
+// VisitArrayContains and VisitArrayOverlaps are not supported: conditions
+// apply to the values of scalar columns, not to lists.
+func (v *Visitor) VisitArrayContains(field string, values []interface{}) {
+	v.fail(fmt.Errorf("%w: array containment in an Arrow filter", specifications.ErrUnsupported))
+}
+
+func (v *Visitor) VisitArrayOverlaps(field string, values []interface{}) {
+	v.fail(fmt.Errorf("%w: array overlap in an Arrow filter", specifications.ErrUnsupported))
+}
+
+// VisitTextSearch and VisitSimilar are not supported: they depend on the
+// text search and trigram functions of a database.
+func (v *Visitor) VisitTextSearch(field, query, language string) {
+	v.fail(fmt.Errorf("%w: full-text search in an Arrow filter", specifications.ErrUnsupported))
+}
+
+func (v *Visitor) VisitSimilar(field, value string, threshold float64) {
+	v.fail(fmt.Errorf("%w: trigram similarity in an Arrow filter", specifications.ErrUnsupported))
+}
+
+// VisitWithinRadius and VisitWithinBox are not supported: Arrow has no
+// geospatial kernels.
+func (v *Visitor) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	v.fail(fmt.Errorf("%w: geospatial condition in an Arrow filter", specifications.ErrUnsupported))
+}
+
+func (v *Visitor) VisitWithinBox(field string, box specifications.BoundingBox) {
+	v.fail(fmt.Errorf("%w: geospatial condition in an Arrow filter", specifications.ErrUnsupported))
+}
+
+// VisitRelated is not supported: files are scanned one at a time. Expand it
+// with specifications.ExpandRelated first.
+func (v *Visitor) VisitRelated(relation string, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: related specification on %q in an Arrow filter", specifications.ErrUnsupported, relation))
+}
@@ -0,0 +1,57 @@
+package specifications
+
+import "time"
+
+// Clock tells the current time to relative-time specifications.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock reading the system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// RelativeTime builds specifications relative to the current time of its
+// Clock. The time is read once, when the specification is built, so every
+// visitor sees the same bounds.
+type RelativeTime struct {
+	Clock Clock
+}
+
+// Relative returns a RelativeTime reading clock, e.g. a fake clock from
+// spectest in tests.
+func Relative(clock Clock) RelativeTime {
+	return RelativeTime{Clock: clock}
+}
+
+// WithinLast matches values of field between d ago and now, inclusive.
+func (r RelativeTime) WithinLast(field string, d time.Duration) Specification {
+	now := r.Clock.Now()
+	return Between(field, now.Add(-d), now)
+}
+
+// InCurrentMonth matches values of field in the current calendar month of
+// the clock's time zone.
+func (r RelativeTime) InCurrentMonth(field string) Specification {
+	now := r.Clock.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return And(
+		GreaterThanOrEqual(field, start),
+		LowerThan(field, start.AddDate(0, 1, 0)),
+	)
+}
+
+// WithinLast is RelativeTime.WithinLast with the SystemClock.
+func WithinLast(field string, d time.Duration) Specification {
+	return Relative(SystemClock).WithinLast(field, d)
+}
+
+// InCurrentMonth is RelativeTime.InCurrentMonth with the SystemClock.
+func InCurrentMonth(field string) Specification {
+	return Relative(SystemClock).InCurrentMonth(field)
+}
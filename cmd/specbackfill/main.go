@@ -0,0 +1,217 @@
+// Command specbackfill replays a corpus of serialized specifications
+// through a SQL visitor and compares the queries and arguments with those
+// rendered by another version of it, so that visitor changes can be
+// validated against real-world specifications before release. Record the
+// output of the released version, then compare the changed one with it:
+//
+//	go run github.com/thefabric-io/specifications/cmd/specbackfill@v1.4.0 -corpus specs.jsonl -record golden.jsonl
+//	go run ./cmd/specbackfill -compare golden.jsonl
+//
+// The corpus holds one specification per line, as {"id": "...", "spec":
+// ...} with the spec rendered by the JSON codec of codec/json, e.g.
+// exported from the filters saved in production. The recorded file holds
+// the specifications too, so that comparing only needs it. Differences are
+// listed on standard output and make specbackfill exit with status 1.
+//
+// Both runs must use the same -dialect, -base and -fieldmap.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thefabric-io/specifications"
+	specjson "github.com/thefabric-io/specifications/codec/json"
+	"github.com/thefabric-io/specifications/mysql"
+	"github.com/thefabric-io/specifications/postgres"
+	"github.com/thefabric-io/specifications/sqlite"
+	"github.com/thefabric-io/specifications/sqlserver"
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.corpus, "corpus", "", "corpus of specifications to record")
+	flag.StringVar(&opts.record, "record", "", "file the rendered queries are recorded to")
+	flag.StringVar(&opts.compare, "compare", "", "recorded file to compare the rendered queries with, instead of -corpus")
+	flag.StringVar(&opts.dialect, "dialect", "postgres", "SQL dialect: postgres, mysql, sqlite or sqlserver")
+	flag.StringVar(&opts.base, "base", "SELECT * FROM t", "base query the specifications are rendered after")
+	flag.StringVar(&opts.fieldMap, "fieldmap", "", "JSON file holding the field map, an object of columns by field")
+	flag.Parse()
+
+	differ, err := run(opts, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "specbackfill:", err)
+		os.Exit(2)
+	}
+	if differ {
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	corpus, record, compare, dialect, base, fieldMap string
+}
+
+// visitors are the visitors of the dialects, by -dialect name.
+var visitors = map[string]func(fieldMap map[string]string) *sqlspec.Visitor{
+	"postgres":  func(fieldMap map[string]string) *sqlspec.Visitor { return postgres.NewVisitor(fieldMap) },
+	"mysql":     func(fieldMap map[string]string) *sqlspec.Visitor { return mysql.NewVisitor(fieldMap) },
+	"sqlite":    func(fieldMap map[string]string) *sqlspec.Visitor { return sqlite.NewVisitor(fieldMap) },
+	"sqlserver": func(fieldMap map[string]string) *sqlspec.Visitor { return sqlserver.NewVisitor(fieldMap) },
+}
+
+// entry is a line of the corpus, or of a recorded file with the output of
+// rendering Spec.
+type entry struct {
+	ID    string          `json:"id"`
+	Spec  json.RawMessage `json:"spec"`
+	Query string          `json:"query,omitempty"`
+	Args  json.RawMessage `json:"args,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// run records or compares as set by opts, and reports whether a rendered
+// query differs from the recorded one.
+func run(opts options, out io.Writer) (bool, error) {
+	if (opts.corpus == "") == (opts.compare == "") {
+		return false, fmt.Errorf("exactly one of -corpus and -compare is required")
+	}
+	if opts.corpus != "" && opts.record == "" {
+		return false, fmt.Errorf("-corpus requires -record")
+	}
+	newVisitor, ok := visitors[opts.dialect]
+	if !ok {
+		return false, fmt.Errorf("unknown dialect %q", opts.dialect)
+	}
+	var fieldMap map[string]string
+	if opts.fieldMap != "" {
+		data, err := os.ReadFile(opts.fieldMap)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(data, &fieldMap); err != nil {
+			return false, fmt.Errorf("%s: %w", opts.fieldMap, err)
+		}
+	}
+	render := func(e entry) entry {
+		return rendered(e, opts.base, newVisitor(fieldMap))
+	}
+
+	if opts.compare != "" {
+		entries, err := read(opts.compare)
+		if err != nil {
+			return false, err
+		}
+		return compare(entries, render, out), nil
+	}
+	entries, err := read(opts.corpus)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Create(opts.record)
+	if err != nil {
+		return false, err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(render(e)); err != nil {
+			f.Close()
+			return false, err
+		}
+	}
+	return false, f.Close()
+}
+
+// read returns the entries of the JSON lines file at path.
+func read(path string) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []entry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 16<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if e.ID == "" {
+			e.ID = fmt.Sprintf("%s:%d", path, line)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// rendered returns e with the query and arguments v renders for its
+// specification after base, or the error preventing it.
+func rendered(e entry, base string, v *sqlspec.Visitor) entry {
+	out := entry{ID: e.ID, Spec: e.Spec}
+	spec, err := specjson.Unmarshal(e.Spec)
+	if err == nil {
+		err = specifications.Apply(spec, v)
+	}
+	var query string
+	var args []interface{}
+	if err == nil {
+		query, args, err = v.Build(base)
+	}
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Query = query
+	if out.Args, err = json.Marshal(args); err != nil {
+		out.Query, out.Args, out.Error = "", nil, err.Error()
+	}
+	return out
+}
+
+// compare renders the specifications of the recorded entries, writes the
+// differences with the recorded output to out, and reports whether there
+// are any.
+func compare(recorded []entry, render func(entry) entry, out io.Writer) bool {
+	differ := 0
+	for _, was := range recorded {
+		now := render(was)
+		if now.Query == was.Query && now.Error == was.Error && sameJSON(now.Args, was.Args) {
+			continue
+		}
+		differ++
+		fmt.Fprintf(out, "%s: %s\n", was.ID, was.Spec)
+		fmt.Fprintf(out, "  was: %s\n", summary(was))
+		fmt.Fprintf(out, "  now: %s\n", summary(now))
+	}
+	fmt.Fprintf(out, "%d of %d specifications differ\n", differ, len(recorded))
+	return differ > 0
+}
+
+func summary(e entry) string {
+	if e.Error != "" {
+		return "error: " + e.Error
+	}
+	return e.Query + " " + string(e.Args)
+}
+
+// sameJSON reports whether a and b are equal once compacted.
+func sameJSON(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -0,0 +1,420 @@
+// Command specgen generates typed fields for the domain fields of a struct
+// or a field map, so that specifications are built without field name
+// strings:
+//
+//	//go:generate go run github.com/thefabric-io/specifications/cmd/specgen -type User -tag db
+//
+// writes user_fields.go next to the file declaring User, with
+//
+//	var UserFields = struct {
+//		Email specifications.TypedField[string]
+//		...
+//	}{...}
+//
+// so that UserFields.Email.Eq("x") is checked by the compiler, both for the
+// field name and the type of the value. The domain fields are the names of
+// the exported fields, including those of embedded structs of the same
+// package; fields of pointer types take the values they point to. With
+// -tag, the struct tags also give UserFieldMap, as FieldMapFromStruct.
+//
+// With -map, the keys of a package-level map[string]string variable
+// declared with a composite literal give the fields instead, typed any.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.typeName, "type", "", "struct type whose fields are generated")
+	flag.StringVar(&opts.mapName, "map", "", "field map variable whose keys are generated, instead of -type")
+	flag.StringVar(&opts.tag, "tag", "", "struct tag the field map is derived from, e.g. db")
+	flag.StringVar(&opts.name, "name", "", "name of the generated variable (default <type>Fields)")
+	flag.StringVar(&opts.output, "output", "", "output file (default <type>_fields.go)")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "specgen:", err)
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	typeName, mapName, tag, name, output string
+}
+
+// field is a generated field: its domain name, the Go type of its values
+// and its column, empty without tag.
+type field struct {
+	name, typ, column string
+}
+
+func run(opts options) error {
+	source := opts.typeName
+	if (source == "") == (opts.mapName == "") {
+		return fmt.Errorf("exactly one of -type and -map is required")
+	}
+	if source == "" {
+		source = opts.mapName
+	}
+	if opts.name == "" {
+		opts.name = identifier(source) + "Fields"
+	}
+	if opts.output == "" {
+		opts.output = snake(source) + "_fields.go"
+	}
+
+	pkg, err := parsePackage(".", opts.output)
+	if err != nil {
+		return err
+	}
+	var fields []field
+	if opts.typeName != "" {
+		fields, err = pkg.structFields(opts.typeName, opts.tag)
+	} else {
+		fields, err = pkg.mapFields(opts.mapName)
+	}
+	if err != nil {
+		return err
+	}
+	src, err := render(pkg, opts, fields)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(opts.output, src, 0o644)
+}
+
+// pkg holds the parsed files of the package in a directory.
+type pkg struct {
+	fset  *token.FileSet
+	name  string
+	files []*ast.File
+	// imports holds the import paths used by the generated types, by
+	// package name.
+	imports map[string]string
+}
+
+func parsePackage(dir, output string) (*pkg, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	p := &pkg{fset: token.NewFileSet(), imports: map[string]string{}}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || filepath.Base(path) == filepath.Base(output) {
+			continue
+		}
+		f, err := parser.ParseFile(p.fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.name = f.Name.Name
+		p.files = append(p.files, f)
+	}
+	if len(p.files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return p, nil
+}
+
+// lookup returns the declaration of the type or variable name, with the
+// file declaring it.
+func (p *pkg) lookup(name string) (ast.Spec, *ast.File) {
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.Name == name {
+						return s, f
+					}
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.Name == name {
+							return s, f
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (p *pkg) structFields(name, tag string) ([]field, error) {
+	spec, file := p.lookup(name)
+	ts, ok := spec.(*ast.TypeSpec)
+	if !ok {
+		return nil, fmt.Errorf("no type %s in package %s", name, p.name)
+	}
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct type", name)
+	}
+	var fields []field
+	seen := map[string]bool{}
+	if err := p.addFields(&fields, seen, st, file, tag); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// addFields appends the exported fields of st, declared in file, skipping
+// names already seen, like promoted fields are shadowed.
+func (p *pkg) addFields(fields *[]field, seen map[string]bool, st *ast.StructType, file *ast.File, tag string) error {
+	var embedded []*ast.StructType
+	var embeddedFiles []*ast.File
+	for _, f := range st.Fields.List {
+		column := ""
+		if tag != "" && f.Tag != nil {
+			raw, _ := strconv.Unquote(f.Tag.Value)
+			value, ok := reflect.StructTag(raw).Lookup(tag)
+			if ok && value == "-" {
+				continue
+			}
+			column, _, _ = strings.Cut(value, ",")
+		}
+		typ := f.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if len(f.Names) == 0 {
+			// Untagged embedded structs of the package are flattened.
+			if ident, ok := typ.(*ast.Ident); ok && column == "" {
+				if spec, file := p.lookup(ident.Name); spec != nil {
+					if s, ok := spec.(*ast.TypeSpec).Type.(*ast.StructType); ok {
+						embedded = append(embedded, s)
+						embeddedFiles = append(embeddedFiles, file)
+						continue
+					}
+				}
+			}
+			name := typeName(typ)
+			if name == "" || !ast.IsExported(name) || seen[name] {
+				continue
+			}
+			seen[name] = true
+			text, err := p.typeString(typ, file)
+			if err != nil {
+				return err
+			}
+			*fields = append(*fields, field{name: name, typ: text, column: column})
+			continue
+		}
+		for _, n := range f.Names {
+			if !n.IsExported() || seen[n.Name] {
+				continue
+			}
+			seen[n.Name] = true
+			text, err := p.typeString(typ, file)
+			if err != nil {
+				return err
+			}
+			*fields = append(*fields, field{name: n.Name, typ: text, column: column})
+		}
+	}
+	for i, s := range embedded {
+		if err := p.addFields(fields, seen, s, embeddedFiles[i], tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeName returns the name of an embedded field of type expr.
+func typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return ""
+}
+
+// typeString returns the source of expr, recording the imports of file it
+// uses.
+func (p *pkg) typeString(expr ast.Expr, file *ast.File) (string, error) {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok {
+			path, found := importPath(file, x.Name)
+			if !found {
+				err = fmt.Errorf("no import of package %s for %s.%s", x.Name, x.Name, sel.Sel.Name)
+			}
+			p.imports[x.Name] = path
+		}
+		return false
+	})
+	var b bytes.Buffer
+	if perr := printer.Fprint(&b, p.fset, expr); perr != nil {
+		return "", perr
+	}
+	return b.String(), err
+}
+
+// importPath returns the path of the import of file named name.
+func importPath(file *ast.File, name string) (string, bool) {
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return path, true
+			}
+			continue
+		}
+		if last := path[strings.LastIndexByte(path, '/')+1:]; last == name {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (p *pkg) mapFields(name string) ([]field, error) {
+	spec, _ := p.lookup(name)
+	vs, ok := spec.(*ast.ValueSpec)
+	if !ok {
+		return nil, fmt.Errorf("no variable %s in package %s", name, p.name)
+	}
+	i := slices.IndexFunc(vs.Names, func(n *ast.Ident) bool { return n.Name == name })
+	if i >= len(vs.Values) {
+		return nil, fmt.Errorf("%s is not declared with a composite literal", name)
+	}
+	lit, ok := vs.Values[i].(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("%s is not declared with a composite literal", name)
+	}
+	var fields []field
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.BasicLit)
+		if !ok || key.Kind != token.STRING {
+			return nil, fmt.Errorf("%s has a key that is not a string literal", name)
+		}
+		domain, _ := strconv.Unquote(key.Value)
+		fields = append(fields, field{name: domain, typ: "any"})
+	}
+	slices.SortFunc(fields, func(a, b field) int { return strings.Compare(a.name, b.name) })
+	return fields, nil
+}
+
+const specificationsPath = "github.com/thefabric-io/specifications"
+
+func render(p *pkg, opts options, fields []field) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by specgen %s. DO NOT EDIT.\n\n", strings.Join(os.Args[1:], " "))
+	fmt.Fprintf(&b, "package %s\n\n", p.name)
+	b.WriteString("import (\n")
+	names := make([]string, 0, len(p.imports))
+	for name := range p.imports {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if p.imports[name] != specificationsPath {
+			fmt.Fprintf(&b, "\t%s %q\n", name, p.imports[name])
+		}
+	}
+	fmt.Fprintf(&b, "\t%q\n)\n\n", specificationsPath)
+
+	source := opts.typeName
+	if source == "" {
+		source = opts.mapName
+	}
+	fmt.Fprintf(&b, "// %s are the typed fields of %s.\n", opts.name, source)
+	fmt.Fprintf(&b, "var %s = struct {\n", opts.name)
+	declared := map[string]string{}
+	for _, f := range fields {
+		id := identifier(f.name)
+		if other, ok := declared[id]; ok {
+			return nil, fmt.Errorf("fields %q and %q are both generated as %s", other, f.name, id)
+		}
+		declared[id] = f.name
+		fmt.Fprintf(&b, "\t%s specifications.TypedField[%s]\n", identifier(f.name), f.typ)
+	}
+	b.WriteString("}{\n")
+	for _, f := range fields {
+		fmt.Fprintf(&b, "\t%s: specifications.Field[%s](%q),\n", identifier(f.name), f.typ, f.name)
+	}
+	b.WriteString("}\n")
+
+	if opts.tag != "" {
+		mapName := strings.TrimSuffix(opts.name, "Fields") + "FieldMap"
+		fmt.Fprintf(&b, "\n// %s maps the fields of %s to their %s tags.\n", mapName, source, opts.tag)
+		fmt.Fprintf(&b, "var %s = specifications.FieldMap{\n", mapName)
+		for _, f := range fields {
+			if f.column != "" {
+				fmt.Fprintf(&b, "\t%q: %q,\n", f.name, f.column)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// identifier returns the exported Go identifier for the domain field name,
+// e.g. CreatedAt for created_at.
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "F" + s
+	}
+	return s
+}
+
+// snake returns name in snake case, e.g. order_item for OrderItem and
+// http_log for HTTPLog.
+func snake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
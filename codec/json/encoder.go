@@ -0,0 +1,208 @@
+package json
+
+import (
+	"github.com/thefabric-io/specifications"
+)
+
+// encoder is a SpecificationVisitor collecting one Node per visited
+// specification.
+type encoder struct {
+	nodes []Node
+	err   error
+}
+
+func (e *encoder) Err() error {
+	return e.err
+}
+
+func (e *encoder) add(n Node) {
+	e.nodes = append(e.nodes, n)
+}
+
+func (e *encoder) children(specs []specifications.Specification) []Node {
+	sub := &encoder{}
+	for _, s := range specs {
+		s.Accept(sub)
+	}
+	if sub.err != nil && e.err == nil {
+		e.err = sub.err
+	}
+	return sub.nodes
+}
+
+func (e *encoder) VisitEqual(field string, value interface{}) {
+	e.add(Node{Op: OpEqual, Field: field, Value: value})
+}
+
+func (e *encoder) VisitNotEqual(field string, value interface{}) {
+	e.add(Node{Op: OpNotEqual, Field: field, Value: value})
+}
+
+func (e *encoder) VisitIn(field string, values []interface{}) {
+	e.add(Node{Op: OpIn, Field: field, Values: values})
+}
+
+func (e *encoder) VisitNotIn(field string, values []interface{}) {
+	e.add(Node{Op: OpNotIn, Field: field, Values: values})
+}
+
+func (e *encoder) VisitAnd(specs []specifications.Specification) {
+	e.add(Node{Op: OpAnd, Specs: e.children(specs)})
+}
+
+func (e *encoder) VisitOr(specs []specifications.Specification) {
+	e.add(Node{Op: OpOr, Specs: e.children(specs)})
+}
+
+// VisitDescribed records description on the node of spec.
+func (e *encoder) VisitDescribed(description string, spec specifications.Specification) {
+	children := e.children([]specifications.Specification{spec})
+	if len(children) == 1 {
+		children[0].Description = description
+		e.add(children[0])
+	}
+}
+
+func (e *encoder) VisitNot(spec specifications.Specification) {
+	e.add(Node{Op: OpNot, Specs: e.children([]specifications.Specification{spec})})
+}
+
+func (e *encoder) VisitGroupBy(fields []string) {
+	e.add(Node{Op: OpGroupBy, Fields: fields})
+}
+
+func (e *encoder) VisitHaving(spec specifications.Specification) {
+	e.add(Node{Op: OpHaving, Specs: e.children([]specifications.Specification{spec})})
+}
+
+func (e *encoder) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	e.add(Node{Op: OpAggregate, Function: string(function), Specs: e.children([]specifications.Specification{spec})})
+}
+
+func (e *encoder) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	e.add(Node{Op: OpLock, Strength: string(strength), Wait: string(wait)})
+}
+
+func (e *encoder) VisitArrayContains(field string, values []interface{}) {
+	e.add(Node{Op: OpArrayContains, Field: field, Values: values})
+}
+
+func (e *encoder) VisitArrayOverlaps(field string, values []interface{}) {
+	e.add(Node{Op: OpArrayOverlaps, Field: field, Values: values})
+}
+
+func (e *encoder) VisitEqualAny(field string, values []interface{}) {
+	e.add(Node{Op: OpEqualAny, Field: field, Values: values})
+}
+
+func (e *encoder) VisitJSONContains(field string, doc interface{}) {
+	e.add(Node{Op: OpJSONContains, Field: field, Value: doc})
+}
+
+func (e *encoder) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	e.add(Node{Op: OpJSONPathEqual, Field: field, Path: path, Value: value})
+}
+
+func (e *encoder) VisitJSONKeyExists(field, key string) {
+	e.add(Node{Op: OpJSONKeyExists, Field: field, Key: key})
+}
+
+func (e *encoder) VisitTextSearch(field, query, language string) {
+	e.add(Node{Op: OpTextSearch, Field: field, Query: query, Language: language})
+}
+
+func (e *encoder) VisitTextRank(field, query, language string) {
+	e.add(Node{Op: OpTextRank, Field: field, Query: query, Language: language})
+}
+
+func (e *encoder) VisitSimilar(field, value string, threshold float64) {
+	e.add(Node{Op: OpSimilar, Field: field, Query: value, Threshold: threshold})
+}
+
+func (e *encoder) VisitSimilarityOrder(field, value string) {
+	e.add(Node{Op: OpSimilarityOrder, Field: field, Query: value})
+}
+
+func (e *encoder) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	e.add(Node{Op: OpWithinRadius, Field: field, Point: &center, Meters: meters})
+}
+
+func (e *encoder) VisitWithinBox(field string, box specifications.BoundingBox) {
+	e.add(Node{Op: OpWithinBox, Field: field, Box: &box})
+}
+
+func (e *encoder) VisitOrderByDistance(field string, from specifications.Point) {
+	e.add(Node{Op: OpDistanceOrder, Field: field, Point: &from})
+}
+
+func (e *encoder) VisitRelated(relation string, spec specifications.Specification) {
+	e.add(Node{Op: OpRelated, Relation: relation, Specs: e.children([]specifications.Specification{spec})})
+}
+
+func (e *encoder) VisitLimit(limit int) {
+	e.add(Node{Op: OpLimit, Limit: limit})
+}
+
+func (e *encoder) VisitOffset(offset int) {
+	e.add(Node{Op: OpOffset, Offset: offset})
+}
+
+func (e *encoder) VisitOrder(field, direction string) {
+	e.add(Node{Op: OpOrder, Field: field, Direction: direction})
+}
+
+func (e *encoder) VisitDistinct(fields []string) {
+	e.add(Node{Op: OpDistinct, Fields: fields})
+}
+
+func (e *encoder) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	e.add(Node{Op: OpOrder, Field: field, Direction: direction, Nulls: string(nulls)})
+}
+
+func (e *encoder) VisitGreaterThan(field string, value interface{}) {
+	e.add(Node{Op: OpGreaterThan, Field: field, Value: value})
+}
+
+func (e *encoder) VisitLowerThan(field string, value interface{}) {
+	e.add(Node{Op: OpLowerThan, Field: field, Value: value})
+}
+
+func (e *encoder) VisitGreaterThanOrEqual(field string, value interface{}) {
+	e.add(Node{Op: OpGreaterThanOrEqual, Field: field, Value: value})
+}
+
+func (e *encoder) VisitLowerThanOrEqual(field string, value interface{}) {
+	e.add(Node{Op: OpLowerThanOrEqual, Field: field, Value: value})
+}
+
+func (e *encoder) VisitBetween(field string, low, high interface{}) {
+	e.add(Node{Op: OpBetween, Field: field, Low: low, High: high})
+}
+
+func (e *encoder) VisitIsNull(field string) {
+	e.add(Node{Op: OpIsNull, Field: field})
+}
+
+func (e *encoder) VisitIsNotNull(field string) {
+	e.add(Node{Op: OpIsNotNull, Field: field})
+}
+
+func (e *encoder) VisitLike(field string, value interface{}) {
+	e.add(Node{Op: OpLike, Field: field, Value: value})
+}
+
+func (e *encoder) VisitILike(field string, value interface{}) {
+	e.add(Node{Op: OpILike, Field: field, Value: value})
+}
+
+func (e *encoder) VisitLikeEscaped(field string, pattern string) {
+	e.add(Node{Op: OpLikeEscaped, Field: field, Pattern: pattern})
+}
+
+func (e *encoder) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	e.add(Node{Op: OpRegex, Field: field, Pattern: pattern, CaseInsensitive: caseInsensitive})
+}
+
+func (e *encoder) VisitSample(percent float64, method specifications.SampleMethod) {
+	e.add(Node{Op: OpSample, Percent: percent, Method: string(method)})
+}
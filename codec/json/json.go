@@ -0,0 +1,315 @@
+// Package json serializes specifications to and from a stable JSON
+// structure so filters can be exchanged between services.
+//
+// Every specification becomes a Node tagged with its operator:
+//
+//	{"op":"and","specs":[{"op":"eq","field":"Status","value":"active"},{"op":"limit","limit":10}]}
+//
+// Values round-trip as JSON values: integral numbers decode as int64, other
+// numbers as float64, and times as RFC 3339 strings.
+package json
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Operators used in Node.Op.
+const (
+	OpEqual              = "eq"
+	OpNotEqual           = "ne"
+	OpGreaterThan        = "gt"
+	OpGreaterThanOrEqual = "gte"
+	OpLowerThan          = "lt"
+	OpLowerThanOrEqual   = "lte"
+	OpBetween            = "between"
+	OpIn                 = "in"
+	OpNotIn              = "not_in"
+	OpIsNull             = "is_null"
+	OpIsNotNull          = "is_not_null"
+	OpLike               = "like"
+	OpILike              = "ilike"
+	OpLikeEscaped        = "like_escaped"
+	OpRegex              = "regex"
+	OpAnd                = "and"
+	OpOr                 = "or"
+	OpNot                = "not"
+	OpOrder              = "order"
+	OpLimit              = "limit"
+	OpOffset             = "offset"
+	OpSample             = "sample"
+	OpDistinct           = "distinct"
+	OpGroupBy            = "group_by"
+	OpHaving             = "having"
+	OpAggregate          = "aggregate"
+	OpLock               = "lock"
+	OpJSONContains       = "json_contains"
+	OpJSONPathEqual      = "json_path_eq"
+	OpJSONKeyExists      = "json_key_exists"
+	OpArrayContains      = "array_contains"
+	OpArrayOverlaps      = "array_overlaps"
+	OpEqualAny           = "eq_any"
+	OpTextSearch         = "text_search"
+	OpTextRank           = "text_rank"
+	OpSimilar            = "similar"
+	OpSimilarityOrder    = "similarity_order"
+	OpWithinRadius       = "within_radius"
+	OpWithinBox          = "within_box"
+	OpDistanceOrder      = "distance_order"
+	OpRelated            = "related"
+)
+
+// ErrInvalidNode is returned when a node cannot be decoded into a
+// specification.
+var ErrInvalidNode = errors.New("json: invalid specification node")
+
+// Node is the serialized form of a specification. Only the members relevant
+// to Op are set.
+type Node struct {
+	Op              string        `json:"op"`
+	Field           string        `json:"field,omitempty"`
+	Fields          []string      `json:"fields,omitempty"`
+	Value           interface{}   `json:"value,omitempty"`
+	Values          []interface{} `json:"values,omitempty"`
+	Low             interface{}   `json:"low,omitempty"`
+	High            interface{}   `json:"high,omitempty"`
+	Pattern         string        `json:"pattern,omitempty"`
+	CaseInsensitive bool          `json:"case_insensitive,omitempty"`
+	Direction       string        `json:"direction,omitempty"`
+	Nulls           string        `json:"nulls,omitempty"`
+	Limit           int           `json:"limit,omitempty"`
+	Offset          int           `json:"offset,omitempty"`
+	Percent         float64       `json:"percent,omitempty"`
+	Method          string        `json:"method,omitempty"`
+	Function        string        `json:"function,omitempty"`
+	Strength        string        `json:"strength,omitempty"`
+	Wait            string        `json:"wait,omitempty"`
+	Path            []string      `json:"path,omitempty"`
+	Key             string        `json:"key,omitempty"`
+	Query           string        `json:"query,omitempty"`
+	Language        string        `json:"language,omitempty"`
+	Threshold       float64       `json:"threshold,omitempty"`
+	Relation        string        `json:"relation,omitempty"`
+	Specs           []Node        `json:"specs,omitempty"`
+	// Point, Meters and Box are the members of geospatial nodes.
+	Point  *specifications.Point       `json:"point,omitempty"`
+	Meters float64                     `json:"meters,omitempty"`
+	Box    *specifications.BoundingBox `json:"box,omitempty"`
+	// Description is the text attached with specifications.WithDescription.
+	Description string `json:"description,omitempty"`
+	// Version is the wire format version, only set on a root node by
+	// producers pinning it. Zero means 1.
+	Version int `json:"version,omitempty"`
+}
+
+// Marshal renders spec as JSON.
+func Marshal(spec specifications.Specification) ([]byte, error) {
+	node, err := Encode(spec)
+	if err != nil {
+		return nil, err
+	}
+	return stdjson.Marshal(node)
+}
+
+// Unmarshal reconstructs a specification rendered by Marshal. It is
+// lenient: unknown members, e.g. added by a newer version of the format,
+// are ignored. UnmarshalStrict rejects them.
+func Unmarshal(data []byte) (specifications.Specification, error) {
+	var node Node
+	dec := stdjson.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&node); err != nil {
+		return nil, err
+	}
+	return Decode(node)
+}
+
+// Encode converts spec into its Node form.
+func Encode(spec specifications.Specification) (Node, error) {
+	if spec == nil {
+		return Node{}, fmt.Errorf("%w: nil specification", ErrInvalidNode)
+	}
+	e := &encoder{}
+	if err := specifications.Apply(spec, e); err != nil {
+		return Node{}, err
+	}
+	if len(e.nodes) != 1 {
+		return Node{}, fmt.Errorf("%w: specification produced %d nodes", ErrInvalidNode, len(e.nodes))
+	}
+	return e.nodes[0], nil
+}
+
+// Decode converts a Node back into a specification.
+func Decode(n Node) (specifications.Specification, error) {
+	spec, err := decode(n)
+	if err != nil || n.Description == "" {
+		return spec, err
+	}
+	return specifications.WithDescription(spec, n.Description), nil
+}
+
+func decode(n Node) (specifications.Specification, error) {
+	switch n.Op {
+	case OpEqual:
+		return specifications.Equal(n.Field, value(n.Value)), nil
+	case OpNotEqual:
+		return specifications.NotEqual(n.Field, value(n.Value)), nil
+	case OpGreaterThan:
+		return specifications.GreaterThan(n.Field, value(n.Value)), nil
+	case OpGreaterThanOrEqual:
+		return specifications.GreaterThanOrEqual(n.Field, value(n.Value)), nil
+	case OpLowerThan:
+		return specifications.LowerThan(n.Field, value(n.Value)), nil
+	case OpLowerThanOrEqual:
+		return specifications.LowerThanOrEqual(n.Field, value(n.Value)), nil
+	case OpBetween:
+		return specifications.Between(n.Field, value(n.Low), value(n.High)), nil
+	case OpIn:
+		return specifications.In(n.Field, values(n.Values)...), nil
+	case OpNotIn:
+		return specifications.NotIn(n.Field, values(n.Values)...), nil
+	case OpArrayContains:
+		return specifications.ArrayContains(n.Field, values(n.Values)...), nil
+	case OpArrayOverlaps:
+		return specifications.ArrayOverlaps(n.Field, values(n.Values)...), nil
+	case OpEqualAny:
+		return specifications.EqualAny(n.Field, values(n.Values)...), nil
+	case OpIsNull:
+		return specifications.IsNull(n.Field), nil
+	case OpIsNotNull:
+		return specifications.IsNotNull(n.Field), nil
+	case OpLike:
+		return specifications.Like(n.Field, value(n.Value)), nil
+	case OpILike:
+		return specifications.ILike(n.Field, value(n.Value)), nil
+	case OpLikeEscaped:
+		return specifications.LikeEscaped(n.Field, n.Pattern), nil
+	case OpRegex:
+		if n.CaseInsensitive {
+			return specifications.IMatches(n.Field, n.Pattern), nil
+		}
+		return specifications.Matches(n.Field, n.Pattern), nil
+	case OpAnd, OpOr:
+		specs := make([]specifications.Specification, len(n.Specs))
+		for i, child := range n.Specs {
+			s, err := Decode(child)
+			if err != nil {
+				return nil, err
+			}
+			specs[i] = s
+		}
+		if n.Op == OpAnd {
+			return specifications.And(specs...), nil
+		}
+		return specifications.Or(specs...), nil
+	case OpNot, OpHaving, OpAggregate, OpRelated:
+		if len(n.Specs) != 1 {
+			return nil, fmt.Errorf("%w: %s takes one specification, got %d", ErrInvalidNode, n.Op, len(n.Specs))
+		}
+		spec, err := Decode(n.Specs[0])
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case OpHaving:
+			return specifications.Having(spec), nil
+		case OpRelated:
+			if n.Relation == "" {
+				return nil, fmt.Errorf("%w: related without relation", ErrInvalidNode)
+			}
+			return specifications.Related(n.Relation, spec), nil
+		case OpAggregate:
+			f, err := specifications.ParseAggregateFunction(n.Function)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidNode, err)
+			}
+			return specifications.Aggregated(f, spec), nil
+		}
+		return specifications.Not(spec), nil
+	case OpOrder:
+		nulls, err := specifications.ParseNullsPosition(n.Nulls)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidNode, err)
+		}
+		return specifications.OrderByNulls(n.Field, n.Direction, nulls), nil
+	case OpDistinct:
+		return specifications.DistinctOn(n.Fields...), nil
+	case OpGroupBy:
+		return specifications.GroupBy(n.Fields...), nil
+	case OpLock:
+		strength, wait, err := specifications.ParseLock(n.Strength, n.Wait)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidNode, err)
+		}
+		return specifications.Lock(strength, wait), nil
+	case OpJSONContains:
+		return specifications.JSONContains(n.Field, value(n.Value)), nil
+	case OpJSONPathEqual:
+		return specifications.JSONPathEqual(n.Field, n.Path, value(n.Value)), nil
+	case OpJSONKeyExists:
+		return specifications.JSONKeyExists(n.Field, n.Key), nil
+	case OpTextSearch:
+		return specifications.TextSearch(n.Field, n.Query, specifications.Language(n.Language)), nil
+	case OpTextRank:
+		return specifications.OrderByRank(n.Field, n.Query, specifications.Language(n.Language)), nil
+	case OpSimilar:
+		return specifications.SimilarTo(n.Field, n.Query, n.Threshold), nil
+	case OpSimilarityOrder:
+		return specifications.OrderBySimilarity(n.Field, n.Query), nil
+	case OpWithinRadius, OpDistanceOrder:
+		if n.Point == nil {
+			return nil, fmt.Errorf("%w: %s without point", ErrInvalidNode, n.Op)
+		}
+		if n.Op == OpDistanceOrder {
+			return specifications.OrderByDistance(n.Field, n.Point.Lat, n.Point.Lng), nil
+		}
+		return specifications.WithinRadius(n.Field, n.Point.Lat, n.Point.Lng, n.Meters), nil
+	case OpWithinBox:
+		if n.Box == nil {
+			return nil, fmt.Errorf("%w: %s without box", ErrInvalidNode, n.Op)
+		}
+		return specifications.WithinBoundingBox(n.Field, *n.Box), nil
+	case OpLimit:
+		return specifications.Limit(n.Limit), nil
+	case OpOffset:
+		return specifications.Offset(n.Offset), nil
+	case OpSample:
+		return specifications.Sample(n.Percent, specifications.SampleMethod(n.Method)), nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidNode, n.Op)
+}
+
+// value converts decoded JSON numbers into int64 or float64.
+func value(v interface{}) interface{} {
+	switch x := v.(type) {
+	case stdjson.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case []interface{}:
+		return values(x)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			out[k] = value(e)
+		}
+		return out
+	}
+	return v
+}
+
+func values(vs []interface{}) []interface{} {
+	out := make([]interface{}, len(vs))
+	for i, v := range vs {
+		out[i] = value(v)
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package json
+
+import (
+	"bytes"
+	_ "embed"
+	stdjson "encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// FormatVersion is the version of the wire format described by JSONSchema.
+// A root node may carry it in its version member; absent means 1.
+const FormatVersion = 1
+
+//go:embed schema.json
+var schema []byte
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of the wire format,
+// for producers in other languages. It describes what UnmarshalStrict
+// accepts.
+func JSONSchema() []byte {
+	return slices.Clone(schema)
+}
+
+// UnmarshalStrict is like Unmarshal but rejects input that Unmarshal
+// tolerates for forward compatibility: unknown members, members that do
+// not apply to the node's operator, missing required members and newer
+// format versions. Use it where filters are built by hand or by other
+// languages, so mistakes fail rather than being silently ignored.
+//
+// Both modes reject unknown operators: ignoring a condition would widen
+// the filter.
+func UnmarshalStrict(data []byte) (specifications.Specification, error) {
+	var node Node
+	dec := stdjson.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&node); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidNode, err)
+	}
+	return DecodeStrict(node)
+}
+
+// DecodeStrict is like Decode but checks n like UnmarshalStrict.
+func DecodeStrict(n Node) (specifications.Specification, error) {
+	if n.Version > FormatVersion {
+		return nil, fmt.Errorf("%w: format version %d is newer than %d", ErrInvalidNode, n.Version, FormatVersion)
+	}
+	if err := checkStrict(n, "", true); err != nil {
+		return nil, err
+	}
+	return Decode(n)
+}
+
+// members lists, per operator, the members a node may set besides op and
+// description, the required ones first.
+var members = map[string]struct {
+	required []string
+	optional []string
+}{
+	OpEqual:              {[]string{"field"}, []string{"value"}},
+	OpNotEqual:           {[]string{"field"}, []string{"value"}},
+	OpGreaterThan:        {[]string{"field"}, []string{"value"}},
+	OpGreaterThanOrEqual: {[]string{"field"}, []string{"value"}},
+	OpLowerThan:          {[]string{"field"}, []string{"value"}},
+	OpLowerThanOrEqual:   {[]string{"field"}, []string{"value"}},
+	OpLike:               {[]string{"field"}, []string{"value"}},
+	OpILike:              {[]string{"field"}, []string{"value"}},
+	OpBetween:            {[]string{"field"}, []string{"low", "high"}},
+	OpIn:                 {[]string{"field"}, []string{"values"}},
+	OpNotIn:              {[]string{"field"}, []string{"values"}},
+	OpArrayContains:      {[]string{"field"}, []string{"values"}},
+	OpArrayOverlaps:      {[]string{"field"}, []string{"values"}},
+	OpEqualAny:           {[]string{"field"}, []string{"values"}},
+	OpIsNull:             {[]string{"field"}, nil},
+	OpIsNotNull:          {[]string{"field"}, nil},
+	OpLikeEscaped:        {[]string{"field"}, []string{"pattern"}},
+	OpRegex:              {[]string{"field"}, []string{"pattern", "case_insensitive"}},
+	OpAnd:                {nil, []string{"specs"}},
+	OpOr:                 {nil, []string{"specs"}},
+	OpNot:                {[]string{"specs"}, nil},
+	OpHaving:             {[]string{"specs"}, nil},
+	OpAggregate:          {[]string{"function", "specs"}, nil},
+	OpOrder:              {[]string{"field"}, []string{"direction", "nulls"}},
+	OpLimit:              {nil, []string{"limit"}},
+	OpOffset:             {nil, []string{"offset"}},
+	OpSample:             {nil, []string{"percent", "method"}},
+	OpDistinct:           {nil, []string{"fields"}},
+	OpGroupBy:            {[]string{"fields"}, nil},
+	OpLock:               {[]string{"strength"}, []string{"wait"}},
+	OpJSONContains:       {[]string{"field"}, []string{"value"}},
+	OpJSONPathEqual:      {[]string{"field", "path"}, []string{"value"}},
+	OpJSONKeyExists:      {[]string{"field", "key"}, nil},
+	OpTextSearch:         {[]string{"field", "query"}, []string{"language"}},
+	OpTextRank:           {[]string{"field", "query"}, []string{"language"}},
+	OpSimilar:            {[]string{"field", "query"}, []string{"threshold"}},
+	OpSimilarityOrder:    {[]string{"field", "query"}, nil},
+	OpWithinRadius:       {[]string{"field", "point"}, []string{"meters"}},
+	OpWithinBox:          {[]string{"field", "box"}, nil},
+	OpDistanceOrder:      {[]string{"field", "point"}, nil},
+	OpRelated:            {[]string{"relation", "specs"}, nil},
+}
+
+func checkStrict(n Node, path string, root bool) error {
+	allowed, ok := members[n.Op]
+	if !ok {
+		return fmt.Errorf("%w: unknown operator %q at /%s", ErrInvalidNode, n.Op, path)
+	}
+	set := n.set()
+	if !root && n.Version != 0 {
+		return fmt.Errorf("%w: version below the root at /%s", ErrInvalidNode, path)
+	}
+	for _, m := range allowed.required {
+		if !slices.Contains(set, m) {
+			return fmt.Errorf("%w: %s requires %q at /%s", ErrInvalidNode, n.Op, m, path)
+		}
+	}
+	for _, m := range set {
+		if !slices.Contains(allowed.required, m) && !slices.Contains(allowed.optional, m) {
+			return fmt.Errorf("%w: %q does not apply to %s at /%s", ErrInvalidNode, m, n.Op, path)
+		}
+	}
+	for i, child := range n.Specs {
+		childPath := "specs/" + strconv.Itoa(i)
+		if path != "" {
+			childPath = path + "/" + childPath
+		}
+		if err := checkStrict(child, childPath, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set returns the names of the members set in n, except op, version and
+// description. Members holding their zero value cannot be told apart from
+// absent ones and are not listed.
+func (n Node) set() []string {
+	var set []string
+	add := func(name string, ok bool) {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	add("field", n.Field != "")
+	add("fields", n.Fields != nil)
+	add("value", n.Value != nil)
+	add("values", n.Values != nil)
+	add("low", n.Low != nil)
+	add("high", n.High != nil)
+	add("pattern", n.Pattern != "")
+	add("case_insensitive", n.CaseInsensitive)
+	add("direction", n.Direction != "")
+	add("nulls", n.Nulls != "")
+	add("limit", n.Limit != 0)
+	add("offset", n.Offset != 0)
+	add("percent", n.Percent != 0)
+	add("method", n.Method != "")
+	add("function", n.Function != "")
+	add("strength", n.Strength != "")
+	add("wait", n.Wait != "")
+	add("path", n.Path != nil)
+	add("key", n.Key != "")
+	add("query", n.Query != "")
+	add("language", n.Language != "")
+	add("threshold", n.Threshold != 0)
+	add("relation", n.Relation != "")
+	add("point", n.Point != nil)
+	add("meters", n.Meters != 0)
+	add("box", n.Box != nil)
+	add("specs", n.Specs != nil)
+	return set
+}
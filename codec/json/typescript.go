@@ -0,0 +1,176 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// tsOps lists the field operators TypeScript generates builders for, in
+// output order, with the builder's name and parameters, where T stands for
+// the TypeScript type of the field's values, and the node members it sets.
+// timed sets them from Date values for TypeTime fields.
+var tsOps = []struct {
+	op, name, params, members, timed string
+}{
+	{OpEqual, "eq", "value: T", "value", "value: time(value)"},
+	{OpNotEqual, "ne", "value: T", "value", "value: time(value)"},
+	{OpGreaterThan, "gt", "value: T", "value", "value: time(value)"},
+	{OpGreaterThanOrEqual, "gte", "value: T", "value", "value: time(value)"},
+	{OpLowerThan, "lt", "value: T", "value", "value: time(value)"},
+	{OpLowerThanOrEqual, "lte", "value: T", "value", "value: time(value)"},
+	{OpBetween, "between", "low: T, high: T", "low, high", "low: time(low), high: time(high)"},
+	{OpIn, "in", "...values: T[]", "values", "values: values.map(time)"},
+	{OpNotIn, "notIn", "...values: T[]", "values", "values: values.map(time)"},
+	{OpEqualAny, "eqAny", "...values: T[]", "values", "values: values.map(time)"},
+	{OpArrayContains, "arrayContains", "...values: T[]", "values", "values: values.map(time)"},
+	{OpArrayOverlaps, "arrayOverlaps", "...values: T[]", "values", "values: values.map(time)"},
+	{OpIsNull, "isNull", "", "", ""},
+	{OpIsNotNull, "isNotNull", "", "", ""},
+	{OpLike, "like", "pattern: string", "value: pattern", ""},
+	{OpILike, "ilike", "pattern: string", "value: pattern", ""},
+	{OpLikeEscaped, "likeEscaped", "pattern: string", "pattern", ""},
+	{OpRegex, "regex", "pattern: string, caseInsensitive = false", "pattern, case_insensitive: caseInsensitive", ""},
+	{OpJSONContains, "jsonContains", "doc: unknown", "value: doc", ""},
+	{OpJSONPathEqual, "jsonPathEqual", "path: string[], value: unknown", "path, value", ""},
+	{OpJSONKeyExists, "jsonKeyExists", "key: string", "key", ""},
+	{OpTextSearch, "textSearch", "query: string, language?: string", "query, language", ""},
+	{OpTextRank, "orderByRank", "query: string, language?: string", "query, language", ""},
+	{OpSimilar, "similarTo", "value: string, threshold?: number", "query: value, threshold", ""},
+	{OpSimilarityOrder, "orderBySimilarity", "value: string", "query: value", ""},
+	{OpWithinRadius, "withinRadius", "lat: number, lng: number, meters: number", "point: { lat, lng }, meters", ""},
+	{OpWithinBox, "withinBox", "box: BoundingBox", "box", ""},
+	{OpDistanceOrder, "orderByDistance", "lat: number, lng: number", "point: { lat, lng }", ""},
+}
+
+// tsTypes maps value types to TypeScript types.
+var tsTypes = map[ValueType]string{
+	TypeString:  "string",
+	TypeNumber:  "number",
+	TypeBoolean: "boolean",
+	TypeTime:    "Date | string",
+}
+
+const tsPrelude = `// Code generated by github.com/thefabric-io/specifications/codec/json. DO NOT EDIT.
+
+/** A serialized specification, as accepted by Unmarshal. */
+export interface Node {
+  op: string;
+  field?: string;
+  fields?: string[];
+  value?: unknown;
+  values?: unknown[];
+  low?: unknown;
+  high?: unknown;
+  pattern?: string;
+  case_insensitive?: boolean;
+  direction?: "ASC" | "DESC";
+  nulls?: "FIRST" | "LAST";
+  limit?: number;
+  offset?: number;
+  path?: string[];
+  key?: string;
+  query?: string;
+  language?: string;
+  threshold?: number;
+  relation?: string;
+  point?: { lat: number; lng: number };
+  meters?: number;
+  box?: BoundingBox;
+  specs?: Node[];
+  description?: string;
+  version?: number;
+}
+
+/** The area between two parallels and two meridians, in degrees. */
+export interface BoundingBox {
+  min_lat: number;
+  min_lng: number;
+  max_lat: number;
+  max_lng: number;
+}
+
+const time = (v: Date | string): string => (v instanceof Date ? v.toISOString() : v);
+
+export const and = (...specs: Node[]): Node => ({ op: "and", specs });
+export const or = (...specs: Node[]): Node => ({ op: "or", specs });
+export const not = (spec: Node): Node => ({ op: "not", specs: [spec] });
+export const related = (relation: string, spec: Node): Node => ({ op: "related", relation, specs: [spec] });
+export const limit = (limit: number): Node => ({ op: "limit", limit });
+export const offset = (offset: number): Node => ({ op: "offset", offset });
+export const described = (spec: Node, description: string): Node => ({ ...spec, description });
+`
+
+// TypeScript returns the source of a TypeScript module building the nodes
+// of specifications valid against schema: a builder object per field in
+// fields, with a method per allowed operator taking values of the field's
+// type, and the composites. Its nodes serialize with JSON.stringify into
+// input for Unmarshal, e.g.
+//
+//	and(fields.Status.eq("active"), fields.Price.asc(), limit(10))
+//
+// Regenerate it when the schema changes, so that filters built by the
+// frontend keep passing ValidateAll. Renamed fields are left out.
+func TypeScript(schema Schema) []byte {
+	names := make([]string, 0, len(schema.Fields))
+	for field := range schema.Fields {
+		names = append(names, field)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	b.WriteString(tsPrelude)
+
+	union := "never"
+	if len(names) > 0 {
+		quoted := make([]string, len(names))
+		for i, field := range names {
+			quoted[i] = tsString(field)
+		}
+		union = strings.Join(quoted, " | ")
+	}
+	fmt.Fprintf(&b, "\nexport type Field = %s;\n\n", union)
+	b.WriteString("export const groupBy = (...fields: Field[]): Node => ({ op: \"group_by\", fields });\n")
+	b.WriteString("export const distinct = (...fields: Field[]): Node => (fields.length > 0 ? { op: \"distinct\", fields } : { op: \"distinct\" });\n")
+
+	b.WriteString("\nexport const fields = {\n")
+	for _, field := range names {
+		ops := schema.Fields[field]
+		typ, ok := tsTypes[schema.Types[field]]
+		if !ok {
+			typ = "unknown"
+		}
+		array := typ + "[]"
+		if strings.Contains(typ, "|") {
+			array = "(" + typ + ")[]"
+		}
+		quoted := tsString(field)
+		fmt.Fprintf(&b, "  %s: {\n", quoted)
+		for _, o := range tsOps {
+			if ops != nil && !contains(ops, o.op) {
+				continue
+			}
+			members := fmt.Sprintf("op: %s, field: %s", tsString(o.op), quoted)
+			switch {
+			case o.timed != "" && schema.Types[field] == TypeTime:
+				members += ", " + o.timed
+			case o.members != "":
+				members += ", " + o.members
+			}
+			fmt.Fprintf(&b, "    %s: (%s): Node => ({ %s }),\n", o.name, strings.NewReplacer("T[]", array, "T", typ).Replace(o.params), members)
+		}
+		fmt.Fprintf(&b, "    asc: (): Node => ({ op: %s, field: %s, direction: \"ASC\" }),\n", tsString(OpOrder), quoted)
+		fmt.Fprintf(&b, "    desc: (): Node => ({ op: %s, field: %s, direction: \"DESC\" }),\n", tsString(OpOrder), quoted)
+		b.WriteString("  },\n")
+	}
+	b.WriteString("} as const;\n")
+	return []byte(b.String())
+}
+
+// tsString quotes s as a string literal, valid in both JSON and
+// TypeScript.
+func tsString(s string) string {
+	quoted, _ := stdjson.Marshal(s)
+	return string(quoted)
+}
@@ -0,0 +1,164 @@
+package json
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrUnknownField reports a field missing from the schema.
+	ErrUnknownField = errors.New("json: unknown field")
+	// ErrRenamedField reports a field the schema renamed; Issue.Suggestion
+	// holds the new name.
+	ErrRenamedField = errors.New("json: renamed field")
+	// ErrOperatorNotAllowed reports an operator no longer allowed on a field.
+	ErrOperatorNotAllowed = errors.New("json: operator not allowed")
+)
+
+// SerializedSpec is a stored specification, as rendered by Marshal.
+type SerializedSpec struct {
+	ID   string
+	Data []byte
+}
+
+// Schema describes the fields and operators specifications may use.
+type Schema struct {
+	// Fields maps each field to the operators allowed on it, as Node.Op
+	// values. A nil list allows every operator. Ordering, DistinctOn and
+	// GroupBy are allowed on every field.
+	Fields map[string][]string
+	// Renamed maps former field names to their new names.
+	Renamed map[string]string
+	// Types maps fields to the type of their values, for TypeScript.
+	// Fields without a type take any value.
+	Types map[string]ValueType
+}
+
+// ValueType is the type of the values of a field.
+type ValueType string
+
+const (
+	TypeString  ValueType = "string"
+	TypeNumber  ValueType = "number"
+	TypeBoolean ValueType = "boolean"
+	// TypeTime values are RFC 3339 strings.
+	TypeTime ValueType = "time"
+)
+
+// Issue is a problem found in a stored specification.
+type Issue struct {
+	SpecID string
+	// Path locates the node within the specification, e.g. "specs/1/specs/0".
+	Path  string
+	Field string
+	Op    string
+	Err   error
+	// Suggestion is the replacement field for ErrRenamedField.
+	Suggestion string
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("%s at /%s: %v", i.SpecID, i.Path, i.Err)
+	if i.Suggestion != "" {
+		s += fmt.Sprintf(" (use %q)", i.Suggestion)
+	}
+	return s
+}
+
+// Report is the result of ValidateAll.
+type Report struct {
+	Checked int
+	Issues  []Issue
+}
+
+// OK reports whether every specification is valid.
+func (r Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Broken returns the IDs of the specifications with issues, in order.
+func (r Report) Broken() []string {
+	var ids []string
+	for _, issue := range r.Issues {
+		if len(ids) == 0 || ids[len(ids)-1] != issue.SpecID {
+			ids = append(ids, issue.SpecID)
+		}
+	}
+	return ids
+}
+
+// ValidateAll checks stored specifications against schema, e.g. after
+// fields were renamed or operators removed, and reports every issue found.
+// It stops early, returning the partial report, when ctx is done.
+func ValidateAll(ctx context.Context, specs []SerializedSpec, schema Schema) Report {
+	var r Report
+	for _, s := range specs {
+		if ctx.Err() != nil {
+			break
+		}
+		r.Checked++
+		spec, err := Unmarshal(s.Data)
+		if err != nil {
+			r.Issues = append(r.Issues, Issue{SpecID: s.ID, Err: err})
+			continue
+		}
+		node, err := Encode(spec)
+		if err != nil {
+			r.Issues = append(r.Issues, Issue{SpecID: s.ID, Err: err})
+			continue
+		}
+		r.Issues = schema.check(r.Issues, s.ID, "", node)
+	}
+	return r
+}
+
+func (schema Schema) check(issues []Issue, id, path string, n Node) []Issue {
+	// "*" is the field of specifications.Count("*").
+	if n.Field != "" && n.Field != "*" {
+		issues = schema.checkField(issues, id, path, n.Field, n.Op)
+	}
+	for _, field := range n.Fields {
+		issues = schema.checkField(issues, id, path, field, n.Op)
+	}
+	if n.Op == OpRelated {
+		// The fields of related specifications are those of another entity.
+		return issues
+	}
+	for i, child := range n.Specs {
+		childPath := "specs/" + strconv.Itoa(i)
+		if path != "" {
+			childPath = path + "/" + childPath
+		}
+		issues = schema.check(issues, id, childPath, child)
+	}
+	return issues
+}
+
+func (schema Schema) checkField(issues []Issue, id, path, field, op string) []Issue {
+	issue := Issue{SpecID: id, Path: path, Field: field, Op: op}
+	ops, known := schema.Fields[field]
+	switch {
+	case !known && schema.Renamed[field] != "":
+		issue.Err = ErrRenamedField
+		issue.Suggestion = schema.Renamed[field]
+		issues = append(issues, issue)
+	case !known:
+		issue.Err = ErrUnknownField
+		issues = append(issues, issue)
+	case op != OpOrder && op != OpDistinct && op != OpGroupBy && ops != nil && !contains(ops, op):
+		issue.Err = ErrOperatorNotAllowed
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package specifications
+
+import "context"
+
+// ValueConverter converts a value compared with field, e.g. a price entered
+// in the user's currency into the storage currency, or a local date into
+// UTC. It returns value unchanged for fields it does not handle.
+type ValueConverter func(field string, value interface{}) (interface{}, error)
+
+// ConvertValues returns a copy of spec in which every compared value,
+// including In lists and Between bounds, is passed through convert. LIKE
+// and regular expression patterns are kept as is. The first conversion
+// error is returned.
+func ConvertValues(spec Specification, convert ValueConverter) (Specification, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	r := &rewriter{value: convert}
+	return r.rewrite(spec)
+}
+
+// FieldConverters dispatches to the converter registered for each field and
+// keeps the values of other fields.
+func FieldConverters(converters map[string]ValueConverter) ValueConverter {
+	return func(field string, value interface{}) (interface{}, error) {
+		if convert, ok := converters[field]; ok {
+			return convert(field, value)
+		}
+		return value, nil
+	}
+}
+
+type converterKey struct{}
+
+// ContextWithConverter returns a copy of ctx carrying convert, typically set
+// by middleware from the user's locale, currency or time zone.
+func ContextWithConverter(ctx context.Context, convert ValueConverter) context.Context {
+	return context.WithValue(ctx, converterKey{}, convert)
+}
+
+// ConverterFromContext returns the converter carried by ctx, if any.
+func ConverterFromContext(ctx context.Context) (ValueConverter, bool) {
+	convert, ok := ctx.Value(converterKey{}).(ValueConverter)
+	return convert, ok
+}
+
+// ConvertValuesContext is like ConvertValues with the converter carried by
+// ctx. Without one, spec is returned unchanged.
+func ConvertValuesContext(ctx context.Context, spec Specification) (Specification, error) {
+	convert, ok := ConverterFromContext(ctx)
+	if !ok {
+		return spec, nil
+	}
+	return ConvertValues(spec, convert)
+}
@@ -0,0 +1,212 @@
+// Package degrade tightens the execution of specifications while the
+// database is under pressure, so that overloaded databases keep serving
+// cheaper queries rather than time out on all of them. A Monitor detects
+// pressure from the latencies and errors of the calls it observes, and a
+// Reader per endpoint applies that endpoint's Policy while it lasts:
+//
+//	monitor := &degrade.Monitor{Latency: 200 * time.Millisecond, ErrorRate: 0.05}
+//	search := &degrade.Reader{
+//		Backend: backend,
+//		Monitor: monitor,
+//		Policy:  degrade.Policy{MaxLimit: 20, RejectLeadingWildcards: true, ServeCached: true},
+//	}
+package degrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// ErrDegraded is returned for specifications a Policy rejects under
+// pressure.
+var ErrDegraded = errors.New("degrade: rejected under pressure")
+
+// Backend returns the identifiers of the entities matching a specification.
+type Backend interface {
+	IDs(ctx context.Context, spec specifications.Specification) ([]string, error)
+}
+
+// BackendFunc adapts a function to the Backend interface.
+type BackendFunc func(ctx context.Context, spec specifications.Specification) ([]string, error)
+
+func (f BackendFunc) IDs(ctx context.Context, spec specifications.Specification) ([]string, error) {
+	return f(ctx, spec)
+}
+
+// Monitor reports pressure when the recent calls it observed were slow or
+// failing. Share one per database among the Readers of its endpoints.
+type Monitor struct {
+	// Latency is the mean latency of the recent calls above which the
+	// database is under pressure. Zero ignores latencies.
+	Latency time.Duration
+	// ErrorRate is the fraction of failed recent calls, in (0, 1], above
+	// which the database is under pressure. Zero ignores errors.
+	ErrorRate float64
+	// Window is the number of recent calls observed, 100 when zero.
+	// Pressure is only reported from full windows, so that a few slow calls
+	// at start-up do not degrade the endpoints.
+	Window int
+	// Pressure, when set, also reports pressure from other signals, e.g.
+	// the replication lag or the saturation of the connection pool.
+	Pressure func() bool
+
+	mu    sync.Mutex
+	calls []call
+	next  int
+}
+
+// call is an observed call.
+type call struct {
+	latency time.Duration
+	failed  bool
+}
+
+// Observe records a call that took latency and returned err. Calls canceled
+// by their caller do not count as failures.
+func (m *Monitor) Observe(latency time.Duration, err error) {
+	c := call{latency: latency, failed: err != nil && !errors.Is(err, context.Canceled)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.calls) < m.window() {
+		m.calls = append(m.calls, c)
+		return
+	}
+	m.calls[m.next%len(m.calls)] = c
+	m.next++
+}
+
+func (m *Monitor) window() int {
+	if m.Window <= 0 {
+		return 100
+	}
+	return m.Window
+}
+
+// Pressured reports whether the database is under pressure.
+func (m *Monitor) Pressured() bool {
+	if m.Pressure != nil && m.Pressure() {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.calls) < m.window() {
+		return false
+	}
+	var total time.Duration
+	failed := 0
+	for _, c := range m.calls {
+		total += c.latency
+		if c.failed {
+			failed++
+		}
+	}
+	return (m.Latency > 0 && total/time.Duration(len(m.calls)) > m.Latency) ||
+		(m.ErrorRate > 0 && float64(failed)/float64(len(m.calls)) > m.ErrorRate)
+}
+
+// Policy is how an endpoint executes specifications under pressure.
+type Policy struct {
+	// MaxLimit caps the limit of specifications, and sets it on those
+	// without. Zero keeps limits.
+	MaxLimit int
+	// RejectLeadingWildcards rejects specifications with LIKE conditions
+	// starting with a wildcard, which scan every row, with ErrDegraded.
+	RejectLeadingWildcards bool
+	// ServeCached serves the last result of a structurally equal
+	// specification, as by specifications.Hash, from before the pressure
+	// without calling the backend. Specifications without one execute
+	// tightened.
+	ServeCached bool
+	// CacheSize bounds the number of results kept for ServeCached, 1000
+	// when zero.
+	CacheSize int
+}
+
+// Tighten returns spec as p executes it under pressure.
+func (p Policy) Tighten(spec specifications.Specification) (specifications.Specification, error) {
+	if p.RejectLeadingWildcards {
+		if field, ok := specifications.LeadingWildcard(spec); ok {
+			return nil, fmt.Errorf("%w: leading wildcard on %s", ErrDegraded, field)
+		}
+	}
+	if p.MaxLimit > 0 {
+		offset, limit, limited := specifications.Window(spec)
+		if !limited || limit > p.MaxLimit {
+			spec = specifications.Rewindow(spec, offset, p.MaxLimit)
+		}
+	}
+	return spec, nil
+}
+
+// Reader is a Backend executing specifications against Backend, observed
+// by Monitor, and applying Policy while Monitor reports pressure.
+type Reader struct {
+	Backend Backend
+	Monitor *Monitor
+	Policy  Policy
+
+	mu    sync.RWMutex
+	cache map[string][]string
+}
+
+func (r *Reader) IDs(ctx context.Context, spec specifications.Specification) ([]string, error) {
+	if !r.Monitor.Pressured() {
+		ids, err := r.call(ctx, spec)
+		if err == nil && r.Policy.ServeCached {
+			r.store(spec, ids)
+		}
+		return ids, err
+	}
+
+	if r.Policy.ServeCached {
+		if ids, ok := r.cached(spec); ok {
+			return ids, nil
+		}
+	}
+	tightened, err := r.Policy.Tighten(spec)
+	if err != nil {
+		return nil, err
+	}
+	return r.call(ctx, tightened)
+}
+
+// call executes spec against Backend, reporting it to Monitor.
+func (r *Reader) call(ctx context.Context, spec specifications.Specification) ([]string, error) {
+	start := time.Now()
+	ids, err := r.Backend.IDs(ctx, spec)
+	r.Monitor.Observe(time.Since(start), err)
+	return ids, err
+}
+
+func (r *Reader) cached(spec specifications.Specification) ([]string, bool) {
+	key := specifications.Hash(spec)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids, ok := r.cache[key]
+	return slices.Clone(ids), ok
+}
+
+// store keeps ids as the result of spec, replacing an earlier one. Once
+// the cache is full, results of other specifications are not kept.
+func (r *Reader) store(spec specifications.Specification, ids []string) {
+	key := specifications.Hash(spec)
+	size := r.Policy.CacheSize
+	if size <= 0 {
+		size = 1000
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = map[string][]string{}
+	}
+	if _, ok := r.cache[key]; ok || len(r.cache) < size {
+		r.cache[key] = slices.Clone(ids)
+	}
+}
@@ -0,0 +1,308 @@
+package specifications
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DescribedVisitor is implemented by visitors interested in the
+// descriptions attached with WithDescription. Other visitors see the
+// described specification only.
+type DescribedVisitor interface {
+	VisitDescribed(description string, spec Specification)
+}
+
+type describedSpec struct {
+	description string
+	spec        Specification
+}
+
+func (s *describedSpec) Accept(v SpecificationVisitor) {
+	if dv, ok := v.(DescribedVisitor); ok {
+		dv.VisitDescribed(s.description, s.spec)
+		return
+	}
+	s.spec.Accept(v)
+}
+
+// WithDescription attaches a human-readable description to spec, e.g.
+// "Active customers", without changing what it matches.
+func WithDescription(spec Specification, description string) Specification {
+	return &describedSpec{description: description, spec: spec}
+}
+
+// Description is the structured, human-readable form of a specification.
+// Described specifications keep the structure they describe as Children.
+type Description struct {
+	Text     string        `json:"text"`
+	Children []Description `json:"children,omitempty"`
+}
+
+// String renders d as one sentence: described specifications by their
+// description, conjunctions as comma-separated lists.
+func (d Description) String() string {
+	switch {
+	case d.Text == "all of" && len(d.Children) > 0:
+		return joinDescriptions(d.Children, ", ")
+	case d.Text == "any of" && len(d.Children) > 0:
+		return "(" + joinDescriptions(d.Children, " or ") + ")"
+	case d.Text == "not" && len(d.Children) == 1:
+		return "not " + d.Children[0].String()
+	case d.Text == "having" && len(d.Children) == 1:
+		return "having " + d.Children[0].String()
+	case strings.HasSuffix(d.Text, " matching") && len(d.Children) == 1:
+		return d.Text + " " + d.Children[0].String()
+	case isAggregate(d.Text) && len(d.Children) == 1:
+		return d.Text + " " + d.Children[0].String()
+	}
+	return d.Text
+}
+
+// isAggregate reports whether text is that of VisitAggregate, e.g.
+// "sum of".
+func isAggregate(text string) bool {
+	function, ok := strings.CutSuffix(text, " of")
+	_, err := ParseAggregateFunction(function)
+	return ok && err == nil && function == strings.ToLower(function)
+}
+
+func joinDescriptions(ds []Description, sep string) string {
+	parts := make([]string, len(ds))
+	for i, d := range ds {
+		parts[i] = d.String()
+	}
+	return strings.Join(parts, sep)
+}
+
+// Describe returns the description of spec. Parts without an attached
+// description are described from their fields and values, e.g. "Price > 100".
+func Describe(spec Specification) Description {
+	d := &describer{}
+	if spec != nil {
+		spec.Accept(d)
+	}
+	if len(d.out) == 1 {
+		return d.out[0]
+	}
+	return Description{Text: "all of", Children: d.out}
+}
+
+// describer is a SpecificationVisitor collecting one Description per
+// visited specification.
+type describer struct {
+	out []Description
+}
+
+func (d *describer) add(format string, args ...interface{}) {
+	d.out = append(d.out, Description{Text: fmt.Sprintf(format, args...)})
+}
+
+func (d *describer) children(specs []Specification) []Description {
+	sub := &describer{}
+	for _, s := range specs {
+		s.Accept(sub)
+	}
+	return sub.out
+}
+
+func (d *describer) VisitDescribed(description string, spec Specification) {
+	d.out = append(d.out, Description{Text: description, Children: d.children([]Specification{spec})})
+}
+
+func (d *describer) VisitEqual(field string, value interface{}) {
+	d.add("%s = %v", field, value)
+}
+
+func (d *describer) VisitNotEqual(field string, value interface{}) {
+	d.add("%s != %v", field, value)
+}
+
+func (d *describer) VisitIn(field string, values []interface{}) {
+	d.add("%s in (%s)", field, joinValues(values))
+}
+
+func (d *describer) VisitNotIn(field string, values []interface{}) {
+	d.add("%s not in (%s)", field, joinValues(values))
+}
+
+func joinValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (d *describer) VisitAnd(specs []Specification) {
+	d.out = append(d.out, Description{Text: "all of", Children: d.children(specs)})
+}
+
+func (d *describer) VisitOr(specs []Specification) {
+	d.out = append(d.out, Description{Text: "any of", Children: d.children(specs)})
+}
+
+func (d *describer) VisitNot(spec Specification) {
+	d.out = append(d.out, Description{Text: "not", Children: d.children([]Specification{spec})})
+}
+
+func (d *describer) VisitLimit(limit int) {
+	d.add("at most %d results", limit)
+}
+
+func (d *describer) VisitOffset(offset int) {
+	d.add("skipping %d results", offset)
+}
+
+func (d *describer) VisitOrder(field, direction string) {
+	if strings.EqualFold(strings.TrimSpace(direction), "DESC") {
+		d.add("ordered by %s descending", field)
+		return
+	}
+	d.add("ordered by %s", field)
+}
+
+func (d *describer) VisitDistinct(fields []string) {
+	if len(fields) == 0 {
+		d.add("distinct")
+		return
+	}
+	d.add("distinct on %s", strings.Join(fields, ", "))
+}
+
+func (d *describer) VisitGroupBy(fields []string) {
+	d.add("grouped by %s", strings.Join(fields, ", "))
+}
+
+func (d *describer) VisitHaving(spec Specification) {
+	d.out = append(d.out, Description{Text: "having", Children: d.children([]Specification{spec})})
+}
+
+// VisitAggregate describes the fields of spec as "sum of amount".
+func (d *describer) VisitAggregate(function AggregateFunction, spec Specification) {
+	d.out = append(d.out, Description{Text: strings.ToLower(string(function)) + " of", Children: d.children([]Specification{spec})})
+}
+
+func (d *describer) VisitLock(strength LockStrength, wait LockWait) {
+	if wait == LockWaitDefault {
+		d.add("locked for %s", strings.ToLower(string(strength)))
+		return
+	}
+	d.add("locked for %s, %s", strings.ToLower(string(strength)), strings.ToLower(string(wait)))
+}
+
+func (d *describer) VisitJSONContains(field string, doc interface{}) {
+	d.add("%s contains %v", field, doc)
+}
+
+func (d *describer) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	d.add("%s = %v", strings.Join(append([]string{field}, path...), "."), value)
+}
+
+func (d *describer) VisitJSONKeyExists(field, key string) {
+	d.add("%s has key %s", field, key)
+}
+
+func (d *describer) VisitArrayContains(field string, values []interface{}) {
+	d.add("%s contains all of (%s)", field, joinValues(values))
+}
+
+func (d *describer) VisitArrayOverlaps(field string, values []interface{}) {
+	d.add("%s contains any of (%s)", field, joinValues(values))
+}
+
+func (d *describer) VisitEqualAny(field string, values []interface{}) {
+	d.VisitIn(field, values)
+}
+
+func (d *describer) VisitTextSearch(field, query, language string) {
+	d.add("%s matches the words %q", field, query)
+}
+
+func (d *describer) VisitTextRank(field, query, language string) {
+	d.add("ordered by relevance of %s to %q", field, query)
+}
+
+func (d *describer) VisitSimilar(field, value string, threshold float64) {
+	if threshold == 0 {
+		d.add("%s is similar to %q", field, value)
+		return
+	}
+	d.add("%s is similar to %q, by more than %v", field, value, threshold)
+}
+
+func (d *describer) VisitSimilarityOrder(field, value string) {
+	d.add("ordered by similarity of %s to %q", field, value)
+}
+
+func (d *describer) VisitWithinRadius(field string, center Point, meters float64) {
+	d.add("%s within %vm of (%v, %v)", field, meters, center.Lat, center.Lng)
+}
+
+func (d *describer) VisitWithinBox(field string, box BoundingBox) {
+	d.add("%s within (%v, %v) to (%v, %v)", field, box.MinLat, box.MinLng, box.MaxLat, box.MaxLng)
+}
+
+func (d *describer) VisitOrderByDistance(field string, from Point) {
+	d.add("ordered by distance of %s from (%v, %v)", field, from.Lat, from.Lng)
+}
+
+func (d *describer) VisitRelated(relation string, spec Specification) {
+	d.out = append(d.out, Description{Text: relation + " matching", Children: d.children([]Specification{spec})})
+}
+
+func (d *describer) VisitOrderNulls(field, direction string, nulls NullsPosition) {
+	d.VisitOrder(field, direction)
+	d.out[len(d.out)-1].Text += ", nulls " + strings.ToLower(string(nulls))
+}
+
+func (d *describer) VisitGreaterThan(field string, value interface{}) {
+	d.add("%s > %v", field, value)
+}
+
+func (d *describer) VisitLowerThan(field string, value interface{}) {
+	d.add("%s < %v", field, value)
+}
+
+func (d *describer) VisitGreaterThanOrEqual(field string, value interface{}) {
+	d.add("%s >= %v", field, value)
+}
+
+func (d *describer) VisitLowerThanOrEqual(field string, value interface{}) {
+	d.add("%s <= %v", field, value)
+}
+
+func (d *describer) VisitBetween(field string, low, high interface{}) {
+	d.add("%s between %v and %v", field, low, high)
+}
+
+func (d *describer) VisitIsNull(field string) {
+	d.add("%s is empty", field)
+}
+
+func (d *describer) VisitIsNotNull(field string) {
+	d.add("%s is set", field)
+}
+
+func (d *describer) VisitLike(field string, value interface{}) {
+	d.add("%s like %v", field, value)
+}
+
+func (d *describer) VisitILike(field string, value interface{}) {
+	d.add("%s like %v, ignoring case", field, value)
+}
+
+func (d *describer) VisitLikeEscaped(field string, pattern string) {
+	d.add("%s like %s", field, pattern)
+}
+
+func (d *describer) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	if caseInsensitive {
+		d.add("%s matches /%s/i", field, pattern)
+		return
+	}
+	d.add("%s matches /%s/", field, pattern)
+}
+
+func (d *describer) VisitSample(percent float64, method SampleMethod) {
+	d.add("a %v%% sample", percent)
+}
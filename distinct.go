@@ -0,0 +1,23 @@
+package specifications
+
+type distinctSpec struct {
+	fields []string
+}
+
+func (s *distinctSpec) Accept(v SpecificationVisitor) {
+	v.VisitDistinct(s.fields)
+}
+
+// Distinct removes duplicate rows from the result.
+func Distinct() Specification {
+	return &distinctSpec{}
+}
+
+// DistinctOn keeps only the first row of each combination of values of
+// fields, as ordered by the OrderBy specifications, e.g. the latest event
+// per aggregate when ordering by aggregate and descending time. The
+// ordering must start with fields. It is PostgreSQL-specific; visitors
+// without an equivalent report ErrUnsupported.
+func DistinctOn(fields ...string) Specification {
+	return &distinctSpec{fields: fields}
+}
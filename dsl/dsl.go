@@ -0,0 +1,454 @@
+// Package dsl parses a small SQL-like filter language into specifications:
+//
+//	status = 'active' AND (age > 18 OR vip = true) ORDER BY created_at DESC LIMIT 20
+//
+// Only whitelisted fields and operators are accepted, so expressions can come
+// from end users. Values are literals bound through the specification, never
+// spliced into queries.
+//
+// Supported predicates are =, != (or <>), >, >=, <, <=, IN (...), NOT IN (...),
+// BETWEEN ... AND ..., LIKE, ILIKE, IS NULL and IS NOT NULL, combined with
+// AND, OR and parentheses. Literals are single-quoted strings, in which a
+// quote is escaped by doubling it, numbers, true and false. An expression
+// may end with ORDER BY, LIMIT and OFFSET clauses, in that order.
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+var (
+	// ErrSyntax is returned for malformed expressions.
+	ErrSyntax = errors.New("dsl: syntax error")
+	// ErrFieldNotAllowed is returned for fields missing from the whitelist.
+	ErrFieldNotAllowed = errors.New("dsl: field not allowed")
+	// ErrOperatorNotAllowed is returned for operators missing from the
+	// whitelist.
+	ErrOperatorNotAllowed = errors.New("dsl: operator not allowed")
+)
+
+// Operator identifies a predicate of the language.
+type Operator string
+
+const (
+	OpEqual              Operator = "="
+	OpNotEqual           Operator = "!="
+	OpGreaterThan        Operator = ">"
+	OpGreaterThanOrEqual Operator = ">="
+	OpLowerThan          Operator = "<"
+	OpLowerThanOrEqual   Operator = "<="
+	OpIn                 Operator = "IN"
+	OpNotIn              Operator = "NOT IN"
+	OpBetween            Operator = "BETWEEN"
+	OpLike               Operator = "LIKE"
+	OpILike              Operator = "ILIKE"
+	OpIsNull             Operator = "IS NULL"
+	OpIsNotNull          Operator = "IS NOT NULL"
+	OpOr                 Operator = "OR"
+	OpOrderBy            Operator = "ORDER BY"
+	OpLimit              Operator = "LIMIT"
+	OpOffset             Operator = "OFFSET"
+)
+
+// maxDepth bounds parenthesis nesting.
+const maxDepth = 32
+
+// Parser parses expressions restricted to a set of fields and operators.
+type Parser struct {
+	fields    map[string]bool
+	operators map[Operator]bool
+	maxLimit  int
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithOperators restricts the accepted operators to ops. By default every
+// operator is accepted, AND and parentheses always are.
+func WithOperators(ops ...Operator) Option {
+	return func(p *Parser) {
+		p.operators = make(map[Operator]bool, len(ops))
+		for _, op := range ops {
+			p.operators[op] = true
+		}
+	}
+}
+
+// WithMaxLimit rejects LIMIT values above max, and adds LIMIT max to
+// expressions without one.
+func WithMaxLimit(max int) Option {
+	return func(p *Parser) {
+		p.maxLimit = max
+	}
+}
+
+// NewParser returns a parser accepting the given fields, which are the
+// domain field names of the specifications it produces.
+func NewParser(fields []string, opts ...Option) *Parser {
+	p := &Parser{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		p.fields[f] = true
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Parse turns input into a specification. An empty input yields an empty
+// And.
+func (p *Parser) Parse(input string) (specifications.Specification, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	s := &state{parser: p, tokens: tokens}
+
+	var specs []specifications.Specification
+	if !s.peek().is("ORDER") && !s.peek().is("LIMIT") && !s.peek().is("OFFSET") && s.peek().kind != tokEOF {
+		cond, err := s.or(0)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, cond)
+	}
+
+	tail, err := s.tail()
+	if err != nil {
+		return nil, err
+	}
+	specs = append(specs, tail...)
+
+	if t := s.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("%w at offset %d: unexpected %s", ErrSyntax, t.pos, t)
+	}
+	if len(specs) == 1 {
+		return specs[0], nil
+	}
+	return specifications.And(specs...), nil
+}
+
+// Parse parses input with a parser accepting fields and every operator.
+func Parse(input string, fields ...string) (specifications.Specification, error) {
+	return NewParser(fields).Parse(input)
+}
+
+type state struct {
+	parser *Parser
+	tokens []token
+	pos    int
+}
+
+func (s *state) peek() token {
+	return s.tokens[s.pos]
+}
+
+func (s *state) next() token {
+	t := s.tokens[s.pos]
+	if t.kind != tokEOF {
+		s.pos++
+	}
+	return t
+}
+
+func (s *state) expect(kw string) error {
+	if t := s.next(); !t.is(kw) {
+		return fmt.Errorf("%w at offset %d: expected %s, got %s", ErrSyntax, t.pos, kw, t)
+	}
+	return nil
+}
+
+func (s *state) allow(op Operator, pos int) error {
+	if s.parser.operators != nil && !s.parser.operators[op] {
+		return fmt.Errorf("%w at offset %d: %s", ErrOperatorNotAllowed, pos, op)
+	}
+	return nil
+}
+
+func (s *state) field() (string, error) {
+	t := s.next()
+	if t.kind != tokIdent {
+		return "", fmt.Errorf("%w at offset %d: expected field, got %s", ErrSyntax, t.pos, t)
+	}
+	if !s.parser.fields[t.text] {
+		return "", fmt.Errorf("%w at offset %d: %q", ErrFieldNotAllowed, t.pos, t.text)
+	}
+	return t.text, nil
+}
+
+func (s *state) or(depth int) (specifications.Specification, error) {
+	first, err := s.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	specs := []specifications.Specification{first}
+	for s.peek().is("OR") {
+		if err := s.allow(OpOr, s.next().pos); err != nil {
+			return nil, err
+		}
+		spec, err := s.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 1 {
+		return first, nil
+	}
+	return specifications.Or(specs...), nil
+}
+
+func (s *state) and(depth int) (specifications.Specification, error) {
+	first, err := s.primary(depth)
+	if err != nil {
+		return nil, err
+	}
+	specs := []specifications.Specification{first}
+	for s.peek().is("AND") {
+		s.next()
+		spec, err := s.primary(depth)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 1 {
+		return first, nil
+	}
+	return specifications.And(specs...), nil
+}
+
+func (s *state) primary(depth int) (specifications.Specification, error) {
+	if t := s.peek(); t.kind == tokLParen {
+		if depth >= maxDepth {
+			return nil, fmt.Errorf("%w at offset %d: nesting deeper than %d", ErrSyntax, t.pos, maxDepth)
+		}
+		s.next()
+		spec, err := s.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t := s.next(); t.kind != tokRParen {
+			return nil, fmt.Errorf("%w at offset %d: expected ')', got %s", ErrSyntax, t.pos, t)
+		}
+		return spec, nil
+	}
+	return s.predicate()
+}
+
+func (s *state) predicate() (specifications.Specification, error) {
+	field, err := s.field()
+	if err != nil {
+		return nil, err
+	}
+
+	t := s.next()
+	var op Operator
+	switch {
+	case t.kind == tokOperator:
+		op = Operator(t.text)
+	case t.is("IN"):
+		op = OpIn
+	case t.is("NOT"):
+		if err := s.expect("IN"); err != nil {
+			return nil, err
+		}
+		op = OpNotIn
+	case t.is("BETWEEN"):
+		op = OpBetween
+	case t.is("LIKE"):
+		op = OpLike
+	case t.is("ILIKE"):
+		op = OpILike
+	case t.is("IS"):
+		op = OpIsNull
+		if s.peek().is("NOT") {
+			s.next()
+			op = OpIsNotNull
+		}
+		if err := s.expect("NULL"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w at offset %d: expected operator, got %s", ErrSyntax, t.pos, t)
+	}
+	if err := s.allow(op, t.pos); err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case OpIsNull:
+		return specifications.IsNull(field), nil
+	case OpIsNotNull:
+		return specifications.IsNotNull(field), nil
+	case OpIn, OpNotIn:
+		values, err := s.list()
+		if err != nil {
+			return nil, err
+		}
+		if op == OpIn {
+			return specifications.In(field, values...), nil
+		}
+		return specifications.NotIn(field, values...), nil
+	case OpBetween:
+		low, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		return specifications.Between(field, low, high), nil
+	}
+
+	value, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case OpEqual:
+		return specifications.Equal(field, value), nil
+	case OpNotEqual:
+		return specifications.NotEqual(field, value), nil
+	case OpGreaterThan:
+		return specifications.GreaterThan(field, value), nil
+	case OpGreaterThanOrEqual:
+		return specifications.GreaterThanOrEqual(field, value), nil
+	case OpLowerThan:
+		return specifications.LowerThan(field, value), nil
+	case OpLowerThanOrEqual:
+		return specifications.LowerThanOrEqual(field, value), nil
+	case OpLike:
+		return specifications.Like(field, value), nil
+	default:
+		return specifications.ILike(field, value), nil
+	}
+}
+
+func (s *state) list() ([]interface{}, error) {
+	if t := s.next(); t.kind != tokLParen {
+		return nil, fmt.Errorf("%w at offset %d: expected '(', got %s", ErrSyntax, t.pos, t)
+	}
+	var values []interface{}
+	for {
+		value, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		t := s.next()
+		if t.kind == tokRParen {
+			return values, nil
+		}
+		if t.kind != tokComma {
+			return nil, fmt.Errorf("%w at offset %d: expected ',' or ')', got %s", ErrSyntax, t.pos, t)
+		}
+	}
+}
+
+// value parses a literal: strings, integers as int64, other numbers as
+// float64, and booleans.
+func (s *state) value() (interface{}, error) {
+	t := s.next()
+	switch {
+	case t.kind == tokString:
+		return t.text, nil
+	case t.kind == tokNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("%w at offset %d: invalid number %q", ErrSyntax, t.pos, t.text)
+	case t.is("TRUE"):
+		return true, nil
+	case t.is("FALSE"):
+		return false, nil
+	case t.is("NULL"):
+		return nil, fmt.Errorf("%w at offset %d: use IS NULL or IS NOT NULL to compare with null", ErrSyntax, t.pos)
+	}
+	return nil, fmt.Errorf("%w at offset %d: expected value, got %s", ErrSyntax, t.pos, t)
+}
+
+// tail parses the optional ORDER BY, LIMIT and OFFSET clauses.
+func (s *state) tail() ([]specifications.Specification, error) {
+	var specs []specifications.Specification
+
+	if t := s.peek(); t.is("ORDER") {
+		s.next()
+		if err := s.expect("BY"); err != nil {
+			return nil, err
+		}
+		if err := s.allow(OpOrderBy, t.pos); err != nil {
+			return nil, err
+		}
+		for {
+			field, err := s.field()
+			if err != nil {
+				return nil, err
+			}
+			direction := "ASC"
+			if d := s.peek(); d.is("ASC") || d.is("DESC") {
+				direction = strings.ToUpper(s.next().text)
+			}
+			specs = append(specs, specifications.OrderBy(field, direction))
+			if s.peek().kind != tokComma {
+				break
+			}
+			s.next()
+		}
+	}
+
+	limited := false
+	if t := s.peek(); t.is("LIMIT") {
+		s.next()
+		if err := s.allow(OpLimit, t.pos); err != nil {
+			return nil, err
+		}
+		n, err := s.count()
+		if err != nil {
+			return nil, err
+		}
+		if s.parser.maxLimit > 0 && n > s.parser.maxLimit {
+			return nil, fmt.Errorf("%w: limit %d exceeds %d", specifications.ErrInvalidValue, n, s.parser.maxLimit)
+		}
+		specs = append(specs, specifications.Limit(n))
+		limited = true
+	}
+	if !limited && s.parser.maxLimit > 0 {
+		specs = append(specs, specifications.Limit(s.parser.maxLimit))
+	}
+
+	if t := s.peek(); t.is("OFFSET") {
+		s.next()
+		if err := s.allow(OpOffset, t.pos); err != nil {
+			return nil, err
+		}
+		n, err := s.count()
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, specifications.Offset(n))
+	}
+	return specs, nil
+}
+
+// count parses a non-negative integer.
+func (s *state) count() (int, error) {
+	t := s.next()
+	n, err := strconv.Atoi(t.text)
+	if t.kind != tokNumber || err != nil || n < 0 {
+		return 0, fmt.Errorf("%w at offset %d: expected non-negative integer, got %s", ErrSyntax, t.pos, t)
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// is reports whether t is the keyword kw, compared case-insensitively.
+func (t token) is(kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '=':
+			tokens = append(tokens, token{kind: tokOperator, text: "=", pos: i})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(input) && (input[i+1] == '=' || (c == '<' && input[i+1] == '>')) {
+				op += string(input[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("%w at offset %d: unexpected '!'", ErrSyntax, i)
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+			i += len(op)
+		case c == '\'':
+			s, n, err := lexString(input[i:], i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: s, pos: i})
+			i += n
+		case c == '-' || c == '+' || isDigit(c):
+			start := i
+			i++
+			for i < len(input) && (isDigit(input[i]) || strings.IndexByte(".eE+-", input[i]) >= 0) {
+				if (input[i] == '+' || input[i] == '-') && input[i-1] != 'e' && input[i-1] != 'E' {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: input[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && (isIdentStart(input[i]) || isDigit(input[i]) || input[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: input[start:i], pos: start})
+		default:
+			return nil, fmt.Errorf("%w at offset %d: unexpected %q", ErrSyntax, i, c)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(input)}), nil
+}
+
+// lexString reads a single-quoted string at the start of s, where a doubled
+// quote stands for a literal one. It returns the value and the number of
+// bytes consumed.
+func lexString(s string, pos int) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\'' {
+			b.WriteByte('\'')
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("%w at offset %d: unterminated string", ErrSyntax, pos)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,14 @@
+package specifications
+
+// Duplicates matches the groups of rows sharing the values of the fields
+// on, with more than one row, largest groups first, e.g. for data-quality
+// jobs finding users registered twice:
+//
+//	specifications.Duplicates("Email")
+//
+// It is And(GroupBy(on...), Having(Count("*").Gt(1)), Count("*").Desc()),
+// hence only supported by visitors supporting grouping. Add conditions on
+// the rows to restrict the search, e.g. to active users.
+func Duplicates(on ...string) Specification {
+	return And(GroupBy(on...), Having(Count("*").Gt(1)), Count("*").Desc())
+}
@@ -0,0 +1,701 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Visitor translates specifications into an Elasticsearch query DSL request
+// body: a bool query plus from/size, sort and search_after.
+type Visitor struct {
+	clauses     []map[string]interface{}
+	paths       []string // nested path of each clause, or ""
+	nestedPaths []string
+	fieldMap    map[string]string
+	sort        []interface{}
+	sortFields  []string // domain field of each sort entry
+	searchAfter []interface{}
+	collapse    string
+	limit       int
+	offset      int
+	empty       specifications.EmptyComposite
+	// limitSet and offsetSet tell a visited Limit or Offset of zero from
+	// none.
+	limitSet, offsetSet bool
+	err                 error
+}
+
+// Option configures a Visitor.
+type Option func(*Visitor)
+
+// WithEmptyComposite sets how And and Or without children are translated.
+// The default is specifications.EmptyCompositeSkip.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return func(v *Visitor) {
+		v.empty = mode
+	}
+}
+
+// WithNestedPaths declares the nested fields of the index mapping, e.g.
+// "comments". Conditions on fields below a nested path are wrapped in nested
+// queries, and the conditions on the same path combined by And or Or share
+// one nested query, so that they match the same nested object. Sorting on
+// such fields sets the nested sort option.
+func WithNestedPaths(paths ...string) Option {
+	return func(v *Visitor) {
+		v.nestedPaths = append(v.nestedPaths, paths...)
+	}
+}
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	v := &Visitor{
+		clauses:  []map[string]interface{}{},
+		fieldMap: fieldMap,
+		sort:     []interface{}{},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// child returns an empty visitor sharing v's configuration, used for
+// composite specifications.
+func (v *Visitor) child() *Visitor {
+	return NewVisitor(v.fieldMap, WithEmptyComposite(v.empty), WithNestedPaths(v.nestedPaths...))
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (v *Visitor) Err() error {
+	return v.err
+}
+
+func (v *Visitor) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+func (v *Visitor) mapField(domainField string) string {
+	if domainField == "" {
+		v.fail(specifications.ErrInvalidField)
+	}
+	if dbField, ok := v.fieldMap[domainField]; ok {
+		return dbField
+	}
+	return domainField
+}
+
+func (v *Visitor) add(clause map[string]interface{}) {
+	v.addAt("", clause)
+}
+
+// addOn adds a clause on dbField, within its nested path if any.
+func (v *Visitor) addOn(dbField string, clause map[string]interface{}) {
+	v.addAt(v.nestedPath(dbField), clause)
+}
+
+// addAt adds a clause to be wrapped in a nested query for path, unless path
+// is empty.
+func (v *Visitor) addAt(path string, clause map[string]interface{}) {
+	v.clauses = append(v.clauses, clause)
+	v.paths = append(v.paths, path)
+}
+
+// nestedPath returns the innermost nested path enclosing dbField, or "".
+func (v *Visitor) nestedPath(dbField string) string {
+	path := ""
+	for _, p := range v.nestedPaths {
+		if len(p) > len(path) && strings.HasPrefix(dbField, p+".") {
+			path = p
+		}
+	}
+	return path
+}
+
+// commonPath returns the nested path shared by all clauses, or "".
+func (v *Visitor) commonPath() string {
+	if len(v.paths) == 0 {
+		return ""
+	}
+	for _, p := range v.paths[1:] {
+		if p != v.paths[0] {
+			return ""
+		}
+	}
+	return v.paths[0]
+}
+
+// nest wraps query in a nested query for path and each enclosing nested
+// path.
+func (v *Visitor) nest(path string, query map[string]interface{}) map[string]interface{} {
+	for path != "" {
+		query = map[string]interface{}{"nested": map[string]interface{}{"path": path, "query": query}}
+		path = v.nestedPath(path)
+	}
+	return query
+}
+
+func leaf(kind, field string, body interface{}) map[string]interface{} {
+	return map[string]interface{}{kind: map[string]interface{}{field: body}}
+}
+
+func exists(field string) map[string]interface{} {
+	return map[string]interface{}{"exists": map[string]interface{}{"field": field}}
+}
+
+func mustNot(clauses ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{"must_not": clauses}}
+}
+
+func (v *Visitor) VisitEqual(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, leaf("term", dbField, value))
+}
+
+// VisitNotEqual follows SQL semantics: documents without the field do not
+// match.
+func (v *Visitor) VisitNotEqual(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, map[string]interface{}{"bool": map[string]interface{}{
+		"filter":   []interface{}{exists(dbField)},
+		"must_not": []interface{}{leaf("term", dbField, value)},
+	}})
+}
+
+func (v *Visitor) VisitIn(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, map[string]interface{}{"terms": map[string]interface{}{dbField: values}})
+}
+
+func (v *Visitor) VisitNotIn(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, mustNot(map[string]interface{}{"terms": map[string]interface{}{dbField: values}}))
+}
+
+func (v *Visitor) VisitEqualAny(field string, values []interface{}) {
+	v.VisitIn(field, values)
+}
+
+// VisitArrayContains requires a term per value, as fields hold arrays as
+// multiple values.
+func (v *Visitor) VisitArrayContains(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	if len(values) == 0 {
+		v.addOn(dbField, exists(dbField))
+		return
+	}
+	terms := make([]interface{}, len(values))
+	for i, value := range values {
+		terms[i] = leaf("term", dbField, value)
+	}
+	v.addOn(dbField, map[string]interface{}{"bool": map[string]interface{}{"filter": terms}})
+}
+
+func (v *Visitor) VisitArrayOverlaps(field string, values []interface{}) {
+	v.VisitIn(field, values)
+}
+
+func (v *Visitor) rangeQuery(field string, bounds map[string]interface{}) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, leaf("range", dbField, bounds))
+}
+
+func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
+	v.rangeQuery(field, map[string]interface{}{"gt": value})
+}
+
+func (v *Visitor) VisitLowerThan(field string, value interface{}) {
+	v.rangeQuery(field, map[string]interface{}{"lt": value})
+}
+
+func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
+	v.rangeQuery(field, map[string]interface{}{"gte": value})
+}
+
+func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
+	v.rangeQuery(field, map[string]interface{}{"lte": value})
+}
+
+func (v *Visitor) VisitBetween(field string, low, high interface{}) {
+	v.rangeQuery(field, map[string]interface{}{"gte": low, "lte": high})
+}
+
+func (v *Visitor) VisitIsNull(field string) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, mustNot(exists(dbField)))
+}
+
+func (v *Visitor) VisitIsNotNull(field string) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, exists(dbField))
+}
+
+func (v *Visitor) VisitLike(field string, value interface{}) {
+	v.wildcard(field, value, false)
+}
+
+func (v *Visitor) VisitILike(field string, value interface{}) {
+	v.wildcard(field, value, true)
+}
+
+func (v *Visitor) VisitLikeEscaped(field string, pattern string) {
+	v.wildcard(field, pattern, false)
+}
+
+func (v *Visitor) wildcard(field string, value interface{}, caseInsensitive bool) {
+	pattern, ok := value.(string)
+	if !ok {
+		v.fail(fmt.Errorf("%w: LIKE pattern for %q must be a string", specifications.ErrInvalidValue, field))
+		return
+	}
+	dbField := v.mapField(field)
+	v.addOn(dbField, leaf("wildcard", dbField, map[string]interface{}{
+		"value":            likeToWildcard(pattern),
+		"case_insensitive": caseInsensitive,
+	}))
+}
+
+// likeToWildcard converts a LIKE pattern into wildcard query syntax.
+func likeToWildcard(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			if r == '*' || r == '?' || r == '\\' {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+			escaped = false
+		case r == specifications.LikeEscapeChar:
+			escaped = true
+		case r == '%':
+			b.WriteRune('*')
+		case r == '_':
+			b.WriteRune('?')
+		case r == '*' || r == '?':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// VisitRegex emits a regexp query. Elasticsearch patterns are implicitly
+// anchored, so unanchored patterns are wrapped with ".*" and explicit ^/$
+// anchors are dropped.
+func (v *Visitor) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	if p, ok := strings.CutPrefix(pattern, "^"); ok {
+		pattern = p
+	} else {
+		pattern = ".*" + pattern
+	}
+	if p, ok := strings.CutSuffix(pattern, "$"); ok {
+		pattern = p
+	} else {
+		pattern += ".*"
+	}
+	dbField := v.mapField(field)
+	v.addOn(dbField, leaf("regexp", dbField, map[string]interface{}{
+		"value":            pattern,
+		"case_insensitive": caseInsensitive,
+	}))
+}
+
+func (v *Visitor) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(map[string]interface{}{"match_all": map[string]interface{}{}})
+		return
+	}
+
+	subVisitor := v.child()
+
+	for _, s := range specs {
+		s.Accept(subVisitor)
+	}
+
+	if path := subVisitor.commonPath(); path != "" {
+		v.addAt(path, combine(subVisitor.clauses))
+	} else if len(subVisitor.clauses) > 0 {
+		v.add(subVisitor.Query())
+	}
+
+	v.merge(subVisitor)
+}
+
+func (v *Visitor) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(map[string]interface{}{"match_none": map[string]interface{}{}})
+		return
+	}
+
+	branches := []*Visitor{}
+
+	for _, s := range specs {
+		temp := v.child()
+
+		s.Accept(temp)
+
+		if len(temp.clauses) > 0 {
+			branches = append(branches, temp)
+		}
+
+		v.merge(temp)
+	}
+
+	if len(branches) == 0 {
+		return
+	}
+
+	// Branches all within the same nested path share its nested query.
+	path := branches[0].commonPath()
+	for _, b := range branches[1:] {
+		if b.commonPath() != path {
+			path = ""
+		}
+	}
+	should := make([]interface{}, len(branches))
+	for i, b := range branches {
+		if path != "" {
+			should[i] = combine(b.clauses)
+		} else {
+			should[i] = b.Query()
+		}
+	}
+	v.addAt(path, map[string]interface{}{"bool": map[string]interface{}{
+		"should":               should,
+		"minimum_should_match": 1,
+	}})
+}
+
+func (v *Visitor) VisitNot(spec specifications.Specification) {
+	sub := v.child()
+	spec.Accept(sub)
+	if len(sub.clauses) > 0 {
+		v.add(mustNot(sub.Query()))
+	}
+	v.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being the
+// query equivalent to its logical identity.
+func (v *Visitor) visitEmpty(identity map[string]interface{}) {
+	switch v.empty {
+	case specifications.EmptyCompositeIdentity:
+		v.add(identity)
+	case specifications.EmptyCompositeError:
+		v.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but clauses over from a sub-visitor.
+func (v *Visitor) merge(sub *Visitor) {
+	v.sort = append(v.sort, sub.sort...)
+	v.sortFields = append(v.sortFields, sub.sortFields...)
+
+	if sub.collapse != "" {
+		v.collapse = sub.collapse
+	}
+
+	if sub.limitSet {
+		v.limit, v.limitSet = sub.limit, true
+	}
+
+	if sub.offsetSet {
+		v.offset, v.offsetSet = sub.offset, true
+	}
+
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+}
+
+func (v *Visitor) VisitLimit(limit int) {
+	if limit < 0 {
+		v.fail(fmt.Errorf("%w: negative limit %d", specifications.ErrInvalidValue, limit))
+		return
+	}
+	v.limit, v.limitSet = limit, true
+}
+
+func (v *Visitor) VisitOffset(offset int) {
+	if offset < 0 {
+		v.fail(fmt.Errorf("%w: negative offset %d", specifications.ErrInvalidValue, offset))
+		return
+	}
+	v.offset, v.offsetSet = offset, true
+}
+
+func (v *Visitor) VisitOrder(field, direction string) {
+	v.VisitOrderNulls(field, direction, specifications.NullsDefault)
+}
+
+// VisitOrderNulls places documents without a value for field with the
+// "missing" sort option.
+func (v *Visitor) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	dbField := v.mapField(field)
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	options := map[string]interface{}{"order": strings.ToLower(string(d))}
+	switch nulls {
+	case specifications.NullsDefault:
+	case specifications.NullsFirst:
+		options["missing"] = "_first"
+	case specifications.NullsLast:
+		options["missing"] = "_last"
+	default:
+		v.fail(fmt.Errorf("%w: nulls position %q", specifications.ErrInvalidValue, nulls))
+		return
+	}
+	if path := v.nestedPath(dbField); path != "" {
+		options["nested"] = v.sortNested(path)
+	}
+	v.sort = append(v.sort, map[string]interface{}{dbField: options})
+	v.sortFields = append(v.sortFields, field)
+}
+
+// sortNested returns the nested sort option for path, itself nested in the
+// options of its enclosing nested paths.
+func (v *Visitor) sortNested(path string) map[string]interface{} {
+	nested := map[string]interface{}{"path": path}
+	for outer := v.nestedPath(path); outer != ""; outer = v.nestedPath(outer) {
+		nested = map[string]interface{}{"path": outer, "nested": nested}
+	}
+	return nested
+}
+
+// SearchAfter continues a deep pagination after the last document of the
+// previous page, whose values of the visited OrderBy fields are given in
+// last, keyed by domain field. The search_after values follow the sort
+// order, so the sort must identify documents uniquely, e.g. by ending with
+// an ID field. Call it after visiting; it cannot be combined with an
+// Offset.
+func (v *Visitor) SearchAfter(last map[string]interface{}) {
+	if len(v.sortFields) == 0 {
+		v.fail(fmt.Errorf("%w: search_after without sort", specifications.ErrInvalidValue))
+		return
+	}
+	if v.offset > 0 {
+		v.fail(fmt.Errorf("%w: search_after with an offset", specifications.ErrInvalidValue))
+		return
+	}
+	values := make([]interface{}, len(v.sortFields))
+	for i, field := range v.sortFields {
+		value, ok := last[field]
+		if !ok {
+			v.fail(fmt.Errorf("%w: search_after value for sort field %q", specifications.ErrInvalidValue, field))
+			return
+		}
+		values[i] = value
+	}
+	v.searchAfter = values
+}
+
+// VisitDistinct collapses the results on the field of a DistinctOn,
+// keeping the top hit of each value; only one field is supported. Distinct
+// alone is a no-op since documents are distinct by their _id.
+func (v *Visitor) VisitDistinct(fields []string) {
+	switch len(fields) {
+	case 0:
+	case 1:
+		v.collapse = v.mapField(fields[0])
+	default:
+		v.fail(fmt.Errorf("%w: collapsing on %d fields", specifications.ErrUnsupported, len(fields)))
+	}
+}
+
+// VisitJSONContains is not supported: object fields are flattened in the
+// index, so containment has no query equivalent.
+func (v *Visitor) VisitJSONContains(field string, doc interface{}) {
+	v.fail(fmt.Errorf("%w: JSON containment in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitJSONPathEqual matches the object subfield at path, e.g. a term query
+// on attributes.color. A nil value matches documents without it.
+func (v *Visitor) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	dbField := strings.Join(append([]string{v.mapField(field)}, path...), ".")
+	if value == nil {
+		v.addOn(dbField, mustNot(exists(dbField)))
+		return
+	}
+	v.addOn(dbField, leaf("term", dbField, value))
+}
+
+func (v *Visitor) VisitJSONKeyExists(field, key string) {
+	dbField := v.mapField(field) + "." + key
+	v.addOn(dbField, exists(dbField))
+}
+
+// VisitTextSearch uses a match query requiring every word of query, which
+// analyzes it like the field. language is ignored: the field's
+// analyzer, set in the index mapping, determines it.
+func (v *Visitor) VisitTextSearch(field, query, language string) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, leaf("match", dbField, map[string]interface{}{"query": query, "operator": "and"}))
+}
+
+// VisitTextRank is not supported: clauses are combined in filter context,
+// which does not compute relevance scores.
+func (v *Visitor) VisitTextRank(field, query, language string) {
+	v.fail(fmt.Errorf("%w: relevance ordering in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitSimilar is not supported: fuzzy matching in Elasticsearch depends
+// on the field's analyzer, e.g. an n-gram one, rather than a similarity
+// threshold.
+func (v *Visitor) VisitSimilar(field, value string, threshold float64) {
+	v.fail(fmt.Errorf("%w: trigram similarity in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitSimilarityOrder is not supported, like VisitTextRank.
+func (v *Visitor) VisitSimilarityOrder(field, value string) {
+	v.fail(fmt.Errorf("%w: similarity ordering in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitWithinRadius uses a geo_distance query on a geo_point field.
+func (v *Visitor) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, map[string]interface{}{"geo_distance": map[string]interface{}{
+		"distance": strconv.FormatFloat(meters, 'f', -1, 64) + "m",
+		dbField:    geoPoint(center),
+	}})
+}
+
+func (v *Visitor) VisitWithinBox(field string, box specifications.BoundingBox) {
+	dbField := v.mapField(field)
+	v.addOn(dbField, leaf("geo_bounding_box", dbField, map[string]interface{}{
+		"top_left":     geoPoint(specifications.Point{Lat: box.MaxLat, Lng: box.MinLng}),
+		"bottom_right": geoPoint(specifications.Point{Lat: box.MinLat, Lng: box.MaxLng}),
+	}))
+}
+
+// VisitOrderByDistance sorts by _geo_distance in meters, nearest first.
+// Its SearchAfter value is the distance, keyed by field.
+func (v *Visitor) VisitOrderByDistance(field string, from specifications.Point) {
+	dbField := v.mapField(field)
+	v.sort = append(v.sort, map[string]interface{}{"_geo_distance": map[string]interface{}{
+		dbField: geoPoint(from),
+		"order": "asc",
+		"unit":  "m",
+	}})
+	v.sortFields = append(v.sortFields, field)
+}
+
+// VisitRelated is not supported: documents are searched one index at a
+// time. Expand it with specifications.ExpandRelated first.
+func (v *Visitor) VisitRelated(relation string, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: related specification on %q in an Elasticsearch query", specifications.ErrUnsupported, relation))
+}
+
+func geoPoint(p specifications.Point) map[string]interface{} {
+	return map[string]interface{}{"lat": p.Lat, "lon": p.Lng}
+}
+
+// VisitLock is not supported: Elasticsearch has no row locks.
+func (v *Visitor) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	v.fail(fmt.Errorf("%w: row locking in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitAggregate is not supported, like VisitGroupBy.
+func (v *Visitor) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: aggregates in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitGroupBy is not supported: grouping maps to aggregations, which are
+// not part of the query translation.
+func (v *Visitor) VisitGroupBy(fields []string) {
+	v.fail(fmt.Errorf("%w: grouping in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitHaving is not supported, like VisitGroupBy.
+func (v *Visitor) VisitHaving(spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: grouping in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// VisitSample is not supported by the query DSL translation.
+func (v *Visitor) VisitSample(percent float64, method specifications.SampleMethod) {
+	v.fail(fmt.Errorf("%w: sampling in an Elasticsearch query", specifications.ErrUnsupported))
+}
+
+// Query returns the query clause. Multiple top-level clauses are combined in
+// a bool filter; no clauses yield match_all. Clauses on the same nested path
+// are combined within one nested query.
+func (v *Visitor) Query() map[string]interface{} {
+	var (
+		groups [][]map[string]interface{}
+		paths  []string
+		index  = map[string]int{}
+	)
+	for i, c := range v.clauses {
+		path := v.paths[i]
+		if j, ok := index[path]; ok && path != "" {
+			groups[j] = append(groups[j], c)
+			continue
+		}
+		index[path] = len(groups)
+		groups = append(groups, []map[string]interface{}{c})
+		paths = append(paths, path)
+	}
+	clauses := make([]map[string]interface{}, len(groups))
+	for j, g := range groups {
+		clauses[j] = v.nest(paths[j], combine(g))
+	}
+	return combine(clauses)
+}
+
+// combine returns the only clause, clauses combined in a bool filter, or
+// match_all without clauses.
+func combine(clauses []map[string]interface{}) map[string]interface{} {
+	switch len(clauses) {
+	case 0:
+		return map[string]interface{}{"match_all": map[string]interface{}{}}
+	case 1:
+		return clauses[0]
+	}
+	filter := make([]interface{}, len(clauses))
+	for i, c := range clauses {
+		filter[i] = c
+	}
+	return map[string]interface{}{"bool": map[string]interface{}{"filter": filter}}
+}
+
+// Source returns the full search request body including from, size, sort,
+// search_after and collapse.
+func (v *Visitor) Source() map[string]interface{} {
+	body := map[string]interface{}{"query": v.Query()}
+	if len(v.sort) > 0 {
+		body["sort"] = v.sort
+	}
+	if v.searchAfter != nil {
+		body["search_after"] = v.searchAfter
+	}
+	if v.collapse != "" {
+		body["collapse"] = map[string]interface{}{"field": v.collapse}
+	}
+	if v.limitSet {
+		body["size"] = v.limit
+	}
+	if v.offset > 0 {
+		body["from"] = v.offset
+	}
+	return body
+}
+
+// Build returns the JSON-encoded search request body, or the first error
+// reported while visiting.
+func (v *Visitor) Build() ([]byte, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	return json.Marshal(v.Source())
+}
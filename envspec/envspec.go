@@ -0,0 +1,142 @@
+// Package envspec builds specifications from environment variables and
+// command-line flags, so batch jobs can be parameterized with filters:
+//
+//	FILTER_STATUS__IN=active,trial FILTER_CREATED_AT__GTE=2024-01-01 FILTER_SORT=-created_at FILTER_LIMIT=100 ./job
+//	./job -filter status__in=active,trial -filter created_at__gte=2024-01-01 -sort -created_at -limit 100
+//
+// A key names a field, optionally followed by "__" and an httpspec operator
+// (EQ, NE, GT, GTE, LT, LTE, IN, NIN, LIKE, ILIKE, NULL), case-insensitively.
+// Environment keys spell fields in upper case with '_' for other characters
+// than letters and digits; SORT, LIMIT and OFFSET are reserved.
+package envspec
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/httpspec"
+)
+
+// FromEnv builds a specification from the environment variables starting
+// with prefix followed by '_', e.g. FILTER_STATUS__IN with prefix "FILTER".
+func FromEnv(prefix string, fields []string, opts ...httpspec.Option) (specifications.Specification, error) {
+	return FromEnviron(os.Environ(), prefix, fields, opts...)
+}
+
+// FromEnviron is like FromEnv for environ, a list of "key=value" entries as
+// returned by os.Environ.
+func FromEnviron(environ []string, prefix string, fields []string, opts ...httpspec.Option) (specifications.Specification, error) {
+	byKey := make(map[string]string, len(fields))
+	for _, f := range fields {
+		byKey[envKey(f)] = f
+	}
+
+	params := map[string][]string{}
+	for _, entry := range environ {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(key, prefix+"_") {
+			continue
+		}
+		key = strings.TrimPrefix(key, prefix+"_")
+		switch key {
+		case "SORT", "LIMIT", "OFFSET":
+			params[strings.ToLower(key)] = []string{value}
+			continue
+		}
+		name, op, _ := strings.Cut(key, "__")
+		field, ok := byKey[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s_%s", httpspec.ErrFieldNotAllowed, prefix, key)
+		}
+		param, err := filterParam(field, op)
+		if err != nil {
+			return nil, err
+		}
+		params[param] = append(params[param], value)
+	}
+	return httpspec.NewParser(fields, opts...).Parse(params)
+}
+
+// envKey spells field as an environment variable name.
+func envKey(field string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(field) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// filterParam returns the httpspec parameter for field and op.
+func filterParam(field, op string) (string, error) {
+	if op == "" {
+		return field, nil
+	}
+	if strings.Contains(op, "__") {
+		return "", fmt.Errorf("%w: operator %q on %q", httpspec.ErrOperatorNotAllowed, op, field)
+	}
+	return field + "[" + strings.ToLower(op) + "]", nil
+}
+
+// Flags collects filters from command-line flags registered on a FlagSet.
+type Flags struct {
+	fields []string
+	opts   []httpspec.Option
+	params map[string][]string
+	sort   string
+	limit  string
+	offset string
+}
+
+// NewFlags registers the -filter (repeatable, "field[__op]=value"), -sort,
+// -limit and -offset flags on fs. Call Spec after fs.Parse.
+func NewFlags(fs *flag.FlagSet, fields []string, opts ...httpspec.Option) *Flags {
+	f := &Flags{fields: fields, opts: opts, params: map[string][]string{}}
+	fs.Var(filterFlag{f}, "filter", "filter as field[__op]=value, e.g. status__in=a,b (repeatable)")
+	fs.StringVar(&f.sort, "sort", "", "comma-separated sort fields, descending when prefixed with '-'")
+	fs.StringVar(&f.limit, "limit", "", "maximum number of results")
+	fs.StringVar(&f.offset, "offset", "", "number of results to skip")
+	return f
+}
+
+// Spec returns the specification described by the parsed flags.
+func (f *Flags) Spec() (specifications.Specification, error) {
+	params := make(map[string][]string, len(f.params)+3)
+	for k, v := range f.params {
+		params[k] = v
+	}
+	for k, v := range map[string]string{"sort": f.sort, "limit": f.limit, "offset": f.offset} {
+		if v != "" {
+			params[k] = []string{v}
+		}
+	}
+	return httpspec.NewParser(f.fields, f.opts...).Parse(params)
+}
+
+type filterFlag struct {
+	f *Flags
+}
+
+func (ff filterFlag) String() string {
+	return ""
+}
+
+func (ff filterFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%w: %q is not field[__op]=value", httpspec.ErrInvalidParam, s)
+	}
+	field, op, _ := strings.Cut(key, "__")
+	param, err := filterParam(field, op)
+	if err != nil {
+		return err
+	}
+	ff.f.params[param] = append(ff.f.params[param], value)
+	return nil
+}
@@ -0,0 +1,352 @@
+package specifications
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EqualSpecs reports whether a and b are structurally equal: the same
+// conditions on the same fields and values, and the same ordering,
+// pagination and other clauses. The children of And and Or, and the values
+// of In, NotIn and the array conditions, may be in any order; only that of
+// orderings, and of repeated clauses such as Limit, matters. Numbers are
+// compared by value, whatever their type, so that specifications decoded
+// from JSON equal the ones they were encoded from, and times by instant.
+// Descriptions are ignored.
+func EqualSpecs(a, b Specification) bool {
+	return canonical(a) == canonical(b)
+}
+
+// Hash returns a stable digest of spec, equal for specifications EqualSpecs
+// reports equal, e.g. to deduplicate saved filters or key caches by
+// specification. It is the hexadecimal SHA-256 of a canonical form of spec.
+func Hash(spec Specification) string {
+	sum := sha256.Sum256([]byte(canonical(spec)))
+	return hex.EncodeToString(sum[:])
+}
+
+// canonical returns the canonical form of spec, "" for nil.
+func canonical(spec Specification) string {
+	if spec == nil {
+		return ""
+	}
+	return canonicalComposite("and", []Specification{spec})
+}
+
+// canonicalComposite returns the canonical form of the composite op, "and" or "or",
+// of specs.
+func canonicalComposite(op string, specs []Specification) string {
+	c := &canonicalizer{op: op}
+	for _, s := range specs {
+		s.Accept(c)
+	}
+	var conditions []string
+	var clauses []canonicalNode
+	for _, n := range c.out {
+		if n.clause != "" && op == "and" {
+			clauses = append(clauses, n)
+		} else {
+			conditions = append(conditions, n.text)
+		}
+	}
+	slices.Sort(conditions)
+	slices.SortStableFunc(clauses, func(a, b canonicalNode) int {
+		return strings.Compare(a.clause, b.clause)
+	})
+	terms := conditions
+	for _, n := range clauses {
+		terms = append(terms, n.text)
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return op + "(" + strings.Join(terms, ",") + ")"
+}
+
+// canonicalizer is a SpecificationVisitor writing the canonical forms of the
+// specifications visited within the composite op. Nested composites of the
+// same op are flattened, as And and Or are associative.
+type canonicalizer struct {
+	op  string
+	out []canonicalNode
+}
+
+type canonicalNode struct {
+	text string
+	// clause is the kind of the ordering, pagination and other clauses,
+	// "" for conditions. The order of clauses of the same kind is kept in
+	// conjunctions.
+	clause string
+}
+
+func (c *canonicalizer) add(op string, args ...string) {
+	c.out = append(c.out, canonicalNode{text: op + "(" + strings.Join(args, ",") + ")"})
+}
+
+// addClause adds a clause of kind, e.g. "order" for all the orderings.
+func (c *canonicalizer) addClause(kind, op string, args ...string) {
+	c.add(op, args...)
+	c.out[len(c.out)-1].clause = kind
+}
+
+func (c *canonicalizer) child(spec Specification) string {
+	return canonicalComposite("and", []Specification{spec})
+}
+
+func (c *canonicalizer) VisitAnd(specs []Specification) {
+	c.visitComposite("and", specs)
+}
+
+func (c *canonicalizer) VisitOr(specs []Specification) {
+	c.visitComposite("or", specs)
+}
+
+func (c *canonicalizer) visitComposite(op string, specs []Specification) {
+	if op != c.op {
+		c.out = append(c.out, canonicalNode{text: canonicalComposite(op, specs)})
+		return
+	}
+	for _, s := range specs {
+		s.Accept(c)
+	}
+}
+
+func (c *canonicalizer) VisitNot(spec Specification) {
+	c.add("not", c.child(spec))
+}
+
+func (c *canonicalizer) VisitHaving(spec Specification) {
+	c.addClause("having", "having", c.child(spec))
+}
+
+func (c *canonicalizer) VisitAggregate(function AggregateFunction, spec Specification) {
+	// Aggregates are conditions on groups, within Having, or orderings.
+	sub := &canonicalizer{op: "and"}
+	spec.Accept(sub)
+	for _, n := range sub.out {
+		if n.clause == "" {
+			c.add("aggregate", strconv.Quote(string(function)), c.child(spec))
+			return
+		}
+	}
+	c.addClause("order", "aggregate", strconv.Quote(string(function)), c.child(spec))
+}
+
+func (c *canonicalizer) VisitRelated(relation string, spec Specification) {
+	c.add("related", strconv.Quote(relation), c.child(spec))
+}
+
+func (c *canonicalizer) VisitEqual(field string, value interface{}) {
+	c.add("eq", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitNotEqual(field string, value interface{}) {
+	c.add("ne", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitIn(field string, values []interface{}) {
+	c.add("in", strconv.Quote(field), canonicalSet(values))
+}
+
+func (c *canonicalizer) VisitNotIn(field string, values []interface{}) {
+	c.add("nin", strconv.Quote(field), canonicalSet(values))
+}
+
+func (c *canonicalizer) VisitGreaterThan(field string, value interface{}) {
+	c.add("gt", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitGreaterThanOrEqual(field string, value interface{}) {
+	c.add("gte", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitLowerThan(field string, value interface{}) {
+	c.add("lt", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitLowerThanOrEqual(field string, value interface{}) {
+	c.add("lte", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitBetween(field string, low, high interface{}) {
+	c.add("between", strconv.Quote(field), canonicalValue(low), canonicalValue(high))
+}
+
+func (c *canonicalizer) VisitIsNull(field string) {
+	c.add("null", strconv.Quote(field))
+}
+
+func (c *canonicalizer) VisitIsNotNull(field string) {
+	c.add("notnull", strconv.Quote(field))
+}
+
+func (c *canonicalizer) VisitLike(field string, value interface{}) {
+	c.add("like", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitILike(field string, value interface{}) {
+	c.add("ilike", strconv.Quote(field), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitLikeEscaped(field string, pattern string) {
+	c.add("like_escaped", strconv.Quote(field), strconv.Quote(pattern))
+}
+
+func (c *canonicalizer) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	c.add("regex", strconv.Quote(field), strconv.Quote(pattern), strconv.FormatBool(caseInsensitive))
+}
+
+func (c *canonicalizer) VisitJSONContains(field string, doc interface{}) {
+	c.add("json_contains", strconv.Quote(field), canonicalValue(doc))
+}
+
+func (c *canonicalizer) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	c.add("json_path_eq", strconv.Quote(field), quoteAll(path), canonicalValue(value))
+}
+
+func (c *canonicalizer) VisitJSONKeyExists(field, key string) {
+	c.add("json_key_exists", strconv.Quote(field), strconv.Quote(key))
+}
+
+func (c *canonicalizer) VisitArrayContains(field string, values []interface{}) {
+	c.add("array_contains", strconv.Quote(field), canonicalSet(values))
+}
+
+func (c *canonicalizer) VisitArrayOverlaps(field string, values []interface{}) {
+	c.add("array_overlaps", strconv.Quote(field), canonicalSet(values))
+}
+
+func (c *canonicalizer) VisitEqualAny(field string, values []interface{}) {
+	c.add("equal_any", strconv.Quote(field), canonicalSet(values))
+}
+
+func (c *canonicalizer) VisitTextSearch(field, query, language string) {
+	c.add("text_search", strconv.Quote(field), strconv.Quote(query), strconv.Quote(language))
+}
+
+func (c *canonicalizer) VisitSimilar(field, value string, threshold float64) {
+	c.add("similar", strconv.Quote(field), strconv.Quote(value), canonicalValue(threshold))
+}
+
+func (c *canonicalizer) VisitWithinRadius(field string, center Point, meters float64) {
+	c.add("within_radius", strconv.Quote(field), canonicalValue(center.Lat), canonicalValue(center.Lng), canonicalValue(meters))
+}
+
+func (c *canonicalizer) VisitWithinBox(field string, box BoundingBox) {
+	c.add("within_box", strconv.Quote(field), canonicalValue(box.MinLat), canonicalValue(box.MinLng), canonicalValue(box.MaxLat), canonicalValue(box.MaxLng))
+}
+
+func (c *canonicalizer) VisitLimit(limit int) {
+	c.addClause("limit", "limit", strconv.Itoa(limit))
+}
+
+func (c *canonicalizer) VisitOffset(offset int) {
+	c.addClause("offset", "offset", strconv.Itoa(offset))
+}
+
+func (c *canonicalizer) VisitOrder(field, direction string) {
+	c.VisitOrderNulls(field, direction, NullsDefault)
+}
+
+func (c *canonicalizer) VisitOrderNulls(field, direction string, nulls NullsPosition) {
+	c.addClause("order", "order", strconv.Quote(field), strconv.Quote(strings.ToUpper(direction)), strconv.Quote(string(nulls)))
+}
+
+func (c *canonicalizer) VisitDistinct(fields []string) {
+	c.addClause("distinct", "distinct", quoteAll(fields))
+}
+
+func (c *canonicalizer) VisitGroupBy(fields []string) {
+	c.addClause("group_by", "group_by", quoteAll(fields))
+}
+
+func (c *canonicalizer) VisitLock(strength LockStrength, wait LockWait) {
+	c.addClause("lock", "lock", strconv.Quote(string(strength)), strconv.Quote(string(wait)))
+}
+
+func (c *canonicalizer) VisitSample(percent float64, method SampleMethod) {
+	c.addClause("sample", "sample", canonicalValue(percent), strconv.Quote(string(method)))
+}
+
+func (c *canonicalizer) VisitTextRank(field, query, language string) {
+	c.addClause("order", "text_rank", strconv.Quote(field), strconv.Quote(query), strconv.Quote(language))
+}
+
+func (c *canonicalizer) VisitSimilarityOrder(field, value string) {
+	c.addClause("order", "similarity_order", strconv.Quote(field), strconv.Quote(value))
+}
+
+func (c *canonicalizer) VisitOrderByDistance(field string, from Point) {
+	c.addClause("order", "distance_order", strconv.Quote(field), canonicalValue(from.Lat), canonicalValue(from.Lng))
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[" + strings.Join(quoted, ",") + "]"
+}
+
+// canonicalSet writes values sorted, as a set.
+func canonicalSet(values []interface{}) string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = canonicalValue(v)
+	}
+	slices.Sort(out)
+	return "{" + strings.Join(slices.Compact(out), ",") + "}"
+}
+
+// canonicalValue writes numbers by value, times as UTC instants, and other
+// values with their type.
+func canonicalValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return "time:" + v.UTC().Format(time.RFC3339Nano)
+	case []interface{}:
+		out := make([]string, len(v))
+		for i, e := range v {
+			out[i] = canonicalValue(e)
+		}
+		return "[" + strings.Join(out, ",") + "]"
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return strconv.Quote(rv.String())
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			return strconv.FormatInt(int64(f), 10)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		// Documents, e.g. of JSONContains, compare as JSON, whose object
+		// keys are sorted.
+		if data, err := json.Marshal(value); err == nil {
+			return "json:" + string(data)
+		}
+	}
+	return fmt.Sprintf("%T:%#v", value, value)
+}
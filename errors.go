@@ -0,0 +1,38 @@
+package specifications
+
+import "errors"
+
+var (
+	// ErrUnsupported is reported by visitors that cannot translate a
+	// specification or operator.
+	ErrUnsupported = errors.New("specifications: unsupported specification")
+	// ErrInvalidField is reported for empty or otherwise unusable field names.
+	ErrInvalidField = errors.New("specifications: invalid field")
+	// ErrInvalidValue is reported when a value cannot be used with its operator.
+	ErrInvalidValue = errors.New("specifications: invalid value")
+	// ErrEmptyComposite is reported for And or Or without children when
+	// visitors are configured with EmptyCompositeError.
+	ErrEmptyComposite = errors.New("specifications: empty composite specification")
+	// ErrMergeConflict is reported by Merge with MergeError for fields both
+	// merged specifications have conditions on.
+	ErrMergeConflict = errors.New("specifications: conflicting conditions")
+)
+
+// ErrorReporter is implemented by visitors that can fail while translating a
+// specification. Visit methods record the first failure instead of producing
+// a broken result, and Err returns it.
+type ErrorReporter interface {
+	Err() error
+}
+
+// Apply lets v visit spec and returns the error reported by v, if any. A nil
+// spec is a no-op. Visitors that do not implement ErrorReporter never fail.
+func Apply(spec Specification, v SpecificationVisitor) error {
+	if spec != nil {
+		spec.Accept(v)
+	}
+	if r, ok := v.(ErrorReporter); ok {
+		return r.Err()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package specifications
+
+// TypedField is a domain field whose values have type T. Its methods build
+// the regular untyped specifications, so every visitor understands them, but
+// the compiler checks the values passed in.
+type TypedField[T any] struct {
+	name string
+}
+
+// Field declares a typed domain field, e.g.
+//
+//	var Price = specifications.Field[float64]("Price")
+//	spec := Price.Gt(100)
+func Field[T any](name string) TypedField[T] {
+	return TypedField[T]{name: name}
+}
+
+// Name returns the domain field name.
+func (f TypedField[T]) Name() string {
+	return f.name
+}
+
+func (f TypedField[T]) Eq(value T) Specification {
+	return Equal(f.name, value)
+}
+
+func (f TypedField[T]) Ne(value T) Specification {
+	return NotEqual(f.name, value)
+}
+
+func (f TypedField[T]) Gt(value T) Specification {
+	return GreaterThan(f.name, value)
+}
+
+func (f TypedField[T]) Gte(value T) Specification {
+	return GreaterThanOrEqual(f.name, value)
+}
+
+func (f TypedField[T]) Lt(value T) Specification {
+	return LowerThan(f.name, value)
+}
+
+func (f TypedField[T]) Lte(value T) Specification {
+	return LowerThanOrEqual(f.name, value)
+}
+
+func (f TypedField[T]) Between(low, high T) Specification {
+	return Between(f.name, low, high)
+}
+
+func (f TypedField[T]) In(values ...T) Specification {
+	return In(f.name, toInterfaces(values)...)
+}
+
+func (f TypedField[T]) NotIn(values ...T) Specification {
+	return NotIn(f.name, toInterfaces(values)...)
+}
+
+func (f TypedField[T]) IsNull() Specification {
+	return IsNull(f.name)
+}
+
+func (f TypedField[T]) IsNotNull() Specification {
+	return IsNotNull(f.name)
+}
+
+func (f TypedField[T]) OrderBy(direction string) Specification {
+	return OrderBy(f.name, direction)
+}
+
+func toInterfaces[T any](values []T) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// InFrom builds an In specification from values selected out of items, e.g.
+// the IDs of previously loaded aggregates:
+//
+//	specifications.InFrom("CustomerID", orders, func(o Order) string { return o.CustomerID })
+func InFrom[T any, V any](field string, items []T, selector func(T) V) Specification {
+	return In(field, selectInterfaces(items, selector)...)
+}
+
+// NotInFrom is the NotIn counterpart of InFrom.
+func NotInFrom[T any, V any](field string, items []T, selector func(T) V) Specification {
+	return NotIn(field, selectInterfaces(items, selector)...)
+}
+
+func selectInterfaces[T any, V any](items []T, selector func(T) V) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = selector(item)
+	}
+	return out
+}
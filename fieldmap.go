@@ -0,0 +1,80 @@
+package specifications
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// FieldMap maps domain fields to storage fields, such as columns, as taken
+// by the visitors.
+type FieldMap map[string]string
+
+// FieldMapFromStruct derives the field map of the struct v, or of the
+// struct v points to, from the struct tag tag of its fields, e.g. "db" or
+// "json": each exported field maps from its name to the tag's name, the
+// part before any comma.
+//
+//	type Order struct {
+//		ID     string `db:"id"`
+//		Status string `db:"status"`
+//	}
+//
+//	visitor := postgres.NewVisitor(specifications.FieldMapFromStruct(Order{}, "db"))
+//
+// Fields without the tag or a name in it, or tagged "-", are left out; the fields of
+// untagged embedded structs are included as the struct's own. It panics
+// when v is not a struct, as it is meant for package initialization.
+func FieldMapFromStruct(v any, tag string) FieldMap {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("specifications: FieldMapFromStruct of %T, not a struct", v))
+	}
+	m := FieldMap{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || !flattened(t, f.Index, tag) {
+			continue
+		}
+		value, ok := f.Tag.Lookup(tag)
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(value, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		m[f.Name] = name
+	}
+	return m
+}
+
+// flattened reports whether the embedded structs leading to the field at
+// index, if any, are untagged, so that their fields are included.
+func flattened(t reflect.Type, index []int, tag string) bool {
+	for _, i := range index[:len(index)-1] {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup(tag); ok {
+			return false
+		}
+		t = f.Type
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+	return true
+}
+
+// With returns a copy of m with the entries of overrides added or
+// replacing its own, e.g. for computed columns.
+func (m FieldMap) With(overrides map[string]string) FieldMap {
+	out := maps.Clone(m)
+	if out == nil {
+		out = FieldMap{}
+	}
+	maps.Copy(out, overrides)
+	return out
+}
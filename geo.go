@@ -0,0 +1,65 @@
+package specifications
+
+// Point is a location in WGS 84 (SRID 4326) latitude and longitude degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// BoundingBox is the area between two parallels and two meridians. It does
+// not cross the antimeridian: MinLng is west of MaxLng.
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+type withinRadiusSpec struct {
+	field  string
+	center Point
+	meters float64
+}
+
+func (s *withinRadiusSpec) Accept(v SpecificationVisitor) {
+	v.VisitWithinRadius(s.field, s.center, s.meters)
+}
+
+type withinBoxSpec struct {
+	field string
+	box   BoundingBox
+}
+
+func (s *withinBoxSpec) Accept(v SpecificationVisitor) {
+	v.VisitWithinBox(s.field, s.box)
+}
+
+type distanceOrderSpec struct {
+	field string
+	from  Point
+}
+
+func (s *distanceOrderSpec) Accept(v SpecificationVisitor) {
+	v.VisitOrderByDistance(s.field, s.from)
+}
+
+// WithinRadius matches entities whose location field is at most meters
+// away from the point at lat, lng. In PostgreSQL, field is a PostGIS
+// geography column:
+//
+//	ST_DWithin(field, ST_MakePoint(lng, lat)::geography, meters)
+func WithinRadius(field string, lat, lng, meters float64) Specification {
+	return &withinRadiusSpec{field: field, center: Point{Lat: lat, Lng: lng}, meters: meters}
+}
+
+// WithinBoundingBox matches entities whose location field is in box, e.g.
+// the visible area of a map.
+func WithinBoundingBox(field string, box BoundingBox) Specification {
+	return &withinBoxSpec{field: field, box: box}
+}
+
+// OrderByDistance orders by increasing distance of field from the point at
+// lat, lng, nearest first.
+func OrderByDistance(field string, lat, lng float64) Specification {
+	return &distanceOrderSpec{field: field, from: Point{Lat: lat, Lng: lng}}
+}
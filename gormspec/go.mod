@@ -0,0 +1,16 @@
+module github.com/thefabric-io/specifications/gormspec
+
+go 1.23.4
+
+require (
+	github.com/thefabric-io/specifications v0.0.0-00010101000000-000000000000
+	gorm.io/gorm v1.30.0
+)
+
+require (
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	golang.org/x/text v0.24.0 // indirect
+)
+
+replace github.com/thefabric-io/specifications => ../
@@ -0,0 +1,669 @@
+// Package gormspec applies specifications to GORM queries.
+//
+//	db.Scopes(gormspec.Scope(spec, fieldMap)).Find(&products)
+//
+// Conditions are built from GORM clause expressions, so column quoting and
+// placeholders follow the dialect of the *gorm.DB.
+package gormspec
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/internal/jsondoc"
+	"github.com/thefabric-io/specifications/internal/pgarray"
+	"github.com/thefabric-io/specifications/internal/sqlsafe"
+)
+
+// Scope returns a GORM scope applying the conditions, ordering and
+// pagination of spec. Errors reported while visiting are added to the
+// *gorm.DB, which then fails on execution.
+func Scope(spec specifications.Specification, fieldMap map[string]string, opts ...Option) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		v := NewVisitor(fieldMap, opts...)
+		if err := specifications.Apply(spec, v); err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		return v.Apply(db)
+	}
+}
+
+// Visitor translates specifications into GORM clause expressions.
+type Visitor struct {
+	exprs    []clause.Expression
+	fieldMap map[string]string
+	orders   []order
+	distinct bool
+	groups   []clause.Column
+	havings  []clause.Expression
+	lock     *clause.Locking
+	limit    int
+	offset   int
+	empty    specifications.EmptyComposite
+	// aggregate is applied to the columns while visiting the specification
+	// of an Aggregated.
+	aggregate specifications.AggregateFunction
+	// inHaving is set while visiting the specification of a Having, whose
+	// conditions may be on aggregates.
+	inHaving bool
+	// limitSet and offsetSet tell a visited Limit or Offset of zero from
+	// none.
+	limitSet, offsetSet bool
+	// hardened validates the mapped columns, as sqlspec.WithHardening.
+	hardened bool
+	err      error
+}
+
+// order is an ORDER BY column, optionally preceded by the placement of its
+// NULLs.
+type order struct {
+	clause.OrderByColumn
+	nulls specifications.NullsPosition
+	// expr is an ordering expression binding variables, e.g. a relevance
+	// rank, which replaces OrderByColumn.
+	expr *clause.Expr
+}
+
+// Option configures a Visitor.
+type Option func(*Visitor)
+
+// WithEmptyComposite sets how And and Or without children are translated.
+// The default is specifications.EmptyCompositeSkip.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return func(v *Visitor) {
+		v.empty = mode
+	}
+}
+
+// WithHardening validates every mapped column against a strict identifier
+// grammar. Invalid input is reported by Err and added to the *gorm.DB by
+// Apply, which then fails on execution. Hardening is on by default when
+// built with the specifications_hardened tag.
+func WithHardening() Option {
+	return func(v *Visitor) {
+		v.hardened = true
+	}
+}
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	v := &Visitor{
+		exprs:    []clause.Expression{},
+		fieldMap: fieldMap,
+		orders:   []order{},
+		hardened: sqlsafe.HardenedByDefault,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// child returns an empty visitor sharing v's configuration, used for
+// composite specifications.
+func (v *Visitor) child() *Visitor {
+	sub := NewVisitor(v.fieldMap, WithEmptyComposite(v.empty))
+	sub.hardened = v.hardened
+	sub.aggregate = v.aggregate
+	sub.inHaving = v.inHaving
+	return sub
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (v *Visitor) Err() error {
+	return v.err
+}
+
+func (v *Visitor) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+// column maps a domain field to a GORM column; "table.column" mappings are
+// split so both parts get quoted. Aggregated columns are raw, hence not
+// quoted.
+func (v *Visitor) column(domainField string) clause.Column {
+	if v.aggregate == specifications.AggregateCount && domainField == "*" {
+		return clause.Column{Name: "COUNT(*)", Raw: true}
+	}
+	if domainField == "" {
+		v.fail(specifications.ErrInvalidField)
+	}
+	dbField := domainField
+	if mapped, ok := v.fieldMap[domainField]; ok {
+		dbField = mapped
+	}
+	if v.hardened && !sqlsafe.Identifier(dbField) {
+		v.fail(fmt.Errorf("%w: %q is not a valid identifier", specifications.ErrInvalidField, dbField))
+	}
+	if v.aggregate != "" {
+		return clause.Column{Name: aggregated(v.aggregate, dbField), Raw: true}
+	}
+	if table, name, ok := strings.Cut(dbField, "."); ok {
+		return clause.Column{Table: table, Name: name}
+	}
+	return clause.Column{Name: dbField}
+}
+
+func (v *Visitor) add(expr clause.Expression) {
+	v.exprs = append(v.exprs, expr)
+}
+
+func (v *Visitor) VisitEqual(field string, value interface{}) {
+	v.add(clause.Eq{Column: v.column(field), Value: value})
+}
+
+func (v *Visitor) VisitNotEqual(field string, value interface{}) {
+	v.add(clause.Neq{Column: v.column(field), Value: value})
+}
+
+func (v *Visitor) VisitIn(field string, values []interface{}) {
+	v.add(clause.IN{Column: v.column(field), Values: values})
+}
+
+func (v *Visitor) VisitNotIn(field string, values []interface{}) {
+	col := v.column(field)
+	if len(values) == 0 {
+		v.add(clause.Expr{SQL: "1=1"})
+		return
+	}
+	v.add(clause.Not(clause.IN{Column: col, Values: values}))
+}
+
+// VisitEqualAny binds values as one PostgreSQL array with = ANY(?).
+func (v *Visitor) VisitEqualAny(field string, values []interface{}) {
+	if len(values) == 0 {
+		v.VisitIn(field, values)
+		return
+	}
+	v.add(clause.Expr{SQL: "? = ANY(?)", Vars: []interface{}{v.column(field), pgarray.Array(values)}})
+}
+
+// VisitArrayContains uses the PostgreSQL array containment operator.
+func (v *Visitor) VisitArrayContains(field string, values []interface{}) {
+	v.add(clause.Expr{SQL: "? @> ?", Vars: []interface{}{v.column(field), pgarray.Array(values)}})
+}
+
+// VisitArrayOverlaps uses the PostgreSQL array overlap operator.
+func (v *Visitor) VisitArrayOverlaps(field string, values []interface{}) {
+	v.add(clause.Expr{SQL: "? && ?", Vars: []interface{}{v.column(field), pgarray.Array(values)}})
+}
+
+func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
+	v.add(clause.Gt{Column: v.column(field), Value: value})
+}
+
+func (v *Visitor) VisitLowerThan(field string, value interface{}) {
+	v.add(clause.Lt{Column: v.column(field), Value: value})
+}
+
+func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
+	v.add(clause.Gte{Column: v.column(field), Value: value})
+}
+
+func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
+	v.add(clause.Lte{Column: v.column(field), Value: value})
+}
+
+func (v *Visitor) VisitBetween(field string, low, high interface{}) {
+	v.add(clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{v.column(field), low, high}})
+}
+
+func (v *Visitor) VisitIsNull(field string) {
+	v.add(clause.Eq{Column: v.column(field), Value: nil})
+}
+
+func (v *Visitor) VisitIsNotNull(field string) {
+	v.add(clause.Neq{Column: v.column(field), Value: nil})
+}
+
+func (v *Visitor) VisitLike(field string, value interface{}) {
+	v.add(clause.Like{Column: v.column(field), Value: value})
+}
+
+// VisitILike uses LOWER() on both sides so it works on every dialect.
+func (v *Visitor) VisitILike(field string, value interface{}) {
+	v.add(clause.Expr{SQL: "LOWER(?) LIKE LOWER(?)", Vars: []interface{}{v.column(field), value}})
+}
+
+func (v *Visitor) VisitLikeEscaped(field string, pattern string) {
+	v.add(clause.Expr{SQL: `? LIKE ? ESCAPE '\'`, Vars: []interface{}{v.column(field), pattern}})
+}
+
+// VisitRegex uses the PostgreSQL regular expression operators.
+func (v *Visitor) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	op := "? ~ ?"
+	if caseInsensitive {
+		op = "? ~* ?"
+	}
+	v.add(clause.Expr{SQL: op, Vars: []interface{}{v.column(field), pattern}})
+}
+
+// VisitJSONContains uses the PostgreSQL jsonb containment operator.
+func (v *Visitor) VisitJSONContains(field string, doc interface{}) {
+	column := v.column(field)
+	text, err := jsondoc.Encode(doc)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.add(clause.Expr{SQL: "? @> ?::jsonb", Vars: []interface{}{column, text}})
+}
+
+// VisitJSONPathEqual compares the text at path, extracted with the
+// PostgreSQL jsonb operators, with the text form of value.
+func (v *Visitor) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	vars := []interface{}{v.column(field)}
+	for _, key := range path {
+		vars = append(vars, key)
+	}
+	extract := "(? ->> ?)"
+	if len(path) != 1 {
+		extract = "(? #>> ARRAY[" + strings.TrimSuffix(strings.Repeat("?, ", len(path)), ", ") + "]::text[])"
+	}
+	if value == nil {
+		v.add(clause.Expr{SQL: extract + " IS NULL", Vars: vars})
+		return
+	}
+	text, err := jsondoc.Text(value)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.add(clause.Expr{SQL: extract + " = ?", Vars: append(vars, text)})
+}
+
+// VisitJSONKeyExists uses jsonb_exists rather than the ? operator, which
+// GORM would take for a placeholder.
+func (v *Visitor) VisitJSONKeyExists(field, key string) {
+	v.add(clause.Expr{SQL: "jsonb_exists(?, ?)", Vars: []interface{}{v.column(field), key}})
+}
+
+// VisitTextSearch uses the PostgreSQL full-text search functions.
+func (v *Visitor) VisitTextSearch(field, query, language string) {
+	if !v.textSearchConfig(language) {
+		return
+	}
+	v.add(clause.Expr{SQL: tsvector(language) + " @@ " + tsquery(language), Vars: []interface{}{v.column(field), query}})
+}
+
+// VisitTextRank orders by the PostgreSQL ts_rank of the rows, most relevant
+// first.
+func (v *Visitor) VisitTextRank(field, query, language string) {
+	if !v.textSearchConfig(language) {
+		return
+	}
+	v.orders = append(v.orders, order{expr: &clause.Expr{
+		SQL:  "ts_rank(" + tsvector(language) + ", " + tsquery(language) + ") DESC",
+		Vars: []interface{}{v.column(field), query},
+	}})
+}
+
+// VisitSimilar uses the pg_trgm similarity operator, or compares the
+// similarity score with threshold.
+func (v *Visitor) VisitSimilar(field, value string, threshold float64) {
+	switch {
+	case threshold < 0 || threshold > 1:
+		v.fail(fmt.Errorf("%w: similarity threshold %v", specifications.ErrInvalidValue, threshold))
+	case threshold == 0:
+		v.add(clause.Expr{SQL: "? % ?", Vars: []interface{}{v.column(field), value}})
+	default:
+		v.add(clause.Expr{SQL: "similarity(?, ?) > ?", Vars: []interface{}{v.column(field), value, threshold}})
+	}
+}
+
+// VisitSimilarityOrder orders by the pg_trgm similarity, most similar
+// first, like VisitTextRank.
+func (v *Visitor) VisitSimilarityOrder(field, value string) {
+	v.orders = append(v.orders, order{expr: &clause.Expr{
+		SQL:  "similarity(?, ?) DESC",
+		Vars: []interface{}{v.column(field), value},
+	}})
+}
+
+// VisitWithinRadius uses PostGIS on a geography column.
+func (v *Visitor) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	v.add(clause.Expr{SQL: "ST_DWithin(?, ST_MakePoint(?, ?)::geography, ?)", Vars: []interface{}{v.column(field), center.Lng, center.Lat, meters}})
+}
+
+func (v *Visitor) VisitWithinBox(field string, box specifications.BoundingBox) {
+	v.add(clause.Expr{
+		SQL:  "ST_Intersects(?, ST_MakeEnvelope(?, ?, ?, ?, 4326)::geography)",
+		Vars: []interface{}{v.column(field), box.MinLng, box.MinLat, box.MaxLng, box.MaxLat},
+	})
+}
+
+// VisitOrderByDistance orders by the PostGIS distance operator, nearest
+// first.
+func (v *Visitor) VisitOrderByDistance(field string, from specifications.Point) {
+	v.orders = append(v.orders, order{expr: &clause.Expr{
+		SQL:  "? <-> ST_MakePoint(?, ?)::geography",
+		Vars: []interface{}{v.column(field), from.Lng, from.Lat},
+	}})
+}
+
+// VisitRelated is not supported. Expand it with
+// specifications.ExpandRelated first.
+func (v *Visitor) VisitRelated(relation string, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: related specification on %q", specifications.ErrUnsupported, relation))
+}
+
+// textSearchConfig reports whether language is empty or a valid text search
+// configuration name, which is written in the query rather than bound.
+func (v *Visitor) textSearchConfig(language string) bool {
+	if language != "" && !sqlsafe.Identifier(language) {
+		v.fail(fmt.Errorf("%w: text search configuration %q", specifications.ErrInvalidValue, language))
+		return false
+	}
+	return true
+}
+
+func tsvector(language string) string {
+	if language == "" {
+		return "to_tsvector(?)"
+	}
+	return "to_tsvector('" + language + "', ?)"
+}
+
+func tsquery(language string) string {
+	if language == "" {
+		return "plainto_tsquery(?)"
+	}
+	return "plainto_tsquery('" + language + "', ?)"
+}
+
+func (v *Visitor) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(clause.Expr{SQL: "1=1"})
+		return
+	}
+
+	subVisitor := v.child()
+
+	for _, s := range specs {
+		s.Accept(subVisitor)
+	}
+
+	if len(subVisitor.exprs) > 0 {
+		v.add(clause.And(subVisitor.exprs...))
+	}
+
+	v.merge(subVisitor)
+}
+
+func (v *Visitor) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(clause.Expr{SQL: "1=0"})
+		return
+	}
+
+	orParts := []clause.Expression{}
+
+	for _, s := range specs {
+		temp := v.child()
+
+		s.Accept(temp)
+
+		if len(temp.exprs) > 0 {
+			orParts = append(orParts, clause.And(temp.exprs...))
+		}
+
+		v.merge(temp)
+	}
+
+	if len(orParts) > 0 {
+		v.add(clause.Or(orParts...))
+	}
+}
+
+func (v *Visitor) VisitNot(spec specifications.Specification) {
+	sub := v.child()
+	spec.Accept(sub)
+	if len(sub.exprs) > 0 {
+		// clause.Not negates each condition of a conjunction separately,
+		// so the negation is spelled out instead.
+		v.add(clause.Expr{SQL: "NOT (?)", Vars: []interface{}{clause.And(sub.exprs...)}})
+	}
+	v.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being the
+// expression equivalent to its logical identity.
+func (v *Visitor) visitEmpty(identity clause.Expression) {
+	switch v.empty {
+	case specifications.EmptyCompositeIdentity:
+		v.add(identity)
+	case specifications.EmptyCompositeError:
+		v.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but expressions over from a sub-visitor.
+func (v *Visitor) merge(sub *Visitor) {
+	v.orders = append(v.orders, sub.orders...)
+	v.distinct = v.distinct || sub.distinct
+	if sub.lock != nil {
+		v.lock = sub.lock
+	}
+	v.groups = append(v.groups, sub.groups...)
+	v.havings = append(v.havings, sub.havings...)
+
+	if sub.limitSet {
+		v.limit, v.limitSet = sub.limit, true
+	}
+
+	if sub.offsetSet {
+		v.offset, v.offsetSet = sub.offset, true
+	}
+
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+}
+
+func (v *Visitor) VisitLimit(limit int) {
+	if limit < 0 {
+		v.fail(fmt.Errorf("%w: negative limit %d", specifications.ErrInvalidValue, limit))
+		return
+	}
+	v.limit, v.limitSet = limit, true
+}
+
+func (v *Visitor) VisitOffset(offset int) {
+	if offset < 0 {
+		v.fail(fmt.Errorf("%w: negative offset %d", specifications.ErrInvalidValue, offset))
+		return
+	}
+	v.offset, v.offsetSet = offset, true
+}
+
+func (v *Visitor) VisitOrder(field, direction string) {
+	v.VisitOrderNulls(field, direction, specifications.NullsDefault)
+}
+
+// VisitOrderNulls orders by whether field is NULL first, which is portable
+// across databases unlike NULLS FIRST / NULLS LAST.
+func (v *Visitor) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	col := v.column(field)
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	if _, err := specifications.ParseNullsPosition(string(nulls)); err != nil {
+		v.fail(err)
+		return
+	}
+	v.orders = append(v.orders, order{
+		OrderByColumn: clause.OrderByColumn{Column: col, Desc: d == specifications.Descending},
+		nulls:         nulls,
+	})
+}
+
+// VisitDistinct supports Distinct only: GORM has no DISTINCT ON clause. GORM
+// renders DISTINCT only for queries selecting columns, e.g. with db.Select.
+func (v *Visitor) VisitDistinct(fields []string) {
+	if len(fields) > 0 {
+		v.fail(fmt.Errorf("%w: DISTINCT ON with GORM", specifications.ErrUnsupported))
+		return
+	}
+	v.distinct = true
+}
+
+func (v *Visitor) VisitGroupBy(fields []string) {
+	if len(fields) == 0 {
+		v.fail(fmt.Errorf("%w: GROUP BY without fields", specifications.ErrInvalidValue))
+		return
+	}
+	for _, f := range fields {
+		v.groups = append(v.groups, v.column(f))
+	}
+}
+
+// VisitHaving makes Apply add the conditions of spec to the HAVING clause.
+// Its ordering and pagination apply to the query, like in VisitNot.
+func (v *Visitor) VisitHaving(spec specifications.Specification) {
+	sub := v.child()
+	sub.inHaving = true
+	spec.Accept(sub)
+	v.havings = append(v.havings, sub.exprs...)
+	v.merge(sub)
+}
+
+// VisitAggregate visits spec with function applied to its columns, e.g.
+// SUM(amount) >= ? within Having, or ORDER BY COUNT(*) DESC. Conditions on
+// aggregates outside Having are reported as unsupported.
+func (v *Visitor) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	f, err := specifications.ParseAggregateFunction(string(function))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	sub := v.child()
+	sub.aggregate = f
+	spec.Accept(sub)
+	if len(sub.exprs) > 0 && !v.inHaving {
+		v.fail(fmt.Errorf("%w: conditions on %s aggregates outside Having", specifications.ErrUnsupported, f))
+		return
+	}
+	v.exprs = append(v.exprs, sub.exprs...)
+	v.merge(sub)
+}
+
+// VisitLock makes Apply add a locking clause, e.g. FOR UPDATE SKIP LOCKED.
+func (v *Visitor) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	s, w, err := specifications.ParseLock(string(strength), string(wait))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.lock = &clause.Locking{Strength: string(s), Options: string(w)}
+}
+
+// VisitSample is not supported by GORM's portable clauses.
+func (v *Visitor) VisitSample(percent float64, method specifications.SampleMethod) {
+	v.fail(fmt.Errorf("%w: sampling with GORM", specifications.ErrUnsupported))
+}
+
+// ordersByExpr reports whether the ordering holds an expression.
+func (v *Visitor) ordersByExpr() bool {
+	for _, o := range v.orders {
+		if o.expr != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// orderBy returns the ordering as a single expression, as GORM binds
+// variables in ORDER BY clauses only through one. Unlike the columns added
+// otherwise, it replaces the ordering already set on the query.
+func (v *Visitor) orderBy() clause.Expr {
+	var parts []string
+	var vars []interface{}
+	for _, o := range v.orders {
+		if o.expr != nil {
+			parts = append(parts, o.expr.SQL)
+			vars = append(vars, o.expr.Vars...)
+			continue
+		}
+		switch o.nulls {
+		case specifications.NullsFirst:
+			parts = append(parts, "CASE WHEN ? IS NULL THEN 0 ELSE 1 END")
+			vars = append(vars, o.Column)
+		case specifications.NullsLast:
+			parts = append(parts, "CASE WHEN ? IS NULL THEN 1 ELSE 0 END")
+			vars = append(vars, o.Column)
+		}
+		if o.Desc {
+			parts = append(parts, "? DESC")
+		} else {
+			parts = append(parts, "?")
+		}
+		vars = append(vars, o.Column)
+	}
+	return clause.Expr{SQL: strings.Join(parts, ", "), Vars: vars}
+}
+
+func rawOrder(sql string) clause.OrderByColumn {
+	return clause.OrderByColumn{Column: clause.Column{Name: sql, Raw: true}}
+}
+
+// Apply adds the visited conditions, grouping, ordering, pagination and
+// row locking to db. A visiting error is added to db instead.
+func (v *Visitor) Apply(db *gorm.DB) *gorm.DB {
+	if v.err != nil {
+		_ = db.AddError(v.err)
+		return db
+	}
+	if len(v.exprs) > 0 {
+		db = db.Where(clause.And(v.exprs...))
+	}
+	if v.distinct {
+		db = db.Distinct()
+	}
+	if len(v.groups) > 0 || len(v.havings) > 0 {
+		db = db.Clauses(clause.GroupBy{Columns: v.groups, Having: v.havings})
+	}
+	if v.ordersByExpr() {
+		db = db.Order(clause.OrderBy{Expression: v.orderBy()})
+	} else {
+		for _, o := range v.orders {
+			switch o.nulls {
+			case specifications.NullsFirst:
+				db = db.Order(rawOrder("CASE WHEN " + db.Statement.Quote(o.Column) + " IS NULL THEN 0 ELSE 1 END"))
+			case specifications.NullsLast:
+				db = db.Order(rawOrder("CASE WHEN " + db.Statement.Quote(o.Column) + " IS NULL THEN 1 ELSE 0 END"))
+			}
+			db = db.Order(o.OrderByColumn)
+		}
+	}
+	if v.limitSet {
+		db = db.Limit(v.limit)
+	}
+	if v.offset > 0 {
+		db = db.Offset(v.offset)
+	}
+	if v.lock != nil {
+		db = db.Clauses(*v.lock)
+	}
+	return db
+}
+
+// aggregated applies function to column, writing statistical aggregates in
+// standard SQL, as supported by PostgreSQL: STDDEV_SAMP, and
+// PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY column).
+func aggregated(function specifications.AggregateFunction, column string) string {
+	if function == specifications.AggregateStddev {
+		return "STDDEV_SAMP(" + column + ")"
+	}
+	if _, ok := function.Percentile(); ok {
+		return string(function) + " WITHIN GROUP (ORDER BY " + column + ")"
+	}
+	return string(function) + "(" + column + ")"
+}
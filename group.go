@@ -0,0 +1,30 @@
+package specifications
+
+type groupBySpec struct {
+	fields []string
+}
+
+func (s *groupBySpec) Accept(v SpecificationVisitor) {
+	v.VisitGroupBy(s.fields)
+}
+
+// GroupBy groups the matched rows by the values of fields, for aggregate
+// reporting queries. Backends without grouping report ErrUnsupported.
+func GroupBy(fields ...string) Specification {
+	return &groupBySpec{fields: fields}
+}
+
+type havingSpec struct {
+	spec Specification
+}
+
+func (s *havingSpec) Accept(v SpecificationVisitor) {
+	v.VisitHaving(s.spec)
+}
+
+// Having keeps only the groups formed by GroupBy whose rows satisfy spec.
+// Its conditions apply after grouping, so they may only refer to grouping
+// fields.
+func Having(spec Specification) Specification {
+	return &havingSpec{spec: spec}
+}
@@ -0,0 +1,332 @@
+// Package httpspec parses REST-style query parameters into specifications:
+//
+//	?status=active&created_at[gte]=2024-01-01&sort=-created_at&limit=20&offset=40
+//
+// A parameter filters the field it is named after, with an optional operator
+// suffix in brackets; without one it compares for equality, or membership
+// when repeated. Only configured fields and operators are accepted.
+package httpspec
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+var (
+	// ErrFieldNotAllowed is returned for parameters naming a field that is
+	// not allowed.
+	ErrFieldNotAllowed = errors.New("httpspec: field not allowed")
+	// ErrOperatorNotAllowed is returned for unknown or disallowed operator
+	// suffixes.
+	ErrOperatorNotAllowed = errors.New("httpspec: operator not allowed")
+	// ErrInvalidParam is returned for malformed parameters.
+	ErrInvalidParam = errors.New("httpspec: invalid parameter")
+)
+
+// Operator is the bracketed suffix of a filter parameter.
+type Operator string
+
+const (
+	OpEqual              Operator = "eq"
+	OpNotEqual           Operator = "ne"
+	OpGreaterThan        Operator = "gt"
+	OpGreaterThanOrEqual Operator = "gte"
+	OpLowerThan          Operator = "lt"
+	OpLowerThanOrEqual   Operator = "lte"
+	// OpIn and OpNotIn take a comma-separated list.
+	OpIn    Operator = "in"
+	OpNotIn Operator = "nin"
+	OpLike  Operator = "like"
+	OpILike Operator = "ilike"
+	// OpNull takes true (IS NULL) or false (IS NOT NULL).
+	OpNull Operator = "null"
+)
+
+// Converter turns the raw value of a parameter into the value compared with
+// field.
+type Converter func(field, value string) (interface{}, error)
+
+// Parser parses query parameters restricted to a set of fields and
+// operators.
+type Parser struct {
+	fields        map[string]bool
+	operators     map[Operator]bool
+	convert       Converter
+	sortParam     string
+	limitParam    string
+	offsetParam   string
+	maxLimit      int
+	ignoreUnknown bool
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithOperators restricts the accepted operator suffixes to ops. By default
+// every operator is accepted.
+func WithOperators(ops ...Operator) Option {
+	return func(p *Parser) {
+		p.operators = make(map[Operator]bool, len(ops))
+		for _, op := range ops {
+			p.operators[op] = true
+		}
+	}
+}
+
+// WithConverter sets how raw values are converted. By default values are
+// passed on as strings.
+func WithConverter(convert Converter) Option {
+	return func(p *Parser) {
+		p.convert = convert
+	}
+}
+
+// WithParamNames renames the sort, limit and offset parameters. Empty names
+// keep the defaults "sort", "limit" and "offset".
+func WithParamNames(sort, limit, offset string) Option {
+	return func(p *Parser) {
+		if sort != "" {
+			p.sortParam = sort
+		}
+		if limit != "" {
+			p.limitParam = limit
+		}
+		if offset != "" {
+			p.offsetParam = offset
+		}
+	}
+}
+
+// WithMaxLimit rejects limits above max, and applies max when no limit is
+// given.
+func WithMaxLimit(max int) Option {
+	return func(p *Parser) {
+		p.maxLimit = max
+	}
+}
+
+// WithIgnoreUnknown skips parameters naming fields that are not allowed
+// instead of failing, for endpoints sharing the query string with other
+// parameters.
+func WithIgnoreUnknown() Option {
+	return func(p *Parser) {
+		p.ignoreUnknown = true
+	}
+}
+
+// NewParser returns a parser accepting filters and sorting on fields, the
+// domain field names of the specifications it produces.
+func NewParser(fields []string, opts ...Option) *Parser {
+	p := &Parser{
+		fields:      make(map[string]bool, len(fields)),
+		sortParam:   "sort",
+		limitParam:  "limit",
+		offsetParam: "offset",
+	}
+	for _, f := range fields {
+		p.fields[f] = true
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// ParseRequest parses the query string of r.
+func (p *Parser) ParseRequest(r *http.Request) (specifications.Specification, error) {
+	return p.Parse(r.URL.Query())
+}
+
+// Parse turns query parameters into a specification. Filters are combined
+// with And in parameter name order, followed by ordering and pagination.
+func (p *Parser) Parse(values url.Values) (specifications.Specification, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var specs []specifications.Specification
+	for _, key := range keys {
+		if key == p.sortParam || key == p.limitParam || key == p.offsetParam {
+			continue
+		}
+		spec, err := p.Filter(key, values[key])
+		if err != nil {
+			return nil, err
+		}
+		if spec != nil {
+			specs = append(specs, spec)
+		}
+	}
+
+	if raw := values.Get(p.sortParam); raw != "" {
+		order, err := p.Sort(raw)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, order...)
+	}
+
+	limit, limited, err := p.count(values, p.limitParam)
+	if err != nil {
+		return nil, err
+	}
+	if p.maxLimit > 0 {
+		if limit > p.maxLimit {
+			return nil, fmt.Errorf("%w: limit %d exceeds %d", specifications.ErrInvalidValue, limit, p.maxLimit)
+		}
+		if !limited {
+			limit, limited = p.maxLimit, true
+		}
+	}
+	if limited {
+		specs = append(specs, specifications.Limit(limit))
+	}
+
+	offset, _, err := p.count(values, p.offsetParam)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		specs = append(specs, specifications.Offset(offset))
+	}
+
+	return specifications.And(specs...), nil
+}
+
+// Filter parses the filter parameter key, "field" or "field[op]", with its
+// values raw, as Parse does. It returns nil for ignored parameters.
+func (p *Parser) Filter(key string, raw []string) (specifications.Specification, error) {
+	field, op := key, OpEqual
+	if i := strings.IndexByte(key, '['); i >= 0 {
+		if !strings.HasSuffix(key, "]") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidParam, key)
+		}
+		field, op = key[:i], Operator(key[i+1:len(key)-1])
+	}
+	if !p.fields[field] {
+		if p.ignoreUnknown {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %q", ErrFieldNotAllowed, field)
+	}
+	if !validOperator(op) || (p.operators != nil && !p.operators[op]) {
+		return nil, fmt.Errorf("%w: %q on %q", ErrOperatorNotAllowed, op, field)
+	}
+
+	if op == OpEqual && len(raw) > 1 {
+		op = OpIn
+		raw = []string{strings.Join(raw, ",")}
+	} else if len(raw) > 1 {
+		return nil, fmt.Errorf("%w: %q given more than once", ErrInvalidParam, key)
+	}
+
+	switch op {
+	case OpNull:
+		isNull, err := strconv.ParseBool(raw[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q expects true or false", ErrInvalidParam, key)
+		}
+		if isNull {
+			return specifications.IsNull(field), nil
+		}
+		return specifications.IsNotNull(field), nil
+	case OpIn, OpNotIn:
+		parts := strings.Split(raw[0], ",")
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			v, err := p.value(field, part)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		if op == OpIn {
+			return specifications.In(field, list...), nil
+		}
+		return specifications.NotIn(field, list...), nil
+	}
+
+	value, err := p.value(field, raw[0])
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case OpNotEqual:
+		return specifications.NotEqual(field, value), nil
+	case OpGreaterThan:
+		return specifications.GreaterThan(field, value), nil
+	case OpGreaterThanOrEqual:
+		return specifications.GreaterThanOrEqual(field, value), nil
+	case OpLowerThan:
+		return specifications.LowerThan(field, value), nil
+	case OpLowerThanOrEqual:
+		return specifications.LowerThanOrEqual(field, value), nil
+	case OpLike:
+		return specifications.Like(field, value), nil
+	case OpILike:
+		return specifications.ILike(field, value), nil
+	}
+	return specifications.Equal(field, value), nil
+}
+
+func validOperator(op Operator) bool {
+	switch op {
+	case OpEqual, OpNotEqual, OpGreaterThan, OpGreaterThanOrEqual, OpLowerThan, OpLowerThanOrEqual,
+		OpIn, OpNotIn, OpLike, OpILike, OpNull:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) value(field, raw string) (interface{}, error) {
+	if p.convert == nil {
+		return raw, nil
+	}
+	v, err := p.convert(field, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidParam, field, err)
+	}
+	return v, nil
+}
+
+// Sort parses a comma-separated list of fields, descending when prefixed
+// with '-', as Parse does with the sort parameter.
+func (p *Parser) Sort(raw string) ([]specifications.Specification, error) {
+	var specs []specifications.Specification
+	for _, term := range strings.Split(raw, ",") {
+		direction := "ASC"
+		if strings.HasPrefix(term, "-") {
+			term, direction = term[1:], "DESC"
+		} else {
+			term = strings.TrimPrefix(term, "+")
+		}
+		if !p.fields[term] {
+			return nil, fmt.Errorf("%w: %q", ErrFieldNotAllowed, term)
+		}
+		specs = append(specs, specifications.OrderBy(term, direction))
+	}
+	return specs, nil
+}
+
+// count parses the non-negative integer param, ok reporting whether it is
+// given.
+func (p *Parser) count(values url.Values, param string) (n int, ok bool, err error) {
+	raw := values.Get(param)
+	if raw == "" {
+		return 0, false, nil
+	}
+	n, err = strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, false, fmt.Errorf("%w: %s must be a non-negative integer", ErrInvalidParam, param)
+	}
+	return n, true, nil
+}
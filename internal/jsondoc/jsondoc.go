@@ -0,0 +1,178 @@
+// Package jsondoc holds the JSON document handling shared by the visitors
+// of the JSON specifications.
+package jsondoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Encode returns doc as JSON text. Strings, byte slices and
+// json.RawMessage are taken as JSON text already and must be valid.
+func Encode(doc interface{}) (string, error) {
+	var text []byte
+	switch d := doc.(type) {
+	case string:
+		text = []byte(d)
+	case []byte:
+		text = d
+	case json.RawMessage:
+		text = d
+	default:
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return "", fmt.Errorf("%w: JSON document: %w", specifications.ErrInvalidValue, err)
+		}
+		return string(b), nil
+	}
+	if !json.Valid(text) {
+		return "", fmt.Errorf("%w: invalid JSON document %q", specifications.ErrInvalidValue, text)
+	}
+	return string(text), nil
+}
+
+// Decode returns doc as the value produced by json.Unmarshal into an
+// interface{}, with numbers as json.Number. Strings, byte slices and
+// json.RawMessage are parsed as JSON text.
+func Decode(doc interface{}) (interface{}, error) {
+	text, err := Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(text)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: JSON document: %w", specifications.ErrInvalidValue, err)
+	}
+	return v, nil
+}
+
+// Text returns the text form of a JSON value, as returned by the
+// PostgreSQL ->> operator: strings without quotes, other values as JSON.
+func Text(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: JSON value: %w", specifications.ErrInvalidValue, err)
+	}
+	return string(b), nil
+}
+
+// Path returns the value of decoded doc at path, following object keys and
+// array indexes.
+func Path(doc interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[key]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if i < 0 {
+				i += len(d)
+			}
+			if err != nil || i < 0 || i >= len(d) {
+				return nil, false
+			}
+			doc = d[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// Contains reports whether decoded doc contains decoded sub like the
+// PostgreSQL @> operator: objects contain the members of sub, arrays every
+// element of sub, and scalars equal values. A top-level array also
+// contains a scalar element.
+func Contains(doc, sub interface{}) bool {
+	if a, ok := doc.([]interface{}); ok {
+		switch sub.(type) {
+		case []interface{}, map[string]interface{}:
+		default:
+			for _, e := range a {
+				if contains(e, sub) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return contains(doc, sub)
+}
+
+func contains(doc, sub interface{}) bool {
+	switch s := sub.(type) {
+	case map[string]interface{}:
+		d, ok := doc.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, sv := range s {
+			dv, ok := d[k]
+			if !ok || !contains(dv, sv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		d, ok := doc.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, se := range s {
+			found := false
+			for _, de := range d {
+				if contains(de, se) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		d, ok := doc.(json.Number)
+		if !ok {
+			return false
+		}
+		df, err1 := d.Float64()
+		sf, err2 := s.Float64()
+		return err1 == nil && err2 == nil && df == sf
+	}
+	return reflect.DeepEqual(doc, sub)
+}
+
+// HasKey reports whether decoded doc has key as a top-level object key or
+// string array element, like the PostgreSQL ? operator.
+func HasKey(doc interface{}, key string) bool {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		_, ok := d[key]
+		return ok
+	case []interface{}:
+		for _, e := range d {
+			if s, ok := e.(string); ok && s == key {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Package pgarray binds lists of values as PostgreSQL arrays.
+package pgarray
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Array is a list of values bound as one PostgreSQL array. Its Value is
+// the text form of the array, e.g. {"a","b"}, which the server converts to
+// the array type the statement expects, so it works with lib/pq and pgx
+// alike.
+type Array []interface{}
+
+// Value implements driver.Valuer.
+func (a Array) Value() (driver.Value, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := element(&b, v); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func element(b *strings.Builder, v interface{}) error {
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return err
+		}
+	}
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("NULL")
+	case bool:
+		b.WriteString(strconv.FormatBool(x))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		fmt.Fprint(b, x)
+	case string:
+		quote(b, x)
+	case []byte:
+		quote(b, `\x`+hex.EncodeToString(x))
+	case time.Time:
+		quote(b, x.Format(time.RFC3339Nano))
+	default:
+		quote(b, fmt.Sprint(x))
+	}
+	return nil
+}
+
+// quote writes s as a quoted array element.
+func quote(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+}
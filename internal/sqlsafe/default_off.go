@@ -0,0 +1,13 @@
+//go:build !specifications_hardened
+
+package sqlsafe
+
+// HardenedByDefault reports whether SQL visitors start in hardened mode:
+// those of sqlspec and its dialects, squirrel and gormspec. Build with
+// -tags specifications_hardened to turn it on everywhere.
+//
+// Hardening validates what specifications carry: fields, order directions
+// and text search configurations. The SQL fragments of the application,
+// such as base queries, relations and field maps, are trusted, not
+// validated.
+const HardenedByDefault = false
@@ -0,0 +1,6 @@
+//go:build specifications_hardened
+
+package sqlsafe
+
+// HardenedByDefault reports whether SQL visitors start in hardened mode.
+const HardenedByDefault = true
@@ -0,0 +1,13 @@
+// Package sqlsafe holds the strict grammars used by the SQL visitors when
+// hardening is enabled.
+package sqlsafe
+
+import "regexp"
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Identifier reports whether name is a plain, optionally qualified SQL
+// identifier such as "price" or "p.price".
+func Identifier(name string) bool {
+	return len(name) <= 255 && identifier.MatchString(name)
+}
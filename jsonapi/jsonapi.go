@@ -0,0 +1,190 @@
+// Package jsonapi parses JSON:API query parameters into specifications:
+//
+//	?filter[status]=active,trial&filter[created_at][gte]=2024-01-01&sort=-created_at&page[number]=3&page[size]=20
+//
+// filter[field] compares for equality, or membership when given a
+// comma-separated list, and filter[field][op] accepts the operators of
+// httpspec. sort takes a comma-separated list of fields, descending when
+// prefixed with '-'. Pagination uses page[number] (1-based) and page[size],
+// or page[offset] and page[limit].
+package jsonapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/httpspec"
+)
+
+// Parser parses JSON:API query parameters restricted to a set of fields.
+type Parser struct {
+	fields      []string
+	httpOptions []httpspec.Option
+	defaultSize int
+	maxSize     int
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithOperators restricts the accepted filter operators to ops.
+func WithOperators(ops ...httpspec.Operator) Option {
+	return func(p *Parser) {
+		p.httpOptions = append(p.httpOptions, httpspec.WithOperators(ops...))
+	}
+}
+
+// WithConverter sets how raw filter values are converted. By default values
+// are passed on as strings.
+func WithConverter(convert httpspec.Converter) Option {
+	return func(p *Parser) {
+		p.httpOptions = append(p.httpOptions, httpspec.WithConverter(convert))
+	}
+}
+
+// WithPageSize sets the page size used without page[size] or page[limit],
+// and the largest accepted one. Zero disables either.
+func WithPageSize(defaultSize, maxSize int) Option {
+	return func(p *Parser) {
+		p.defaultSize = defaultSize
+		p.maxSize = maxSize
+	}
+}
+
+// NewParser returns a parser accepting filters and sorting on fields, the
+// domain field names of the specifications it produces.
+func NewParser(fields []string, opts ...Option) *Parser {
+	p := &Parser{fields: fields}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// ParseRequest parses the query string of r.
+func (p *Parser) ParseRequest(r *http.Request) (specifications.Specification, error) {
+	return p.Parse(r.URL.Query())
+}
+
+// Parse turns JSON:API query parameters into a specification. Parameters
+// other than filter, sort and page are ignored.
+func (p *Parser) Parse(values url.Values) (specifications.Specification, error) {
+	params := url.Values{}
+	for key, vs := range values {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		name := strings.TrimPrefix(key, "filter[")
+		end := strings.IndexByte(name, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: %q", httpspec.ErrInvalidParam, key)
+		}
+		field, op := name[:end], name[end+1:]
+		if op == "" {
+			// A comma-separated list is a membership test.
+			for _, v := range vs {
+				params[field] = append(params[field], strings.Split(v, ",")...)
+			}
+			continue
+		}
+		params[field+op] = vs
+	}
+
+	// The specification is built here rather than by parsing params, in
+	// which filters on fields named like httpspec's sort, limit and offset
+	// parameters would be taken for them.
+	parser := httpspec.NewParser(p.fields, p.httpOptions...)
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var specs []specifications.Specification
+	for _, key := range keys {
+		spec, err := parser.Filter(key, params[key])
+		if err != nil {
+			return nil, err
+		}
+		if spec != nil {
+			specs = append(specs, spec)
+		}
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		order, err := parser.Sort(raw)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, order...)
+	}
+
+	limit, limited, offset, err := p.page(values)
+	if err != nil {
+		return nil, err
+	}
+	if limited {
+		specs = append(specs, specifications.Limit(limit))
+	}
+	if offset > 0 {
+		specs = append(specs, specifications.Offset(offset))
+	}
+
+	return specifications.And(specs...), nil
+}
+
+// page converts the page parameters into a limit, limited reporting
+// whether there is one, so that an explicit page size of zero selects no
+// results, and an offset.
+func (p *Parser) page(values url.Values) (limit int, limited bool, offset int, err error) {
+	number, _, err := pageParam(values, "number")
+	if err != nil {
+		return 0, false, 0, err
+	}
+	size, sized, err := pageParam(values, "size")
+	if err != nil {
+		return 0, false, 0, err
+	}
+	offset, _, err = pageParam(values, "offset")
+	if err != nil {
+		return 0, false, 0, err
+	}
+	limit, limited, err = pageParam(values, "limit")
+	if err != nil {
+		return 0, false, 0, err
+	}
+
+	if sized {
+		limit, limited = size, true
+	}
+	if !limited && p.defaultSize > 0 {
+		limit, limited = p.defaultSize, true
+	}
+	if p.maxSize > 0 && limit > p.maxSize {
+		return 0, false, 0, fmt.Errorf("%w: page size %d exceeds %d", specifications.ErrInvalidValue, limit, p.maxSize)
+	}
+	if number > 1 {
+		if !limited {
+			return 0, false, 0, fmt.Errorf("%w: page[number] requires a page size", httpspec.ErrInvalidParam)
+		}
+		offset = (number - 1) * limit
+	}
+	return limit, limited, offset, nil
+}
+
+func pageParam(values url.Values, name string) (n int, ok bool, err error) {
+	raw := values.Get("page[" + name + "]")
+	if raw == "" {
+		return 0, false, nil
+	}
+	n, err = strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, false, fmt.Errorf("%w: page[%s] must be a non-negative integer", httpspec.ErrInvalidParam, name)
+	}
+	return n, true, nil
+}
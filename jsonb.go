@@ -0,0 +1,54 @@
+package specifications
+
+type jsonContainsSpec struct {
+	field string
+	doc   interface{}
+}
+
+func (s *jsonContainsSpec) Accept(v SpecificationVisitor) {
+	v.VisitJSONContains(s.field, s.doc)
+}
+
+// JSONContains matches entities whose JSON document field contains doc:
+// objects contain the members of doc, at any depth, and arrays its
+// elements, like the PostgreSQL @> operator. doc is a Go value encoded as
+// JSON, or JSON text as a string, []byte or json.RawMessage, e.g.
+//
+//	JSONContains("Attributes", map[string]interface{}{"color": "red"})
+func JSONContains(field string, doc interface{}) Specification {
+	return &jsonContainsSpec{field: field, doc: doc}
+}
+
+type jsonPathEqualSpec struct {
+	field string
+	path  []string
+	value interface{}
+}
+
+func (s *jsonPathEqualSpec) Accept(v SpecificationVisitor) {
+	v.VisitJSONPathEqual(s.field, s.path, s.value)
+}
+
+// JSONPathEqual matches entities whose JSON document field holds value at
+// path, a list of object keys or array indexes. Like the PostgreSQL ->> and
+// #>> operators, the text form of the JSON value is compared: strings
+// without quotes, other values as JSON.
+func JSONPathEqual(field string, path []string, value interface{}) Specification {
+	return &jsonPathEqualSpec{field: field, path: path, value: value}
+}
+
+type jsonKeyExistsSpec struct {
+	field string
+	key   string
+}
+
+func (s *jsonKeyExistsSpec) Accept(v SpecificationVisitor) {
+	v.VisitJSONKeyExists(s.field, s.key)
+}
+
+// JSONKeyExists matches entities whose JSON document field has key as a
+// top-level object key, or as a string element of a top-level array, like
+// the PostgreSQL ? operator.
+func JSONKeyExists(field, key string) Specification {
+	return &jsonKeyExistsSpec{field: field, key: key}
+}
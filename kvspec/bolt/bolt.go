@@ -0,0 +1,38 @@
+// Package bolt adapts bbolt buckets to kvspec. It is a module of its own,
+// so that only its users depend on bbolt.
+package bolt
+
+import (
+	"bytes"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/thefabric-io/specifications/kvspec"
+)
+
+// Scanner returns a kvspec.Scanner over the keys of a bbolt bucket. Nested
+// buckets are skipped. The bucket is only valid for its transaction.
+func Scanner(b *bbolt.Bucket) kvspec.Scanner {
+	return scanner{bucket: b}
+}
+
+type scanner struct {
+	bucket *bbolt.Bucket
+}
+
+func (s scanner) Scan(r kvspec.Range, fn func(key, value []byte) error) error {
+	start := r.Prefix
+	if bytes.Compare(r.Start, start) > 0 {
+		start = r.Start
+	}
+	c := s.bucket.Cursor()
+	for k, v := c.Seek(start); k != nil && r.Contains(k); k, v = c.Next() {
+		if v == nil {
+			continue
+		}
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
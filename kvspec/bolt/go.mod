@@ -0,0 +1,12 @@
+module github.com/thefabric-io/specifications/kvspec/bolt
+
+go 1.23.4
+
+require (
+	github.com/thefabric-io/specifications v0.0.0-00010101000000-000000000000
+	go.etcd.io/bbolt v1.4.0
+)
+
+require golang.org/x/sys v0.32.0 // indirect
+
+replace github.com/thefabric-io/specifications => ../../
@@ -0,0 +1,347 @@
+// Package kvspec filters entities stored in ordered key-value stores such as
+// BoltDB or Badger with specifications.
+//
+// Keys follow a Layout: a prefix followed by the encoded values of some
+// fields, each terminated by a separator. Plan turns the conditions on these
+// fields into key ranges, so only the part of the keyspace that may match is
+// scanned, and Filter evaluates the whole specification in memory on the
+// decoded entities. The kvspec/bolt module adapts bbolt buckets; other
+// stores implement Scanner, e.g. for Badger with an iterator's Seek and
+// ValidForPrefix.
+package kvspec
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/memory"
+)
+
+// Layout describes how entity keys are built: Prefix, then for each of
+// Fields its encoded value followed by Separator, then anything, typically
+// the rest of the entity's identity. Build keys with Key.
+type Layout struct {
+	Prefix []byte
+	Fields []string
+	// Separator must sort before every byte of the encoded values, so that
+	// keys sort like their values. The zero byte suits text.
+	Separator byte
+	// Encode returns the encoding of a field value in keys, which must
+	// preserve the order of values. Nil encodes strings and byte slices as
+	// is; conditions on other values are then left to the in-memory
+	// evaluation.
+	Encode func(field string, value interface{}) ([]byte, bool)
+}
+
+func (l Layout) encode(field string, value interface{}) ([]byte, bool) {
+	if l.Encode != nil {
+		return l.Encode(field, value)
+	}
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
+
+// Key returns the key prefix of an entity whose layout fields have values,
+// in the order of Fields. Append the rest of its identity to it.
+func (l Layout) Key(values ...interface{}) ([]byte, error) {
+	if len(values) != len(l.Fields) {
+		return nil, fmt.Errorf("%w: kvspec: %d key values for %d fields", specifications.ErrInvalidValue, len(values), len(l.Fields))
+	}
+	key := slices.Clone(l.Prefix)
+	for i, v := range values {
+		enc, ok := l.encode(l.Fields[i], v)
+		if !ok {
+			return nil, fmt.Errorf("%w: kvspec: cannot encode %q value %v", specifications.ErrInvalidValue, l.Fields[i], v)
+		}
+		key = append(append(key, enc...), l.Separator)
+	}
+	return key, nil
+}
+
+// Range is a contiguous range of keys: the keys starting with Prefix, at or
+// after Start and before End when they are set.
+type Range struct {
+	Prefix []byte
+	Start  []byte
+	End    []byte
+}
+
+// Contains reports whether key is in r.
+func (r Range) Contains(key []byte) bool {
+	return bytes.HasPrefix(key, r.Prefix) &&
+		(r.Start == nil || bytes.Compare(key, r.Start) >= 0) &&
+		(r.End == nil || bytes.Compare(key, r.End) < 0)
+}
+
+// maxRanges bounds the number of ranges planned from In conditions.
+const maxRanges = 256
+
+// Plan returns the key ranges holding every entity that may match spec,
+// derived from its top-level conditions on the layout fields: equalities
+// and In on leading fields, then a range or a StartsWith prefix on the next
+// one. Without such conditions it is a single range covering Prefix. Other
+// conditions are not reflected and must be evaluated on the entities.
+func (l Layout) Plan(spec specifications.Specification) []Range {
+	c := &collector{conditions: map[string]*conditions{}}
+	if spec != nil {
+		spec.Accept(c)
+	}
+
+	prefixes := [][]byte{slices.Clone(l.Prefix)}
+	for _, field := range l.Fields {
+		cond := c.conditions[field]
+		if cond == nil {
+			break
+		}
+		if values := l.encodeAll(field, cond.equal); values != nil && len(prefixes)*len(values) <= maxRanges {
+			next := make([][]byte, 0, len(prefixes)*len(values))
+			for _, p := range prefixes {
+				for _, v := range values {
+					next = append(next, append(append(slices.Clone(p), v...), l.Separator))
+				}
+			}
+			prefixes = next
+			continue
+		}
+		return l.ranges(prefixes, field, cond)
+	}
+	return l.ranges(prefixes, "", nil)
+}
+
+// encodeAll returns the sorted, distinct encodings of values, or nil if any
+// cannot be encoded or values is nil.
+func (l Layout) encodeAll(field string, values []interface{}) [][]byte {
+	if values == nil {
+		return nil
+	}
+	out := make([][]byte, 0, len(values))
+	for _, v := range values {
+		enc, ok := l.encode(field, v)
+		if !ok {
+			return nil
+		}
+		out = append(out, enc)
+	}
+	slices.SortFunc(out, bytes.Compare)
+	return slices.CompactFunc(out, bytes.Equal)
+}
+
+// ranges returns one range per prefix, narrowed by the range conditions on
+// field, the first layout field without equality.
+func (l Layout) ranges(prefixes [][]byte, field string, cond *conditions) []Range {
+	var start, end, match []byte
+	if cond != nil {
+		for _, b := range cond.lower {
+			if enc, ok := l.encode(field, b.value); ok {
+				if !b.inclusive {
+					// Skip every key holding the bound itself.
+					enc = append(slices.Clone(enc), l.Separator+1)
+				}
+				if start == nil || bytes.Compare(enc, start) > 0 {
+					start = enc
+				}
+			}
+		}
+		for _, b := range cond.upper {
+			if enc, ok := l.encode(field, b.value); ok {
+				if b.inclusive {
+					enc = append(slices.Clone(enc), l.Separator+1)
+				}
+				if end == nil || bytes.Compare(enc, end) < 0 {
+					end = enc
+				}
+			}
+		}
+		match = []byte(cond.prefix)
+	}
+
+	out := make([]Range, len(prefixes))
+	for i, p := range prefixes {
+		r := Range{Prefix: append(slices.Clone(p), match...)}
+		if start != nil {
+			r.Start = append(slices.Clone(p), start...)
+		}
+		if end != nil {
+			r.End = append(slices.Clone(p), end...)
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// Scanner iterates over the keys of a store in order.
+type Scanner interface {
+	// Scan calls fn for each key in r and its value, in key order, and
+	// stops at the first error returned by fn.
+	Scan(r Range, fn func(key, value []byte) error) error
+}
+
+// Filter scans the ranges planned for spec, decodes their entries and
+// returns the entities matching spec, honoring its order, offset and limit
+// like memory.Filter.
+func Filter[T any](s Scanner, l Layout, spec specifications.Specification, decode func(key, value []byte) (T, error)) ([]T, error) {
+	return FilterWith(memory.NewEvaluator(nil), s, l, spec, decode)
+}
+
+// FilterWith is like Filter but evaluates spec with the given, unvisited
+// Evaluator, e.g. one with a field map or accessors.
+func FilterWith[T any](e *memory.Evaluator, s Scanner, l Layout, spec specifications.Specification, decode func(key, value []byte) (T, error)) ([]T, error) {
+	var items []T
+	for _, r := range l.Plan(spec) {
+		err := s.Scan(r, func(key, value []byte) error {
+			item, err := decode(key, value)
+			if err != nil {
+				return fmt.Errorf("kvspec: decoding %q: %w", key, err)
+			}
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return memory.FilterWith(e, items, spec)
+}
+
+type bound struct {
+	value     interface{}
+	inclusive bool
+}
+
+// conditions holds the plannable conditions on one field. equal is nil
+// without Equal or In condition.
+type conditions struct {
+	equal  []interface{}
+	lower  []bound
+	upper  []bound
+	prefix string
+}
+
+// collector is a SpecificationVisitor recording the conditions of the
+// top-level conjunction. Conditions under Or and Not are ignored.
+type collector struct {
+	conditions map[string]*conditions
+}
+
+func (c *collector) on(field string) *conditions {
+	cond := c.conditions[field]
+	if cond == nil {
+		cond = &conditions{}
+		c.conditions[field] = cond
+	}
+	return cond
+}
+
+func (c *collector) VisitEqual(field string, value interface{}) {
+	c.on(field).equal = []interface{}{value}
+}
+
+func (c *collector) VisitIn(field string, values []interface{}) {
+	if cond := c.on(field); cond.equal == nil || len(values) < len(cond.equal) {
+		cond.equal = append([]interface{}{}, values...)
+	}
+}
+
+func (c *collector) VisitGreaterThan(field string, value interface{}) {
+	c.on(field).lower = append(c.on(field).lower, bound{value: value})
+}
+
+func (c *collector) VisitGreaterThanOrEqual(field string, value interface{}) {
+	c.on(field).lower = append(c.on(field).lower, bound{value: value, inclusive: true})
+}
+
+func (c *collector) VisitLowerThan(field string, value interface{}) {
+	c.on(field).upper = append(c.on(field).upper, bound{value: value})
+}
+
+func (c *collector) VisitLowerThanOrEqual(field string, value interface{}) {
+	c.on(field).upper = append(c.on(field).upper, bound{value: value, inclusive: true})
+}
+
+func (c *collector) VisitBetween(field string, low, high interface{}) {
+	c.VisitGreaterThanOrEqual(field, low)
+	c.VisitLowerThanOrEqual(field, high)
+}
+
+// VisitLikeEscaped plans patterns made of a literal prefix followed by a
+// single trailing '%', as built by StartsWith.
+func (c *collector) VisitLikeEscaped(field string, pattern string) {
+	literal, ok := strings.CutSuffix(pattern, "%")
+	if !ok {
+		return
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range literal {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == specifications.LikeEscapeChar:
+			escaped = true
+		case r == '%' || r == '_':
+			return
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if cond := c.on(field); len(b.String()) > len(cond.prefix) {
+		cond.prefix = b.String()
+	}
+}
+
+func (c *collector) VisitAnd(specs []specifications.Specification) {
+	for _, s := range specs {
+		s.Accept(c)
+	}
+}
+
+func (c *collector) VisitDescribed(_ string, spec specifications.Specification) {
+	spec.Accept(c)
+}
+
+// VisitEqualAny plans like In.
+func (c *collector) VisitEqualAny(field string, values []interface{}) {
+	c.VisitIn(field, values)
+}
+
+func (c *collector) VisitNotEqual(string, interface{})                                             {}
+func (c *collector) VisitNotIn(string, []interface{})                                              {}
+func (c *collector) VisitOr([]specifications.Specification)                                        {}
+func (c *collector) VisitNot(specifications.Specification)                                         {}
+func (c *collector) VisitLimit(int)                                                                {}
+func (c *collector) VisitOffset(int)                                                               {}
+func (c *collector) VisitOrder(string, string)                                                     {}
+func (c *collector) VisitOrderNulls(string, string, specifications.NullsPosition)                  {}
+func (c *collector) VisitDistinct([]string)                                                        {}
+func (c *collector) VisitGroupBy([]string)                                                         {}
+func (c *collector) VisitHaving(specifications.Specification)                                      {}
+func (c *collector) VisitLock(specifications.LockStrength, specifications.LockWait)                {}
+func (c *collector) VisitAggregate(specifications.AggregateFunction, specifications.Specification) {}
+func (c *collector) VisitJSONContains(string, interface{})                                         {}
+func (c *collector) VisitJSONPathEqual(string, []string, interface{})                              {}
+func (c *collector) VisitArrayContains(string, []interface{})                                      {}
+func (c *collector) VisitArrayOverlaps(string, []interface{})                                      {}
+func (c *collector) VisitTextSearch(string, string, string)                                        {}
+func (c *collector) VisitTextRank(string, string, string)                                          {}
+func (c *collector) VisitSimilar(string, string, float64)                                          {}
+func (c *collector) VisitSimilarityOrder(string, string)                                           {}
+func (c *collector) VisitWithinRadius(string, specifications.Point, float64)                       {}
+func (c *collector) VisitWithinBox(string, specifications.BoundingBox)                             {}
+func (c *collector) VisitOrderByDistance(string, specifications.Point)                             {}
+func (c *collector) VisitRelated(string, specifications.Specification)                             {}
+func (c *collector) VisitJSONKeyExists(string, string)                                             {}
+func (c *collector) VisitLike(string, interface{})                                                 {}
+func (c *collector) VisitILike(string, interface{})                                                {}
+func (c *collector) VisitRegex(string, string, bool)                                               {}
+func (c *collector) VisitIsNull(string)                                                            {}
+func (c *collector) VisitIsNotNull(string)                                                         {}
+func (c *collector) VisitSample(float64, specifications.SampleMethod)                              {}
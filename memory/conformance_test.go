@@ -0,0 +1,26 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/thefabric-io/specifications/memory"
+	"github.com/thefabric-io/specifications/spectest"
+)
+
+// TestConformance runs the conformance corpus against ConformanceRows, so
+// that the evaluator keeps the semantics of the SQL backends.
+func TestConformance(t *testing.T) {
+	for _, c := range spectest.ConformanceCases() {
+		t.Run(c.Name, func(t *testing.T) {
+			rows, err := memory.FilterWith(memory.NewEvaluator(spectest.ConformanceFieldMap), spectest.ConformanceRows(), c.Spec)
+			if err != nil {
+				t.Fatalf("filter: %v", err)
+			}
+			var got []int64
+			for _, r := range rows {
+				got = append(got, r.ID)
+			}
+			spectest.CheckConformance(t, c, got)
+		})
+	}
+}
@@ -0,0 +1,810 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/internal/jsondoc"
+)
+
+// Predicate reports whether an entity satisfies a specification.
+type Predicate func(entity any) bool
+
+// truth is a value of SQL's three-valued logic: conditions on NULL are
+// unknown rather than false, and so are their negations, so that Not
+// matches the entities a database would.
+type truth uint8
+
+const (
+	isFalse truth = iota
+	isTrue
+	isUnknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+// condition is a visited condition, evaluated with three-valued logic.
+type condition func(entity any) truth
+
+type order struct {
+	field     string
+	direction string
+	nulls     specifications.NullsPosition
+	// key, when set, computes the ordered value instead of field.
+	key func(entity any) any
+}
+
+// Evaluator is a SpecificationVisitor that compiles specifications into a
+// Predicate evaluated against Go values, plus the ordering, pagination and
+// sampling modifiers needed by Filter.
+//
+// Fields are resolved by reflection: the field map translates domain fields
+// into (possibly dotted) struct field names or map keys, and unmapped fields
+// are used as-is. Accessors registered with WithAccessor take precedence.
+// Unresolvable fields behave like NULL.
+type Evaluator struct {
+	conditions []condition
+	fieldMap   map[string]string
+	accessors  map[string]func(entity any) any
+	orders     []order
+	limit      int
+	offset     int
+	sample     float64
+	distinct   bool
+	distinctOn []string
+	empty      specifications.EmptyComposite
+	// limitSet and offsetSet tell a visited Limit or Offset of zero from
+	// none.
+	limitSet, offsetSet bool
+	err                 error
+}
+
+func NewEvaluator(fieldMap map[string]string) *Evaluator {
+	return &Evaluator{
+		conditions: []condition{},
+		fieldMap:   fieldMap,
+		accessors:  map[string]func(entity any) any{},
+		orders:     []order{},
+		sample:     -1,
+	}
+}
+
+// WithAccessor registers a function returning the value of a domain field,
+// bypassing reflection for it.
+func (e *Evaluator) WithAccessor(field string, fn func(entity any) any) *Evaluator {
+	e.accessors[field] = fn
+	return e
+}
+
+// WithEmptyComposite sets how And and Or without children are evaluated. The
+// default is specifications.EmptyCompositeSkip.
+func (e *Evaluator) WithEmptyComposite(mode specifications.EmptyComposite) *Evaluator {
+	e.empty = mode
+	return e
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (e *Evaluator) Err() error {
+	return e.err
+}
+
+// Predicate returns the conjunction of all visited conditions. With no
+// conditions every entity matches. Like a WHERE clause, it only matches
+// entities for which the conjunction is true, not unknown.
+func (e *Evaluator) Predicate() Predicate {
+	c := e.condition()
+	return func(entity any) bool { return c(entity) == isTrue }
+}
+
+// condition returns the conjunction of all visited conditions: false if any
+// is false, else unknown if any is unknown.
+func (e *Evaluator) condition() condition {
+	conditions := e.conditions
+	return func(entity any) truth {
+		t := isTrue
+		for _, c := range conditions {
+			switch c(entity) {
+			case isFalse:
+				return isFalse
+			case isUnknown:
+				t = isUnknown
+			}
+		}
+		return t
+	}
+}
+
+func (e *Evaluator) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *Evaluator) child() *Evaluator {
+	c := NewEvaluator(e.fieldMap)
+	c.accessors = e.accessors
+	c.empty = e.empty
+	return c
+}
+
+// get returns a function extracting the value of a domain field.
+func (e *Evaluator) get(field string) func(entity any) any {
+	if field == "" {
+		e.fail(specifications.ErrInvalidField)
+	}
+	if fn, ok := e.accessors[field]; ok {
+		return func(entity any) any { return plain(fn(entity)) }
+	}
+	path := field
+	if mapped, ok := e.fieldMap[field]; ok {
+		path = mapped
+	}
+	return func(entity any) any {
+		v, _ := resolve(entity, path)
+		return v
+	}
+}
+
+func (e *Evaluator) add(c condition) {
+	e.conditions = append(e.conditions, c)
+}
+
+// on adds the condition p on the values of field, which is unknown when the
+// value is NULL, as in SQL.
+func (e *Evaluator) on(field string, p func(got any) bool) {
+	get := e.get(field)
+	e.add(func(entity any) truth {
+		got := get(entity)
+		if got == nil {
+			return isUnknown
+		}
+		return truthOf(p(got))
+	})
+}
+
+// always adds a condition of constant value t.
+func (e *Evaluator) always(t truth) {
+	e.add(func(any) truth { return t })
+}
+
+func (e *Evaluator) compareWith(field string, value interface{}, ok func(c int) bool) {
+	want := plain(value)
+	if want == nil {
+		e.always(isUnknown)
+		return
+	}
+	e.on(field, func(got any) bool {
+		c, comparable := compare(got, want)
+		return comparable && ok(c)
+	})
+}
+
+func (e *Evaluator) VisitEqual(field string, value interface{}) {
+	want := plain(value)
+	if want == nil {
+		e.always(isUnknown)
+		return
+	}
+	e.on(field, func(got any) bool { return equal(got, want) })
+}
+
+func (e *Evaluator) VisitNotEqual(field string, value interface{}) {
+	want := plain(value)
+	if want == nil {
+		e.always(isUnknown)
+		return
+	}
+	e.on(field, func(got any) bool { return !equal(got, want) })
+}
+
+func (e *Evaluator) VisitIn(field string, values []interface{}) {
+	set := normalizeValues(values)
+	if len(set) == 0 {
+		e.always(isFalse)
+		return
+	}
+	e.on(field, func(got any) bool { return contains(set, got) })
+}
+
+func (e *Evaluator) VisitNotIn(field string, values []interface{}) {
+	set := normalizeValues(values)
+	if len(set) == 0 {
+		e.always(isTrue)
+		return
+	}
+	e.on(field, func(got any) bool { return !contains(set, got) })
+}
+
+func (e *Evaluator) VisitEqualAny(field string, values []interface{}) {
+	e.VisitIn(field, values)
+}
+
+// VisitArrayContains matches entities whose slice or array field has an
+// element equal to each of values.
+func (e *Evaluator) VisitArrayContains(field string, values []interface{}) {
+	want := normalizeValues(values)
+	e.on(field, func(v any) bool {
+		got, ok := elements(v)
+		if !ok {
+			return false
+		}
+		for _, w := range want {
+			if !contains(got, w) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (e *Evaluator) VisitArrayOverlaps(field string, values []interface{}) {
+	want := normalizeValues(values)
+	e.on(field, func(v any) bool {
+		got, _ := elements(v)
+		for _, g := range got {
+			if contains(want, g) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func normalizeValues(values []interface{}) []any {
+	set := make([]any, len(values))
+	for i, v := range values {
+		set[i] = plain(v)
+	}
+	return set
+}
+
+func contains(set []any, v any) bool {
+	for _, s := range set {
+		if equal(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Evaluator) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		e.visitEmpty(true)
+		return
+	}
+	sub := e.child()
+	for _, s := range specs {
+		s.Accept(sub)
+	}
+	if len(sub.conditions) > 0 {
+		e.add(sub.condition())
+	}
+	e.merge(sub)
+}
+
+func (e *Evaluator) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		e.visitEmpty(false)
+		return
+	}
+	branches := []condition{}
+	for _, s := range specs {
+		temp := e.child()
+		s.Accept(temp)
+		if len(temp.conditions) > 0 {
+			branches = append(branches, temp.condition())
+		}
+		e.merge(temp)
+	}
+	if len(branches) == 0 {
+		return
+	}
+	e.add(func(entity any) truth {
+		t := isFalse
+		for _, b := range branches {
+			switch b(entity) {
+			case isTrue:
+				return isTrue
+			case isUnknown:
+				t = isUnknown
+			}
+		}
+		return t
+	})
+}
+
+// VisitNot negates spec with three-valued logic, so that Not(Equal("x", 1))
+// does not match entities whose x is NULL, like in SQL.
+func (e *Evaluator) VisitNot(spec specifications.Specification) {
+	sub := e.child()
+	spec.Accept(sub)
+	if len(sub.conditions) > 0 {
+		c := sub.condition()
+		e.add(func(entity any) truth {
+			switch t := c(entity); t {
+			case isTrue:
+				return isFalse
+			case isFalse:
+				return isTrue
+			default:
+				return t
+			}
+		})
+	}
+	e.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being its
+// logical identity.
+func (e *Evaluator) visitEmpty(identity bool) {
+	switch e.empty {
+	case specifications.EmptyCompositeIdentity:
+		e.always(truthOf(identity))
+	case specifications.EmptyCompositeError:
+		e.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but conditions over from a sub-evaluator.
+func (e *Evaluator) merge(sub *Evaluator) {
+	e.orders = append(e.orders, sub.orders...)
+
+	if sub.limitSet {
+		e.limit, e.limitSet = sub.limit, true
+	}
+
+	if sub.offsetSet {
+		e.offset, e.offsetSet = sub.offset, true
+	}
+
+	if sub.sample >= 0 {
+		e.sample = sub.sample
+	}
+
+	if sub.distinct {
+		e.distinct, e.distinctOn = true, sub.distinctOn
+	}
+
+	if sub.err != nil {
+		e.fail(sub.err)
+	}
+}
+
+func (e *Evaluator) VisitLimit(limit int) {
+	if limit < 0 {
+		e.fail(fmt.Errorf("%w: negative limit %d", specifications.ErrInvalidValue, limit))
+		return
+	}
+	e.limit, e.limitSet = limit, true
+}
+
+func (e *Evaluator) VisitOffset(offset int) {
+	if offset < 0 {
+		e.fail(fmt.Errorf("%w: negative offset %d", specifications.ErrInvalidValue, offset))
+		return
+	}
+	e.offset, e.offsetSet = offset, true
+}
+
+func (e *Evaluator) VisitOrder(field, direction string) {
+	e.VisitOrderNulls(field, direction, specifications.NullsDefault)
+}
+
+func (e *Evaluator) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	if field == "" {
+		e.fail(specifications.ErrInvalidField)
+	}
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	if _, err := specifications.ParseNullsPosition(string(nulls)); err != nil {
+		e.fail(err)
+		return
+	}
+	e.orders = append(e.orders, order{field: field, direction: string(d), nulls: nulls})
+}
+
+// VisitTextSearch matches texts holding every word of query, ignoring
+// case. Unlike PostgreSQL, words are not stemmed nor stop words dropped,
+// whatever the language.
+func (e *Evaluator) VisitTextSearch(field, query, language string) {
+	want := words(query)
+	e.on(field, func(v any) bool {
+		text, ok := toString(v)
+		if !ok {
+			return false
+		}
+		have := words(text)
+		for _, w := range want {
+			if !slices.Contains(have, w) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// VisitTextRank orders by decreasing number of occurrences of the words of
+// query in field.
+func (e *Evaluator) VisitTextRank(field, query, language string) {
+	get := e.get(field)
+	want := words(query)
+	rank := func(entity any) any {
+		text, _ := toString(get(entity))
+		n := 0
+		for _, w := range words(text) {
+			if slices.Contains(want, w) {
+				n++
+			}
+		}
+		return n
+	}
+	e.orders = append(e.orders, order{field: field, direction: string(specifications.Descending), key: rank})
+}
+
+// DefaultSimilarityThreshold is the threshold of SimilarTo without one,
+// the default of pg_trgm.similarity_threshold.
+const DefaultSimilarityThreshold = 0.3
+
+// VisitSimilar matches texts whose trigram similarity to value, computed
+// like pg_trgm's, is above threshold.
+func (e *Evaluator) VisitSimilar(field, value string, threshold float64) {
+	if threshold < 0 || threshold > 1 {
+		e.fail(fmt.Errorf("%w: similarity threshold %v", specifications.ErrInvalidValue, threshold))
+		return
+	}
+	if threshold == 0 {
+		threshold = DefaultSimilarityThreshold
+	}
+	want := trigrams(value)
+	e.on(field, func(v any) bool {
+		text, ok := toString(v)
+		return ok && similarity(trigrams(text), want) > threshold
+	})
+}
+
+// VisitSimilarityOrder orders by decreasing trigram similarity to value.
+func (e *Evaluator) VisitSimilarityOrder(field, value string) {
+	get := e.get(field)
+	want := trigrams(value)
+	score := func(entity any) any {
+		text, _ := toString(get(entity))
+		return similarity(trigrams(text), want)
+	}
+	e.orders = append(e.orders, order{field: field, direction: string(specifications.Descending), key: score})
+}
+
+// trigrams returns the set of trigrams of the words of text, each padded
+// with two spaces before and one after, like pg_trgm.
+func trigrams(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range words(text) {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// similarity returns the number of trigrams a and b share divided by the
+// number of distinct trigrams in either.
+func similarity(a, b map[string]bool) float64 {
+	shared := 0
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// words splits text into lower-case words of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (e *Evaluator) VisitGreaterThan(field string, value interface{}) {
+	e.compareWith(field, value, func(c int) bool { return c > 0 })
+}
+
+func (e *Evaluator) VisitLowerThan(field string, value interface{}) {
+	e.compareWith(field, value, func(c int) bool { return c < 0 })
+}
+
+func (e *Evaluator) VisitGreaterThanOrEqual(field string, value interface{}) {
+	e.compareWith(field, value, func(c int) bool { return c >= 0 })
+}
+
+func (e *Evaluator) VisitLowerThanOrEqual(field string, value interface{}) {
+	e.compareWith(field, value, func(c int) bool { return c <= 0 })
+}
+
+func (e *Evaluator) VisitBetween(field string, low, high interface{}) {
+	lo, hi := plain(low), plain(high)
+	get := e.get(field)
+	e.add(func(entity any) truth {
+		got := get(entity)
+		if got == nil {
+			return isUnknown
+		}
+		// As in SQL, the comparison with a NULL bound is unknown, not the
+		// whole Between: it can still be false on the other bound.
+		l := bounded(got, lo, func(c int) bool { return c >= 0 })
+		h := bounded(got, hi, func(c int) bool { return c <= 0 })
+		switch {
+		case l == isFalse || h == isFalse:
+			return isFalse
+		case l == isUnknown || h == isUnknown:
+			return isUnknown
+		}
+		return isTrue
+	})
+}
+
+// bounded compares got with the bound of a Between, which is unknown when
+// the bound is NULL.
+func bounded(got, bound any, ok func(c int) bool) truth {
+	if bound == nil {
+		return isUnknown
+	}
+	c, comparable := compare(got, bound)
+	return truthOf(comparable && ok(c))
+}
+
+func (e *Evaluator) VisitIsNull(field string) {
+	get := e.get(field)
+	e.add(func(entity any) truth { return truthOf(get(entity) == nil) })
+}
+
+func (e *Evaluator) VisitIsNotNull(field string) {
+	get := e.get(field)
+	e.add(func(entity any) truth { return truthOf(get(entity) != nil) })
+}
+
+func (e *Evaluator) VisitLike(field string, value interface{}) {
+	e.like(field, value, false)
+}
+
+func (e *Evaluator) VisitILike(field string, value interface{}) {
+	e.like(field, value, true)
+}
+
+func (e *Evaluator) VisitLikeEscaped(field string, pattern string) {
+	e.like(field, pattern, false)
+}
+
+func (e *Evaluator) like(field string, pattern interface{}, caseInsensitive bool) {
+	p, ok := toString(plain(pattern))
+	if !ok {
+		e.fail(fmt.Errorf("%w: LIKE pattern for %q must be a string", specifications.ErrInvalidValue, field))
+		return
+	}
+	e.match(field, specifications.LikeToRegexp(p), caseInsensitive)
+}
+
+func (e *Evaluator) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	e.match(field, pattern, caseInsensitive)
+}
+
+func (e *Evaluator) match(field string, pattern string, caseInsensitive bool) {
+	flags := "(?s)"
+	if caseInsensitive {
+		flags = "(?is)"
+	}
+	re, err := regexp.Compile(flags + pattern)
+	if err != nil {
+		e.fail(fmt.Errorf("%w: pattern for %q: %v", specifications.ErrInvalidValue, field, err))
+		return
+	}
+	e.on(field, func(v any) bool {
+		s, ok := toString(v)
+		return ok && re.MatchString(s)
+	})
+}
+
+// document decodes the JSON document of field, as a Go value or JSON text.
+// Conditions on entities whose document is missing or invalid are unknown.
+func (e *Evaluator) document(field string) func(entity any) (interface{}, bool) {
+	get := e.get(field)
+	return func(entity any) (interface{}, bool) {
+		v := get(entity)
+		if v == nil {
+			return nil, false
+		}
+		doc, err := jsondoc.Decode(v)
+		return doc, err == nil
+	}
+}
+
+func (e *Evaluator) VisitJSONContains(field string, doc interface{}) {
+	sub, err := jsondoc.Decode(doc)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	get := e.document(field)
+	e.add(func(entity any) truth {
+		d, ok := get(entity)
+		if !ok {
+			return isUnknown
+		}
+		return truthOf(jsondoc.Contains(d, sub))
+	})
+}
+
+func (e *Evaluator) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	get := e.document(field)
+	if value == nil {
+		e.add(func(entity any) truth {
+			d, ok := get(entity)
+			if !ok {
+				return isTrue
+			}
+			v, ok := jsondoc.Path(d, path)
+			return truthOf(!ok || v == nil)
+		})
+		return
+	}
+	want, err := jsondoc.Text(value)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	e.add(func(entity any) truth {
+		d, ok := get(entity)
+		if !ok {
+			return isUnknown
+		}
+		v, ok := jsondoc.Path(d, path)
+		if !ok || v == nil {
+			return isUnknown
+		}
+		text, err := jsondoc.Text(v)
+		return truthOf(err == nil && text == want)
+	})
+}
+
+func (e *Evaluator) VisitJSONKeyExists(field, key string) {
+	get := e.document(field)
+	e.add(func(entity any) truth {
+		d, ok := get(entity)
+		if !ok {
+			return isUnknown
+		}
+		return truthOf(jsondoc.HasKey(d, key))
+	})
+}
+
+// VisitWithinRadius matches specifications.Point values at most meters
+// away from center, on a sphere, which differs from PostGIS' spheroid by
+// up to 0.5%.
+func (e *Evaluator) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	e.on(field, func(v any) bool {
+		p, ok := toPoint(v)
+		return ok && distance(p, center) <= meters
+	})
+}
+
+func (e *Evaluator) VisitWithinBox(field string, box specifications.BoundingBox) {
+	e.on(field, func(v any) bool {
+		p, ok := toPoint(v)
+		return ok && p.Lat >= box.MinLat && p.Lat <= box.MaxLat && p.Lng >= box.MinLng && p.Lng <= box.MaxLng
+	})
+}
+
+// VisitOrderByDistance orders by increasing distance from from, entities
+// without a location last.
+func (e *Evaluator) VisitOrderByDistance(field string, from specifications.Point) {
+	get := e.get(field)
+	key := func(entity any) any {
+		p, ok := toPoint(get(entity))
+		if !ok {
+			return math.Inf(1)
+		}
+		return distance(p, from)
+	}
+	e.orders = append(e.orders, order{field: field, direction: string(specifications.Ascending), key: key})
+}
+
+// VisitRelated is not supported: entities hold no related entities to
+// evaluate spec on. Expand it with specifications.ExpandRelated first.
+func (e *Evaluator) VisitRelated(relation string, spec specifications.Specification) {
+	e.fail(fmt.Errorf("%w: related specification on %q in memory", specifications.ErrUnsupported, relation))
+}
+
+func toPoint(v any) (specifications.Point, bool) {
+	switch p := v.(type) {
+	case specifications.Point:
+		return p, true
+	case *specifications.Point:
+		if p != nil {
+			return *p, true
+		}
+	}
+	return specifications.Point{}, false
+}
+
+// earthRadius is the mean radius of the Earth in meters.
+const earthRadius = 6371008.8
+
+// distance returns the great-circle distance between a and b in meters.
+func distance(a, b specifications.Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLng := lat2-lat1, (b.Lng-a.Lng)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// VisitDistinct makes Filter drop entities equal to an earlier one, or
+// with fields, whose values of fields equal those of an earlier one. Entities
+// are compared in order, so the first of each group is kept.
+func (e *Evaluator) VisitDistinct(fields []string) {
+	for _, f := range fields {
+		if f == "" {
+			e.fail(specifications.ErrInvalidField)
+			return
+		}
+	}
+	e.distinct, e.distinctOn = true, fields
+}
+
+// VisitLock is a no-op: entities in memory are not locked, so in-memory
+// fakes of transactional repositories accept locking specifications.
+func (e *Evaluator) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	if _, _, err := specifications.ParseLock(string(strength), string(wait)); err != nil {
+		e.fail(err)
+	}
+}
+
+// VisitAggregate is not supported, like VisitGroupBy.
+func (e *Evaluator) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	e.fail(fmt.Errorf("%w: aggregates in memory", specifications.ErrUnsupported))
+}
+
+// VisitGroupBy is not supported: Filter returns entities, not groups.
+func (e *Evaluator) VisitGroupBy(fields []string) {
+	e.fail(fmt.Errorf("%w: grouping in memory", specifications.ErrUnsupported))
+}
+
+// VisitHaving is not supported, like VisitGroupBy.
+func (e *Evaluator) VisitHaving(spec specifications.Specification) {
+	e.fail(fmt.Errorf("%w: grouping in memory", specifications.ErrUnsupported))
+}
+
+// VisitSample keeps each entity with probability percent/100. Both sample
+// methods behave like BERNOULLI in memory.
+func (e *Evaluator) VisitSample(percent float64, method specifications.SampleMethod) {
+	if method != specifications.SampleSystem && method != specifications.SampleBernoulli {
+		e.fail(fmt.Errorf("%w: sample method %q", specifications.ErrUnsupported, method))
+		return
+	}
+	if percent < 0 || percent > 100 {
+		e.fail(fmt.Errorf("%w: sample percent %v out of range [0, 100]", specifications.ErrInvalidValue, percent))
+		return
+	}
+	e.sample = percent
+}
+
+func (e *Evaluator) sampled() bool {
+	return e.sample < 0 || rand.Float64()*100 < e.sample
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Compile visits spec with a new Evaluator and returns its predicate.
+func Compile(spec specifications.Specification, fieldMap map[string]string) (Predicate, error) {
+	e := NewEvaluator(fieldMap)
+	if err := specifications.Apply(spec, e); err != nil {
+		return nil, err
+	}
+	return e.Predicate(), nil
+}
+
+// Filter returns the items matching spec, honoring its order, offset, limit
+// and sample specifications. Fields are resolved by name; use FilterWith for
+// a field map or custom accessors. Filter returns nil if spec is invalid.
+func Filter[T any](items []T, spec specifications.Specification) []T {
+	out, err := FilterWith(NewEvaluator(nil), items, spec)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// FilterWith is like Filter but uses the given, unvisited Evaluator and
+// returns the error of an invalid spec.
+func FilterWith[T any](e *Evaluator, items []T, spec specifications.Specification) ([]T, error) {
+	if err := specifications.Apply(spec, e); err != nil {
+		return nil, err
+	}
+
+	match := e.Predicate()
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if e.sampled() && match(item) {
+			out = append(out, item)
+		}
+	}
+
+	if len(e.orders) > 0 {
+		getters := make([]func(entity any) any, len(e.orders))
+		for i, o := range e.orders {
+			getters[i] = o.key
+			if o.key == nil {
+				getters[i] = e.get(o.field)
+			}
+		}
+		slices.SortStableFunc(out, func(a, b T) int {
+			for i, o := range e.orders {
+				if c := compareForOrder(getters[i](a), getters[i](b), o.direction, o.nulls); c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+
+	if e.distinct {
+		out = distinct(e, out)
+	}
+
+	if e.offset > 0 {
+		if e.offset >= len(out) {
+			return out[:0], nil
+		}
+		out = out[e.offset:]
+	}
+	if e.limitSet && e.limit < len(out) {
+		out = out[:e.limit]
+	}
+	return out, nil
+}
+
+// distinct keeps the first of the items equal to each other, or with
+// distinctOn, having equal values of these fields.
+func distinct[T any](e *Evaluator, items []T) []T {
+	getters := make([]func(entity any) any, len(e.distinctOn))
+	for i, f := range e.distinctOn {
+		getters[i] = e.get(f)
+	}
+	key := func(item T) any {
+		if len(getters) == 0 {
+			return item
+		}
+		values := make([]any, len(getters))
+		for i, get := range getters {
+			values[i] = get(item)
+		}
+		return values
+	}
+
+	out := items[:0]
+	var kept []any
+	for _, item := range items {
+		k := key(item)
+		if !slices.ContainsFunc(kept, func(seen any) bool { return reflect.DeepEqual(seen, k) }) {
+			kept = append(kept, k)
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// compareForOrder orders values like PostgreSQL: by default NULLs sort as
+// larger than any other value, so they come last ascending and first
+// descending. Incomparable values keep their relative order.
+func compareForOrder(a, b any, direction string, nulls specifications.NullsPosition) int {
+	desc := strings.EqualFold(strings.TrimSpace(direction), "DESC")
+	if nulls == specifications.NullsDefault {
+		nulls = specifications.NullsLast
+		if desc {
+			nulls = specifications.NullsFirst
+		}
+	}
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil && nulls == specifications.NullsFirst, b == nil && nulls == specifications.NullsLast:
+		return -1
+	case a == nil, b == nil:
+		return 1
+	}
+	c, _ := compare(a, b)
+	if desc {
+		return -c
+	}
+	return c
+}
@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// resolve looks up a dotted field path on entity. Structs are matched by Go
+// field name (case-insensitively as a fallback) and maps by string key.
+// Pointers and interfaces are dereferenced on the way. The second result is
+// false when the path cannot be resolved.
+func resolve(entity any, path string) (any, bool) {
+	rv := reflect.ValueOf(entity)
+	for _, part := range strings.Split(path, ".") {
+		rv = indirect(rv)
+		if !rv.IsValid() {
+			return nil, false
+		}
+		switch rv.Kind() {
+		case reflect.Struct:
+			f := rv.FieldByName(part)
+			if !f.IsValid() {
+				f = rv.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, part) })
+			}
+			if !f.IsValid() || !f.CanInterface() {
+				return nil, false
+			}
+			rv = f
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			f := rv.MapIndex(reflect.ValueOf(part).Convert(rv.Type().Key()))
+			if !f.IsValid() {
+				return nil, false
+			}
+			rv = f
+		default:
+			return nil, false
+		}
+	}
+	return normalize(rv), true
+}
+
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.IsValid() && (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// normalize turns a resolved value into plain data: nil pointers become nil,
+// pointers are dereferenced and driver.Valuer types (sql.NullString, ...)
+// are unwrapped.
+func normalize(rv reflect.Value) any {
+	if !rv.IsValid() {
+		return nil
+	}
+	if rv.CanInterface() {
+		if valuer, ok := rv.Interface().(driver.Valuer); ok {
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				return nil
+			}
+			v, err := valuer.Value()
+			if err != nil {
+				return nil
+			}
+			return v
+		}
+	}
+	rv = indirect(rv)
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// plain normalizes a value supplied by a specification the same way entity
+// values are normalized.
+func plain(v any) any {
+	return normalize(reflect.ValueOf(v))
+}
+
+// elements returns the normalized elements of a slice or array other than
+// []byte, and false for other values.
+func elements(v any) ([]any, bool) {
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = normalize(rv.Index(i))
+	}
+	return out, true
+}
+
+// equal reports whether a and b are equal. Numbers of different types are
+// compared by value. NULL is never equal to anything, as in SQL.
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders a and b. The second result is false when the values are not
+// comparable (different kinds, NULL, unsupported types).
+func compare(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb), true
+		}
+		return 0, false
+	}
+
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isString(ra) && isString(rb):
+		return strings.Compare(ra.String(), rb.String()), true
+	case isBool(ra) && isBool(rb):
+		x, y := ra.Bool(), rb.Bool()
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case isInt(ra) && isInt(rb):
+		return cmpOrdered(ra.Int(), rb.Int()), true
+	case isUint(ra) && isUint(rb):
+		return cmpOrdered(ra.Uint(), rb.Uint()), true
+	case isInt(ra) && isUint(rb):
+		if ra.Int() < 0 {
+			return -1, true
+		}
+		return cmpOrdered(uint64(ra.Int()), rb.Uint()), true
+	case isUint(ra) && isInt(rb):
+		if rb.Int() < 0 {
+			return 1, true
+		}
+		return cmpOrdered(ra.Uint(), uint64(rb.Int())), true
+	case isNumber(ra) && isNumber(rb):
+		return cmpOrdered(toFloat(ra), toFloat(rb)), true
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | uint64 | float64](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func isString(rv reflect.Value) bool { return rv.Kind() == reflect.String }
+
+func isBool(rv reflect.Value) bool { return rv.Kind() == reflect.Bool }
+
+func isInt(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isNumber(rv reflect.Value) bool {
+	return isInt(rv) || isUint(rv) || rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64
+}
+
+func toFloat(rv reflect.Value) float64 {
+	switch {
+	case isInt(rv):
+		return float64(rv.Int())
+	case isUint(rv):
+		return float64(rv.Uint())
+	default:
+		return rv.Float()
+	}
+}
+
+// toString returns the string form of a string-kinded value.
+func toString(v any) (string, bool) {
+	rv := reflect.ValueOf(v)
+	if v == nil || !isString(rv) {
+		return "", false
+	}
+	return rv.String(), true
+}
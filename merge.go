@@ -0,0 +1,301 @@
+package specifications
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MergeStrategy decides how Merge combines the conditions base and
+// override both have on a field.
+type MergeStrategy int
+
+const (
+	// MergeIntersect keeps the conditions of both, so that results match
+	// both. When all of them are range bounds (GreaterThan, LowerThan and
+	// their variants, Between) on comparable values, they are combined into
+	// the tightest range.
+	MergeIntersect MergeStrategy = iota
+	// MergePreferOverride drops the conditions of base on the fields
+	// override has conditions on.
+	MergePreferOverride
+	// MergeError makes Merge report ErrMergeConflict.
+	MergeError
+)
+
+// Merge layers override, e.g. a user's filter, on top of base, e.g. a
+// preset, and returns the conjunction of their top-level conditions. The
+// conditions both have on the same field are combined by strategy. Other
+// parts, such as Or, Not, ordering and pagination, are kept from both,
+// those of override last, so that its Limit and Offset take precedence.
+func Merge(base, override Specification, strategy MergeStrategy) (Specification, error) {
+	if base == nil {
+		return override, nil
+	}
+	if override == nil {
+		return base, nil
+	}
+	baseParts, overrideParts := splitConditions(base), splitConditions(override)
+	overridden := map[string]bool{}
+	for _, p := range overrideParts {
+		if p.field != "" {
+			overridden[p.field] = true
+		}
+	}
+
+	var out []Specification
+	switch strategy {
+	case MergeIntersect:
+		out = intersect(append(baseParts, overrideParts...))
+	case MergePreferOverride, MergeError:
+		for _, p := range baseParts {
+			if overridden[p.field] {
+				if strategy == MergeError {
+					return nil, fmt.Errorf("%w: both specifications have conditions on %q", ErrMergeConflict, p.field)
+				}
+				continue
+			}
+			out = append(out, p.spec)
+		}
+		for _, p := range overrideParts {
+			out = append(out, p.spec)
+		}
+	default:
+		return nil, fmt.Errorf("%w: merge strategy %d", ErrInvalidValue, strategy)
+	}
+	if len(out) == 1 {
+		return out[0], nil
+	}
+	return And(out...), nil
+}
+
+// conditionPart is a top-level part of a specification, with the field it
+// is a condition on, if any.
+type conditionPart struct {
+	condition
+	spec Specification
+}
+
+// splitConditions returns the parts of the top-level conjunction of spec.
+func splitConditions(spec Specification) []conditionPart {
+	if and, ok := spec.(*andSpec); ok {
+		var out []conditionPart
+		for _, s := range and.specs {
+			out = append(out, splitConditions(s)...)
+		}
+		return out
+	}
+	c := &condition{}
+	spec.Accept(c)
+	if c.visits != 1 {
+		// Custom specifications may visit any number of methods.
+		c = &condition{}
+	}
+	return []conditionPart{{condition: *c, spec: spec}}
+}
+
+// intersect combines the range bounds on each field whose conditions are
+// all range bounds, in place of the first of them.
+func intersect(parts []conditionPart) []Specification {
+	byField := map[string][]conditionPart{}
+	for _, p := range parts {
+		if p.field != "" {
+			byField[p.field] = append(byField[p.field], p)
+		}
+	}
+	done := map[string]bool{}
+	var out []Specification
+	for _, p := range parts {
+		if done[p.field] {
+			continue
+		}
+		if combined, ok := tightest(byField[p.field]); ok {
+			out = append(out, combined...)
+			done[p.field] = true
+			continue
+		}
+		out = append(out, p.spec)
+	}
+	return out
+}
+
+// tightest returns the range matching every part, when there are several
+// and all are range bounds on comparable values.
+func tightest(parts []conditionPart) ([]Specification, bool) {
+	if len(parts) < 2 {
+		return nil, false
+	}
+	var lower, upper *rangeBound
+	for _, p := range parts {
+		if !p.ranged {
+			return nil, false
+		}
+		var ok bool
+		if lower, ok = tighter(lower, p.lower, 1); !ok {
+			return nil, false
+		}
+		if upper, ok = tighter(upper, p.upper, -1); !ok {
+			return nil, false
+		}
+	}
+	field := parts[0].field
+	if lower != nil && upper != nil && lower.inclusive && upper.inclusive {
+		return []Specification{Between(field, lower.value, upper.value)}, true
+	}
+	var out []Specification
+	switch {
+	case lower == nil:
+	case lower.inclusive:
+		out = append(out, GreaterThanOrEqual(field, lower.value))
+	default:
+		out = append(out, GreaterThan(field, lower.value))
+	}
+	switch {
+	case upper == nil:
+	case upper.inclusive:
+		out = append(out, LowerThanOrEqual(field, upper.value))
+	default:
+		out = append(out, LowerThan(field, upper.value))
+	}
+	return out, true
+}
+
+// tighter returns the tighter of the bounds a and b, sign being 1 for lower
+// bounds and -1 for upper ones. ok is false for incomparable values.
+func tighter(a, b *rangeBound, sign int) (bound *rangeBound, ok bool) {
+	if a == nil || b == nil {
+		if a == nil {
+			return b, true
+		}
+		return a, true
+	}
+	c, ok := compareValues(b.value, a.value)
+	switch {
+	case !ok:
+		return nil, false
+	case c*sign > 0:
+		return b, true
+	case c == 0:
+		return &rangeBound{value: a.value, inclusive: a.inclusive && b.inclusive}, true
+	}
+	return a, true
+}
+
+// compareValues compares numbers, strings and times. ok is false for other
+// values and values of different kinds.
+func compareValues(a, b interface{}) (c int, ok bool) {
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return strings.Compare(x, y), ok
+	case time.Time:
+		y, ok := b.(time.Time)
+		return x.Compare(y), ok
+	}
+	x, ok := number(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := number(b)
+	return cmp.Compare(x, y), ok
+}
+
+func number(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+type rangeBound struct {
+	value     interface{}
+	inclusive bool
+}
+
+// condition is a SpecificationVisitor recording the field a leaf
+// specification is a condition on, and its bounds for range conditions.
+// Composites, ordering and pagination have no field.
+type condition struct {
+	field        string
+	ranged       bool
+	lower, upper *rangeBound
+	visits       int
+}
+
+func (c *condition) on(field string) {
+	c.field = field
+	c.visits++
+}
+
+func (c *condition) bound(field string, lower, upper *rangeBound) {
+	c.on(field)
+	c.ranged = true
+	c.lower, c.upper = lower, upper
+}
+
+func (c *condition) VisitGreaterThan(field string, value interface{}) {
+	c.bound(field, &rangeBound{value: value}, nil)
+}
+
+func (c *condition) VisitGreaterThanOrEqual(field string, value interface{}) {
+	c.bound(field, &rangeBound{value: value, inclusive: true}, nil)
+}
+
+func (c *condition) VisitLowerThan(field string, value interface{}) {
+	c.bound(field, nil, &rangeBound{value: value})
+}
+
+func (c *condition) VisitLowerThanOrEqual(field string, value interface{}) {
+	c.bound(field, nil, &rangeBound{value: value, inclusive: true})
+}
+
+func (c *condition) VisitBetween(field string, low, high interface{}) {
+	c.bound(field, &rangeBound{value: low, inclusive: true}, &rangeBound{value: high, inclusive: true})
+}
+
+func (c *condition) VisitEqual(field string, _ interface{})                     { c.on(field) }
+func (c *condition) VisitNotEqual(field string, _ interface{})                  { c.on(field) }
+func (c *condition) VisitIn(field string, _ []interface{})                      { c.on(field) }
+func (c *condition) VisitNotIn(field string, _ []interface{})                   { c.on(field) }
+func (c *condition) VisitIsNull(field string)                                   { c.on(field) }
+func (c *condition) VisitIsNotNull(field string)                                { c.on(field) }
+func (c *condition) VisitLike(field string, _ interface{})                      { c.on(field) }
+func (c *condition) VisitILike(field string, _ interface{})                     { c.on(field) }
+func (c *condition) VisitLikeEscaped(field string, _ string)                    { c.on(field) }
+func (c *condition) VisitRegex(field string, _ string, _ bool)                  { c.on(field) }
+func (c *condition) VisitJSONContains(field string, _ interface{})              { c.on(field) }
+func (c *condition) VisitJSONPathEqual(field string, _ []string, _ interface{}) { c.on(field) }
+func (c *condition) VisitJSONKeyExists(field, _ string)                         { c.on(field) }
+func (c *condition) VisitArrayContains(field string, _ []interface{})           { c.on(field) }
+func (c *condition) VisitArrayOverlaps(field string, _ []interface{})           { c.on(field) }
+func (c *condition) VisitEqualAny(field string, _ []interface{})                { c.on(field) }
+func (c *condition) VisitTextSearch(field, _, _ string)                         { c.on(field) }
+func (c *condition) VisitSimilar(field, _ string, _ float64)                    { c.on(field) }
+func (c *condition) VisitWithinRadius(field string, _ Point, _ float64)         { c.on(field) }
+func (c *condition) VisitWithinBox(field string, _ BoundingBox)                 { c.on(field) }
+
+func (c *condition) VisitAnd([]Specification)                        { c.visits++ }
+func (c *condition) VisitOr([]Specification)                         { c.visits++ }
+func (c *condition) VisitNot(Specification)                          { c.visits++ }
+func (c *condition) VisitLimit(int)                                  { c.visits++ }
+func (c *condition) VisitOffset(int)                                 { c.visits++ }
+func (c *condition) VisitOrder(string, string)                       { c.visits++ }
+func (c *condition) VisitOrderNulls(string, string, NullsPosition)   { c.visits++ }
+func (c *condition) VisitDistinct([]string)                          { c.visits++ }
+func (c *condition) VisitGroupBy([]string)                           { c.visits++ }
+func (c *condition) VisitHaving(Specification)                       { c.visits++ }
+func (c *condition) VisitAggregate(AggregateFunction, Specification) { c.visits++ }
+func (c *condition) VisitLock(LockStrength, LockWait)                { c.visits++ }
+func (c *condition) VisitSample(float64, SampleMethod)               { c.visits++ }
+func (c *condition) VisitTextRank(string, string, string)            { c.visits++ }
+func (c *condition) VisitSimilarityOrder(string, string)             { c.visits++ }
+func (c *condition) VisitOrderByDistance(string, Point)              { c.visits++ }
+func (c *condition) VisitRelated(string, Specification)              { c.visits++ }
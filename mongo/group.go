@@ -0,0 +1,118 @@
+package mongo
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// grouping is the $group stage of a pipeline and the $match stage
+// following it.
+type grouping struct {
+	// fields are the grouped fields.
+	fields []string
+	// accumulators are the aggregates computed per group, by output field.
+	accumulators bson.D
+	// having are the conditions on the groups.
+	having []bson.M
+}
+
+// group returns the grouping of v, creating it.
+func (v *Visitor) group() *grouping {
+	if v.grouping == nil {
+		v.grouping = &grouping{}
+	}
+	return v.grouping
+}
+
+func (g *grouping) merge(sub *grouping) {
+	g.fields = append(g.fields, sub.fields...)
+	for _, a := range sub.accumulators {
+		g.accumulate(a.Key, a.Value)
+	}
+	g.having = append(g.having, sub.having...)
+}
+
+func (g *grouping) accumulate(name string, expr interface{}) {
+	if !slices.ContainsFunc(g.accumulators, func(a bson.E) bool { return a.Key == name }) {
+		g.accumulators = append(g.accumulators, bson.E{Key: name, Value: expr})
+	}
+}
+
+// accumulate adds the accumulator of v's aggregate over domainField, and
+// returns the field holding it.
+func (v *Visitor) accumulate(domainField string) string {
+	name := strings.ToLower(string(v.aggregate))
+	if domainField == "*" {
+		if v.aggregate != specifications.AggregateCount {
+			v.fail(fmt.Errorf("%w: %s(*)", specifications.ErrInvalidField, v.aggregate))
+			return name
+		}
+		v.group().accumulate(name, bson.M{"$sum": 1})
+		return name
+	}
+	dbField := domainField
+	if mapped, ok := v.fieldMap[domainField]; ok {
+		dbField = mapped
+	}
+	name += "_" + strings.ReplaceAll(dbField, ".", "_")
+	var expr interface{}
+	switch v.aggregate {
+	case specifications.AggregateCount:
+		// Like COUNT(field), count the values that are neither null nor
+		// missing.
+		expr = bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$" + dbField, nil}}, nil}}, 0, 1}}}
+	case specifications.AggregateSum:
+		expr = bson.M{"$sum": "$" + dbField}
+	case specifications.AggregateMin:
+		expr = bson.M{"$min": "$" + dbField}
+	case specifications.AggregateMax:
+		expr = bson.M{"$max": "$" + dbField}
+	case specifications.AggregateAvg:
+		expr = bson.M{"$avg": "$" + dbField}
+	case specifications.AggregateStddev:
+		expr = bson.M{"$stdDevSamp": "$" + dbField}
+	default:
+		v.fail(fmt.Errorf("%w: %s aggregate in a MongoDB pipeline", specifications.ErrUnsupported, v.aggregate))
+		return name
+	}
+	v.group().accumulate(name, expr)
+	return name
+}
+
+// stages returns the $group stage, a $project stage moving the grouped
+// fields back to their paths alongside the aggregates, and the $match
+// stage of the conditions on the groups.
+func (g *grouping) stages() []bson.D {
+	var id interface{}
+	project := bson.D{}
+	if len(g.fields) > 0 {
+		key := bson.D{}
+		for i, f := range g.fields {
+			key = append(key, bson.E{Key: "f" + strconv.Itoa(i), Value: "$" + f})
+			project = append(project, bson.E{Key: f, Value: "$_id.f" + strconv.Itoa(i)})
+		}
+		id = key
+	}
+	if !slices.Contains(g.fields, "_id") {
+		project = append(bson.D{{Key: "_id", Value: 0}}, project...)
+	}
+	group := bson.D{{Key: "_id", Value: id}}
+	for _, a := range g.accumulators {
+		group = append(group, a)
+		project = append(project, bson.E{Key: a.Key, Value: 1})
+	}
+	stages := []bson.D{
+		{{Key: "$group", Value: group}},
+		{{Key: "$project", Value: project}},
+	}
+	if len(g.having) > 0 {
+		stages = append(stages, bson.D{{Key: "$match", Value: conjunction(g.having)}})
+	}
+	return stages
+}
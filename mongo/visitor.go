@@ -0,0 +1,604 @@
+package mongo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Visitor translates specifications into a MongoDB find filter and find
+// options (sort, limit and skip), or into an aggregation pipeline.
+type Visitor struct {
+	filters    []bson.M
+	fieldMap   map[string]string
+	sort       bson.D
+	limit      int
+	offset     int
+	pipeline   bool
+	sample     float64
+	distinctOn []string
+	empty      specifications.EmptyComposite
+	// limitSet and offsetSet tell a visited Limit or Offset of zero from
+	// none.
+	limitSet, offsetSet bool
+	// grouping is the $group stage of pipelines, when grouped.
+	grouping *grouping
+	// aggregate is applied to the fields while visiting the specification
+	// of an Aggregated.
+	aggregate specifications.AggregateFunction
+	// inHaving is set while visiting the specification of a Having, whose
+	// conditions may be on aggregates.
+	inHaving bool
+	err      error
+}
+
+// Option configures a Visitor.
+type Option func(*Visitor)
+
+// WithEmptyComposite sets how And and Or without children are translated.
+// The default is specifications.EmptyCompositeSkip.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return func(v *Visitor) {
+		v.empty = mode
+	}
+}
+
+// WithPipeline prepares the visitor for Pipeline rather than Filter and
+// FindOptions. Only pipelines support sampling.
+func WithPipeline() Option {
+	return func(v *Visitor) {
+		v.pipeline = true
+	}
+}
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	v := &Visitor{
+		filters:  []bson.M{},
+		fieldMap: fieldMap,
+		sort:     bson.D{},
+		sample:   -1,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// child returns an empty visitor sharing v's configuration, used for
+// composite specifications.
+func (v *Visitor) child() *Visitor {
+	c := NewVisitor(v.fieldMap, WithEmptyComposite(v.empty))
+	c.pipeline = v.pipeline
+	c.aggregate = v.aggregate
+	c.inHaving = v.inHaving
+	return c
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (v *Visitor) Err() error {
+	return v.err
+}
+
+func (v *Visitor) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+func (v *Visitor) mapField(domainField string) string {
+	if domainField == "" {
+		v.fail(specifications.ErrInvalidField)
+	}
+	if v.aggregate != "" {
+		return v.accumulate(domainField)
+	}
+	if dbField, ok := v.fieldMap[domainField]; ok {
+		return dbField
+	}
+	return domainField
+}
+
+func (v *Visitor) add(field string, operator string, value interface{}) {
+	v.filters = append(v.filters, bson.M{v.mapField(field): bson.M{operator: value}})
+}
+
+func (v *Visitor) VisitEqual(field string, value interface{}) {
+	v.add(field, "$eq", value)
+}
+
+func (v *Visitor) VisitNotEqual(field string, value interface{}) {
+	v.add(field, "$ne", value)
+}
+
+func (v *Visitor) VisitIn(field string, values []interface{}) {
+	v.add(field, "$in", bson.A(values))
+}
+
+func (v *Visitor) VisitNotIn(field string, values []interface{}) {
+	v.add(field, "$nin", bson.A(values))
+}
+
+func (v *Visitor) VisitEqualAny(field string, values []interface{}) {
+	v.VisitIn(field, values)
+}
+
+// VisitArrayContains uses $all, or matches any array without values, as
+// $all with an empty list matches nothing.
+func (v *Visitor) VisitArrayContains(field string, values []interface{}) {
+	if len(values) == 0 {
+		v.add(field, "$type", "array")
+		return
+	}
+	v.add(field, "$all", bson.A(values))
+}
+
+// VisitArrayOverlaps uses $in, which matches arrays with an element in the
+// list.
+func (v *Visitor) VisitArrayOverlaps(field string, values []interface{}) {
+	v.add(field, "$in", bson.A(values))
+}
+
+func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
+	v.add(field, "$gt", value)
+}
+
+func (v *Visitor) VisitLowerThan(field string, value interface{}) {
+	v.add(field, "$lt", value)
+}
+
+func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
+	v.add(field, "$gte", value)
+}
+
+func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
+	v.add(field, "$lte", value)
+}
+
+func (v *Visitor) VisitBetween(field string, low, high interface{}) {
+	v.filters = append(v.filters, bson.M{v.mapField(field): bson.M{"$gte": low, "$lte": high}})
+}
+
+// VisitIsNull matches documents where the field is null or missing.
+func (v *Visitor) VisitIsNull(field string) {
+	v.add(field, "$eq", nil)
+}
+
+func (v *Visitor) VisitIsNotNull(field string) {
+	v.add(field, "$ne", nil)
+}
+
+func (v *Visitor) VisitLike(field string, value interface{}) {
+	v.like(field, value, "")
+}
+
+func (v *Visitor) VisitILike(field string, value interface{}) {
+	v.like(field, value, "i")
+}
+
+func (v *Visitor) VisitLikeEscaped(field string, pattern string) {
+	v.like(field, pattern, "")
+}
+
+func (v *Visitor) like(field string, value interface{}, options string) {
+	pattern, ok := value.(string)
+	if !ok {
+		v.fail(fmt.Errorf("%w: LIKE pattern for %q must be a string", specifications.ErrInvalidValue, field))
+		return
+	}
+	v.add(field, "$regex", bson.Regex{Pattern: specifications.LikeToRegexp(pattern), Options: "s" + options})
+}
+
+func (v *Visitor) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	options := ""
+	if caseInsensitive {
+		options = "i"
+	}
+	v.add(field, "$regex", bson.Regex{Pattern: pattern, Options: options})
+}
+
+func (v *Visitor) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(bson.M{})
+		return
+	}
+
+	subVisitor := v.child()
+
+	for _, s := range specs {
+		s.Accept(subVisitor)
+	}
+
+	if len(subVisitor.filters) > 0 {
+		v.filters = append(v.filters, conjunction(subVisitor.filters))
+	}
+
+	v.merge(subVisitor)
+}
+
+func (v *Visitor) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(bson.M{"$expr": false})
+		return
+	}
+
+	orParts := bson.A{}
+
+	for _, s := range specs {
+		temp := v.child()
+
+		s.Accept(temp)
+
+		if len(temp.filters) > 0 {
+			orParts = append(orParts, conjunction(temp.filters))
+		}
+
+		v.merge(temp)
+	}
+
+	if len(orParts) > 0 {
+		v.filters = append(v.filters, bson.M{"$or": orParts})
+	}
+}
+
+func (v *Visitor) VisitNot(spec specifications.Specification) {
+	sub := v.child()
+	spec.Accept(sub)
+	if len(sub.filters) > 0 {
+		v.filters = append(v.filters, bson.M{"$nor": bson.A{conjunction(sub.filters)}})
+	}
+	v.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being the
+// filter equivalent to its logical identity.
+func (v *Visitor) visitEmpty(identity bson.M) {
+	switch v.empty {
+	case specifications.EmptyCompositeIdentity:
+		v.filters = append(v.filters, identity)
+	case specifications.EmptyCompositeError:
+		v.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but filters over from a sub-visitor.
+func (v *Visitor) merge(sub *Visitor) {
+	v.sort = append(v.sort, sub.sort...)
+
+	if sub.limitSet {
+		v.limit, v.limitSet = sub.limit, true
+	}
+
+	if sub.offsetSet {
+		v.offset, v.offsetSet = sub.offset, true
+	}
+
+	if sub.sample >= 0 {
+		v.sample = sub.sample
+	}
+
+	if len(sub.distinctOn) > 0 {
+		v.distinctOn = sub.distinctOn
+	}
+
+	if sub.grouping != nil {
+		v.group().merge(sub.grouping)
+	}
+
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+}
+
+func (v *Visitor) VisitLimit(limit int) {
+	if limit < 0 {
+		v.fail(fmt.Errorf("%w: negative limit %d", specifications.ErrInvalidValue, limit))
+		return
+	}
+	v.limit, v.limitSet = limit, true
+}
+
+func (v *Visitor) VisitOffset(offset int) {
+	if offset < 0 {
+		v.fail(fmt.Errorf("%w: negative offset %d", specifications.ErrInvalidValue, offset))
+		return
+	}
+	v.offset, v.offsetSet = offset, true
+}
+
+func (v *Visitor) VisitOrder(field, direction string) {
+	dbField := v.mapField(field)
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	if d == specifications.Descending {
+		v.sort = append(v.sort, bson.E{Key: dbField, Value: -1})
+	} else {
+		v.sort = append(v.sort, bson.E{Key: dbField, Value: 1})
+	}
+}
+
+// VisitOrderNulls supports only the placement MongoDB applies anyway: null
+// and missing values sort first ascending and last descending.
+func (v *Visitor) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	if (d == specifications.Ascending) != (nulls == specifications.NullsFirst) {
+		v.fail(fmt.Errorf("%w: nulls %s with %s order in MongoDB", specifications.ErrUnsupported, nulls, d))
+		return
+	}
+	v.VisitOrder(field, direction)
+}
+
+// VisitDistinct supports DistinctOn only with WithPipeline, by grouping on
+// the fields. Distinct alone is a no-op since documents are distinct by
+// their _id.
+func (v *Visitor) VisitDistinct(fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	if !v.pipeline {
+		v.fail(fmt.Errorf("%w: DISTINCT ON in a MongoDB find filter", specifications.ErrUnsupported))
+		return
+	}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = v.mapField(f)
+	}
+	v.distinctOn = columns
+}
+
+// VisitJSONContains is not supported: MongoDB has no containment operator
+// for embedded documents, and $eq on them requires the same members in the
+// same order.
+func (v *Visitor) VisitJSONContains(field string, doc interface{}) {
+	v.fail(fmt.Errorf("%w: JSON containment in a MongoDB query", specifications.ErrUnsupported))
+}
+
+// VisitJSONPathEqual matches the embedded field at path with dot notation,
+// e.g. {"attributes.color": "red"}. A nil value matches a null or missing
+// field.
+func (v *Visitor) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	dotted := strings.Join(append([]string{v.mapField(field)}, path...), ".")
+	v.filters = append(v.filters, bson.M{dotted: bson.M{"$eq": value}})
+}
+
+func (v *Visitor) VisitJSONKeyExists(field, key string) {
+	v.filters = append(v.filters, bson.M{v.mapField(field) + "." + key: bson.M{"$exists": true}})
+}
+
+// VisitTextSearch uses $text, which searches the fields of the
+// collection's text index rather than field, and requires one. language
+// overrides the index's default language.
+func (v *Visitor) VisitTextSearch(field, query, language string) {
+	search := bson.M{"$search": query}
+	if language != "" {
+		search["$language"] = language
+	}
+	v.filters = append(v.filters, bson.M{"$text": search})
+}
+
+// VisitTextRank sorts by the text score of a $text search, most relevant
+// first, as computed from the collection's text index rather than field.
+func (v *Visitor) VisitTextRank(field, query, language string) {
+	v.sort = append(v.sort, bson.E{Key: "score", Value: bson.M{"$meta": "textScore"}})
+}
+
+// VisitSimilar is not supported: MongoDB has no trigram similarity.
+func (v *Visitor) VisitSimilar(field, value string, threshold float64) {
+	v.fail(fmt.Errorf("%w: trigram similarity in a MongoDB query", specifications.ErrUnsupported))
+}
+
+// VisitSimilarityOrder is not supported, like VisitSimilar.
+func (v *Visitor) VisitSimilarityOrder(field, value string) {
+	v.fail(fmt.Errorf("%w: trigram similarity in a MongoDB query", specifications.ErrUnsupported))
+}
+
+// VisitWithinRadius uses $geoWithin with $centerSphere, which field, a
+// GeoJSON point or legacy coordinate pair, matches without a geospatial
+// index.
+func (v *Visitor) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	v.add(field, "$geoWithin", bson.M{"$centerSphere": bson.A{bson.A{center.Lng, center.Lat}, meters / earthRadius}})
+}
+
+// VisitWithinBox uses $geoWithin with a GeoJSON polygon, whose edges are
+// geodesics rather than parallels.
+func (v *Visitor) VisitWithinBox(field string, box specifications.BoundingBox) {
+	ring := bson.A{
+		bson.A{box.MinLng, box.MinLat},
+		bson.A{box.MaxLng, box.MinLat},
+		bson.A{box.MaxLng, box.MaxLat},
+		bson.A{box.MinLng, box.MaxLat},
+		bson.A{box.MinLng, box.MinLat},
+	}
+	v.add(field, "$geoWithin", bson.M{"$geometry": bson.M{"type": "Polygon", "coordinates": bson.A{ring}}})
+}
+
+// VisitOrderByDistance is not supported: MongoDB sorts by distance only
+// through the $near filter or the $geoNear stage, not a sort.
+func (v *Visitor) VisitOrderByDistance(field string, from specifications.Point) {
+	v.fail(fmt.Errorf("%w: distance ordering in a MongoDB query", specifications.ErrUnsupported))
+}
+
+// VisitRelated is not supported: joining collections needs an aggregation
+// pipeline. Expand it with specifications.ExpandRelated first.
+func (v *Visitor) VisitRelated(relation string, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: related specification on %q in a MongoDB query", specifications.ErrUnsupported, relation))
+}
+
+// earthRadius is the radius in meters MongoDB converts distances to
+// radians with.
+const earthRadius = 6378100
+
+// VisitLock is not supported: MongoDB has no row locks on reads.
+func (v *Visitor) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	v.fail(fmt.Errorf("%w: row locking in a MongoDB query", specifications.ErrUnsupported))
+}
+
+// VisitAggregate is only supported with WithPipeline, like VisitGroupBy.
+// The conditions and orderings of spec are on the accumulated field of
+// each aggregate, e.g. "count" for Count("*") or "sum_amount" for
+// Sum("Amount") mapped to "amount". Conditions on aggregates outside Having
+// are reported as unsupported.
+func (v *Visitor) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	if !v.pipeline {
+		v.fail(fmt.Errorf("%w: aggregates in a MongoDB find filter", specifications.ErrUnsupported))
+		return
+	}
+	f, err := specifications.ParseAggregateFunction(string(function))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	sub := v.child()
+	sub.aggregate = f
+	spec.Accept(sub)
+	if len(sub.filters) > 0 && !v.inHaving {
+		v.fail(fmt.Errorf("%w: conditions on %s aggregates outside Having", specifications.ErrUnsupported, f))
+	}
+	v.filters = append(v.filters, sub.filters...)
+	v.merge(sub)
+}
+
+// VisitGroupBy is only supported with WithPipeline, by a $group stage on
+// fields. Pipelines then return a document per group holding the grouped
+// fields and the aggregates of the specification.
+func (v *Visitor) VisitGroupBy(fields []string) {
+	if !v.pipeline {
+		v.fail(fmt.Errorf("%w: grouping in a MongoDB find filter", specifications.ErrUnsupported))
+		return
+	}
+	g := v.group()
+	for _, f := range fields {
+		g.fields = append(g.fields, v.mapField(f))
+	}
+}
+
+// VisitHaving is only supported with WithPipeline, by a $match stage
+// following the $group one. Without GroupBy, every document is in a single
+// group.
+func (v *Visitor) VisitHaving(spec specifications.Specification) {
+	if !v.pipeline {
+		v.fail(fmt.Errorf("%w: grouping in a MongoDB find filter", specifications.ErrUnsupported))
+		return
+	}
+	sub := v.child()
+	sub.inHaving = true
+	spec.Accept(sub)
+	g := v.group()
+	g.having = append(g.having, sub.filters...)
+	v.merge(sub)
+}
+
+// VisitSample is only supported with WithPipeline, by matching documents
+// against $rand. Both methods sample documents independently.
+func (v *Visitor) VisitSample(percent float64, method specifications.SampleMethod) {
+	if !v.pipeline {
+		v.fail(fmt.Errorf("%w: sampling in a MongoDB find filter", specifications.ErrUnsupported))
+		return
+	}
+	if percent < 0 || percent > 100 {
+		v.fail(fmt.Errorf("%w: sample percent %v out of range [0, 100]", specifications.ErrInvalidValue, percent))
+		return
+	}
+	v.sample = percent
+}
+
+// Filter returns the find filter. Multiple top-level conditions are combined
+// with $and; no conditions yield an empty filter matching every document.
+// After a Limit of zero, which MongoDB takes for no limit, the filter matches
+// no document.
+func (v *Visitor) Filter() bson.M {
+	filters := v.filters
+	if v.none() {
+		filters = append(filters[:len(filters):len(filters)], bson.M{"$expr": false})
+	}
+	return conjunction(filters)
+}
+
+// conjunction combines filters with $and.
+func conjunction(filters []bson.M) bson.M {
+	switch len(filters) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return filters[0]
+	}
+	and := make(bson.A, len(filters))
+	for i, f := range filters {
+		and[i] = f
+	}
+	return bson.M{"$and": and}
+}
+
+// none reports whether a Limit of zero was visited.
+func (v *Visitor) none() bool {
+	return v.limitSet && v.limit == 0
+}
+
+// FindOptions returns find options carrying the visited sort, limit and skip.
+func (v *Visitor) FindOptions() *options.FindOptionsBuilder {
+	opts := options.Find()
+	if len(v.sort) > 0 {
+		opts.SetSort(v.sort)
+	}
+	if v.limit > 0 {
+		opts.SetLimit(int64(v.limit))
+	}
+	if v.offset > 0 {
+		opts.SetSkip(int64(v.offset))
+	}
+	return opts
+}
+
+// Pipeline returns the aggregation pipeline equivalent to the find filter
+// and options: $match, sampling, $sort, $skip and $limit stages, each only
+// when needed. Grouping adds $group, $project and $match stages before
+// sorting. DistinctOn groups the sorted documents on its fields, keeping
+// the first of each group, and sorts them again.
+func (v *Visitor) Pipeline() []bson.D {
+	pipeline := []bson.D{}
+	if len(v.filters) > 0 || v.none() {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: v.Filter()}})
+	}
+	if v.sample >= 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{
+			"$expr": bson.M{"$lt": bson.A{bson.M{"$rand": bson.M{}}, v.sample / 100}},
+		}}})
+	}
+	if v.grouping != nil {
+		pipeline = append(pipeline, v.grouping.stages()...)
+	}
+	if len(v.sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: v.sort}})
+	}
+	if len(v.distinctOn) > 0 {
+		key := bson.D{}
+		for i, f := range v.distinctOn {
+			key = append(key, bson.E{Key: "f" + strconv.Itoa(i), Value: "$" + f})
+		}
+		pipeline = append(pipeline,
+			bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: key}, {Key: "doc", Value: bson.M{"$first": "$$ROOT"}}}}},
+			bson.D{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$doc"}}},
+		)
+		if len(v.sort) > 0 {
+			pipeline = append(pipeline, bson.D{{Key: "$sort", Value: v.sort}})
+		}
+	}
+	if v.offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: int64(v.offset)}})
+	}
+	if v.limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: int64(v.limit)}})
+	}
+	return pipeline
+}
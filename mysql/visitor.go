@@ -0,0 +1,164 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+// Visitor translates specifications into MySQL conditions with '?'
+// placeholders and backtick-quoted identifiers. It is the generic SQL
+// visitor configured with Dialect.
+type Visitor = sqlspec.Visitor
+
+// Option configures a Visitor.
+type Option = sqlspec.Option
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.NewVisitor(Dialect, fieldMap, opts...)
+}
+
+// AcquireVisitor is like NewVisitor but reuses a visitor released with
+// ReleaseVisitor, as by sqlspec.AcquireVisitor.
+func AcquireVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.AcquireVisitor(Dialect, fieldMap, opts...)
+}
+
+// ReleaseVisitor is an alias for sqlspec.ReleaseVisitor.
+func ReleaseVisitor(v *Visitor) {
+	sqlspec.ReleaseVisitor(v)
+}
+
+// WithHardening is an alias for sqlspec.WithHardening.
+func WithHardening() Option {
+	return sqlspec.WithHardening()
+}
+
+// WithStrictFields is an alias for sqlspec.WithStrictFields.
+func WithStrictFields() Option {
+	return sqlspec.WithStrictFields()
+}
+
+// WithEmptyComposite is an alias for sqlspec.WithEmptyComposite.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return sqlspec.WithEmptyComposite(mode)
+}
+
+// WithDefaultLimit is an alias for sqlspec.WithDefaultLimit.
+func WithDefaultLimit(limit int) Option {
+	return sqlspec.WithDefaultLimit(limit)
+}
+
+// WithMaxLimit is an alias for sqlspec.WithMaxLimit.
+func WithMaxLimit(max int, policy sqlspec.MaxLimitPolicy) Option {
+	return sqlspec.WithMaxLimit(max, policy)
+}
+
+// WithQueryCache is an alias for sqlspec.WithQueryCache.
+func WithQueryCache(c *sqlspec.QueryCache) Option {
+	return sqlspec.WithQueryCache(c)
+}
+
+// WithColumnTypes is an alias for sqlspec.WithColumnTypes.
+func WithColumnTypes(types map[string]sqlspec.ColumnType) Option {
+	return sqlspec.WithColumnTypes(types)
+}
+
+// WithTieBreaker is an alias for sqlspec.WithTieBreaker.
+func WithTieBreaker(field string) Option {
+	return sqlspec.WithTieBreaker(field)
+}
+
+// WithArgTarget is an alias for sqlspec.WithArgTarget.
+func WithArgTarget(t sqlspec.ArgTarget) Option {
+	return sqlspec.WithArgTarget(t)
+}
+
+// Dialect is the MySQL SQL dialect.
+var Dialect sqlspec.Dialect = dialect{}
+
+type dialect struct{}
+
+func (dialect) Placeholder(int) string {
+	return "?"
+}
+
+// QuoteIdentifier quotes name with backticks. Dotted names such as
+// "p.price" are quoted per part.
+func (dialect) QuoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "`" + strings.ReplaceAll(p, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// maxLimit is the documented way to express OFFSET without LIMIT in MySQL.
+const maxLimit = "18446744073709551615"
+
+func (dialect) LimitOffset(limit, offset int, ordered bool) string {
+	switch {
+	case limit > 0 && offset > 0:
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	case limit > 0:
+		return fmt.Sprintf(" LIMIT %d", limit)
+	case offset > 0:
+		return fmt.Sprintf(" LIMIT %s OFFSET %d", maxLimit, offset)
+	}
+	return ""
+}
+
+// ExplicitLimitOffset writes every set clause, including LIMIT 0 and
+// OFFSET 0, with the largest limit for an offset without limit.
+func (dialect) ExplicitLimitOffset(limit, offset int, ordered bool) string {
+	switch {
+	case limit >= 0 && offset >= 0:
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	case limit >= 0:
+		return fmt.Sprintf(" LIMIT %d", limit)
+	case offset >= 0:
+		return fmt.Sprintf(" LIMIT %s OFFSET %d", maxLimit, offset)
+	}
+	return ""
+}
+
+// Lock renders FOR UPDATE and FOR SHARE with their wait policy.
+func (dialect) Lock(strength specifications.LockStrength, wait specifications.LockWait) (string, bool) {
+	clause := " FOR " + string(strength)
+	if wait != specifications.LockWaitDefault {
+		clause += " " + string(wait)
+	}
+	return clause, true
+}
+
+func (dialect) BoolLiteral(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// LikeEscape doubles the backslash, which escapes inside MySQL string
+// literals.
+func (dialect) LikeEscape() string {
+	return `ESCAPE '\\'`
+}
+
+func (dialect) Regex(column string, caseInsensitive bool) (string, bool) {
+	matchType := "c"
+	if caseInsensitive {
+		matchType = "i"
+	}
+	return fmt.Sprintf("REGEXP_LIKE(%s, ?, '%s')", column, matchType), true
+}
+
+// StatisticalAggregate writes the sample standard deviation as
+// STDDEV_SAMP. MySQL has no percentile aggregate.
+func (dialect) StatisticalAggregate(function specifications.AggregateFunction, column string) (string, bool) {
+	if function == specifications.AggregateStddev {
+		return "STDDEV_SAMP(" + column + ")", true
+	}
+	return "", false
+}
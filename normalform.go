@@ -0,0 +1,215 @@
+package specifications
+
+// PushNot returns spec with its negations pushed down to the conditions,
+// for backends without support for negated composites:
+//
+//   - Not(And(a, b)) becomes Or(Not(a), Not(b)), Not(Or(a, b)) becomes
+//     And(Not(a), Not(b)), and Not(Not(a)) becomes a;
+//   - negated comparisons become their complements, e.g. Not(Equal) becomes
+//     NotEqual, Not(In) NotIn, Not(GreaterThan) LowerThanOrEqual, Not(IsNull)
+//     IsNotNull and Not(Between(field, low, high)) becomes
+//     Or(LowerThan(field, low), GreaterThan(field, high)).
+//
+// As with SQL's three-valued logic, NULL values match neither a comparison
+// nor its complement. Other negated conditions, e.g. of Like or Related,
+// are kept, and ordering and pagination are kept as they are. Descriptions
+// are dropped.
+func PushNot(spec Specification) Specification {
+	if spec == nil {
+		return nil
+	}
+	rebuilt, err := (&rewriter{}).rewrite(spec)
+	if err != nil {
+		return spec
+	}
+	return pushNot(rebuilt, false)
+}
+
+// pushNot returns spec, negated when negate is set, with negations on
+// conditions only.
+func pushNot(spec Specification, negate bool) Specification {
+	switch s := spec.(type) {
+	case *describedSpec:
+		return pushNot(s.spec, negate)
+	case *notSpec:
+		return pushNot(s.spec, !negate)
+	case *andSpec:
+		children := make([]Specification, len(s.specs))
+		for i, c := range s.specs {
+			children[i] = pushNot(c, negate)
+		}
+		if negate {
+			return Or(children...)
+		}
+		return And(children...)
+	case *orSpec:
+		children := make([]Specification, len(s.specs))
+		for i, c := range s.specs {
+			children[i] = pushNot(c, negate)
+		}
+		if negate {
+			return And(children...)
+		}
+		return Or(children...)
+	}
+	if !negate || isClause(spec) {
+		return spec
+	}
+	return complement(spec)
+}
+
+// complement returns the negation of the condition spec.
+func complement(spec Specification) Specification {
+	switch s := spec.(type) {
+	case *equalSpec:
+		return NotEqual(s.field, s.value)
+	case *notEqualSpec:
+		return Equal(s.field, s.value)
+	case *inSpec:
+		return NotIn(s.field, s.values...)
+	case *notInSpec:
+		return In(s.field, s.values...)
+	case *greaterThanSpec:
+		return LowerThanOrEqual(s.field, s.value)
+	case *greaterThanOrEqualSpec:
+		return LowerThan(s.field, s.value)
+	case *lowerThanSpec:
+		return GreaterThanOrEqual(s.field, s.value)
+	case *lowerThanOrEqualSpec:
+		return GreaterThan(s.field, s.value)
+	case *betweenSpec:
+		return Or(LowerThan(s.field, s.low), GreaterThan(s.field, s.high))
+	case *isNullSpec:
+		return IsNotNull(s.field)
+	case *isNotNullSpec:
+		return IsNull(s.field)
+	}
+	return Not(spec)
+}
+
+// isClause reports whether spec is not a condition on the entities but
+// ordering, pagination, grouping, locking or sampling.
+func isClause(spec Specification) bool {
+	switch s := spec.(type) {
+	case *limitSpec, *offsetSpec, *orderSpec, *orderNullsSpec, *distanceOrderSpec,
+		*distinctSpec, *groupBySpec, *havingSpec, *aggregateSpec, *lockSpec, *sampleSpec:
+		return true
+	case *similarSpec:
+		return s.order
+	case *textSearchSpec:
+		return s.rank
+	}
+	return false
+}
+
+// ToDNF returns spec in disjunctive normal form, an Or of Ands of
+// conditions, after PushNot. The ordering, pagination and other clauses of
+// its top-level conjunction are kept alongside. Beware that the size of the
+// result may grow exponentially with the nesting of spec.
+func ToDNF(spec Specification) Specification {
+	return normalForm(spec, true)
+}
+
+// ToCNF returns spec in conjunctive normal form, an And of Ors of
+// conditions, after PushNot, like ToDNF.
+func ToCNF(spec Specification) Specification {
+	return normalForm(spec, false)
+}
+
+func normalForm(spec Specification, dnf bool) Specification {
+	spec = PushNot(spec)
+	if spec == nil {
+		return nil
+	}
+	var conditions, clauses []Specification
+	for _, s := range conjuncts(spec) {
+		if isClause(s) {
+			clauses = append(clauses, s)
+		} else {
+			conditions = append(conditions, s)
+		}
+	}
+
+	// Each term of the normal form lists the conditions of its inner
+	// composite.
+	outer := distribute(And(conditions...), dnf)
+	terms := make([]Specification, 0, len(outer))
+	for _, inner := range outer {
+		switch {
+		case len(inner) == 1:
+			terms = append(terms, inner[0])
+		case dnf:
+			terms = append(terms, And(inner...))
+		default:
+			terms = append(terms, Or(inner...))
+		}
+	}
+
+	var form Specification
+	switch {
+	case len(conditions) == 0:
+	case len(terms) == 1:
+		form = terms[0]
+	case dnf:
+		form = Or(terms...)
+	default:
+		form = And(terms...)
+	}
+	if len(clauses) == 0 && form != nil {
+		return form
+	}
+	if form != nil {
+		clauses = append([]Specification{form}, clauses...)
+	}
+	return And(clauses...)
+}
+
+// conjuncts returns the specifications of the top-level conjunction of
+// spec, flattening nested Ands.
+func conjuncts(spec Specification) []Specification {
+	and, ok := spec.(*andSpec)
+	if !ok {
+		return []Specification{spec}
+	}
+	var out []Specification
+	for _, s := range and.specs {
+		out = append(out, conjuncts(s)...)
+	}
+	return out
+}
+
+// distribute returns the terms of the disjunctive normal form of spec when
+// dnf is set, each the conditions of a conjunction, or of its conjunctive
+// normal form, each the conditions of a disjunction.
+func distribute(spec Specification, dnf bool) [][]Specification {
+	var specs []Specification
+	var product bool
+	switch s := spec.(type) {
+	case *andSpec:
+		specs, product = s.specs, dnf
+	case *orSpec:
+		specs, product = s.specs, !dnf
+	default:
+		return [][]Specification{{spec}}
+	}
+
+	if !product {
+		var out [][]Specification
+		for _, s := range specs {
+			out = append(out, distribute(s, dnf)...)
+		}
+		return out
+	}
+	out := [][]Specification{{}}
+	for _, s := range specs {
+		terms := distribute(s, dnf)
+		var next [][]Specification
+		for _, term := range out {
+			for _, t := range terms {
+				next = append(next, append(term[:len(term):len(term)], t...))
+			}
+		}
+		out = next
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package specifications
+
+import "reflect"
+
+// Normalize returns a smaller specification matching the same entities as
+// spec, for smaller and faster queries:
+//
+//   - nested And within And, and Or within Or, are flattened;
+//   - empty And, and Not of an empty Or, are removed from And: they
+//     match every entity both when visitors ignore them, with the default
+//     EmptyCompositeSkip, and when they write their identity, with
+//     EmptyCompositeIdentity. An empty Or, and Not of an empty And, which
+//     then match nothing, are kept;
+//   - identical specifications within the same And or Or are kept once;
+//   - Equal and In conditions on the same field within an Or are merged
+//     into one In, in place of the first of them;
+//   - And and Or left with a single specification are replaced by it.
+//
+// The specifications within Having, Aggregated and Related are kept as
+// they are. A spec normalized to nothing is returned as an empty And.
+func Normalize(spec Specification) Specification {
+	if spec == nil {
+		return nil
+	}
+	rebuilt, err := (&rewriter{}).rewrite(spec)
+	if err != nil {
+		return spec
+	}
+	if out := normalize(rebuilt); out != nil {
+		return out
+	}
+	return And()
+}
+
+// normalize returns the normalized spec, or nil when it matches every
+// entity whatever the EmptyComposite mode.
+func normalize(spec Specification) Specification {
+	switch s := spec.(type) {
+	case *andSpec:
+		return composite(s.specs, true)
+	case *orSpec:
+		return composite(s.specs, false)
+	case *notSpec:
+		inner := normalize(s.spec)
+		if inner == nil {
+			return Not(And())
+		}
+		if or, ok := inner.(*orSpec); ok && len(or.specs) == 0 {
+			return nil
+		}
+		return Not(inner)
+	case *describedSpec:
+		if inner := normalize(s.spec); inner != nil {
+			return WithDescription(inner, s.description)
+		}
+		return nil
+	}
+	return spec
+}
+
+// composite normalizes the children of an And, or of an Or when and is
+// false.
+func composite(specs []Specification, and bool) Specification {
+	var flat []Specification
+	for _, s := range specs {
+		s = normalize(s)
+		switch c := s.(type) {
+		case nil:
+			if !and {
+				flat = append(flat, And())
+			}
+		case *andSpec:
+			if and {
+				flat = append(flat, c.specs...)
+				continue
+			}
+			flat = append(flat, s)
+		case *orSpec:
+			if !and {
+				flat = append(flat, c.specs...)
+				continue
+			}
+			flat = append(flat, s)
+		default:
+			flat = append(flat, s)
+		}
+	}
+	if !and {
+		flat = mergeEquals(flat)
+	}
+	out := distinct(flat)
+	switch {
+	case len(out) == 0 && and:
+		return nil
+	case len(out) == 0:
+		return Or()
+	case len(out) == 1:
+		return out[0]
+	case and:
+		return And(out...)
+	}
+	return Or(out...)
+}
+
+// distinct returns specs without the specifications identical to an
+// earlier one.
+func distinct(specs []Specification) []Specification {
+	var out []Specification
+	for _, s := range specs {
+		if !containsDeep(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// mergeEquals merges the Equal and In conditions on each field into one
+// In. Comparisons with nil, which visitors may write as IS NULL, are kept.
+func mergeEquals(specs []Specification) []Specification {
+	values := map[string][]interface{}{}
+	counts := map[string]int{}
+	for _, s := range specs {
+		if field, vs, ok := equalValues(s); ok {
+			counts[field]++
+			for _, v := range vs {
+				if !containsDeep(values[field], v) {
+					values[field] = append(values[field], v)
+				}
+			}
+		}
+	}
+	var out []Specification
+	for _, s := range specs {
+		field, _, ok := equalValues(s)
+		switch {
+		case !ok || counts[field] < 2:
+			out = append(out, s)
+		case values[field] != nil:
+			out = append(out, In(field, values[field]...))
+			values[field] = nil
+		}
+	}
+	return out
+}
+
+// equalValues returns the field and values of Equal and non-empty In
+// conditions without nil values.
+func equalValues(spec Specification) (field string, values []interface{}, ok bool) {
+	switch s := spec.(type) {
+	case *equalSpec:
+		field, values = s.field, []interface{}{s.value}
+	case *inSpec:
+		field, values = s.field, s.values
+	default:
+		return "", nil, false
+	}
+	if len(values) == 0 {
+		return "", nil, false
+	}
+	for _, v := range values {
+		if v == nil {
+			return "", nil, false
+		}
+	}
+	return field, values, true
+}
+
+func containsDeep[T any](items []T, item T) bool {
+	for _, i := range items {
+		if reflect.DeepEqual(i, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+// Package odata parses OData system query options into specifications:
+//
+//	$filter=Status eq 'active' and (Age gt 18 or contains(Name,'smith'))&$orderby=CreatedAt desc&$top=20&$skip=40
+//
+// $filter supports eq, ne, gt, ge, lt, le and in comparisons, the and, or
+// and not operators, and the contains, startswith and endswith functions.
+// Comparing with null yields IsNull or IsNotNull. Literals are strings,
+// numbers (int64 or float64), booleans, dates (2006-01-02) and date-times
+// (RFC 3339), the latter two as time.Time. Only whitelisted properties are
+// accepted.
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/specifications"
+)
+
+var (
+	// ErrSyntax is returned for malformed expressions.
+	ErrSyntax = errors.New("odata: syntax error")
+	// ErrFieldNotAllowed is returned for properties that are not allowed.
+	ErrFieldNotAllowed = errors.New("odata: property not allowed")
+)
+
+// maxDepth bounds parenthesis and not nesting.
+const maxDepth = 32
+
+// Parser parses query options restricted to a set of properties.
+type Parser struct {
+	fields map[string]bool
+	maxTop int
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithMaxTop rejects $top values above max, and applies max when $top is
+// absent.
+func WithMaxTop(max int) Option {
+	return func(p *Parser) {
+		p.maxTop = max
+	}
+}
+
+// NewParser returns a parser accepting the given property names, which are
+// the domain field names of the specifications it produces.
+func NewParser(fields []string, opts ...Option) *Parser {
+	p := &Parser{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		p.fields[f] = true
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Parse reads $filter, $orderby, $top and $skip from query and combines them
+// with And. Other parameters are ignored.
+func (p *Parser) Parse(query url.Values) (specifications.Specification, error) {
+	var specs []specifications.Specification
+	if filter := query.Get("$filter"); filter != "" {
+		spec, err := p.ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if orderBy := query.Get("$orderby"); orderBy != "" {
+		order, err := p.ParseOrderBy(orderBy)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, order...)
+	}
+
+	top, topped, err := count(query, "$top")
+	if err != nil {
+		return nil, err
+	}
+	if p.maxTop > 0 {
+		if top > p.maxTop {
+			return nil, fmt.Errorf("%w: $top %d exceeds %d", specifications.ErrInvalidValue, top, p.maxTop)
+		}
+		if !topped {
+			top, topped = p.maxTop, true
+		}
+	}
+	if topped {
+		specs = append(specs, specifications.Limit(top))
+	}
+
+	skip, _, err := count(query, "$skip")
+	if err != nil {
+		return nil, err
+	}
+	if skip > 0 {
+		specs = append(specs, specifications.Offset(skip))
+	}
+
+	return specifications.And(specs...), nil
+}
+
+// ParseFilter parses a $filter expression.
+func (p *Parser) ParseFilter(filter string) (specifications.Specification, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+	s := &state{parser: p, tokens: tokens}
+	spec, err := s.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := s.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("%w at offset %d: unexpected %s", ErrSyntax, t.pos, t)
+	}
+	return spec, nil
+}
+
+// ParseOrderBy parses a $orderby expression such as "Name asc,Age desc".
+func (p *Parser) ParseOrderBy(orderBy string) ([]specifications.Specification, error) {
+	var specs []specifications.Specification
+	for _, item := range strings.Split(orderBy, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("%w: $orderby item %q", ErrSyntax, item)
+		}
+		if !p.fields[parts[0]] {
+			return nil, fmt.Errorf("%w: %q", ErrFieldNotAllowed, parts[0])
+		}
+		direction := "ASC"
+		if len(parts) == 2 {
+			switch parts[1] {
+			case "asc":
+			case "desc":
+				direction = "DESC"
+			default:
+				return nil, fmt.Errorf("%w: $orderby direction %q", ErrSyntax, parts[1])
+			}
+		}
+		specs = append(specs, specifications.OrderBy(parts[0], direction))
+	}
+	return specs, nil
+}
+
+// count parses the non-negative integer option, ok reporting whether it is
+// given.
+func count(query url.Values, option string) (n int, ok bool, err error) {
+	raw := query.Get(option)
+	if raw == "" {
+		return 0, false, nil
+	}
+	n, err = strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, false, fmt.Errorf("%w: %s must be a non-negative integer", ErrSyntax, option)
+	}
+	return n, true, nil
+}
+
+type state struct {
+	parser *Parser
+	tokens []token
+	pos    int
+}
+
+func (s *state) peek() token {
+	return s.tokens[s.pos]
+}
+
+func (s *state) next() token {
+	t := s.tokens[s.pos]
+	if t.kind != tokEOF {
+		s.pos++
+	}
+	return t
+}
+
+func (s *state) expect(kind tokenKind, what string) error {
+	if t := s.next(); t.kind != kind {
+		return fmt.Errorf("%w at offset %d: expected %s, got %s", ErrSyntax, t.pos, what, t)
+	}
+	return nil
+}
+
+func (s *state) or(depth int) (specifications.Specification, error) {
+	first, err := s.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	specs := []specifications.Specification{first}
+	for s.peek().is("or") {
+		s.next()
+		spec, err := s.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 1 {
+		return first, nil
+	}
+	return specifications.Or(specs...), nil
+}
+
+func (s *state) and(depth int) (specifications.Specification, error) {
+	first, err := s.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	specs := []specifications.Specification{first}
+	for s.peek().is("and") {
+		s.next()
+		spec, err := s.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 1 {
+		return first, nil
+	}
+	return specifications.And(specs...), nil
+}
+
+func (s *state) unary(depth int) (specifications.Specification, error) {
+	t := s.peek()
+	if depth >= maxDepth {
+		return nil, fmt.Errorf("%w at offset %d: nesting deeper than %d", ErrSyntax, t.pos, maxDepth)
+	}
+	switch {
+	case t.is("not"):
+		s.next()
+		spec, err := s.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return specifications.Not(spec), nil
+	case t.kind == tokLParen:
+		s.next()
+		spec, err := s.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return spec, nil
+	case t.kind == tokIdent && s.tokens[s.pos+1].kind == tokLParen:
+		return s.function()
+	}
+	return s.comparison()
+}
+
+// function parses contains, startswith and endswith calls.
+func (s *state) function() (specifications.Specification, error) {
+	name := s.next()
+	s.next()
+	field, err := s.field()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expect(tokComma, "','"); err != nil {
+		return nil, err
+	}
+	arg := s.next()
+	if arg.kind != tokString {
+		return nil, fmt.Errorf("%w at offset %d: %s expects a string, got %s", ErrSyntax, arg.pos, name.text, arg)
+	}
+	if err := s.expect(tokRParen, "')'"); err != nil {
+		return nil, err
+	}
+	switch name.text {
+	case "contains":
+		return specifications.Contains(field, arg.text), nil
+	case "startswith":
+		return specifications.StartsWith(field, arg.text), nil
+	case "endswith":
+		return specifications.EndsWith(field, arg.text), nil
+	}
+	return nil, fmt.Errorf("%w at offset %d: unsupported function %q", ErrSyntax, name.pos, name.text)
+}
+
+func (s *state) field() (string, error) {
+	t := s.next()
+	if t.kind != tokIdent {
+		return "", fmt.Errorf("%w at offset %d: expected property, got %s", ErrSyntax, t.pos, t)
+	}
+	if !s.parser.fields[t.text] {
+		return "", fmt.Errorf("%w at offset %d: %q", ErrFieldNotAllowed, t.pos, t.text)
+	}
+	return t.text, nil
+}
+
+func (s *state) comparison() (specifications.Specification, error) {
+	field, err := s.field()
+	if err != nil {
+		return nil, err
+	}
+	op := s.next()
+	if op.kind != tokIdent {
+		return nil, fmt.Errorf("%w at offset %d: expected operator, got %s", ErrSyntax, op.pos, op)
+	}
+
+	if op.text == "in" {
+		if err := s.expect(tokLParen, "'('"); err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for {
+			value, null, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			if null {
+				return nil, fmt.Errorf("%w at offset %d: null in a list", ErrSyntax, op.pos)
+			}
+			values = append(values, value)
+			t := s.next()
+			if t.kind == tokRParen {
+				return specifications.In(field, values...), nil
+			}
+			if t.kind != tokComma {
+				return nil, fmt.Errorf("%w at offset %d: expected ',' or ')', got %s", ErrSyntax, t.pos, t)
+			}
+		}
+	}
+
+	value, null, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	if null {
+		switch op.text {
+		case "eq":
+			return specifications.IsNull(field), nil
+		case "ne":
+			return specifications.IsNotNull(field), nil
+		}
+		return nil, fmt.Errorf("%w at offset %d: %s null", ErrSyntax, op.pos, op.text)
+	}
+	switch op.text {
+	case "eq":
+		return specifications.Equal(field, value), nil
+	case "ne":
+		return specifications.NotEqual(field, value), nil
+	case "gt":
+		return specifications.GreaterThan(field, value), nil
+	case "ge":
+		return specifications.GreaterThanOrEqual(field, value), nil
+	case "lt":
+		return specifications.LowerThan(field, value), nil
+	case "le":
+		return specifications.LowerThanOrEqual(field, value), nil
+	}
+	return nil, fmt.Errorf("%w at offset %d: unsupported operator %q", ErrSyntax, op.pos, op.text)
+}
+
+// value parses a literal. null reports the null literal.
+func (s *state) value() (value interface{}, null bool, err error) {
+	t := s.next()
+	switch {
+	case t.kind == tokString:
+		return t.text, false, nil
+	case t.is("true"):
+		return true, false, nil
+	case t.is("false"):
+		return false, false, nil
+	case t.is("null"):
+		return nil, true, nil
+	case t.kind == tokLiteral:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return i, false, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, false, nil
+		}
+		if d, err := time.Parse(time.RFC3339Nano, t.text); err == nil {
+			return d, false, nil
+		}
+		if d, err := time.Parse(time.DateOnly, t.text); err == nil {
+			return d, false, nil
+		}
+		return nil, false, fmt.Errorf("%w at offset %d: invalid literal %q", ErrSyntax, t.pos, t.text)
+	}
+	return nil, false, fmt.Errorf("%w at offset %d: expected value, got %s", ErrSyntax, t.pos, t)
+}
@@ -0,0 +1,114 @@
+package specifications
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrderDirection is the direction of an OrderBy specification.
+type OrderDirection string
+
+const (
+	Ascending  OrderDirection = "ASC"
+	Descending OrderDirection = "DESC"
+)
+
+// ParseOrderDirection normalizes direction, accepting only ASC and DESC,
+// case-insensitively. An empty direction is ascending. Visitors reject
+// other directions with ErrInvalidValue, so that OrderBy cannot inject
+// arbitrary text into a query.
+func ParseOrderDirection(direction string) (OrderDirection, error) {
+	switch d := OrderDirection(strings.ToUpper(strings.TrimSpace(direction))); d {
+	case Ascending, Descending:
+		return d, nil
+	case "":
+		return Ascending, nil
+	}
+	return "", fmt.Errorf("%w: order direction %q", ErrInvalidValue, direction)
+}
+
+// Asc orders by field in ascending order.
+func Asc(field string) Specification {
+	return OrderBy(field, string(Ascending))
+}
+
+// Desc orders by field in descending order.
+func Desc(field string) Specification {
+	return OrderBy(field, string(Descending))
+}
+
+// NullsPosition places NULLs before or after the other values of an
+// ordering, regardless of its direction.
+type NullsPosition string
+
+const (
+	// NullsDefault keeps the backend's placement, e.g. NULLs last ascending
+	// in PostgreSQL but first in MySQL.
+	NullsDefault NullsPosition = ""
+	NullsFirst   NullsPosition = "FIRST"
+	NullsLast    NullsPosition = "LAST"
+)
+
+type orderNullsSpec struct {
+	field     string
+	direction string
+	nulls     NullsPosition
+}
+
+func (s *orderNullsSpec) Accept(v SpecificationVisitor) {
+	v.VisitOrderNulls(s.field, s.direction, s.nulls)
+}
+
+// OrderByNulls is like OrderBy but also controls where NULLs are placed,
+// e.g. to list rows without a due date last in both directions. With
+// NullsDefault it is equivalent to OrderBy.
+func OrderByNulls(field string, direction string, nulls NullsPosition) Specification {
+	if nulls == NullsDefault {
+		return OrderBy(field, direction)
+	}
+	return &orderNullsSpec{
+		field:     field,
+		direction: direction,
+		nulls:     nulls,
+	}
+}
+
+// ParseNullsPosition normalizes nulls, accepting FIRST, LAST and empty,
+// case-insensitively.
+func ParseNullsPosition(nulls string) (NullsPosition, error) {
+	switch p := NullsPosition(strings.ToUpper(strings.TrimSpace(nulls))); p {
+	case NullsDefault, NullsFirst, NullsLast:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: nulls position %q", ErrInvalidValue, nulls)
+}
+
+// OrderedField is one term of a multi-field ordering built by OrderByMany.
+// A zero Direction is ascending.
+type OrderedField struct {
+	Field     string
+	Direction OrderDirection
+	Nulls     NullsPosition
+}
+
+// OrderByMany orders by each of fields in turn.
+func OrderByMany(fields ...OrderedField) Specification {
+	specs := make([]Specification, len(fields))
+	for i, f := range fields {
+		specs[i] = OrderByNulls(f.Field, string(f.Direction), f.Nulls)
+	}
+	return And(specs...)
+}
+
+// StableOrderBy is like OrderByMany but ends with key, typically the primary
+// key, ascending unless fields already order by it. Rows with equal values
+// then keep the same relative order across queries, so that pagination
+// neither skips nor repeats them.
+func StableOrderBy(key string, fields ...OrderedField) Specification {
+	for _, f := range fields {
+		if f.Field == key {
+			return OrderByMany(fields...)
+		}
+	}
+	return OrderByMany(append(fields[:len(fields):len(fields)], OrderedField{Field: key, Direction: Ascending})...)
+}
@@ -0,0 +1,17 @@
+module github.com/thefabric-io/specifications/pgxspec
+
+go 1.23.4
+
+require (
+	github.com/jackc/pgx/v5 v5.7.5
+	github.com/thefabric-io/specifications v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+)
+
+replace github.com/thefabric-io/specifications => ../
@@ -0,0 +1,31 @@
+// Package pgxspec connects the PostgreSQL visitor to pgx idioms.
+package pgxspec
+
+import (
+	"github.com/jackc/pgx/v5"
+
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+// NamedArgs renders @name placeholders with the arguments as pgx.NamedArgs,
+// which pgx rewrites into positional parameters:
+//
+//	v := postgres.NewVisitor(fieldMap, postgres.WithArgTarget(pgxspec.NamedArgs))
+//	...
+//	query, args, err := v.BuildArgs("SELECT * FROM products")
+//	rows, err := conn.Query(ctx, query, args)
+var NamedArgs sqlspec.ArgTarget = namedArgs{}
+
+type namedArgs struct{}
+
+func (namedArgs) Placeholder(_ int, name string) string {
+	return "@" + name
+}
+
+func (namedArgs) Args(names []string, values []interface{}) interface{} {
+	args := make(pgx.NamedArgs, len(names))
+	for i, name := range names {
+		args[name] = values[i]
+	}
+	return args
+}
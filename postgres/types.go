@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+// ColumnType is an alias for sqlspec.ColumnType.
+type ColumnType = sqlspec.ColumnType
+
+// WithColumnTypes is an alias for sqlspec.WithColumnTypes, e.g.
+//
+//	v := postgres.NewVisitor(fieldMap, postgres.WithColumnTypes(map[string]postgres.ColumnType{
+//		"email":  postgres.Citext,
+//		"id":     postgres.UUID,
+//		"price":  postgres.Numeric,
+//		"status": postgres.Enum("order_status", "pending", "paid", "shipped"),
+//	}))
+func WithColumnTypes(types map[string]ColumnType) Option {
+	return sqlspec.WithColumnTypes(types)
+}
+
+var (
+	// Citext is the case-insensitive text type of the citext extension.
+	Citext = ColumnType{Name: "citext", CaseInsensitive: true}
+	// UUID accepts UUIDs as strings, such as
+	// "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", or 16-byte arrays, such as
+	// github.com/google/uuid.UUID.
+	UUID = ColumnType{Name: "uuid", Validate: validUUID}
+	// Numeric accepts Go numbers and decimal strings, such as "19.99".
+	Numeric = ColumnType{Name: "numeric", Validate: validNumeric}
+)
+
+// Enum is the enum type name with values, e.g. created by
+// CREATE TYPE order_status AS ENUM ('pending', 'paid', 'shipped').
+func Enum(name string, values ...string) ColumnType {
+	return ColumnType{Name: name, Values: values}
+}
+
+var (
+	uuidPattern    = regexp.MustCompile(`^\{?[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}\}?$`)
+	numericPattern = regexp.MustCompile(`^\s*[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?\s*$|^(NaN|[+-]?Infinity)$`)
+)
+
+func validUUID(value interface{}) error {
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Array && rv.Len() == 16 && rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil
+	}
+	if s, ok := text(value); ok && uuidPattern.MatchString(s) {
+		return nil
+	}
+	return fmt.Errorf("%v is not a UUID", value)
+}
+
+func validNumeric(value interface{}) error {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	}
+	if s, ok := text(value); ok && numericPattern.MatchString(s) {
+		return nil
+	}
+	return fmt.Errorf("%v is not a number", value)
+}
+
+// text returns the text of strings and of fmt.Stringer values, such as
+// decimal types.
+func text(value interface{}) (string, bool) {
+	if s, ok := value.(fmt.Stringer); ok {
+		return s.String(), true
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.String {
+		return rv.String(), true
+	}
+	return "", false
+}
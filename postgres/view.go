@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// CreateView renders a CREATE VIEW statement defining the view name as
+// baseQuery filtered by spec, e.g. to promote a saved segment to a view
+// for BI tools:
+//
+//	ddl, err := postgres.CreateView("active_customers", "SELECT * FROM customers", spec, fieldMap)
+//
+// View definitions cannot bind parameters, so the values of spec are
+// written as SQL literals; values without a literal form, such as structs,
+// are reported with ErrUnsupported. Like mapped columns, name and
+// baseQuery are trusted SQL. Row locking is rejected, as it does not
+// apply to views.
+func CreateView(name, baseQuery string, spec specifications.Specification, fieldMap map[string]string, opts ...Option) (string, error) {
+	v := NewVisitor(fieldMap, opts...)
+	if err := specifications.Apply(spec, v); err != nil {
+		return "", err
+	}
+	q := v.Query()
+	if q.Lock != nil {
+		return "", fmt.Errorf("%w: row locking in a view", specifications.ErrUnsupported)
+	}
+	query, err := q.RenderInline(Dialect, baseQuery, literal)
+	if err != nil {
+		return "", err
+	}
+	return "CREATE VIEW " + name + " AS " + query, nil
+}
+
+// literal returns value as a PostgreSQL literal. Strings, times and arrays
+// are untyped literals, which take the type of the column they are
+// compared with.
+func literal(value interface{}) (string, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return "NULL", nil
+		}
+		v, err := valuer.Value()
+		if err != nil {
+			return "", err
+		}
+		value = v
+	}
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		return Dialect.BoolLiteral(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return floatLiteral(float64(v)), nil
+	case float64:
+		return floatLiteral(v), nil
+	case string:
+		return quoteLiteral(v)
+	case []byte:
+		s, err := quoteLiteral(`\x` + hex.EncodeToString(v))
+		return s + "::bytea", err
+	case time.Time:
+		return quoteLiteral(v.Format(time.RFC3339Nano))
+	}
+	return "", fmt.Errorf("%w: %T value in a view", specifications.ErrUnsupported, value)
+}
+
+func floatLiteral(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'"
+	case math.IsInf(f, 1):
+		return "'Infinity'"
+	case math.IsInf(f, -1):
+		return "'-Infinity'"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// quoteLiteral quotes s as a string constant, with the escape string syntax
+// when s holds backslashes so it reads the same whatever
+// standard_conforming_strings is set to.
+func quoteLiteral(s string) (string, error) {
+	if strings.ContainsRune(s, 0) {
+		return "", fmt.Errorf("%w: NUL character in a string literal", specifications.ErrInvalidValue)
+	}
+	quoted := "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	if strings.Contains(s, `\`) {
+		quoted = "E" + strings.ReplaceAll(quoted, `\`, `\\`)
+	}
+	return quoted, nil
+}
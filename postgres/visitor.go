@@ -2,200 +2,298 @@ package postgres
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/internal/pgarray"
+	"github.com/thefabric-io/specifications/sqlspec"
 )
 
-type Visitor struct {
-	conditions   []string
-	args         []interface{}
-	fieldMap     map[string]string
-	orderClauses []string
-	limit        int
-	offset       int
-}
-
-func NewVisitor(fieldMap map[string]string) *Visitor {
-	return &Visitor{
-		conditions:   []string{},
-		args:         []interface{}{},
-		fieldMap:     fieldMap,
-		orderClauses: []string{},
-		limit:        0,
-		offset:       0,
-	}
+// Visitor translates specifications into PostgreSQL conditions with $n
+// placeholders. It is the generic SQL visitor configured with Dialect.
+type Visitor = sqlspec.Visitor
+
+// Option configures a Visitor.
+type Option = sqlspec.Option
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.NewVisitor(Dialect, fieldMap, opts...)
 }
 
-func (v *Visitor) mapField(domainField string) string {
-	if dbField, ok := v.fieldMap[domainField]; ok {
-		return dbField
-	}
-	return domainField
+// AcquireVisitor is like NewVisitor but reuses a visitor released with
+// ReleaseVisitor, as by sqlspec.AcquireVisitor.
+func AcquireVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.AcquireVisitor(Dialect, fieldMap, opts...)
 }
 
-func (v *Visitor) VisitEqual(field string, value interface{}) {
-	dbField := v.mapField(field)
-	v.conditions = append(v.conditions, fmt.Sprintf("%s = ?", dbField))
-	v.args = append(v.args, value)
+// ReleaseVisitor is an alias for sqlspec.ReleaseVisitor.
+func ReleaseVisitor(v *Visitor) {
+	sqlspec.ReleaseVisitor(v)
 }
 
-func (v *Visitor) VisitIn(field string, values []interface{}) {
-	dbField := v.mapField(field)
-	if len(values) == 0 {
-		v.conditions = append(v.conditions, "1=0")
-		return
-	}
+// WithHardening is an alias for sqlspec.WithHardening.
+func WithHardening() Option {
+	return sqlspec.WithHardening()
+}
 
-	qs := make([]string, len(values))
-	for i := range values {
-		qs[i] = "?"
-		v.args = append(v.args, values[i])
-	}
-	v.conditions = append(v.conditions, fmt.Sprintf("%s IN (%s)", dbField, strings.Join(qs, ", ")))
+// WithQuotedIdentifiers is an alias for sqlspec.WithQuotedIdentifiers.
+func WithQuotedIdentifiers() Option {
+	return sqlspec.WithQuotedIdentifiers()
 }
 
-func (v *Visitor) VisitAnd(specs []specifications.Specification) {
-	subVisitor := NewVisitor(v.fieldMap)
+// WithStrictFields is an alias for sqlspec.WithStrictFields.
+func WithStrictFields() Option {
+	return sqlspec.WithStrictFields()
+}
 
-	for _, s := range specs {
-		s.Accept(subVisitor)
-	}
+// Relation is an alias for sqlspec.Relation.
+type Relation = sqlspec.Relation
 
-	if len(subVisitor.conditions) > 0 {
-		v.conditions = append(v.conditions, "("+strings.Join(subVisitor.conditions, " AND ")+")")
-		v.args = append(v.args, subVisitor.args...)
-	}
+// WithRelation is an alias for sqlspec.WithRelation.
+func WithRelation(relation string, r Relation) Option {
+	return sqlspec.WithRelation(relation, r)
+}
 
-	v.orderClauses = append(v.orderClauses, subVisitor.orderClauses...)
+// WithAntiJoins is an alias for sqlspec.WithAntiJoins.
+func WithAntiJoins(style sqlspec.AntiJoinStyle) Option {
+	return sqlspec.WithAntiJoins(style)
+}
 
-	if subVisitor.limit > 0 {
-		v.limit = subVisitor.limit
-	}
+// WithEmptyComposite is an alias for sqlspec.WithEmptyComposite.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return sqlspec.WithEmptyComposite(mode)
+}
 
-	if subVisitor.offset > 0 {
-		v.offset = subVisitor.offset
-	}
+// WithDefaultLimit is an alias for sqlspec.WithDefaultLimit.
+func WithDefaultLimit(limit int) Option {
+	return sqlspec.WithDefaultLimit(limit)
 }
 
-func (v *Visitor) VisitOr(specs []specifications.Specification) {
-	subVisitor := NewVisitor(v.fieldMap)
-	orParts := []string{}
+// WithMaxLimit is an alias for sqlspec.WithMaxLimit.
+func WithMaxLimit(max int, policy sqlspec.MaxLimitPolicy) Option {
+	return sqlspec.WithMaxLimit(max, policy)
+}
 
-	for _, s := range specs {
-		temp := NewVisitor(v.fieldMap)
+// QueryCache is an alias for sqlspec.QueryCache.
+type QueryCache = sqlspec.QueryCache
 
-		s.Accept(temp)
+// NewQueryCache is an alias for sqlspec.NewQueryCache.
+func NewQueryCache(size int) *QueryCache {
+	return sqlspec.NewQueryCache(size)
+}
+
+// WithQueryCache is an alias for sqlspec.WithQueryCache.
+func WithQueryCache(c *QueryCache) Option {
+	return sqlspec.WithQueryCache(c)
+}
+
+// WithTieBreaker is an alias for sqlspec.WithTieBreaker.
+func WithTieBreaker(field string) Option {
+	return sqlspec.WithTieBreaker(field)
+}
 
-		if len(temp.conditions) > 0 {
-			orParts = append(orParts, "("+strings.Join(temp.conditions, " AND ")+")")
-			subVisitor.args = append(subVisitor.args, temp.args...)
-		}
+// WithArgTarget is an alias for sqlspec.WithArgTarget.
+func WithArgTarget(t sqlspec.ArgTarget) Option {
+	return sqlspec.WithArgTarget(t)
+}
 
-		subVisitor.orderClauses = append(subVisitor.orderClauses, temp.orderClauses...)
+// ETag is an alias for sqlspec.ETag.
+func ETag(lastModified time.Time, count int64) string {
+	return sqlspec.ETag(lastModified, count)
+}
 
-		if temp.limit > 0 {
-			subVisitor.limit = temp.limit
-		}
+// Dialect is the PostgreSQL SQL dialect.
+var Dialect sqlspec.Dialect = dialect{}
 
-		if temp.offset > 0 {
-			subVisitor.offset = temp.offset
-		}
-	}
+type dialect struct{}
 
-	if len(orParts) > 0 {
-		v.conditions = append(v.conditions, "("+strings.Join(orParts, " OR ")+")")
-		v.args = append(v.args, subVisitor.args...)
+func (dialect) Placeholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+// QuoteIdentifier returns name unchanged: mapped columns are trusted SQL.
+// WithQuotedIdentifiers quotes them with QuoteName instead.
+func (dialect) QuoteIdentifier(name string) string {
+	return name
+}
+
+// QuoteName quotes name with double quotes. Dotted names such as
+// "p.price" are quoted per part.
+func (dialect) QuoteName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
 	}
+	return strings.Join(parts, ".")
+}
 
-	v.orderClauses = append(v.orderClauses, subVisitor.orderClauses...)
+func (dialect) LimitOffset(limit, offset int, ordered bool) string {
+	clause := ""
+	if limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return clause
+}
 
-	if subVisitor.limit > 0 {
-		v.limit = subVisitor.limit
+// ExplicitLimitOffset writes every set clause, including LIMIT 0 and
+// OFFSET 0.
+func (dialect) ExplicitLimitOffset(limit, offset int, ordered bool) string {
+	clause := ""
+	if limit >= 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset >= 0 {
+		clause += fmt.Sprintf(" OFFSET %d", offset)
 	}
+	return clause
+}
+
+func (dialect) NullsOrder(nulls specifications.NullsPosition) string {
+	return " NULLS " + string(nulls)
+}
+
+func (dialect) DistinctOn(columns []string) string {
+	return "DISTINCT ON (" + strings.Join(columns, ", ") + ")"
+}
 
-	if subVisitor.offset > 0 {
-		v.offset = subVisitor.offset
+// JSONContains renders the jsonb containment operator; the column must be
+// jsonb.
+func (dialect) JSONContains(column string) string {
+	return column + " @> ?::jsonb"
+}
+
+func (dialect) JSONPathText(column string, depth int) string {
+	if depth == 1 {
+		return "(" + column + " ->> ?)"
 	}
+	keys := strings.TrimSuffix(strings.Repeat("?, ", depth), ", ")
+	return "(" + column + " #>> ARRAY[" + keys + "]::text[])"
+}
+
+// JSONKeyExists renders jsonb_exists, the function behind the ? operator,
+// which would be mistaken for a placeholder by drivers and by the visitor.
+func (dialect) JSONKeyExists(column string) string {
+	return "jsonb_exists(" + column + ", ?)"
+}
+
+func (dialect) ArrayContains(column string) string {
+	return column + " @> ?"
+}
+
+func (dialect) ArrayOverlaps(column string) string {
+	return column + " && ?"
 }
 
-func (v *Visitor) VisitLimit(limit int) {
-	v.limit = limit
+func (dialect) EqualAny(column string) string {
+	return column + " = ANY(?)"
 }
 
-func (v *Visitor) VisitOrder(field, direction string) {
-	dbField := v.mapField(field)
-	v.orderClauses = append(v.orderClauses, dbField+" "+direction)
+// ArrayArg binds values in the text form of an array, which the server
+// converts to the type of the compared column.
+func (dialect) ArrayArg(values []interface{}) interface{} {
+	return pgarray.Array(values)
 }
 
-func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
-	dbField := v.mapField(field)
-	v.conditions = append(v.conditions, fmt.Sprintf("%s > ?", dbField))
-	v.args = append(v.args, value)
+// TextSearch matches the plain-text query against the column's lexemes.
+// Without an expression index on the same to_tsvector call, it scans the
+// table.
+func (dialect) TextSearch(column, language string) string {
+	return tsvector(column, language) + " @@ " + tsquery(language)
 }
 
-func (v *Visitor) VisitLowerThan(field string, value interface{}) {
-	dbField := v.mapField(field)
-	v.conditions = append(v.conditions, fmt.Sprintf("%s < ?", dbField))
-	v.args = append(v.args, value)
+func (dialect) TextRank(column, language string) string {
+	return "ts_rank(" + tsvector(column, language) + ", " + tsquery(language) + ")"
 }
 
-func (v *Visitor) VisitLike(field string, value interface{}) {
-	dbField := v.mapField(field)
-	// Typically LIKE patterns are expected to include '%' in the value
-	v.conditions = append(v.conditions, fmt.Sprintf("%s LIKE ?", dbField))
-	v.args = append(v.args, value)
+// Similar uses the pg_trgm similarity operator, which trigram indexes
+// support.
+func (dialect) Similar(column string) string {
+	return column + " % ?"
 }
 
-func (v *Visitor) VisitOffset(offset int) {
-	v.offset = offset
+func (dialect) Similarity(column string) string {
+	return "similarity(" + column + ", ?)"
 }
 
-func (v *Visitor) VisitNotEqual(field string, value interface{}) {
-	dbField := v.mapField(field)
-	v.conditions = append(v.conditions, fmt.Sprintf("%s <> ?", dbField))
-	v.args = append(v.args, value)
+// WithinRadius uses PostGIS on a geography column, so that the radius is
+// in meters and a GiST index on the column applies.
+func (dialect) WithinRadius(column string) string {
+	return "ST_DWithin(" + column + ", ST_MakePoint(?, ?)::geography, ?)"
 }
 
-func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
-	dbField := v.mapField(field)
-	v.conditions = append(v.conditions, fmt.Sprintf("%s >= ?", dbField))
-	v.args = append(v.args, value)
+func (dialect) WithinBox(column string) string {
+	return "ST_Intersects(" + column + ", ST_MakeEnvelope(?, ?, ?, ?, 4326)::geography)"
 }
 
-func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
-	dbField := v.mapField(field)
-	v.conditions = append(v.conditions, fmt.Sprintf("%s <= ?", dbField))
-	v.args = append(v.args, value)
+// Distance uses the PostGIS distance operator, which orders a limited
+// query by nearest neighbor search with a GiST index on the column.
+func (dialect) Distance(column string) string {
+	return column + " <-> ST_MakePoint(?, ?)::geography"
 }
 
-func (v *Visitor) BuildQuery(baseQuery string) (string, []interface{}) {
-	query := baseQuery
-	if len(v.conditions) > 0 {
-		fullCondition := strings.Join(v.conditions, " AND ")
-		var finalQuery strings.Builder
-		argIndex := 1
-		for _, ch := range fullCondition {
-			if ch == '?' {
-				finalQuery.WriteString(fmt.Sprintf("$%d", argIndex))
-				argIndex++
-			} else {
-				finalQuery.WriteRune(ch)
-			}
-		}
-		query += " WHERE " + finalQuery.String()
+func tsvector(column, language string) string {
+	if language == "" {
+		return "to_tsvector(" + column + ")"
 	}
+	return "to_tsvector('" + language + "', " + column + ")"
+}
 
-	if len(v.orderClauses) > 0 {
-		query += " ORDER BY " + strings.Join(v.orderClauses, ", ")
+func tsquery(language string) string {
+	if language == "" {
+		return "plainto_tsquery(?)"
 	}
+	return "plainto_tsquery('" + language + "', ?)"
+}
 
-	if v.limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", v.limit)
+// Lock renders FOR UPDATE and FOR SHARE with their wait policy.
+func (dialect) Lock(strength specifications.LockStrength, wait specifications.LockWait) (string, bool) {
+	clause := " FOR " + string(strength)
+	if wait != specifications.LockWaitDefault {
+		clause += " " + string(wait)
 	}
-	if v.offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", v.offset)
+	return clause, true
+}
+
+func (dialect) BoolLiteral(b bool) string {
+	if b {
+		return "TRUE"
 	}
+	return "FALSE"
+}
+
+func (dialect) ILike(column string) string {
+	return column + " ILIKE ?"
+}
 
-	return query, v.args
+func (dialect) Regex(column string, caseInsensitive bool) (string, bool) {
+	if caseInsensitive {
+		return column + " ~* ?", true
+	}
+	return column + " ~ ?", true
+}
+
+func (dialect) TableSample(method specifications.SampleMethod, placeholder string) (string, bool) {
+	switch method {
+	case specifications.SampleSystem, specifications.SampleBernoulli:
+		return fmt.Sprintf("TABLESAMPLE %s (%s)", method, placeholder), true
+	}
+	return "", false
+}
+
+// StatisticalAggregate writes the sample standard deviation as
+// STDDEV_SAMP and percentiles as ordered-set aggregates, e.g.
+// PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY "latency_ms").
+func (dialect) StatisticalAggregate(function specifications.AggregateFunction, column string) (string, bool) {
+	if function == specifications.AggregateStddev {
+		return "STDDEV_SAMP(" + column + ")", true
+	}
+	if _, ok := function.Percentile(); ok {
+		return string(function) + " WITHIN GROUP (ORDER BY " + column + ")", true
+	}
+	return "", false
 }
@@ -0,0 +1,300 @@
+// Package preset defines named specification templates with typed
+// parameters, such as the quick filters of a dashboard ("Today", "My
+// items", "Overdue"), so that they are declared once on the server, listed
+// to frontends and instantiated per request:
+//
+//	reg := preset.NewRegistry()
+//	reg.Register(preset.Preset{
+//		Name:   "overdue",
+//		Title:  "Overdue",
+//		Params: []preset.Param{{Name: "days", Type: preset.Int, Default: 0}},
+//		Build: func(ctx context.Context, args preset.Args) (specifications.Specification, error) {
+//			due := time.Now().AddDate(0, 0, -args.Int("days"))
+//			return specifications.And(specifications.LowerThan("due_at", due), specifications.NotEqual("status", "done")), nil
+//		},
+//	})
+//	spec, err := reg.Instantiate(ctx, "overdue", map[string]interface{}{"days": "3"})
+package preset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thefabric-io/specifications"
+)
+
+var (
+	// ErrUnknownPreset is returned when instantiating a preset that is not
+	// registered.
+	ErrUnknownPreset = errors.New("preset: unknown preset")
+	// ErrInvalidPreset is returned when registering a preset without name
+	// or Build, with a name already registered, or with invalid parameter
+	// declarations.
+	ErrInvalidPreset = errors.New("preset: invalid preset")
+	// ErrInvalidParam is returned for arguments that are unknown, missing,
+	// of the wrong type or rejected by their parameter's validation.
+	ErrInvalidParam = errors.New("preset: invalid parameter")
+)
+
+// ParamType is the type of the values of a parameter.
+type ParamType string
+
+const (
+	String ParamType = "string"
+	Int    ParamType = "int"
+	Float  ParamType = "float"
+	Bool   ParamType = "bool"
+	// Time values are time.Time, given as RFC 3339 times or dates.
+	Time ParamType = "time"
+)
+
+func (t ParamType) valid() bool {
+	switch t {
+	case String, Int, Float, Bool, Time:
+		return true
+	}
+	return false
+}
+
+// Param declares a parameter of a preset.
+type Param struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description,omitempty"`
+	// Default is the value of the parameter when no argument is given, of
+	// Type or convertible to it. Parameters without default are required.
+	Default interface{} `json:"default,omitempty"`
+	// Validate, when set, checks arguments after their conversion to Type,
+	// e.g. that a number of days is positive.
+	Validate func(value interface{}) error `json:"-"`
+}
+
+// Preset is a named specification template.
+type Preset struct {
+	Name string `json:"name"`
+	// Title is the label shown to users, also attached to instantiated
+	// specifications with WithDescription.
+	Title       string  `json:"title,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Params      []Param `json:"params,omitempty"`
+	// Build returns the specification for arguments bound to every
+	// parameter. ctx is that of the request, e.g. carrying the current
+	// user for a "My items" preset.
+	Build func(ctx context.Context, args Args) (specifications.Specification, error) `json:"-"`
+}
+
+// Args holds the arguments of a preset by parameter name, converted to
+// their parameter's type. The accessors return the zero value for other
+// names or types.
+type Args map[string]interface{}
+
+func (a Args) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+func (a Args) Int(name string) int {
+	i, _ := a[name].(int)
+	return i
+}
+
+func (a Args) Float(name string) float64 {
+	f, _ := a[name].(float64)
+	return f
+}
+
+func (a Args) Bool(name string) bool {
+	b, _ := a[name].(bool)
+	return b
+}
+
+func (a Args) Time(name string) time.Time {
+	t, _ := a[name].(time.Time)
+	return t
+}
+
+// Registry holds presets by name. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	presets map[string]Preset
+	names   []string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{presets: map[string]Preset{}}
+}
+
+// Register adds p. Its parameter defaults are checked like arguments, so
+// that a preset instantiated without arguments cannot fail on them.
+func (r *Registry) Register(p Preset) error {
+	if p.Name == "" || p.Build == nil {
+		return fmt.Errorf("%w: %q needs a name and Build", ErrInvalidPreset, p.Name)
+	}
+	p.Params = slices.Clone(p.Params)
+	seen := map[string]bool{}
+	for i, param := range p.Params {
+		if param.Name == "" || seen[param.Name] {
+			return fmt.Errorf("%w: %s: parameter %d has an empty or duplicate name", ErrInvalidPreset, p.Name, i)
+		}
+		seen[param.Name] = true
+		if !param.Type.valid() {
+			return fmt.Errorf("%w: %s: parameter %s has unknown type %q", ErrInvalidPreset, p.Name, param.Name, param.Type)
+		}
+		if param.Default == nil {
+			continue
+		}
+		value, err := param.bind(param.Default)
+		if err != nil {
+			return fmt.Errorf("%w: %s: default of parameter %s: %w", ErrInvalidPreset, p.Name, param.Name, err)
+		}
+		p.Params[i].Default = value
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.presets[p.Name]; ok {
+		return fmt.Errorf("%w: %s is already registered", ErrInvalidPreset, p.Name)
+	}
+	r.presets[p.Name] = p
+	r.names = append(r.names, p.Name)
+	return nil
+}
+
+// List returns the registered presets in registration order, e.g. to serve
+// them to frontends.
+func (r *Registry) List() []Preset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Preset, len(r.names))
+	for i, name := range r.names {
+		out[i] = r.presets[name]
+	}
+	return out
+}
+
+// Handler returns an HTTP handler serving List as JSON: the names, titles,
+// descriptions and parameters of the presets.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.List())
+	})
+}
+
+// Instantiate binds args to the parameters of the preset name and returns
+// the specification it builds, described by the preset's title. Arguments
+// are values of their parameter's type or their text form, as in query
+// parameters; JSON numbers are accepted for Int parameters when integral.
+// Parameters without argument take their default.
+func (r *Registry) Instantiate(ctx context.Context, name string, args map[string]interface{}) (specifications.Specification, error) {
+	r.mu.RLock()
+	p, ok := r.presets[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPreset, name)
+	}
+
+	bound := make(Args, len(p.Params))
+	for _, param := range p.Params {
+		raw, ok := args[param.Name]
+		if !ok {
+			if param.Default == nil {
+				return nil, fmt.Errorf("%w: %s: missing %s", ErrInvalidParam, name, param.Name)
+			}
+			bound[param.Name] = param.Default
+			continue
+		}
+		value, err := param.bind(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s: %w", ErrInvalidParam, name, param.Name, err)
+		}
+		bound[param.Name] = value
+	}
+	for arg := range args {
+		if _, ok := bound[arg]; !ok {
+			return nil, fmt.Errorf("%w: %s: unknown parameter %q", ErrInvalidParam, name, arg)
+		}
+	}
+
+	spec, err := p.Build(ctx, bound)
+	if err != nil {
+		return nil, fmt.Errorf("preset: %s: %w", name, err)
+	}
+	if p.Title != "" {
+		spec = specifications.WithDescription(spec, p.Title)
+	}
+	return spec, nil
+}
+
+func (p Param) bind(raw interface{}) (interface{}, error) {
+	value, err := convert(p.Type, raw)
+	if err != nil {
+		return nil, err
+	}
+	if p.Validate != nil {
+		if err := p.Validate(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// convert returns raw as a value of type t.
+func convert(t ParamType, raw interface{}) (interface{}, error) {
+	s, isString := raw.(string)
+	switch t {
+	case String:
+		if isString {
+			return s, nil
+		}
+	case Int:
+		switch v := raw.(type) {
+		case int:
+			return v, nil
+		case int64:
+			return int(v), nil
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int(v), nil
+			}
+		case string:
+			return strconv.Atoi(v)
+		}
+	case Float:
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	case Bool:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case Time:
+		switch v := raw.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t, nil
+			}
+			return time.Parse(time.DateOnly, v)
+		}
+	}
+	return nil, fmt.Errorf("%T value for a %s parameter", raw, t)
+}
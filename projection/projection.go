@@ -0,0 +1,99 @@
+// Package projection routes events to read-model projection handlers by
+// event type and specification. A handler registered with a specification
+// only receives events whose payload matches it, evaluated in memory, so
+// projections interested in a subset of an event stream are not invoked
+// for the rest.
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/memory"
+)
+
+// Event is an event to project. Payload is matched against the
+// specifications of the handlers registered for Type.
+type Event struct {
+	Type    string
+	Payload any
+}
+
+// Handler updates a read model from an event.
+type Handler interface {
+	Handle(ctx context.Context, e Event) error
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, e Event) error
+
+func (f HandlerFunc) Handle(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+type route struct {
+	match   memory.Predicate
+	handler Handler
+}
+
+// Registry maps event types and specifications to handlers. It is safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	fieldMap map[string]string
+	routes   map[string][]route
+}
+
+// NewRegistry returns an empty registry resolving specification fields in
+// payloads through fieldMap, like memory.NewEvaluator.
+func NewRegistry(fieldMap map[string]string) *Registry {
+	return &Registry{
+		fieldMap: fieldMap,
+		routes:   map[string][]route{},
+	}
+}
+
+// Register routes events of eventType whose payload matches spec to h. A
+// nil spec matches every payload. Ordering and pagination in spec are
+// ignored. An invalid spec is reported and nothing is registered.
+func (r *Registry) Register(eventType string, spec specifications.Specification, h Handler) error {
+	match := memory.Predicate(func(any) bool { return true })
+	if spec != nil {
+		p, err := memory.Compile(spec, r.fieldMap)
+		if err != nil {
+			return fmt.Errorf("projection: %s: %w", eventType, err)
+		}
+		match = p
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[eventType] = append(r.routes[eventType], route{match: match, handler: h})
+	return nil
+}
+
+// Dispatch invokes, in registration order, every handler registered for
+// the event's type whose specification matches its payload. All matching
+// handlers run even if some fail; their errors are joined. It returns the
+// number of handlers invoked.
+func (r *Registry) Dispatch(ctx context.Context, e Event) (int, error) {
+	r.mu.RLock()
+	routes := r.routes[e.Type]
+	r.mu.RUnlock()
+
+	invoked := 0
+	var errs []error
+	for _, rt := range routes {
+		if !rt.match(e.Payload) {
+			continue
+		}
+		invoked++
+		if err := rt.handler.Handle(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return invoked, errors.Join(errs...)
+}
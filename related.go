@@ -0,0 +1,77 @@
+package specifications
+
+import (
+	"context"
+	"fmt"
+)
+
+type relatedSpec struct {
+	relation string
+	spec     Specification
+}
+
+func (s *relatedSpec) Accept(v SpecificationVisitor) {
+	v.VisitRelated(s.relation, s.spec)
+}
+
+// Related matches the entities whose related entity through relation
+// satisfies spec, e.g. the orders of VIP customers:
+//
+//	specifications.And(
+//		specifications.Equal("status", "paid"),
+//		specifications.Related("customer", specifications.Equal("tier", "vip")),
+//	)
+//
+// The fields of spec are those of the related entity. Each relation is
+// resolved either by the visitor, e.g. as an EXISTS subquery by sqlspec
+// visitors configured with WithRelation, or beforehand by ExpandRelated.
+// Visitors knowing neither report ErrUnsupported.
+func Related(relation string, spec Specification) Specification {
+	return &relatedSpec{relation: relation, spec: spec}
+}
+
+// NotRelated matches the entities without any related entity through
+// relation satisfying spec, e.g. the customers without a recent order. It
+// is Not(Related(relation, spec)), which sqlspec visitors write as NOT
+// EXISTS or, with WithAntiJoins, as a LEFT JOIN anti-join.
+func NotRelated(relation string, spec Specification) Specification {
+	return Not(Related(relation, spec))
+}
+
+// RelationResolver resolves a relation in two steps, for relations across
+// databases or services: the keys of the related entities matching a
+// specification are fetched, then compared with Field.
+type RelationResolver struct {
+	// Field is the field of the referencing entity holding the key of the
+	// related one, e.g. "customer_id".
+	Field string
+	// Keys returns the keys of the related entities matching spec, e.g.
+	// from the repository of customers.
+	Keys func(ctx context.Context, spec Specification) ([]interface{}, error)
+}
+
+// ExpandRelated returns a copy of spec in which the Related specifications
+// on the relations of resolvers are replaced by In conditions on the keys
+// their resolver returns. Related specifications on other relations are
+// kept, to be resolved by the visitor. The first error of a resolver is
+// returned.
+func ExpandRelated(ctx context.Context, spec Specification, resolvers map[string]RelationResolver) (Specification, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	r := &rewriter{related: func(relation string, spec Specification) (Specification, bool, error) {
+		resolver, ok := resolvers[relation]
+		if !ok {
+			return nil, false, nil
+		}
+		if resolver.Field == "" || resolver.Keys == nil {
+			return nil, false, fmt.Errorf("%w: relation %q needs a field and Keys", ErrInvalidValue, relation)
+		}
+		keys, err := resolver.Keys(ctx, spec)
+		if err != nil {
+			return nil, false, fmt.Errorf("specifications: resolving relation %q: %w", relation, err)
+		}
+		return In(resolver.Field, keys...), true, nil
+	}}
+	return r.rewrite(spec)
+}
@@ -0,0 +1,324 @@
+package specifications
+
+import "fmt"
+
+// rewriter is a SpecificationVisitor rebuilding each visited specification
+// through the public constructors, after passing it to hooks that may
+// change it. It is the basis of the transformations in this package.
+type rewriter struct {
+	// value converts a value compared with field. Nil keeps values.
+	value func(field string, value interface{}) (interface{}, error)
+	// window, when set, records the pagination instead of emitting it.
+	window *window
+	// related replaces the Related specifications it handles. Nil keeps
+	// them.
+	related func(relation string, spec Specification) (Specification, bool, error)
+	// pattern, when set, is called with the patterns of LIKE conditions.
+	pattern func(field, pattern string)
+	out     []Specification
+	err     error
+}
+
+// window is the pagination of a specification, zero when unset.
+type window struct {
+	offset, limit int
+	// limited tells a Limit of zero, matching nothing, from none.
+	limited bool
+}
+
+// rewrite rebuilds spec with r's hooks.
+func (r *rewriter) rewrite(spec Specification) (Specification, error) {
+	spec.Accept(r)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.out) == 1 {
+		return r.out[0], nil
+	}
+	// Custom specifications may visit any number of methods.
+	return And(r.out...), nil
+}
+
+func (r *rewriter) Err() error {
+	return r.err
+}
+
+func (r *rewriter) emit(spec Specification) {
+	r.out = append(r.out, spec)
+}
+
+func (r *rewriter) convert(field string, value interface{}) interface{} {
+	if r.value == nil || r.err != nil {
+		return value
+	}
+	converted, err := r.value(field, value)
+	if err != nil {
+		r.err = err
+		return value
+	}
+	return converted
+}
+
+func (r *rewriter) convertAll(field string, values []interface{}) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = r.convert(field, v)
+	}
+	return out
+}
+
+// children rebuilds specs with a sub-rewriter sharing r's hooks.
+func (r *rewriter) children(specs []Specification) []Specification {
+	sub := &rewriter{value: r.value, window: r.window, related: r.related, pattern: r.pattern}
+	for _, s := range specs {
+		s.Accept(sub)
+	}
+	if sub.err != nil && r.err == nil {
+		r.err = sub.err
+	}
+	return sub.out
+}
+
+func (r *rewriter) VisitEqual(field string, value interface{}) {
+	r.emit(Equal(field, r.convert(field, value)))
+}
+
+func (r *rewriter) VisitNotEqual(field string, value interface{}) {
+	r.emit(NotEqual(field, r.convert(field, value)))
+}
+
+func (r *rewriter) VisitIn(field string, values []interface{}) {
+	r.emit(In(field, r.convertAll(field, values)...))
+}
+
+func (r *rewriter) VisitNotIn(field string, values []interface{}) {
+	r.emit(NotIn(field, r.convertAll(field, values)...))
+}
+
+// VisitAnd drops conjunctions emptied by the rewrite, e.g. of their
+// pagination, rather than emitting empty ones.
+func (r *rewriter) VisitAnd(specs []Specification) {
+	if children := r.children(specs); len(children) > 0 || len(specs) == 0 {
+		r.emit(And(children...))
+	}
+}
+
+func (r *rewriter) VisitOr(specs []Specification) {
+	if children := r.children(specs); len(children) > 0 || len(specs) == 0 {
+		r.emit(Or(children...))
+	}
+}
+
+func (r *rewriter) VisitDescribed(description string, spec Specification) {
+	children := r.children([]Specification{spec})
+	if len(children) == 1 {
+		r.emit(WithDescription(children[0], description))
+	}
+}
+
+func (r *rewriter) VisitNot(spec Specification) {
+	children := r.children([]Specification{spec})
+	if len(children) == 1 {
+		r.emit(Not(children[0]))
+	}
+}
+
+// VisitLimit records limit in the window, if any, overriding earlier
+// limits like the visitors do.
+func (r *rewriter) VisitLimit(limit int) {
+	if r.window != nil {
+		if limit >= 0 {
+			r.window.limit, r.window.limited = limit, true
+		}
+		return
+	}
+	r.emit(Limit(limit))
+}
+
+func (r *rewriter) VisitOffset(offset int) {
+	if r.window != nil {
+		if offset >= 0 {
+			r.window.offset = offset
+		}
+		return
+	}
+	r.emit(Offset(offset))
+}
+
+func (r *rewriter) VisitOrder(field, direction string) {
+	r.emit(OrderBy(field, direction))
+}
+
+func (r *rewriter) VisitDistinct(fields []string) {
+	if len(fields) == 0 {
+		r.emit(Distinct())
+		return
+	}
+	r.emit(DistinctOn(fields...))
+}
+
+func (r *rewriter) VisitGroupBy(fields []string) {
+	r.emit(GroupBy(fields...))
+}
+
+func (r *rewriter) VisitHaving(spec Specification) {
+	children := r.children([]Specification{spec})
+	if len(children) == 1 {
+		r.emit(Having(children[0]))
+	}
+}
+
+// VisitAggregate keeps the values compared with aggregates, which do not
+// have the type of the aggregated field.
+func (r *rewriter) VisitAggregate(function AggregateFunction, spec Specification) {
+	sub := &rewriter{}
+	spec.Accept(sub)
+	if sub.err != nil && r.err == nil {
+		r.err = sub.err
+	}
+	if len(sub.out) == 1 {
+		r.emit(Aggregated(function, sub.out[0]))
+	}
+}
+
+// VisitRelated keeps spec, whose fields are those of the related entity,
+// including its pagination.
+func (r *rewriter) VisitRelated(relation string, spec Specification) {
+	if r.related != nil && r.err == nil {
+		replaced, ok, err := r.related(relation, spec)
+		if err != nil {
+			r.err = err
+			return
+		}
+		if ok {
+			r.emit(replaced)
+			return
+		}
+	}
+	r.emit(Related(relation, spec))
+}
+
+func (r *rewriter) VisitLock(strength LockStrength, wait LockWait) {
+	r.emit(Lock(strength, wait))
+}
+
+// VisitJSONContains keeps doc, which does not have the type of field.
+func (r *rewriter) VisitJSONContains(field string, doc interface{}) {
+	r.emit(JSONContains(field, doc))
+}
+
+// VisitJSONPathEqual keeps value, like VisitJSONContains.
+func (r *rewriter) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	r.emit(JSONPathEqual(field, path, value))
+}
+
+func (r *rewriter) VisitJSONKeyExists(field, key string) {
+	r.emit(JSONKeyExists(field, key))
+}
+
+func (r *rewriter) VisitArrayContains(field string, values []interface{}) {
+	r.emit(ArrayContains(field, r.convertAll(field, values)...))
+}
+
+func (r *rewriter) VisitArrayOverlaps(field string, values []interface{}) {
+	r.emit(ArrayOverlaps(field, r.convertAll(field, values)...))
+}
+
+func (r *rewriter) VisitEqualAny(field string, values []interface{}) {
+	r.emit(EqualAny(field, r.convertAll(field, values)...))
+}
+
+func (r *rewriter) VisitTextSearch(field, query, language string) {
+	r.emit(TextSearch(field, query, Language(language)))
+}
+
+func (r *rewriter) VisitTextRank(field, query, language string) {
+	r.emit(OrderByRank(field, query, Language(language)))
+}
+
+func (r *rewriter) VisitSimilar(field, value string, threshold float64) {
+	r.emit(SimilarTo(field, value, threshold))
+}
+
+func (r *rewriter) VisitSimilarityOrder(field, value string) {
+	r.emit(OrderBySimilarity(field, value))
+}
+
+func (r *rewriter) VisitWithinRadius(field string, center Point, meters float64) {
+	r.emit(WithinRadius(field, center.Lat, center.Lng, meters))
+}
+
+func (r *rewriter) VisitWithinBox(field string, box BoundingBox) {
+	r.emit(WithinBoundingBox(field, box))
+}
+
+func (r *rewriter) VisitOrderByDistance(field string, from Point) {
+	r.emit(OrderByDistance(field, from.Lat, from.Lng))
+}
+
+func (r *rewriter) VisitOrderNulls(field, direction string, nulls NullsPosition) {
+	r.emit(OrderByNulls(field, direction, nulls))
+}
+
+func (r *rewriter) VisitGreaterThan(field string, value interface{}) {
+	r.emit(GreaterThan(field, r.convert(field, value)))
+}
+
+func (r *rewriter) VisitLowerThan(field string, value interface{}) {
+	r.emit(LowerThan(field, r.convert(field, value)))
+}
+
+func (r *rewriter) VisitGreaterThanOrEqual(field string, value interface{}) {
+	r.emit(GreaterThanOrEqual(field, r.convert(field, value)))
+}
+
+func (r *rewriter) VisitLowerThanOrEqual(field string, value interface{}) {
+	r.emit(LowerThanOrEqual(field, r.convert(field, value)))
+}
+
+func (r *rewriter) VisitBetween(field string, low, high interface{}) {
+	r.emit(Between(field, r.convert(field, low), r.convert(field, high)))
+}
+
+func (r *rewriter) VisitIsNull(field string) {
+	r.emit(IsNull(field))
+}
+
+func (r *rewriter) VisitIsNotNull(field string) {
+	r.emit(IsNotNull(field))
+}
+
+// VisitLike keeps the pattern: converters apply to compared values, not
+// patterns.
+func (r *rewriter) VisitLike(field string, value interface{}) {
+	r.like(field, value)
+	r.emit(Like(field, value))
+}
+
+func (r *rewriter) VisitILike(field string, value interface{}) {
+	r.like(field, value)
+	r.emit(ILike(field, value))
+}
+
+func (r *rewriter) VisitLikeEscaped(field string, pattern string) {
+	r.like(field, pattern)
+	r.emit(LikeEscaped(field, pattern))
+}
+
+func (r *rewriter) like(field string, pattern interface{}) {
+	if r.pattern != nil {
+		r.pattern(field, fmt.Sprint(pattern))
+	}
+}
+
+func (r *rewriter) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	if caseInsensitive {
+		r.emit(IMatches(field, pattern))
+		return
+	}
+	r.emit(Matches(field, pattern))
+}
+
+func (r *rewriter) VisitSample(percent float64, method SampleMethod) {
+	r.emit(Sample(percent, method))
+}
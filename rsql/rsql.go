@@ -0,0 +1,371 @@
+// Package rsql parses RSQL/FIQL filters into specifications:
+//
+//	name==foo;age=gt=30,(status=in=(active,trial);deleted_at=isnull=true)
+//
+// ';' is AND and binds tighter than ',' (OR). Comparisons are ==, !=,
+// =gt= (or >), =ge= (or >=), =lt= (or <), =le= (or <=), =in=, =out= and
+// =isnull=. With ==, a value containing '*' is a LIKE pattern in
+// which '*' matches any sequence of characters. Values are unquoted or
+// quoted with single or double quotes, using '\' as escape.
+package rsql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+var (
+	// ErrSyntax is returned for malformed filters.
+	ErrSyntax = errors.New("rsql: syntax error")
+	// ErrFieldNotAllowed is returned for selectors that are not allowed.
+	ErrFieldNotAllowed = errors.New("rsql: field not allowed")
+	// ErrOperatorNotAllowed is returned for unknown or disallowed
+	// comparison operators.
+	ErrOperatorNotAllowed = errors.New("rsql: operator not allowed")
+)
+
+// Operator is an RSQL comparison operator, in its FIQL form.
+type Operator string
+
+const (
+	OpEqual              Operator = "=="
+	OpNotEqual           Operator = "!="
+	OpGreaterThan        Operator = "=gt="
+	OpGreaterThanOrEqual Operator = "=ge="
+	OpLowerThan          Operator = "=lt="
+	OpLowerThanOrEqual   Operator = "=le="
+	OpIn                 Operator = "=in="
+	OpNotIn              Operator = "=out="
+	OpIsNull             Operator = "=isnull="
+)
+
+var aliases = map[string]Operator{
+	">":  OpGreaterThan,
+	">=": OpGreaterThanOrEqual,
+	"<":  OpLowerThan,
+	"<=": OpLowerThanOrEqual,
+}
+
+// maxDepth bounds parenthesis nesting.
+const maxDepth = 32
+
+// Converter turns a raw argument into the value compared with field.
+type Converter func(field, value string) (interface{}, error)
+
+// Parser parses filters restricted to a set of selectors and operators.
+type Parser struct {
+	fields    map[string]bool
+	operators map[Operator]bool
+	convert   Converter
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithOperators restricts the accepted comparison operators to ops. By
+// default every operator is accepted.
+func WithOperators(ops ...Operator) Option {
+	return func(p *Parser) {
+		p.operators = make(map[Operator]bool, len(ops))
+		for _, op := range ops {
+			p.operators[op] = true
+		}
+	}
+}
+
+// WithConverter sets how arguments are converted. By default they are
+// passed on as strings.
+func WithConverter(convert Converter) Option {
+	return func(p *Parser) {
+		p.convert = convert
+	}
+}
+
+// NewParser returns a parser accepting fields as selectors, the domain field
+// names of the specifications it produces.
+func NewParser(fields []string, opts ...Option) *Parser {
+	p := &Parser{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		p.fields[f] = true
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Parse turns an RSQL filter into a specification. An empty filter yields
+// an empty And.
+func (p *Parser) Parse(input string) (specifications.Specification, error) {
+	if strings.TrimSpace(input) == "" {
+		return specifications.And(), nil
+	}
+	s := &state{parser: p, input: input}
+	spec, err := s.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if s.skipSpace(); s.pos < len(s.input) {
+		return nil, s.errorf("unexpected %q", s.input[s.pos])
+	}
+	return spec, nil
+}
+
+type state struct {
+	parser *Parser
+	input  string
+	pos    int
+}
+
+func (s *state) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrSyntax, s.pos, fmt.Sprintf(format, args...))
+}
+
+func (s *state) skipSpace() {
+	for s.pos < len(s.input) && (s.input[s.pos] == ' ' || s.input[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+// accept consumes c if it is the next non-space byte.
+func (s *state) accept(c byte) bool {
+	s.skipSpace()
+	if s.pos < len(s.input) && s.input[s.pos] == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *state) or(depth int) (specifications.Specification, error) {
+	return s.list(',', depth, s.and, specifications.Or)
+}
+
+func (s *state) and(depth int) (specifications.Specification, error) {
+	return s.list(';', depth, s.constraint, specifications.And)
+}
+
+// list parses operands separated by sep, combining two or more of them.
+func (s *state) list(sep byte, depth int, operand func(int) (specifications.Specification, error), combine func(...specifications.Specification) specifications.Specification) (specifications.Specification, error) {
+	first, err := operand(depth)
+	if err != nil {
+		return nil, err
+	}
+	specs := []specifications.Specification{first}
+	for s.accept(sep) {
+		spec, err := operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 1 {
+		return first, nil
+	}
+	return combine(specs...), nil
+}
+
+func (s *state) constraint(depth int) (specifications.Specification, error) {
+	if s.accept('(') {
+		if depth >= maxDepth {
+			return nil, s.errorf("nesting deeper than %d", maxDepth)
+		}
+		spec, err := s.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !s.accept(')') {
+			return nil, s.errorf("expected ')'")
+		}
+		return spec, nil
+	}
+	return s.comparison()
+}
+
+func (s *state) comparison() (specifications.Specification, error) {
+	s.skipSpace()
+	field := s.unreserved()
+	if field == "" {
+		return nil, s.errorf("expected selector")
+	}
+	if !s.parser.fields[field] {
+		return nil, fmt.Errorf("%w at offset %d: %q", ErrFieldNotAllowed, s.pos-len(field), field)
+	}
+
+	op, err := s.operator()
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	s.skipSpace()
+	if s.accept('(') {
+		for {
+			arg, err := s.argument()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if s.accept(')') {
+				break
+			}
+			if !s.accept(',') {
+				return nil, s.errorf("expected ',' or ')'")
+			}
+		}
+	} else {
+		arg, err := s.argument()
+		if err != nil {
+			return nil, err
+		}
+		args = []string{arg}
+	}
+	if len(args) > 1 && op != OpIn && op != OpNotIn {
+		return nil, s.errorf("%s takes a single argument", op)
+	}
+
+	switch op {
+	case OpIsNull:
+		isNull, err := strconv.ParseBool(args[0])
+		if err != nil {
+			return nil, s.errorf("%s expects true or false", op)
+		}
+		if isNull {
+			return specifications.IsNull(field), nil
+		}
+		return specifications.IsNotNull(field), nil
+	case OpIn, OpNotIn:
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			if values[i], err = s.value(field, arg); err != nil {
+				return nil, err
+			}
+		}
+		if op == OpIn {
+			return specifications.In(field, values...), nil
+		}
+		return specifications.NotIn(field, values...), nil
+	case OpEqual, OpNotEqual:
+		if strings.Contains(args[0], "*") {
+			if op == OpNotEqual {
+				return nil, s.errorf("wildcards are not supported with %s", op)
+			}
+			pattern := strings.ReplaceAll(specifications.EscapeLike(args[0]), "*", "%")
+			return specifications.LikeEscaped(field, pattern), nil
+		}
+	}
+
+	value, err := s.value(field, args[0])
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case OpNotEqual:
+		return specifications.NotEqual(field, value), nil
+	case OpGreaterThan:
+		return specifications.GreaterThan(field, value), nil
+	case OpGreaterThanOrEqual:
+		return specifications.GreaterThanOrEqual(field, value), nil
+	case OpLowerThan:
+		return specifications.LowerThan(field, value), nil
+	case OpLowerThanOrEqual:
+		return specifications.LowerThanOrEqual(field, value), nil
+	}
+	return specifications.Equal(field, value), nil
+}
+
+// operator reads ==, !=, a FIQL =name= operator or one of its aliases.
+func (s *state) operator() (Operator, error) {
+	s.skipSpace()
+	rest := s.input[s.pos:]
+	var op Operator
+	n := 0
+	switch {
+	case strings.HasPrefix(rest, "=="), strings.HasPrefix(rest, "!="):
+		op, n = Operator(rest[:2]), 2
+	case strings.HasPrefix(rest, ">="), strings.HasPrefix(rest, "<="):
+		op, n = aliases[rest[:2]], 2
+	case strings.HasPrefix(rest, ">"), strings.HasPrefix(rest, "<"):
+		op, n = aliases[rest[:1]], 1
+	case strings.HasPrefix(rest, "="):
+		end := strings.IndexByte(rest[1:], '=')
+		if end < 0 {
+			return "", s.errorf("expected comparison operator")
+		}
+		op, n = Operator(rest[:end+2]), end+2
+	default:
+		return "", s.errorf("expected comparison operator")
+	}
+	if !validOperator(op) || (s.parser.operators != nil && !s.parser.operators[op]) {
+		return "", fmt.Errorf("%w at offset %d: %s", ErrOperatorNotAllowed, s.pos, op)
+	}
+	s.pos += n
+	return op, nil
+}
+
+func validOperator(op Operator) bool {
+	switch op {
+	case OpEqual, OpNotEqual, OpGreaterThan, OpGreaterThanOrEqual, OpLowerThan, OpLowerThanOrEqual,
+		OpIn, OpNotIn, OpIsNull:
+		return true
+	}
+	return false
+}
+
+// argument reads a quoted or unreserved value.
+func (s *state) argument() (string, error) {
+	s.skipSpace()
+	if s.pos < len(s.input) && (s.input[s.pos] == '"' || s.input[s.pos] == '\'') {
+		return s.quoted()
+	}
+	arg := s.unreserved()
+	if arg == "" {
+		return "", s.errorf("expected argument")
+	}
+	return arg, nil
+}
+
+func (s *state) quoted() (string, error) {
+	quote := s.input[s.pos]
+	start := s.pos
+	var b strings.Builder
+	for s.pos++; s.pos < len(s.input); s.pos++ {
+		c := s.input[s.pos]
+		switch {
+		case c == '\\' && s.pos+1 < len(s.input):
+			s.pos++
+			b.WriteByte(s.input[s.pos])
+		case c == quote:
+			s.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	s.pos = start
+	return "", s.errorf("unterminated string")
+}
+
+// unreserved reads a run of characters with no meaning in RSQL.
+func (s *state) unreserved() string {
+	start := s.pos
+	for s.pos < len(s.input) && !strings.ContainsRune("\"'();,=!~<> \t", rune(s.input[s.pos])) {
+		s.pos++
+	}
+	return s.input[start:s.pos]
+}
+
+func (s *state) value(field, raw string) (interface{}, error) {
+	if s.parser.convert == nil {
+		return raw, nil
+	}
+	v, err := s.parser.convert(field, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", specifications.ErrInvalidValue, field, err)
+	}
+	return v, nil
+}
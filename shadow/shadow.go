@@ -0,0 +1,154 @@
+// Package shadow executes specifications against a primary and a shadow
+// backend and reports where their results diverge. It is meant for
+// migrations between backends (or visitor versions): callers keep serving
+// the primary result while gaining evidence that the shadow agrees.
+//
+// It also serves as a canary for rewrites such as optimization passes: with
+// the same backend on both sides, ShadowSpec applying the rewrite and a
+// small SampleRate, a fraction of traffic compares the rewritten
+// specification's results with the original's.
+package shadow
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Backend returns the identifiers of the entities matching a specification.
+type Backend interface {
+	IDs(ctx context.Context, spec specifications.Specification) ([]string, error)
+}
+
+// BackendFunc adapts a function to the Backend interface.
+type BackendFunc func(ctx context.Context, spec specifications.Specification) ([]string, error)
+
+func (f BackendFunc) IDs(ctx context.Context, spec specifications.Specification) ([]string, error) {
+	return f(ctx, spec)
+}
+
+// Comparison describes the outcome of one shadowed execution.
+type Comparison struct {
+	Spec         specifications.Specification
+	PrimaryCount int
+	ShadowCount  int
+	// MissingInShadow lists primary IDs the shadow did not return.
+	MissingInShadow []string
+	// ExtraInShadow lists shadow IDs the primary did not return.
+	ExtraInShadow []string
+	// OrderDiffers is set when both returned the same IDs in a different
+	// order. It is only computed when Reader.CompareOrder is set.
+	OrderDiffers bool
+	// ShadowErr is the error returned by the shadow backend, if any.
+	ShadowErr error
+}
+
+// Diverged reports whether the shadow failed or disagreed with the primary.
+func (c Comparison) Diverged() bool {
+	return c.ShadowErr != nil || len(c.MissingInShadow) > 0 || len(c.ExtraInShadow) > 0 || c.OrderDiffers
+}
+
+// Sink receives every comparison, e.g. to increment match/divergence
+// counters and log divergent specifications.
+type Sink interface {
+	Report(ctx context.Context, c Comparison)
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, c Comparison)
+
+func (f SinkFunc) Report(ctx context.Context, c Comparison) {
+	f(ctx, c)
+}
+
+// Reader is a Backend that serves results from Primary while executing the
+// same specification against Shadow concurrently and reporting the
+// comparison to Sink. Shadow failures never affect the returned result.
+type Reader struct {
+	Primary Backend
+	Shadow  Backend
+	Sink    Sink
+	// CompareOrder also compares the order of the returned IDs, for specs
+	// that include ordering.
+	CompareOrder bool
+	// ShadowSpec, when set, derives the specification executed against
+	// Shadow, e.g. by applying an optimization pass.
+	ShadowSpec func(specifications.Specification) specifications.Specification
+	// SampleRate is the fraction of calls, in (0, 1], also executed against
+	// Shadow. Zero shadows every call.
+	SampleRate float64
+}
+
+// sampled reports whether the current call is shadowed.
+func (r *Reader) sampled() bool {
+	return r.SampleRate <= 0 || r.SampleRate >= 1 || rand.Float64() < r.SampleRate
+}
+
+func (r *Reader) IDs(ctx context.Context, spec specifications.Specification) ([]string, error) {
+	if !r.sampled() {
+		return r.Primary.IDs(ctx, spec)
+	}
+	shadowSpec := spec
+	if r.ShadowSpec != nil {
+		shadowSpec = r.ShadowSpec(spec)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		shadowIDs []string
+		shadowErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		shadowIDs, shadowErr = r.Shadow.IDs(ctx, shadowSpec)
+	}()
+
+	ids, err := r.Primary.IDs(ctx, spec)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Sink != nil {
+		r.Sink.Report(ctx, r.compare(spec, ids, shadowIDs, shadowErr))
+	}
+	return ids, nil
+}
+
+func (r *Reader) compare(spec specifications.Specification, primary, shadow []string, shadowErr error) Comparison {
+	c := Comparison{
+		Spec:         spec,
+		PrimaryCount: len(primary),
+		ShadowCount:  len(shadow),
+		ShadowErr:    shadowErr,
+	}
+	if shadowErr != nil {
+		return c
+	}
+
+	c.MissingInShadow = difference(primary, shadow)
+	c.ExtraInShadow = difference(shadow, primary)
+	if r.CompareOrder && len(c.MissingInShadow) == 0 && len(c.ExtraInShadow) == 0 {
+		c.OrderDiffers = !slices.Equal(primary, shadow)
+	}
+	return c
+}
+
+// difference returns the elements of a that are not in b.
+func difference(a, b []string) []string {
+	in := make(map[string]struct{}, len(b))
+	for _, id := range b {
+		in[id] = struct{}{}
+	}
+	var out []string
+	for _, id := range a {
+		if _, ok := in[id]; !ok {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package specifications
+
+type similarSpec struct {
+	field     string
+	value     string
+	threshold float64
+	order     bool
+}
+
+func (s *similarSpec) Accept(v SpecificationVisitor) {
+	if s.order {
+		v.VisitSimilarityOrder(s.field, s.value)
+		return
+	}
+	v.VisitSimilar(s.field, s.value, s.threshold)
+}
+
+// SimilarTo matches entities whose text field is similar to value, as
+// measured by the trigrams they share, e.g. for search-as-you-type
+// tolerating typos. threshold is the minimum similarity, between 0 and 1;
+// zero uses the database's, pg_trgm.similarity_threshold in PostgreSQL,
+// which lets trigram indexes apply. In PostgreSQL,
+//
+//	field % value
+//	similarity(field, value) > threshold
+func SimilarTo(field, value string, threshold float64) Specification {
+	return &similarSpec{field: field, value: value, threshold: threshold}
+}
+
+// OrderBySimilarity orders by decreasing similarity of field to value.
+// Combine it with SimilarTo on the same field and value.
+func OrderBySimilarity(field, value string) Specification {
+	return &similarSpec{field: field, value: value, order: true}
+}
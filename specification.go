@@ -1,5 +1,10 @@
 package specifications
 
+import (
+	"regexp"
+	"strings"
+)
+
 // Specification is the interface that all specifications must implement.
 // This interface represents a condition or a set of conditions that can be
 // translated by a visitor or applied to in-memory objects.
@@ -25,7 +30,63 @@ type SpecificationVisitor interface {
 	VisitGreaterThanOrEqual(field string, value interface{})
 	VisitLowerThanOrEqual(field string, value interface{})
 	VisitOffset(offset int)
-}
+	VisitBetween(field string, low, high interface{})
+	VisitIsNull(field string)
+	VisitIsNotNull(field string)
+	VisitNotIn(field string, values []interface{})
+	VisitSample(percent float64, method SampleMethod)
+	VisitILike(field string, value interface{})
+	// VisitLikeEscaped receives a LIKE pattern whose literal '%', '_' and '\'
+	// characters have been escaped with LikeEscapeChar.
+	VisitLikeEscaped(field string, pattern string)
+	VisitRegex(field string, pattern string, caseInsensitive bool)
+	// VisitNot receives a specification whose conditions must not hold.
+	// Ordering and pagination within it apply unchanged.
+	VisitNot(spec Specification)
+	// VisitOrderNulls is VisitOrder with an explicit placement of NULLs,
+	// NullsFirst or NullsLast.
+	VisitOrderNulls(field, direction string, nulls NullsPosition)
+	// VisitDistinct receives the fields of a DistinctOn specification, or
+	// none for Distinct.
+	VisitDistinct(fields []string)
+	// VisitGroupBy receives the fields of a GroupBy specification.
+	VisitGroupBy(fields []string)
+	// VisitHaving receives a specification filtering the groups.
+	VisitHaving(spec Specification)
+	// VisitAggregate receives a specification whose fields stand for
+	// function applied to them.
+	VisitAggregate(function AggregateFunction, spec Specification)
+	// VisitLock receives the row lock of a Lock specification.
+	VisitLock(strength LockStrength, wait LockWait)
+	VisitJSONContains(field string, doc interface{})
+	VisitJSONPathEqual(field string, path []string, value interface{})
+	VisitJSONKeyExists(field, key string)
+	VisitArrayContains(field string, values []interface{})
+	VisitArrayOverlaps(field string, values []interface{})
+	VisitEqualAny(field string, values []interface{})
+	VisitTextSearch(field, query, language string)
+	VisitTextRank(field, query, language string)
+	// VisitSimilar receives a trigram similarity condition; a zero
+	// threshold stands for the database's.
+	VisitSimilar(field, value string, threshold float64)
+	VisitSimilarityOrder(field, value string)
+	VisitWithinRadius(field string, center Point, meters float64)
+	VisitWithinBox(field string, box BoundingBox)
+	VisitOrderByDistance(field string, from Point)
+	// VisitRelated receives a specification on the entity related through
+	// relation.
+	VisitRelated(relation string, spec Specification)
+}
+
+// SampleMethod selects how rows are sampled by a Sample specification.
+type SampleMethod string
+
+const (
+	// SampleSystem samples whole storage blocks; fast but coarse.
+	SampleSystem SampleMethod = "SYSTEM"
+	// SampleBernoulli samples each row independently.
+	SampleBernoulli SampleMethod = "BERNOULLI"
+)
 
 // Base structure to define atomic specifications (e.g. equality checks)
 type equalSpec struct {
@@ -47,6 +108,15 @@ func (s *inSpec) Accept(v SpecificationVisitor) {
 	v.VisitIn(s.field, s.values)
 }
 
+type notInSpec struct {
+	field  string
+	values []interface{}
+}
+
+func (s *notInSpec) Accept(v SpecificationVisitor) {
+	v.VisitNotIn(s.field, s.values)
+}
+
 // Composite specifications
 type andSpec struct {
 	specs []Specification
@@ -64,6 +134,14 @@ func (s *orSpec) Accept(v SpecificationVisitor) {
 	v.VisitOr(s.specs)
 }
 
+type notSpec struct {
+	spec Specification
+}
+
+func (s *notSpec) Accept(v SpecificationVisitor) {
+	v.VisitNot(s.spec)
+}
+
 type limitSpec struct {
 	limit int
 }
@@ -135,6 +213,34 @@ func (s *likeSpec) Accept(v SpecificationVisitor) {
 	v.VisitLike(s.field, s.value)
 }
 
+type iLikeSpec struct {
+	field string
+	value interface{}
+}
+
+func (s *iLikeSpec) Accept(v SpecificationVisitor) {
+	v.VisitILike(s.field, s.value)
+}
+
+type likeEscapedSpec struct {
+	field   string
+	pattern string
+}
+
+func (s *likeEscapedSpec) Accept(v SpecificationVisitor) {
+	v.VisitLikeEscaped(s.field, s.pattern)
+}
+
+type regexSpec struct {
+	field           string
+	pattern         string
+	caseInsensitive bool
+}
+
+func (s *regexSpec) Accept(v SpecificationVisitor) {
+	v.VisitRegex(s.field, s.pattern, s.caseInsensitive)
+}
+
 type offsetSpec struct {
 	offset int
 }
@@ -143,6 +249,41 @@ func (s *offsetSpec) Accept(v SpecificationVisitor) {
 	v.VisitOffset(s.offset)
 }
 
+type betweenSpec struct {
+	field string
+	low   interface{}
+	high  interface{}
+}
+
+func (s *betweenSpec) Accept(v SpecificationVisitor) {
+	v.VisitBetween(s.field, s.low, s.high)
+}
+
+type isNullSpec struct {
+	field string
+}
+
+func (s *isNullSpec) Accept(v SpecificationVisitor) {
+	v.VisitIsNull(s.field)
+}
+
+type isNotNullSpec struct {
+	field string
+}
+
+func (s *isNotNullSpec) Accept(v SpecificationVisitor) {
+	v.VisitIsNotNull(s.field)
+}
+
+type sampleSpec struct {
+	percent float64
+	method  SampleMethod
+}
+
+func (s *sampleSpec) Accept(v SpecificationVisitor) {
+	v.VisitSample(s.percent, s.method)
+}
+
 func GreaterThanOrEqual(field string, value interface{}) Specification {
 	return &greaterThanOrEqualSpec{
 		field: field,
@@ -185,6 +326,130 @@ func Like(field string, value interface{}) Specification {
 	}
 }
 
+// ILike is the case-insensitive variant of Like. Backends without a native
+// operator may render it as LOWER(field) LIKE LOWER(value).
+func ILike(field string, value interface{}) Specification {
+	return &iLikeSpec{
+		field: field,
+		value: value,
+	}
+}
+
+// LikeEscapeChar is the escape character used by patterns built with
+// StartsWith, EndsWith and Contains.
+const LikeEscapeChar = '\\'
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes LIKE metacharacters in s so that it matches literally.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// LikeToRegexp converts a LIKE pattern into an anchored regular expression,
+// honoring LikeEscapeChar like PostgreSQL does by default. It is meant for
+// backends without a native LIKE operator.
+func LikeToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == LikeEscapeChar:
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// LikeEscaped matches values against pattern, which must already escape
+// literal '%', '_' and LikeEscapeChar with LikeEscapeChar (see EscapeLike).
+func LikeEscaped(field string, pattern string) Specification {
+	return &likeEscapedSpec{
+		field:   field,
+		pattern: pattern,
+	}
+}
+
+// StartsWith matches values beginning with prefix. LIKE metacharacters in
+// prefix are matched literally.
+func StartsWith(field string, prefix string) Specification {
+	return &likeEscapedSpec{
+		field:   field,
+		pattern: EscapeLike(prefix) + "%",
+	}
+}
+
+// EndsWith matches values ending with suffix. LIKE metacharacters in suffix
+// are matched literally.
+func EndsWith(field string, suffix string) Specification {
+	return &likeEscapedSpec{
+		field:   field,
+		pattern: "%" + EscapeLike(suffix),
+	}
+}
+
+// Contains matches values containing substr. LIKE metacharacters in substr
+// are matched literally.
+func Contains(field string, substr string) Specification {
+	return &likeEscapedSpec{
+		field:   field,
+		pattern: "%" + EscapeLike(substr) + "%",
+	}
+}
+
+// LeadingWildcard returns the field of the first Like, ILike or
+// LikeEscaped condition of spec, including within Related specifications,
+// whose pattern starts with a wildcard, as Contains and EndsWith build.
+// B-tree indexes cannot serve such conditions, which scan every row, e.g.
+// to disable them on hot endpoints.
+func LeadingWildcard(spec Specification) (field string, ok bool) {
+	if spec == nil {
+		return "", false
+	}
+	r := &rewriter{}
+	r.pattern = func(f, pattern string) {
+		if !ok && pattern != "" && (pattern[0] == '%' || pattern[0] == '_') {
+			field, ok = f, true
+		}
+	}
+	r.related = func(relation string, spec Specification) (Specification, bool, error) {
+		if f, found := LeadingWildcard(spec); found && !ok {
+			field, ok = relation+"."+f, true
+		}
+		return nil, false, nil
+	}
+	spec.Accept(r)
+	return field, ok
+}
+
+// Matches matches values against the regular expression pattern.
+func Matches(field string, pattern string) Specification {
+	return &regexSpec{
+		field:   field,
+		pattern: pattern,
+	}
+}
+
+// IMatches is the case-insensitive variant of Matches.
+func IMatches(field string, pattern string) Specification {
+	return &regexSpec{
+		field:           field,
+		pattern:         pattern,
+		caseInsensitive: true,
+	}
+}
+
 func Offset(offset int) Specification {
 	return &offsetSpec{
 		offset: offset,
@@ -205,6 +470,14 @@ func In(field string, values ...interface{}) Specification {
 	}
 }
 
+// NotIn matches values outside the given set. An empty set matches everything.
+func NotIn(field string, values ...interface{}) Specification {
+	return &notInSpec{
+		field:  field,
+		values: values,
+	}
+}
+
 func And(specs ...Specification) Specification {
 	return &andSpec{specs: specs}
 }
@@ -213,6 +486,13 @@ func Or(specs ...Specification) Specification {
 	return &orSpec{specs: specs}
 }
 
+// Not negates the conditions of spec.
+func Not(spec Specification) Specification {
+	return &notSpec{spec: spec}
+}
+
+// Limit returns at most limit results. A limit of zero returns none, in
+// the SQL visitors and memory; later limits override earlier ones.
 func Limit(limit int) Specification {
 	return &limitSpec{limit: limit}
 }
@@ -223,3 +503,45 @@ func OrderBy(field string, direction string) Specification {
 		direction: direction,
 	}
 }
+
+// Between matches values in the inclusive range [low, high].
+func Between(field string, low, high interface{}) Specification {
+	return &betweenSpec{
+		field: field,
+		low:   low,
+		high:  high,
+	}
+}
+
+func IsNull(field string) Specification {
+	return &isNullSpec{field: field}
+}
+
+func IsNotNull(field string) Specification {
+	return &isNotNullSpec{field: field}
+}
+
+// Sample restricts the result to a random sample of roughly percent (0-100)
+// of the rows.
+func Sample(percent float64, method SampleMethod) Specification {
+	return &sampleSpec{
+		percent: percent,
+		method:  method,
+	}
+}
+
+// EmptyComposite defines what an And or Or specification without any child
+// specification means to a visitor.
+type EmptyComposite int
+
+const (
+	// EmptyCompositeSkip ignores empty composites: they add no condition at
+	// all. Beware that an empty Or then leaves the query unfiltered. This is
+	// the default.
+	EmptyCompositeSkip EmptyComposite = iota
+	// EmptyCompositeIdentity uses the logical identity: an empty And matches
+	// everything and an empty Or matches nothing.
+	EmptyCompositeIdentity
+	// EmptyCompositeError makes visitors report ErrEmptyComposite.
+	EmptyCompositeError
+)
@@ -0,0 +1,170 @@
+package spectest
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// ConformanceRow is a row of the conformance fixture. Nil pointers are
+// NULLs.
+type ConformanceRow struct {
+	ID    int64
+	Name  *string
+	Score *int64
+	Tag   *string
+}
+
+func str(s string) *string { return &s }
+func num(n int64) *int64   { return &n }
+
+// ConformanceRows returns the rows of the conformance fixture, ordered by
+// ID.
+func ConformanceRows() []ConformanceRow {
+	return []ConformanceRow{
+		{ID: 1, Name: str("alpha"), Score: num(10), Tag: str("x")},
+		{ID: 2, Name: str("Beta"), Score: num(20), Tag: str("y")},
+		{ID: 3, Score: num(30), Tag: str("x")},
+		{ID: 4, Name: str("gamma"), Tag: str("y")},
+		{ID: 5, Name: str("al_pha"), Score: num(10), Tag: str("z")},
+		{ID: 6, Name: str("100%"), Score: num(50), Tag: str("x")},
+		{ID: 7, Name: str("delta"), Score: num(20)},
+	}
+}
+
+// ConformanceFieldMap maps the fields of the conformance cases to the
+// fields of ConformanceRow, for the memory evaluator. SQL columns have the
+// names of the fields.
+var ConformanceFieldMap = map[string]string{
+	"id":    "ID",
+	"name":  "Name",
+	"score": "Score",
+	"tag":   "Tag",
+}
+
+// ConformanceFixture returns portable statements creating the table
+// conformance and inserting ConformanceRows.
+func ConformanceFixture() []string {
+	return []string{
+		"CREATE TABLE conformance (id INTEGER PRIMARY KEY, name VARCHAR(20), score INTEGER, tag VARCHAR(20))",
+		"INSERT INTO conformance (id, name, score, tag) VALUES " +
+			"(1, 'alpha', 10, 'x'), " +
+			"(2, 'Beta', 20, 'y'), " +
+			"(3, NULL, 30, 'x'), " +
+			"(4, 'gamma', NULL, 'y'), " +
+			"(5, 'al_pha', 10, 'z'), " +
+			"(6, '100%', 50, 'x'), " +
+			"(7, 'delta', 20, NULL)",
+	}
+}
+
+// ConformanceCase is a specification with the IDs of the fixture rows it
+// must return, in this order when Ordered.
+type ConformanceCase struct {
+	Name    string
+	Spec    specifications.Specification
+	Want    []int64
+	Ordered bool
+}
+
+// ConformanceCases returns the conformance corpus. It pins the semantics
+// where backends are most likely to diverge: comparisons never match
+// NULLs, LIKE metacharacters in StartsWith and Contains are literal, NULLs
+// are placed as requested, and pagination applies after ordering.
+func ConformanceCases() []ConformanceCase {
+	return []ConformanceCase{
+		{Name: "equal", Spec: specifications.Equal("name", "alpha"), Want: []int64{1}},
+		{Name: "not equal excludes NULL", Spec: specifications.NotEqual("name", "alpha"), Want: []int64{2, 4, 5, 6, 7}},
+		{Name: "greater than excludes NULL", Spec: specifications.GreaterThan("score", 15), Want: []int64{2, 3, 6, 7}},
+		{Name: "lower than or equal", Spec: specifications.LowerThanOrEqual("score", 10), Want: []int64{1, 5}},
+		{Name: "between is inclusive", Spec: specifications.Between("score", 10, 20), Want: []int64{1, 2, 5, 7}},
+		{Name: "not between a NULL bound", Spec: specifications.Not(specifications.Between("score", nil, 20)), Want: []int64{3, 6}},
+		{Name: "is null", Spec: specifications.IsNull("name"), Want: []int64{3}},
+		{Name: "is not null", Spec: specifications.IsNotNull("score"), Want: []int64{1, 2, 3, 5, 6, 7}},
+		{Name: "in", Spec: specifications.In("tag", "x", "z"), Want: []int64{1, 3, 5, 6}},
+		{Name: "empty in matches nothing", Spec: specifications.In("tag"), Want: nil},
+		{Name: "not in excludes NULL", Spec: specifications.NotIn("tag", "x"), Want: []int64{2, 4, 5}},
+		{Name: "not excludes NULL", Spec: specifications.Not(specifications.Equal("tag", "x")), Want: []int64{2, 4, 5}},
+		{Name: "and", Spec: specifications.And(specifications.Equal("tag", "x"), specifications.GreaterThan("score", 15)), Want: []int64{3, 6}},
+		{Name: "or", Spec: specifications.Or(specifications.Equal("name", "alpha"), specifications.IsNull("name")), Want: []int64{1, 3}},
+		{Name: "ilike ignores case", Spec: specifications.ILike("name", "beta"), Want: []int64{2}},
+		{Name: "starts with escapes underscore", Spec: specifications.StartsWith("name", "al_"), Want: []int64{5}},
+		{Name: "contains escapes percent", Spec: specifications.Contains("name", "%"), Want: []int64{6}},
+		{
+			Name:    "ascending with NULLs last",
+			Spec:    specifications.And(specifications.OrderByNulls("score", "ASC", specifications.NullsLast), specifications.Asc("id")),
+			Want:    []int64{1, 5, 2, 7, 3, 6, 4},
+			Ordered: true,
+		},
+		{
+			Name:    "descending with NULLs first",
+			Spec:    specifications.And(specifications.OrderByNulls("score", "DESC", specifications.NullsFirst), specifications.Asc("id")),
+			Want:    []int64{4, 6, 3, 2, 7, 1, 5},
+			Ordered: true,
+		},
+		{Name: "limit", Spec: specifications.And(specifications.Asc("id"), specifications.Limit(3)), Want: []int64{1, 2, 3}, Ordered: true},
+		{Name: "limit and offset", Spec: specifications.And(specifications.Asc("id"), specifications.Limit(2), specifications.Offset(3)), Want: []int64{4, 5}, Ordered: true},
+		{Name: "offset without limit", Spec: specifications.And(specifications.Asc("id"), specifications.Offset(5)), Want: []int64{6, 7}, Ordered: true},
+		{
+			Name:    "nested offset of zero",
+			Spec:    specifications.And(specifications.Asc("id"), specifications.Offset(5), specifications.And(specifications.Offset(0))),
+			Want:    []int64{1, 2, 3, 4, 5, 6, 7},
+			Ordered: true,
+		},
+		{
+			Name:    "pagination after filtering",
+			Spec:    specifications.And(specifications.IsNotNull("score"), specifications.Asc("id"), specifications.Limit(2), specifications.Offset(1)),
+			Want:    []int64{2, 3},
+			Ordered: true,
+		},
+	}
+}
+
+// RunSQLConformance runs every conformance case as a subtest against db,
+// which must hold ConformanceFixture. build renders a specification into
+// a query selecting the id column of conformance, e.g. with a dialect's
+// visitor and "SELECT id FROM conformance".
+func RunSQLConformance(t *testing.T, db *sql.DB, build func(spec specifications.Specification) (string, []interface{}, error)) {
+	t.Helper()
+	for _, c := range ConformanceCases() {
+		t.Run(c.Name, func(t *testing.T) {
+			query, args, err := build(c.Spec)
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			rows, err := db.Query(query, args...)
+			if err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			defer rows.Close()
+			var got []int64
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					t.Fatalf("%s: %v", query, err)
+				}
+				got = append(got, id)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			CheckConformance(t, c, got)
+		})
+	}
+}
+
+// CheckConformance reports an error unless got holds the IDs c wants, in
+// order when c is Ordered. It lets non-SQL backends, such as the memory
+// evaluator on ConformanceRows, run the corpus too.
+func CheckConformance(t testing.TB, c ConformanceCase, got []int64) {
+	t.Helper()
+	want := c.Want
+	if !c.Ordered {
+		got, want = slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(want))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("%s: got IDs %v, want %v", c.Name, got, want)
+	}
+}
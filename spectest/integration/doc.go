@@ -0,0 +1,12 @@
+// Package integration runs the spectest conformance corpus against real
+// databases through every SQL dialect. It is a module of its own, so that
+// the database drivers stay out of the specifications module, and its tests
+// only build with the integration tag:
+//
+//	docker compose up -d --wait
+//	go test -tags integration ./...
+//
+// The databases are those of docker-compose.yml, unless the
+// SPECTEST_POSTGRES_DSN, SPECTEST_MYSQL_DSN or SPECTEST_SQLSERVER_DSN
+// environment variables name others. SQLite runs in memory.
+package integration
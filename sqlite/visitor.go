@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+// Visitor translates specifications into SQLite conditions with '?'
+// placeholders and double-quoted identifiers. It is the generic SQL visitor
+// configured with Dialect.
+type Visitor = sqlspec.Visitor
+
+// Option configures a Visitor.
+type Option = sqlspec.Option
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.NewVisitor(Dialect, fieldMap, opts...)
+}
+
+// AcquireVisitor is like NewVisitor but reuses a visitor released with
+// ReleaseVisitor, as by sqlspec.AcquireVisitor.
+func AcquireVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.AcquireVisitor(Dialect, fieldMap, opts...)
+}
+
+// ReleaseVisitor is an alias for sqlspec.ReleaseVisitor.
+func ReleaseVisitor(v *Visitor) {
+	sqlspec.ReleaseVisitor(v)
+}
+
+// WithHardening is an alias for sqlspec.WithHardening.
+func WithHardening() Option {
+	return sqlspec.WithHardening()
+}
+
+// WithStrictFields is an alias for sqlspec.WithStrictFields.
+func WithStrictFields() Option {
+	return sqlspec.WithStrictFields()
+}
+
+// WithEmptyComposite is an alias for sqlspec.WithEmptyComposite.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return sqlspec.WithEmptyComposite(mode)
+}
+
+// WithDefaultLimit is an alias for sqlspec.WithDefaultLimit.
+func WithDefaultLimit(limit int) Option {
+	return sqlspec.WithDefaultLimit(limit)
+}
+
+// WithMaxLimit is an alias for sqlspec.WithMaxLimit.
+func WithMaxLimit(max int, policy sqlspec.MaxLimitPolicy) Option {
+	return sqlspec.WithMaxLimit(max, policy)
+}
+
+// WithQueryCache is an alias for sqlspec.WithQueryCache.
+func WithQueryCache(c *sqlspec.QueryCache) Option {
+	return sqlspec.WithQueryCache(c)
+}
+
+// WithColumnTypes is an alias for sqlspec.WithColumnTypes.
+func WithColumnTypes(types map[string]sqlspec.ColumnType) Option {
+	return sqlspec.WithColumnTypes(types)
+}
+
+// WithTieBreaker is an alias for sqlspec.WithTieBreaker.
+func WithTieBreaker(field string) Option {
+	return sqlspec.WithTieBreaker(field)
+}
+
+// WithArgTarget is an alias for sqlspec.WithArgTarget.
+func WithArgTarget(t sqlspec.ArgTarget) Option {
+	return sqlspec.WithArgTarget(t)
+}
+
+// Dialect is the SQLite SQL dialect.
+var Dialect sqlspec.Dialect = dialect{}
+
+type dialect struct{}
+
+func (dialect) Placeholder(int) string {
+	return "?"
+}
+
+// QuoteIdentifier quotes name with double quotes. Dotted names such as
+// "p.price" are quoted per part.
+func (dialect) QuoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+func (dialect) LimitOffset(limit, offset int, ordered bool) string {
+	switch {
+	case limit > 0 && offset > 0:
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	case limit > 0:
+		return fmt.Sprintf(" LIMIT %d", limit)
+	case offset > 0:
+		return fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	}
+	return ""
+}
+
+// ExplicitLimitOffset writes every set clause, including LIMIT 0 and
+// OFFSET 0, with LIMIT -1 for an offset without limit.
+func (dialect) ExplicitLimitOffset(limit, offset int, ordered bool) string {
+	switch {
+	case limit >= 0 && offset >= 0:
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	case limit >= 0:
+		return fmt.Sprintf(" LIMIT %d", limit)
+	case offset >= 0:
+		return fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	}
+	return ""
+}
+
+func (dialect) NullsOrder(nulls specifications.NullsPosition) string {
+	return " NULLS " + string(nulls)
+}
+
+func (dialect) BoolLiteral(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// Regex uses the REGEXP operator, which requires the application to register
+// a regexp() function with the driver. Case-insensitive matching is not
+// supported.
+func (dialect) Regex(column string, caseInsensitive bool) (string, bool) {
+	if caseInsensitive {
+		return "", false
+	}
+	return column + " REGEXP ?", true
+}
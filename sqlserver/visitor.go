@@ -0,0 +1,131 @@
+package sqlserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+// Visitor translates specifications into SQL Server conditions with @pN
+// placeholders and bracket-quoted identifiers. It is the generic SQL visitor
+// configured with Dialect.
+type Visitor = sqlspec.Visitor
+
+// Option configures a Visitor.
+type Option = sqlspec.Option
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.NewVisitor(Dialect, fieldMap, opts...)
+}
+
+// AcquireVisitor is like NewVisitor but reuses a visitor released with
+// ReleaseVisitor, as by sqlspec.AcquireVisitor.
+func AcquireVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	return sqlspec.AcquireVisitor(Dialect, fieldMap, opts...)
+}
+
+// ReleaseVisitor is an alias for sqlspec.ReleaseVisitor.
+func ReleaseVisitor(v *Visitor) {
+	sqlspec.ReleaseVisitor(v)
+}
+
+// WithHardening is an alias for sqlspec.WithHardening.
+func WithHardening() Option {
+	return sqlspec.WithHardening()
+}
+
+// WithStrictFields is an alias for sqlspec.WithStrictFields.
+func WithStrictFields() Option {
+	return sqlspec.WithStrictFields()
+}
+
+// WithEmptyComposite is an alias for sqlspec.WithEmptyComposite.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return sqlspec.WithEmptyComposite(mode)
+}
+
+// WithDefaultLimit is an alias for sqlspec.WithDefaultLimit.
+func WithDefaultLimit(limit int) Option {
+	return sqlspec.WithDefaultLimit(limit)
+}
+
+// WithMaxLimit is an alias for sqlspec.WithMaxLimit.
+func WithMaxLimit(max int, policy sqlspec.MaxLimitPolicy) Option {
+	return sqlspec.WithMaxLimit(max, policy)
+}
+
+// WithQueryCache is an alias for sqlspec.WithQueryCache.
+func WithQueryCache(c *sqlspec.QueryCache) Option {
+	return sqlspec.WithQueryCache(c)
+}
+
+// WithColumnTypes is an alias for sqlspec.WithColumnTypes.
+func WithColumnTypes(types map[string]sqlspec.ColumnType) Option {
+	return sqlspec.WithColumnTypes(types)
+}
+
+// WithTieBreaker is an alias for sqlspec.WithTieBreaker.
+func WithTieBreaker(field string) Option {
+	return sqlspec.WithTieBreaker(field)
+}
+
+// WithArgTarget is an alias for sqlspec.WithArgTarget.
+func WithArgTarget(t sqlspec.ArgTarget) Option {
+	return sqlspec.WithArgTarget(t)
+}
+
+// Dialect is the SQL Server (T-SQL) dialect.
+var Dialect sqlspec.Dialect = dialect{}
+
+type dialect struct{}
+
+func (dialect) Placeholder(index int) string {
+	return fmt.Sprintf("@p%d", index)
+}
+
+// QuoteIdentifier quotes name with brackets. Dotted names such as
+// "p.price" are quoted per part.
+func (dialect) QuoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "[" + strings.ReplaceAll(p, "]", "]]") + "]"
+	}
+	return strings.Join(parts, ".")
+}
+
+// LimitOffset uses OFFSET ... FETCH, which requires an ORDER BY clause; an
+// arbitrary one is added when the query is not ordered.
+func (dialect) LimitOffset(limit, offset int, ordered bool) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	clause := ""
+	if !ordered {
+		clause = " ORDER BY (SELECT NULL)"
+	}
+	clause += fmt.Sprintf(" OFFSET %d ROWS", offset)
+	if limit > 0 {
+		clause += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", limit)
+	}
+	return clause
+}
+
+// BoolLiteral returns a comparison: T-SQL has no boolean literals.
+func (dialect) BoolLiteral(b bool) string {
+	if b {
+		return "1=1"
+	}
+	return "1=0"
+}
+
+// StatisticalAggregate writes the sample standard deviation as STDEV.
+// PERCENTILE_CONT is only a window function in SQL Server, which cannot be
+// used in HAVING.
+func (dialect) StatisticalAggregate(function specifications.AggregateFunction, column string) (string, bool) {
+	if function == specifications.AggregateStddev {
+		return "STDEV(" + column + ")", true
+	}
+	return "", false
+}
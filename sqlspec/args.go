@@ -0,0 +1,165 @@
+package sqlspec
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// ArgTarget renders bound arguments for a driver idiom: Placeholder is the
+// text referencing an argument in the query and Args builds the container
+// passed to the driver. Names are unique within a query and derive from the
+// column the value is compared with, e.g. status_1.
+type ArgTarget interface {
+	Placeholder(index int, name string) string
+	Args(names []string, values []interface{}) interface{}
+}
+
+// WithArgTarget sets the target used by BuildArgs. The default is
+// Positional.
+func WithArgTarget(t ArgTarget) Option {
+	return func(c *config) {
+		c.target = t
+	}
+}
+
+// Positional returns the dialect's positional placeholders with the
+// arguments as a []interface{}, like BuildQuery.
+func Positional(d Dialect) ArgTarget {
+	return positional{d}
+}
+
+type positional struct {
+	d Dialect
+}
+
+func (p positional) Placeholder(index int, _ string) string {
+	return p.d.Placeholder(index)
+}
+
+func (positional) Args(_ []string, values []interface{}) interface{} {
+	return values
+}
+
+// NamedMap renders :name placeholders with the arguments as a
+// map[string]interface{}, like BuildNamedQuery.
+var NamedMap ArgTarget = namedMap{}
+
+type namedMap struct{}
+
+func (namedMap) Placeholder(_ int, name string) string {
+	return ":" + name
+}
+
+func (namedMap) Args(names []string, values []interface{}) interface{} {
+	m := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		m[name] = values[i]
+	}
+	return m
+}
+
+// SQLNamed renders @name placeholders with the arguments as a
+// []sql.NamedArg, for database/sql drivers supporting named parameters.
+var SQLNamed ArgTarget = sqlNamed{}
+
+type sqlNamed struct{}
+
+func (sqlNamed) Placeholder(_ int, name string) string {
+	return "@" + name
+}
+
+func (sqlNamed) Args(names []string, values []interface{}) interface{} {
+	args := make([]sql.NamedArg, len(names))
+	for i, name := range names {
+		args[i] = sql.Named(name, values[i])
+	}
+	return args
+}
+
+// RenderTo is like Render but references and collects arguments through t.
+func (q *Query) RenderTo(d Dialect, baseQuery string, t ArgTarget) (string, interface{}) {
+	var names []string
+	next := namer()
+	query, values := q.render(d, baseQuery, func(index int, column string, _ interface{}) string {
+		name := next(column)
+		names = append(names, name)
+		return t.Placeholder(index, name)
+	})
+	return query, t.Args(names, values)
+}
+
+// RenderInline is like Render but writes the arguments into the query as
+// the SQL literals returned by literal, for statements that cannot bind
+// parameters such as view definitions. The base query must not bind
+// parameters either.
+func (q *Query) RenderInline(d Dialect, baseQuery string, literal func(value interface{}) (string, error)) (string, error) {
+	if base := scanBase(baseQuery, numberedPrefix(d)); base.params > 0 {
+		return "", fmt.Errorf("%w: inlined query over a base query with %d parameters", specifications.ErrInvalidValue, base.params)
+	}
+	var err error
+	query, _ := q.render(d, baseQuery, func(_ int, _ string, value interface{}) string {
+		text, lerr := literal(value)
+		if lerr != nil && err == nil {
+			err = lerr
+		}
+		return text
+	})
+	if err != nil {
+		return "", err
+	}
+	return query, nil
+}
+
+// BuildArgs is like Build but returns the arguments in the container of the
+// target set by WithArgTarget.
+func (v *Visitor) BuildArgs(baseQuery string) (string, interface{}, error) {
+	if v.err != nil {
+		return "", nil, v.err
+	}
+	t := v.cfg.target
+	if t == nil {
+		t = Positional(v.dialect)
+	}
+	q := v.query()
+	query, args := q.RenderTo(v.dialect, baseQuery, t)
+	return query, args, nil
+}
+
+// namer returns a generator of unique parameter names for columns.
+func namer() func(column string) string {
+	seen := map[string]int{}
+	return func(column string) string {
+		base := paramName(column)
+		seen[base]++
+		return fmt.Sprintf("%s_%d", base, seen[base])
+	}
+}
+
+// paramName turns a column reference into a valid parameter name: runs of
+// other characters than ASCII letters and digits become one '_', and names
+// start with a letter.
+func paramName(column string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range strings.ToLower(column) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pending && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			pending = false
+			if b.Len() == 0 && r >= '0' && r <= '9' {
+				b.WriteString("arg_")
+			}
+			b.WriteRune(r)
+		} else {
+			pending = true
+		}
+	}
+	if b.Len() == 0 {
+		return "arg"
+	}
+	return b.String()
+}
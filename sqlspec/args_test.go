@@ -0,0 +1,85 @@
+package sqlspec_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/mysql"
+	"github.com/thefabric-io/specifications/sqlite"
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+// TestBuildQueryWithArgs checks that with '?' placeholders the arguments
+// of the base query and of the visited specifications are returned in the
+// order of their placeholders.
+func TestBuildQueryWithArgs(t *testing.T) {
+	orders := sqlspec.WithRelation("orders", sqlspec.Relation{Table: "orders", On: "orders.customer_id = t.id", Key: "orders.id"})
+	for _, c := range []struct {
+		name     string
+		visitor  *sqlspec.Visitor
+		spec     specifications.Specification
+		base     string
+		existing []interface{}
+		want     string
+		args     []interface{}
+	}{
+		{
+			name:     "trailing clauses",
+			visitor:  mysql.NewVisitor(nil),
+			spec:     specifications.Equal("a", 1),
+			base:     "SELECT * FROM t WHERE tenant = ? ORDER BY x LIMIT ?",
+			existing: []interface{}{7, 5},
+			want:     "SELECT * FROM t WHERE (tenant = ?) AND `a` = ? ORDER BY x LIMIT ?",
+			args:     []interface{}{7, 1, 5},
+		},
+		{
+			name:     "clauses of the specification",
+			visitor:  sqlite.NewVisitor(nil),
+			spec:     specifications.And(specifications.Equal("a", 1), specifications.Limit(10)),
+			base:     "SELECT * FROM t WHERE tenant = ? GROUP BY t.id HAVING COUNT(*) > ?",
+			existing: []interface{}{7, 2},
+			want:     `SELECT * FROM t WHERE (tenant = ?) AND "a" = ? GROUP BY t.id HAVING COUNT(*) > ? LIMIT 10`,
+			args:     []interface{}{7, 1, 2},
+		},
+		{
+			name:     "anti-join",
+			visitor:  mysql.NewVisitor(nil, orders, sqlspec.WithAntiJoins(sqlspec.AntiJoinLeftJoin)),
+			spec:     specifications.And(specifications.Not(specifications.Related("orders", specifications.Equal("status", "open"))), specifications.Equal("a", 1)),
+			base:     "SELECT * FROM t JOIN regions r ON r.id = t.region_id AND r.code = ? WHERE tenant = ? LIMIT ?",
+			existing: []interface{}{"eu", 7, 5},
+			want:     "SELECT * FROM t JOIN regions r ON r.id = t.region_id AND r.code = ? LEFT JOIN orders ON orders.customer_id = t.id AND `status` = ? WHERE (tenant = ?) AND orders.id IS NULL AND `a` = ? LIMIT ?",
+			args:     []interface{}{"eu", "open", 7, 1, 5},
+		},
+		{
+			name:     "no WHERE clause",
+			visitor:  sqlite.NewVisitor(nil),
+			spec:     specifications.Equal("a", 1),
+			base:     "SELECT * FROM t ORDER BY x LIMIT ?",
+			existing: []interface{}{5},
+			want:     `SELECT * FROM t WHERE "a" = ? ORDER BY x LIMIT ?`,
+			args:     []interface{}{1, 5},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.spec.Accept(c.visitor)
+			query, args := c.visitor.BuildQueryWithArgs(c.base, c.existing)
+			if err := c.visitor.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if query != c.want || !reflect.DeepEqual(args, c.args) {
+				t.Errorf("got %q %v, want %q %v", query, args, c.want, c.args)
+			}
+		})
+	}
+}
+
+func TestBuildQueryWithArgsMismatch(t *testing.T) {
+	v := mysql.NewVisitor(nil)
+	specifications.Equal("a", 1).Accept(v)
+	query, args := v.BuildQueryWithArgs("SELECT * FROM t WHERE tenant = ? LIMIT ?", []interface{}{7})
+	if !errors.Is(v.Err(), specifications.ErrInvalidValue) || query != "" || args != nil {
+		t.Errorf("got %q %v and error %v, want ErrInvalidValue", query, args, v.Err())
+	}
+}
@@ -0,0 +1,427 @@
+package sqlspec
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Query is the structured result of visiting specifications: the WHERE
+// expressions, grouping, ordering, pagination and sampling, before any SQL
+// text is assembled. It can be inspected or rewritten and is rendered by Render.
+type Query struct {
+	// Where holds the top-level conditions, combined with AND.
+	Where []Expr
+	// GroupBy holds the grouping columns and Having the conditions on the
+	// groups, combined with AND.
+	GroupBy  []string
+	Having   []Expr
+	OrderBy  []OrderTerm
+	Limit    int
+	Offset   int
+	Sample   *Sample
+	Distinct *Distinct
+	Lock     *Lock
+	// AntiJoins are appended to the FROM clause of the base query.
+	AntiJoins []AntiJoin
+	// LimitSet and OffsetSet write Limit and Offset even when zero, as set
+	// by Limit and Offset specifications: LIMIT 0 returns no rows.
+	LimitSet, OffsetSet bool
+}
+
+// Expr is a node of a WHERE clause: a Predicate, a Group, a Not or an
+// Exists.
+type Expr interface {
+	expr()
+}
+
+// Predicate is a single condition. SQL holds one '?' marker per element of
+// Args, replaced by the dialect's placeholders when rendering. Column is
+// the column the arguments are compared with; it names them in named
+// output.
+type Predicate struct {
+	SQL    string
+	Column string
+	Args   []interface{}
+}
+
+// Group combines expressions with Op, "AND" or "OR", and renders
+// parenthesized.
+type Group struct {
+	Op    string
+	Exprs []Expr
+}
+
+// Not negates Expr.
+type Not struct {
+	Expr Expr
+}
+
+// Exists holds when a row of Table satisfies On and Where, combined with
+// AND. Relation is the name of the relation passed to
+// specifications.Related.
+type Exists struct {
+	Relation string
+	Table    string
+	On       string
+	Where    []Expr
+}
+
+func (Predicate) expr() {}
+func (Group) expr()     {}
+func (Not) expr()       {}
+func (Exists) expr()    {}
+
+// OrderTerm is one ORDER BY entry. Nulls is rendered through the dialect's
+// NullsOrderer, or by first ordering on whether Column is NULL.
+type OrderTerm struct {
+	Column    string
+	Direction string
+	Nulls     specifications.NullsPosition
+	// Args are bound to the '?' markers of a computed Column, such as a
+	// text search rank.
+	Args []interface{}
+}
+
+// AntiJoin is a LEFT JOIN on the rows of Table satisfying On and Where,
+// combined with AND. The rows without such a row are those whose Key is
+// NULL, tested in the WHERE clause.
+type AntiJoin struct {
+	Table string
+	On    string
+	Where []Expr
+	Key   string
+}
+
+// Distinct removes duplicate rows, or with On keeps the first row of each
+// combination of values of these columns. It is inserted after the first
+// top-level SELECT of the base query.
+type Distinct struct {
+	On []string
+}
+
+// Lock is a row-locking clause, rendered through the dialect's Locker
+// after pagination.
+type Lock struct {
+	Strength specifications.LockStrength
+	Wait     specifications.LockWait
+}
+
+// Sample is a sampling clause, rendered through the dialect's Sampler.
+type Sample struct {
+	Percent float64
+	Method  specifications.SampleMethod
+}
+
+// Render assembles the query after baseQuery with d's positional
+// placeholders and returns it with its arguments in placeholder order.
+//
+// When baseQuery already has a top-level WHERE clause, its condition is
+// parenthesized and the visited conditions are appended with AND. When it
+// ends with clauses following WHERE, such as ORDER BY or LIMIT, the visited
+// conditions are inserted before them, and the clauses of q are written
+// after them: they must come later in SQL, e.g. a limit after ORDER BY,
+// which the visitors check. Numbered
+// placeholders continue after the highest one in baseQuery, e.g. from $3
+// after "WHERE tenant_id = $1 AND region = $2"; the caller passes the base
+// query's arguments first.
+func (q *Query) Render(d Dialect, baseQuery string) (string, []interface{}) {
+	return q.render(d, baseQuery, nil)
+}
+
+// RenderNamed is like Render but emits named parameters such as :status_1,
+// whatever the dialect, and returns the arguments keyed by name. Names
+// derive from the column the value is compared with.
+func (q *Query) RenderNamed(d Dialect, baseQuery string) (string, map[string]interface{}) {
+	query, args := q.RenderTo(d, baseQuery, NamedMap)
+	return query, args.(map[string]interface{})
+}
+
+// Dedup removes predicates and groups repeated within the same group, or
+// within Where, keeping the first occurrence. Both x AND x and x OR x are
+// equivalent to x, so the result matches the same rows.
+func (q *Query) Dedup() {
+	q.Where = dedup(q.Where)
+	q.Having = dedup(q.Having)
+}
+
+func dedup(exprs []Expr) []Expr {
+	out := make([]Expr, 0, len(exprs))
+	for _, e := range exprs {
+		switch x := e.(type) {
+		case Group:
+			e = Group{Op: x.Op, Exprs: dedup(x.Exprs)}
+		case Not:
+			e = Not{Expr: dedup([]Expr{x.Expr})[0]}
+		case Exists:
+			e = Exists{Relation: x.Relation, Table: x.Table, On: x.On, Where: dedup(x.Where)}
+		}
+		duplicate := false
+		for _, kept := range out {
+			if reflect.DeepEqual(kept, e) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// renderer writes expressions, replacing '?' markers through placeholder,
+// or the dialect's placeholders when nil, and collecting the bound
+// arguments. index is the 1-based index of the last placeholder written,
+// including those of the base query.
+type renderer struct {
+	buf         *bytes.Buffer
+	dialect     Dialect
+	placeholder func(index int, column string, value interface{}) string
+	index       int
+	args        []interface{}
+	// fromArgs and whereArgs are the numbers of arguments bound before
+	// the condition of the base query and before its trailing clauses.
+	fromArgs, whereArgs int
+}
+
+func (q *Query) render(d Dialect, baseQuery string, placeholder func(index int, column string, value interface{}) string) (string, []interface{}) {
+	query, args, _ := q.renderAfter(d, baseQuery, nil, placeholder)
+	return query, args
+}
+
+// renderAfter is like render for a base query binding existing, and
+// returns existing with the arguments of q. Numbered placeholders are
+// numbered after existing, which come first. '?' placeholders bind in the
+// order they appear, so existing is split around the arguments of q: those
+// of the sampling clause and anti-joins precede the base query's WHERE
+// condition, and the visited conditions its trailing clauses.
+func (q *Query) renderAfter(d Dialect, baseQuery string, existing []interface{}, placeholder func(index int, column string, value interface{}) string) (string, []interface{}, error) {
+	prefix := numberedPrefix(d)
+	base := scanBase(baseQuery, prefix)
+	if prefix == "" && len(existing) > 0 && len(existing) != len(base.marks) {
+		return "", nil, fmt.Errorf("%w: %d arguments for the %d placeholders of the base query", specifications.ErrInvalidValue, len(existing), len(base.marks))
+	}
+	conditionEnd := len(baseQuery)
+	if base.tail >= 0 {
+		conditionEnd = base.tail
+	}
+	fromEnd := conditionEnd
+	if base.where >= 0 {
+		fromEnd = base.where
+	}
+	r := &renderer{buf: getBuffer(), dialect: d, placeholder: placeholder, index: max(base.params, len(existing)), args: make([]interface{}, 0, q.argCount())}
+	defer putBuffer(r.buf)
+
+	// The sampling clause follows the table reference, hence precedes an
+	// existing WHERE clause, and the visited conditions precede the clauses
+	// following it, such as ORDER BY.
+	baseCondition, tail := "", ""
+	if base.tail >= 0 {
+		tail = baseQuery[base.tail:]
+		baseQuery = strings.TrimRight(baseQuery[:base.tail], " \t\r\n")
+	}
+	if base.where >= 0 {
+		baseCondition = strings.TrimSpace(baseQuery[base.where+len("WHERE"):])
+		baseQuery = strings.TrimRight(baseQuery[:base.where], " \t")
+	}
+	if q.Distinct != nil && base.selectEnd >= 0 {
+		r.buf.WriteString(baseQuery[:base.selectEnd])
+		if len(q.Distinct.On) == 0 {
+			r.buf.WriteString(" DISTINCT")
+		} else {
+			r.buf.WriteString(" ")
+			r.buf.WriteString(d.(DistinctOner).DistinctOn(q.Distinct.On))
+		}
+		baseQuery = baseQuery[base.selectEnd:]
+	}
+	r.buf.WriteString(baseQuery)
+	if q.Sample != nil {
+		clause, _ := d.(Sampler).TableSample(q.Sample.Method, r.bind("sample_percent", q.Sample.Percent))
+		r.buf.WriteString(" ")
+		r.buf.WriteString(clause)
+	}
+	for _, j := range q.AntiJoins {
+		r.buf.WriteString(" LEFT JOIN ")
+		r.buf.WriteString(j.Table)
+		r.buf.WriteString(" ON ")
+		r.buf.WriteString(j.On)
+		r.and(j.Where)
+	}
+	r.fromArgs = len(r.args)
+
+	where := q.Where
+	explicit, ok := d.(ExplicitPaginator)
+	if q.LimitSet && q.Limit == 0 && !ok {
+		where = append(slices.Clip(where), Predicate{SQL: d.BoolLiteral(false)})
+	}
+	r.where(baseCondition, where)
+	r.whereArgs = len(r.args)
+	if tail != "" {
+		r.buf.WriteString(" ")
+		r.buf.WriteString(tail)
+	}
+
+	if len(q.GroupBy) > 0 {
+		r.buf.WriteString(" GROUP BY ")
+		r.buf.WriteString(strings.Join(q.GroupBy, ", "))
+	}
+	if len(q.Having) > 0 {
+		r.buf.WriteString(" HAVING ")
+		r.join(q.Having, " AND ")
+	}
+
+	ordered := len(q.OrderBy) > 0 || base.last == orderClause
+	for i, o := range q.OrderBy {
+		if i == 0 {
+			r.buf.WriteString(" ORDER BY ")
+		} else {
+			r.buf.WriteString(", ")
+		}
+		r.orderTerm(d, o)
+	}
+
+	if ok {
+		limit, offset := -1, -1
+		if q.Limit > 0 || q.LimitSet {
+			limit = q.Limit
+		}
+		if q.Offset > 0 || q.OffsetSet {
+			offset = q.Offset
+		}
+		r.buf.WriteString(explicit.ExplicitLimitOffset(limit, offset, ordered))
+	} else {
+		r.buf.WriteString(d.LimitOffset(q.Limit, q.Offset, ordered))
+	}
+
+	if q.Lock != nil {
+		clause, _ := d.(Locker).Lock(q.Lock.Strength, q.Lock.Wait)
+		r.buf.WriteString(clause)
+	}
+
+	if len(existing) == 0 {
+		return r.buf.String(), r.args, nil
+	}
+	if prefix != "" {
+		return r.buf.String(), append(slices.Clip(existing), r.args...), nil
+	}
+	from, condition := base.marksBefore(fromEnd), base.marksBefore(conditionEnd)
+	args := make([]interface{}, 0, len(existing)+len(r.args))
+	args = append(args, existing[:from]...)
+	args = append(args, r.args[:r.fromArgs]...)
+	args = append(args, existing[from:condition]...)
+	args = append(args, r.args[r.fromArgs:r.whereArgs]...)
+	args = append(args, existing[condition:]...)
+	args = append(args, r.args[r.whereArgs:]...)
+	return r.buf.String(), args, nil
+}
+
+func (r *renderer) orderTerm(d Dialect, o OrderTerm) {
+	n, native := d.(NullsOrderer)
+	if o.Nulls != specifications.NullsDefault && !native {
+		r.buf.WriteString("CASE WHEN ")
+		r.buf.WriteString(o.Column)
+		if o.Nulls == specifications.NullsFirst {
+			r.buf.WriteString(" IS NULL THEN 0 ELSE 1 END, ")
+		} else {
+			r.buf.WriteString(" IS NULL THEN 1 ELSE 0 END, ")
+		}
+	}
+	r.substitute(o.Column, o.Column, o.Args)
+	r.buf.WriteString(" ")
+	r.buf.WriteString(o.Direction)
+	if o.Nulls != specifications.NullsDefault && native {
+		r.buf.WriteString(n.NullsOrder(o.Nulls))
+	}
+}
+
+// where writes " WHERE " followed by the base query's condition, if any,
+// and exprs combined with AND, or nothing when both are empty.
+func (r *renderer) where(baseCondition string, exprs []Expr) {
+	switch {
+	case baseCondition == "" && len(exprs) == 0:
+		return
+	case baseCondition == "":
+		r.buf.WriteString(" WHERE ")
+	case len(exprs) == 0:
+		r.buf.WriteString(" WHERE ")
+		r.buf.WriteString(baseCondition)
+		return
+	default:
+		r.buf.WriteString(" WHERE (")
+		r.buf.WriteString(baseCondition)
+		r.buf.WriteString(") AND ")
+	}
+	r.join(exprs, " AND ")
+}
+
+// bind returns the placeholder for the next argument and records it.
+func (r *renderer) bind(column string, value interface{}) string {
+	r.index++
+	r.args = append(r.args, value)
+	if r.placeholder == nil {
+		return r.dialect.Placeholder(r.index)
+	}
+	return r.placeholder(r.index, column, value)
+}
+
+func (r *renderer) join(exprs []Expr, sep string) {
+	for i, e := range exprs {
+		if i > 0 {
+			r.buf.WriteString(sep)
+		}
+		r.expr(e)
+	}
+}
+
+// and writes " AND " before each of exprs.
+func (r *renderer) and(exprs []Expr) {
+	for _, e := range exprs {
+		r.buf.WriteString(" AND ")
+		r.expr(e)
+	}
+}
+
+func (r *renderer) expr(e Expr) {
+	switch e := e.(type) {
+	case Predicate:
+		r.predicate(e)
+	case Group:
+		r.buf.WriteString("(")
+		r.join(e.Exprs, " "+e.Op+" ")
+		r.buf.WriteString(")")
+	case Not:
+		r.buf.WriteString("NOT ")
+		r.expr(e.Expr)
+	case Exists:
+		r.buf.WriteString("EXISTS (SELECT 1 FROM ")
+		r.buf.WriteString(e.Table)
+		r.buf.WriteString(" WHERE ")
+		r.buf.WriteString(e.On)
+		r.and(e.Where)
+		r.buf.WriteString(")")
+	}
+}
+
+func (r *renderer) predicate(p Predicate) {
+	r.substitute(p.SQL, p.Column, p.Args)
+}
+
+// substitute writes sql with its '?' markers bound to args, compared with
+// column.
+func (r *renderer) substitute(sql, column string, args []interface{}) {
+	for _, arg := range args {
+		j := strings.IndexByte(sql, '?')
+		if j < 0 {
+			break
+		}
+		r.buf.WriteString(sql[:j])
+		r.buf.WriteString(r.bind(column, arg))
+		sql = sql[j+1:]
+	}
+	r.buf.WriteString(sql)
+}
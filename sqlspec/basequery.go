@@ -0,0 +1,189 @@
+package sqlspec
+
+import (
+	"strconv"
+	"strings"
+)
+
+// baseQuery describes what rendering must know about the SQL the visited
+// conditions are appended to.
+type baseQuery struct {
+	// where is the offset of a top-level WHERE keyword, or -1.
+	where int
+	// selectEnd is the offset after the first top-level SELECT keyword, or
+	// -1.
+	selectEnd int
+	// params is the highest numbered placeholder, e.g. 2 for "$2".
+	params int
+	// tail is the offset of the first top-level clause following the
+	// WHERE clause, such as ORDER BY or LIMIT, or -1.
+	tail int
+	// last is the clause of the tail written last.
+	last clause
+	// marks are the offsets of the '?' placeholders, counted when
+	// placeholders are not numbered.
+	marks []int
+}
+
+// marksBefore returns the number of '?' placeholders before offset.
+func (b baseQuery) marksBefore(offset int) int {
+	n := 0
+	for n < len(b.marks) && b.marks[n] < offset {
+		n++
+	}
+	return n
+}
+
+// clause is a clause rendered after WHERE, in the order SQL requires.
+type clause int
+
+const (
+	noClause clause = iota
+	groupClause
+	havingClause
+	windowClause
+	orderClause
+	pageClause
+	lockClause
+)
+
+// clauses are the keywords starting the clauses following WHERE.
+var clauses = map[string]clause{
+	"GROUP":  groupClause,
+	"HAVING": havingClause,
+	"WINDOW": windowClause,
+	"ORDER":  orderClause,
+	"LIMIT":  pageClause,
+	"OFFSET": pageClause,
+	"FETCH":  pageClause,
+	"FOR":    lockClause,
+}
+
+func (c clause) String() string {
+	return [...]string{"", "GROUP BY", "HAVING", "WINDOW", "ORDER BY", "LIMIT", "FOR"}[c]
+}
+
+// first returns the first clause q renders after WHERE, or noClause.
+func (q *Query) first() clause {
+	switch {
+	case len(q.GroupBy) > 0:
+		return groupClause
+	case len(q.Having) > 0:
+		return havingClause
+	case len(q.OrderBy) > 0:
+		return orderClause
+	case q.Limit > 0 || q.LimitSet || q.Offset > 0 || q.OffsetSet:
+		return pageClause
+	case q.Lock != nil:
+		return lockClause
+	}
+	return noClause
+}
+
+// scanBase finds the top-level SELECT and WHERE keywords in query, the
+// clauses following them, and the highest placeholder numbered after
+// prefix. Quoted strings and
+// identifiers, comments and parenthesized subqueries are skipped when
+// looking for keywords; placeholders are counted everywhere outside quotes
+// and comments.
+// An empty prefix counts '?' placeholders instead.
+func scanBase(query, prefix string) baseQuery {
+	b := baseQuery{where: -1, selectEnd: -1, tail: -1}
+	depth, from := 0, false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(query, i+1, c)
+		case c == '[':
+			i = skipQuoted(query, i+1, ']')
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(query)
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(query)
+			}
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case prefix == "" && c == '?':
+			b.marks = append(b.marks, i)
+			i++
+		case prefix != "" && strings.HasPrefix(query[i:], prefix):
+			j := i + len(prefix)
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if n, err := strconv.Atoi(query[i+len(prefix) : j]); err == nil && n > b.params {
+				b.params = n
+			}
+			i = max(j, i+1)
+		case isWordByte(c):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			word := ""
+			if depth == 0 && j-i <= len("HAVING") {
+				word = strings.ToUpper(query[i:j])
+			}
+			switch c := clauses[word]; {
+			case word == "":
+			case b.where < 0 && b.tail < 0 && word == "WHERE":
+				b.where = i
+			case b.selectEnd < 0 && word == "SELECT":
+				b.selectEnd = j
+			case word == "FROM":
+				from = true
+			case from && c != noClause:
+				if b.tail < 0 {
+					b.tail = i
+				}
+				b.last = max(b.last, c)
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return b
+}
+
+// skipQuoted returns the offset after the quote closing a quoted section
+// starting at i. A doubled quote is an escaped one.
+func skipQuoted(query string, i int, quote byte) int {
+	for i < len(query) {
+		if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return i
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// numberedPrefix returns the text preceding the index in d's placeholders,
+// e.g. "$" or "@p", or "" when its placeholders are not numbered.
+func numberedPrefix(d Dialect) string {
+	p := d.Placeholder(1)
+	if p == d.Placeholder(2) || !strings.HasSuffix(p, "1") {
+		return ""
+	}
+	return strings.TrimSuffix(p, "1")
+}
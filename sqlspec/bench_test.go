@@ -0,0 +1,88 @@
+package sqlspec_test
+
+import (
+	"testing"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/postgres"
+)
+
+// BenchmarkBuildQuery visits and renders the common shapes of
+// specifications: a flat And of Equals, as most filters are, and Or
+// specifications nesting And and Or.
+func BenchmarkBuildQuery(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		spec specifications.Specification
+	}{
+		{"FlatAnd", specifications.And(
+			specifications.Equal("tenant_id", 42),
+			specifications.Equal("status", "active"),
+			specifications.Equal("region", "eu-west-1"),
+			specifications.Equal("archived", false),
+			specifications.Equal("owner_id", 7),
+		)},
+		{"NestedOr", specifications.Or(
+			specifications.And(
+				specifications.Equal("status", "active"),
+				specifications.Or(specifications.Equal("plan", "pro"), specifications.Equal("plan", "team")),
+			),
+			specifications.And(
+				specifications.In("region", "eu-west-1", "eu-central-1"),
+				specifications.Or(specifications.GreaterThan("seats", 10), specifications.IsNull("trial_ends_at")),
+			),
+			specifications.Equal("owner_id", 7),
+		)},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v := postgres.NewVisitor(nil)
+				c.spec.Accept(v)
+				v.BuildQuery("SELECT * FROM accounts")
+			}
+		})
+	}
+}
+
+// BenchmarkVisitOr visits and renders an Or of many branches, with
+// ordering and a limit, which rendering collects the arguments of.
+func BenchmarkVisitOr(b *testing.B) {
+	branches := make([]specifications.Specification, 16)
+	for i := range branches {
+		branches[i] = specifications.And(
+			specifications.Equal("region", i),
+			specifications.GreaterThanOrEqual("seats", i*10),
+		)
+	}
+	spec := specifications.And(
+		specifications.Equal("tenant_id", 42),
+		specifications.Or(branches...),
+		specifications.OrderBy("created_at", "DESC"),
+		specifications.Limit(50),
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v := postgres.NewVisitor(nil)
+		spec.Accept(v)
+		v.BuildQuery("SELECT * FROM accounts")
+	}
+}
+
+// BenchmarkBuildQueryWithArgs renders after a base query binding its own
+// arguments, whose placeholders the visited ones are numbered after.
+func BenchmarkBuildQueryWithArgs(b *testing.B) {
+	spec := specifications.And(
+		specifications.Equal("status", "active"),
+		specifications.In("plan", "pro", "team", "enterprise"),
+		specifications.Or(specifications.GreaterThan("seats", 10), specifications.IsNull("trial_ends_at")),
+	)
+	base := "SELECT * FROM accounts WHERE tenant_id = $1 AND region = $2"
+	existing := []interface{}{42, "eu-west-1"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v := postgres.NewVisitor(nil)
+		spec.Accept(v)
+		v.BuildQueryWithArgs(base, existing)
+	}
+}
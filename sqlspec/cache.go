@@ -0,0 +1,232 @@
+package sqlspec
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QueryCache memoizes the SQL that BuildQuery, and so Build and
+// BuildSelect, render per query shape: the base query and the visited
+// conditions, ordering and pagination, without the values they bind. The
+// arguments are still collected on every call, but queries of a known
+// shape are not assembled again, e.g. in hot request paths running the
+// same filters with different values:
+//
+//	var cache = postgres.NewQueryCache(1024)
+//
+//	v := postgres.NewVisitor(fieldMap, postgres.WithQueryCache(cache))
+//
+// It is safe for concurrent use. Visitors sharing a cache must share their
+// dialect.
+type QueryCache struct {
+	size    int
+	mu      sync.RWMutex
+	queries map[string]string
+}
+
+// NewQueryCache returns a cache of up to size queries. Once full, queries
+// of other shapes are rendered without being cached, so that shapes built
+// from unbounded input cannot grow it without limit.
+func NewQueryCache(size int) *QueryCache {
+	return &QueryCache{size: size, queries: map[string]string{}}
+}
+
+// WithQueryCache makes the visitor render queries through c.
+func WithQueryCache(c *QueryCache) Option {
+	return func(cfg *config) {
+		cfg.cache = c
+	}
+}
+
+// Len returns the number of cached queries.
+func (c *QueryCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.queries)
+}
+
+// render is Query.Render through the cache.
+func (c *QueryCache) render(d Dialect, q *Query, baseQuery string) (string, []interface{}) {
+	key := getBuffer()
+	defer putBuffer(key)
+	q.shape(key, baseQuery)
+
+	c.mu.RLock()
+	query, ok := c.queries[string(key.Bytes())]
+	c.mu.RUnlock()
+	if ok {
+		return query, q.args()
+	}
+
+	query, args := q.Render(d, baseQuery)
+	c.mu.Lock()
+	if len(c.queries) < c.size {
+		c.queries[key.String()] = query
+	}
+	c.mu.Unlock()
+	return query, args
+}
+
+// shape writes to buf what the SQL rendered for q after baseQuery depends
+// on: everything but the values of the arguments.
+func (q *Query) shape(buf *bytes.Buffer, baseQuery string) {
+	w := shapeWriter{buf}
+	w.string(baseQuery)
+	if q.Distinct != nil {
+		w.string("distinct")
+		w.strings(q.Distinct.On)
+	}
+	if q.Sample != nil {
+		w.string("sample")
+		w.string(string(q.Sample.Method))
+	}
+	for _, j := range q.AntiJoins {
+		w.string("anti")
+		w.string(j.Table)
+		w.string(j.On)
+		w.exprs(j.Where)
+	}
+	w.string("where")
+	w.exprs(q.Where)
+	w.string("group")
+	w.strings(q.GroupBy)
+	w.string("having")
+	w.exprs(q.Having)
+	w.string("order")
+	for _, o := range q.OrderBy {
+		w.string(o.Column)
+		w.string(o.Direction)
+		w.string(string(o.Nulls))
+		w.int(len(o.Args))
+	}
+	w.int(q.Limit)
+	w.int(q.Offset)
+	w.string(strconv.FormatBool(q.LimitSet) + strconv.FormatBool(q.OffsetSet))
+	if q.Lock != nil {
+		w.string(string(q.Lock.Strength))
+		w.string(string(q.Lock.Wait))
+	}
+}
+
+// shapeWriter writes the parts of a query shape, each terminated by a NUL
+// byte, which SQL text does not hold.
+type shapeWriter struct {
+	buf *bytes.Buffer
+}
+
+func (w shapeWriter) string(s string) {
+	w.buf.WriteString(s)
+	w.buf.WriteByte(0)
+}
+
+func (w shapeWriter) strings(ss []string) {
+	w.int(len(ss))
+	for _, s := range ss {
+		w.string(s)
+	}
+}
+
+func (w shapeWriter) int(i int) {
+	w.string(strconv.Itoa(i))
+}
+
+func (w shapeWriter) exprs(exprs []Expr) {
+	w.int(len(exprs))
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case Predicate:
+			w.string("p")
+			w.string(e.SQL)
+			w.int(len(e.Args))
+		case Group:
+			w.string(e.Op)
+			w.exprs(e.Exprs)
+		case Not:
+			w.string("not")
+			w.exprs([]Expr{e.Expr})
+		case Exists:
+			w.string("exists")
+			w.string(e.Table)
+			w.string(e.On)
+			w.exprs(e.Where)
+		}
+	}
+}
+
+// args returns the arguments Render binds for q, in placeholder order.
+func (q *Query) args() []interface{} {
+	args := make([]interface{}, 0, q.argCount())
+	if q.Sample != nil {
+		args = append(args, q.Sample.Percent)
+	}
+	for _, j := range q.AntiJoins {
+		args = appendArgs(args, j.Where)
+	}
+	args = appendArgs(args, q.Where)
+	args = appendArgs(args, q.Having)
+	for _, o := range q.OrderBy {
+		args = appendBound(args, o.Column, o.Args)
+	}
+	return args
+}
+
+// argCount returns the number of arguments Render binds for q, at most.
+func (q *Query) argCount() int {
+	n := countArgs(q.Where) + countArgs(q.Having)
+	if q.Sample != nil {
+		n++
+	}
+	for _, j := range q.AntiJoins {
+		n += countArgs(j.Where)
+	}
+	for _, o := range q.OrderBy {
+		n += len(o.Args)
+	}
+	return n
+}
+
+func countArgs(exprs []Expr) int {
+	n := 0
+	for _, e := range exprs {
+		n += exprArgs(e)
+	}
+	return n
+}
+
+func exprArgs(e Expr) int {
+	switch e := e.(type) {
+	case Predicate:
+		return len(e.Args)
+	case Group:
+		return countArgs(e.Exprs)
+	case Not:
+		return exprArgs(e.Expr)
+	case Exists:
+		return countArgs(e.Where)
+	}
+	return 0
+}
+
+func appendArgs(args []interface{}, exprs []Expr) []interface{} {
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case Predicate:
+			args = appendBound(args, e.SQL, e.Args)
+		case Group:
+			args = appendArgs(args, e.Exprs)
+		case Not:
+			args = appendArgs(args, []Expr{e.Expr})
+		case Exists:
+			args = appendArgs(args, e.Where)
+		}
+	}
+	return args
+}
+
+// appendBound appends the values the '?' markers of sql are bound to, as
+// by renderer.substitute.
+func appendBound(args []interface{}, sql string, values []interface{}) []interface{} {
+	return append(args, values[:min(len(values), strings.Count(sql, "?"))]...)
+}
@@ -0,0 +1,90 @@
+package sqlspec
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// ColumnType is the SQL type of the column a field maps to, when comparing
+// it with parameters typed as text or its Go type would not do, e.g. a
+// PostgreSQL citext, numeric, uuid or enum column.
+type ColumnType struct {
+	// Name is the SQL type, e.g. "uuid" or an enum's type name. Values
+	// compared with the column are cast to it, as CAST(? AS uuid). Like
+	// the columns of field maps, it is trusted SQL.
+	Name string `json:"name,omitempty"`
+	// CaseInsensitive marks types compared case-insensitively, such as
+	// citext: ILike is then written as LIKE, without LOWER.
+	CaseInsensitive bool `json:"case_insensitive,omitempty"`
+	// Values lists the values of enum types. Others are reported as
+	// ErrInvalidValue.
+	Values []string `json:"values,omitempty"`
+	// Validate, when set, reports the values the column cannot hold, which
+	// are then reported as ErrInvalidValue rather than by the database.
+	Validate func(value interface{}) error `json:"-"`
+}
+
+// WithColumnTypes sets the types of the columns of the fields of types, so
+// that comparisons with their values are cast and validated. Fields without
+// one are compared as before.
+func WithColumnTypes(types map[string]ColumnType) Option {
+	return func(c *config) {
+		c.columnTypes = maps.Clone(c.columnTypes)
+		if c.columnTypes == nil {
+			c.columnTypes = map[string]ColumnType{}
+		}
+		maps.Copy(c.columnTypes, types)
+	}
+}
+
+// check reports the first of values the column cannot hold. NULL fits any
+// column.
+func (t ColumnType) check(values []interface{}) error {
+	for _, value := range values {
+		switch {
+		case value == nil:
+		case t.Values != nil && !slices.Contains(t.Values, fmt.Sprint(value)):
+			return fmt.Errorf("%v is not one of %s", value, strings.Join(t.Values, ", "))
+		case t.Validate != nil:
+			if err := t.Validate(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnType returns the type of the column of field, unless it is
+// aggregated, after checking values.
+func (v *Visitor) columnType(field string, values []interface{}) (ColumnType, bool) {
+	t, ok := v.cfg.columnTypes[field]
+	if !ok || v.aggregate != "" {
+		return ColumnType{}, false
+	}
+	if err := t.check(values); err != nil {
+		v.fail(fmt.Errorf("%w: %s: %v", specifications.ErrInvalidValue, field, err))
+	}
+	return t, true
+}
+
+// placeholder returns the marker of values compared with field, cast to its
+// column type, if any.
+func (v *Visitor) placeholder(field string, values []interface{}) string {
+	if t, ok := v.columnType(field, values); ok && t.Name != "" {
+		return "CAST(? AS " + t.Name + ")"
+	}
+	return "?"
+}
+
+// comparison adds the condition dbField followed by operator, e.g. " = ?",
+// with its markers bound to values like placeholder.
+func (v *Visitor) comparison(field, dbField, operator string, values ...interface{}) {
+	if p := v.placeholder(field, values); p != "?" {
+		operator = strings.ReplaceAll(operator, "?", p)
+	}
+	v.where(fragment(dbField, operator), dbField, values...)
+}
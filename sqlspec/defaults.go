@@ -0,0 +1,48 @@
+package sqlspec
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/thefabric-io/specifications/internal/sqlsafe"
+)
+
+// ErrDefaultsSet is returned by SetDefaults once defaults are set.
+var ErrDefaultsSet = errors.New("sqlspec: defaults already set")
+
+var (
+	defaultsOnce sync.Once
+	defaults     atomic.Pointer[config]
+)
+
+// SetDefaults sets the options every visitor starts from, before the
+// options passed to NewVisitor, e.g. at startup:
+//
+//	sqlspec.SetDefaults(sqlspec.WithHardening(), sqlspec.WithDefaultLimit(100))
+//
+// The defaults are immutable: only the first call sets them and later ones
+// return ErrDefaultsSet. Each visitor gets its own copy, so SetDefaults is
+// safe to call concurrently with NewVisitor, but visitors created before
+// it keep the previous defaults.
+func SetDefaults(opts ...Option) error {
+	err := ErrDefaultsSet
+	defaultsOnce.Do(func() {
+		cfg := baseConfig()
+		for _, opt := range opts {
+			opt(cfg)
+		}
+		defaults.Store(cfg)
+		err = nil
+	})
+	return err
+}
+
+// baseConfig returns a copy of the configuration visitors start from.
+func baseConfig() *config {
+	if cfg := defaults.Load(); cfg != nil {
+		c := *cfg
+		return &c
+	}
+	return &config{hardened: sqlsafe.HardenedByDefault}
+}
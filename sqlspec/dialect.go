@@ -0,0 +1,143 @@
+package sqlspec
+
+import "github.com/thefabric-io/specifications"
+
+// Dialect captures the SQL syntax differences between databases. The
+// generic Visitor renders every specification through it.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the 1-based argument index,
+	// e.g. "$1" or "?".
+	Placeholder(index int) string
+	// QuoteIdentifier quotes a (possibly qualified) column reference.
+	QuoteIdentifier(name string) string
+	// LimitOffset renders the pagination clause, including its leading
+	// space, or "" when limit and offset are both zero. ordered reports
+	// whether the query has an ORDER BY clause.
+	LimitOffset(limit, offset int, ordered bool) string
+	// BoolLiteral returns a condition that is always true or always false.
+	BoolLiteral(b bool) string
+}
+
+// The optional interfaces below let a Dialect support operators that have
+// no portable SQL form. Without them the Visitor falls back to a portable
+// rendering or reports specifications.ErrUnsupported.
+
+// NameQuoter is implemented by dialects whose QuoteIdentifier trusts
+// mapped columns as SQL. QuoteName quotes each dotted part of name as a
+// delimited identifier; the Visitor uses it with WithQuotedIdentifiers.
+type NameQuoter interface {
+	QuoteName(name string) string
+}
+
+// ExplicitPaginator renders the pagination clause like LimitOffset, limit
+// and offset being negative when unset rather than zero, so that LIMIT 0
+// and OFFSET 0 can be written. Without it, a zero limit is rendered as an
+// always false condition and a zero offset is left out.
+type ExplicitPaginator interface {
+	ExplicitLimitOffset(limit, offset int, ordered bool) string
+}
+
+// ILiker renders case-insensitive LIKE natively. The fallback is
+// LOWER(column) LIKE LOWER(?).
+type ILiker interface {
+	ILike(column string) string
+}
+
+// LikeEscaper returns the ESCAPE clause used with patterns escaped with
+// specifications.LikeEscapeChar. The fallback is ESCAPE '\'.
+type LikeEscaper interface {
+	LikeEscape() string
+}
+
+// Regexer renders regular expression matching. ok is false when the
+// requested variant is unsupported.
+type Regexer interface {
+	Regex(column string, caseInsensitive bool) (condition string, ok bool)
+}
+
+// NullsOrderer renders the placement of NULLs after an ORDER BY term,
+// including its leading space, e.g. " NULLS FIRST". The fallback first
+// orders by a CASE expression on whether the column is NULL.
+type NullsOrderer interface {
+	NullsOrder(nulls specifications.NullsPosition) string
+}
+
+// DistinctOner renders the DISTINCT ON clause keeping the first row of each
+// combination of values of columns.
+type DistinctOner interface {
+	DistinctOn(columns []string) string
+}
+
+// JSONer renders conditions on JSON document columns. Each '?' marker is
+// bound, in order, to the document as JSON text, to the keys of the path or
+// to the key.
+type JSONer interface {
+	JSONContains(column string) string
+	// JSONPathText returns the expression of the text at a path of depth
+	// keys.
+	JSONPathText(column string, depth int) string
+	JSONKeyExists(column string) string
+}
+
+// Arrayer renders conditions on array columns. The '?' marker is bound to
+// the argument returned by ArrayArg.
+type Arrayer interface {
+	ArrayContains(column string) string
+	ArrayOverlaps(column string) string
+	// EqualAny renders a comparison of column with any element of the
+	// array, for EqualAny.
+	EqualAny(column string) string
+	// ArrayArg returns the argument binding values as one array.
+	ArrayArg(values []interface{}) interface{}
+}
+
+// TextSearcher renders full-text search conditions and ranks, whose '?'
+// marker is bound to the query. language is empty or the name of a text
+// search configuration, validated as an identifier. It is written in the
+// SQL so that expression indexes on the configuration apply.
+type TextSearcher interface {
+	TextSearch(column, language string) string
+	TextRank(column, language string) string
+}
+
+// Similarer renders trigram similarity conditions, whose '?' marker is
+// bound to the compared value. Similar uses the database's threshold, and
+// Similarity is the similarity score, also compared with an explicit
+// threshold.
+type Similarer interface {
+	Similar(column string) string
+	Similarity(column string) string
+}
+
+// Geographer renders geospatial conditions and distances. Their '?'
+// markers are bound to longitudes and latitudes, in this order, like
+// ST_MakePoint arguments: WithinRadius binds the longitude and latitude of
+// the center, then the radius in meters; WithinBox the minimum longitude
+// and latitude, then the maximum ones; Distance the longitude and
+// latitude of the point distances are measured from.
+type Geographer interface {
+	WithinRadius(column string) string
+	WithinBox(column string) string
+	Distance(column string) string
+}
+
+// Locker renders the row-locking clause appended to the query, including
+// its leading space, e.g. " FOR UPDATE SKIP LOCKED". ok is false for
+// unsupported combinations.
+type Locker interface {
+	Lock(strength specifications.LockStrength, wait specifications.LockWait) (clause string, ok bool)
+}
+
+// StatisticalAggregator renders the statistical aggregates of column,
+// specifications.AggregateStddev and specifications.AggregatePercentile
+// functions. ok is false for unsupported functions. Without it, they are
+// unsupported.
+type StatisticalAggregator interface {
+	StatisticalAggregate(function specifications.AggregateFunction, column string) (expr string, ok bool)
+}
+
+// Sampler renders a sampling clause appended to the base query, using
+// placeholder for the percentage. ok is false for unsupported methods.
+type Sampler interface {
+	TableSample(method specifications.SampleMethod, placeholder string) (clause string, ok bool)
+}
@@ -0,0 +1,49 @@
+package sqlspec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// BuildETagQuery builds a single query returning the latest change timestamp
+// and the row count of the collection matched by the visited specification:
+//
+//	SELECT MAX(updated_at), COUNT(*) FROM table WHERE ...
+//
+// With GROUP BY or HAVING, it returns those of the groups instead, the
+// latest change of a group being that of its rows:
+//
+//	SELECT MAX(last_modified), COUNT(*) FROM (SELECT MAX(updated_at) AS last_modified FROM table WHERE ... GROUP BY ... HAVING ...) AS tagged
+//
+// Anti-joins are joined as by BuildQuery. Ordering, pagination and sampling
+// are ignored so the result describes the whole filtered collection. The
+// domain field is mapped like any other field.
+func (v *Visitor) BuildETagQuery(baseTable, updatedAtField string) (string, []interface{}) {
+	updatedAt := v.mapField(updatedAtField)
+	if v.failed() {
+		return "", nil
+	}
+	q := &Query{Where: v.conditions, GroupBy: v.groupBy, Having: v.having, AntiJoins: v.antiJoins}
+	if len(q.GroupBy) == 0 && len(q.Having) == 0 {
+		return q.Render(v.dialect, fmt.Sprintf("SELECT MAX(%s), COUNT(*) FROM %s", updatedAt, baseTable))
+	}
+	query, args := q.Render(v.dialect, fmt.Sprintf("SELECT MAX(%s) AS last_modified FROM %s", updatedAt, baseTable))
+	return "SELECT MAX(last_modified), COUNT(*) FROM (" + query + ") AS tagged", args
+}
+
+// ETag derives a weak entity tag from the values returned by the query built
+// with BuildETagQuery. A zero lastModified (empty collection) is valid.
+func ETag(lastModified time.Time, count int64) string {
+	var nanos int64
+	if !lastModified.IsZero() {
+		nanos = lastModified.UnixNano()
+	}
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatInt(nanos, 10)))
+	h.Write([]byte{':'})
+	h.Write([]byte(strconv.FormatInt(count, 10)))
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
@@ -0,0 +1,58 @@
+package sqlspec_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/postgres"
+	"github.com/thefabric-io/specifications/sqlspec"
+)
+
+func TestBuildETagQuery(t *testing.T) {
+	orders := sqlspec.WithRelation("orders", sqlspec.Relation{Table: "orders", On: "orders.customer_id = customers.id", Key: "orders.id"})
+	for _, c := range []struct {
+		name    string
+		visitor *sqlspec.Visitor
+		spec    specifications.Specification
+		table   string
+		want    string
+		args    []interface{}
+	}{
+		{
+			name:    "conditions",
+			visitor: postgres.NewVisitor(nil),
+			spec:    specifications.And(specifications.Equal("status", "active"), specifications.OrderBy("name", "ASC"), specifications.Limit(10)),
+			table:   "customers",
+			want:    "SELECT MAX(updated_at), COUNT(*) FROM customers WHERE status = $1",
+			args:    []interface{}{"active"},
+		},
+		{
+			name:    "anti-join",
+			visitor: postgres.NewVisitor(nil, orders, sqlspec.WithAntiJoins(sqlspec.AntiJoinLeftJoin)),
+			spec:    specifications.And(specifications.Equal("status", "active"), specifications.Not(specifications.Related("orders", specifications.Equal("state", "open")))),
+			table:   "customers",
+			want:    "SELECT MAX(updated_at), COUNT(*) FROM customers LEFT JOIN orders ON orders.customer_id = customers.id AND state = $1 WHERE status = $2 AND orders.id IS NULL",
+			args:    []interface{}{"open", "active"},
+		},
+		{
+			name:    "groups",
+			visitor: postgres.NewVisitor(nil),
+			spec:    specifications.And(specifications.Equal("status", "active"), specifications.Duplicates("email")),
+			table:   "users",
+			want:    "SELECT MAX(last_modified), COUNT(*) FROM (SELECT MAX(updated_at) AS last_modified FROM users WHERE status = $1 GROUP BY email HAVING COUNT(*) > $2) AS tagged",
+			args:    []interface{}{"active", 1},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.spec.Accept(c.visitor)
+			query, args := c.visitor.BuildETagQuery(c.table, "updated_at")
+			if err := c.visitor.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if query != c.want || !reflect.DeepEqual(args, c.args) {
+				t.Errorf("got %q %v, want %q %v", query, args, c.want, c.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package sqlspec
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// maxFragments bounds the fragment cache so that queries over unbounded
+// column sets cannot grow it without limit.
+const maxFragments = 4096
+
+type fragmentKey struct {
+	column   string
+	operator string
+}
+
+var (
+	fragments     sync.Map // fragmentKey -> string
+	fragmentCount atomic.Int64
+)
+
+// fragment returns column+operator, reusing the string rendered for the
+// same pair by earlier visitors. Services building many similar queries then
+// share one allocation per (column, operator) instead of one per condition.
+func fragment(column, operator string) string {
+	key := fragmentKey{column: column, operator: operator}
+	if s, ok := fragments.Load(key); ok {
+		return s.(string)
+	}
+	s := column + operator
+	if fragmentCount.Load() < maxFragments {
+		if _, loaded := fragments.LoadOrStore(key, s); !loaded {
+			fragmentCount.Add(1)
+		}
+	}
+	return s
+}
+
+// buffers holds the scratch buffers queries are rendered into. The rendered
+// query is copied out, so a buffer's capacity is reused across builds.
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledBuffer keeps unusually large queries from pinning memory in the
+// pool.
+const maxPooledBuffer = 64 << 10
+
+func getBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buffers.Put(buf)
+}
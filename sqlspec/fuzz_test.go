@@ -0,0 +1,67 @@
+package sqlspec_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/postgres"
+)
+
+// FuzzBuildQuery checks that hardened visitors render hostile fields and
+// order directions as nothing but an error, and valid ones as the query
+// they stand for.
+func FuzzBuildQuery(f *testing.F) {
+	for _, seed := range []struct{ field, direction string }{
+		{"name", "ASC"},
+		{"p.price", "desc"},
+		{"status", " Desc "},
+		{"", ""},
+		{"mapped", "ASC"},
+		{"id; DROP TABLE users; --", "ASC"},
+		{"name", "ASC; DELETE FROM users"},
+		{"name", "DESC NULLS FIRST, (SELECT 1)"},
+		{"price) OR (1=1", "ASC"},
+		{"a?b", "ASC"},
+		{"a$1", "ASC"},
+		{"x\x00y", "DESC"},
+		{`"quoted"`, "ASC"},
+		{"`backticked`", "ASC"},
+		{"[bracketed]", "DESC"},
+		{"name--", "ASC"},
+		{"name/*", "*/ASC"},
+		{"1starts_with_digit", "ASC"},
+		{"trailing.", "ASC"},
+		{"a..b", "ASC"},
+		{"naïve", "ASC"},
+		{"name\n", "ASC\n--"},
+		{strings.Repeat("a", 300), "ASC"},
+	} {
+		f.Add(seed.field, seed.direction)
+	}
+	f.Fuzz(func(t *testing.T, field, direction string) {
+		v := postgres.NewVisitor(map[string]string{"mapped": "t.mapped"}, postgres.WithHardening())
+		specifications.Equal(field, "value").Accept(v)
+		specifications.OrderBy(field, direction).Accept(v)
+		query, args := v.BuildQuery("SELECT * FROM t")
+		if v.Err() != nil {
+			if query != "" || args != nil {
+				t.Fatalf("rendered %q %v despite %v", query, args, v.Err())
+			}
+			return
+		}
+
+		column := field
+		if field == "mapped" {
+			column = "t.mapped"
+		}
+		d, err := specifications.ParseOrderDirection(direction)
+		if err != nil {
+			t.Fatalf("accepted direction %q: %v", direction, err)
+		}
+		want := "SELECT * FROM t WHERE " + column + " = $1 ORDER BY " + column + " " + string(d)
+		if query != want || len(args) != 1 || args[0] != "value" {
+			t.Fatalf("got %q %v, want %q [value]", query, args, want)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package sqlspec
+
+import "sync"
+
+// visitors holds the visitors released with ReleaseVisitor.
+var visitors = sync.Pool{
+	New: func() interface{} {
+		return &Visitor{}
+	},
+}
+
+// maxPooledExprs keeps visitors of unusually large specifications from
+// pinning memory in the pool.
+const maxPooledExprs = 1024
+
+// Reset discards the visited specifications and the error reported while
+// visiting them, keeping the configuration, so that v visits another
+// specification. The capacity of its internal slices is kept.
+func (v *Visitor) Reset() {
+	clear(v.conditions)
+	clear(v.orderClauses)
+	clear(v.having)
+	clear(v.antiJoins)
+	*v = Visitor{
+		dialect:      v.dialect,
+		cfg:          v.cfg,
+		fieldMap:     v.fieldMap,
+		conditions:   v.conditions[:0],
+		orderClauses: v.orderClauses[:0],
+		groupBy:      v.groupBy[:0],
+		having:       v.having[:0],
+		antiJoins:    v.antiJoins[:0],
+	}
+	v.mapTieBreaker()
+}
+
+// AcquireVisitor is like NewVisitor but reuses a visitor released with
+// ReleaseVisitor, if any, and the capacity of its internal slices, for hot
+// request paths building many queries:
+//
+//	v := sqlspec.AcquireVisitor(postgres.Dialect, fieldMap)
+//	defer sqlspec.ReleaseVisitor(v)
+//	spec.Accept(v)
+//	query, args, err := v.Build("SELECT * FROM orders")
+func AcquireVisitor(dialect Dialect, fieldMap map[string]string, opts ...Option) *Visitor {
+	v := visitors.Get().(*Visitor)
+	v.dialect, v.fieldMap, v.cfg = dialect, fieldMap, newConfig(opts)
+	v.Reset()
+	if v.conditions == nil {
+		v.conditions, v.orderClauses = []Expr{}, []OrderTerm{}
+	}
+	return v
+}
+
+// ReleaseVisitor returns v to the pool of AcquireVisitor. v must not be
+// used afterwards, unlike the queries, arguments and Query it returned.
+func ReleaseVisitor(v *Visitor) {
+	if cap(v.conditions) > maxPooledExprs || cap(v.having) > maxPooledExprs {
+		return
+	}
+	v.Reset()
+	v.dialect, v.fieldMap, v.cfg = nil, nil, nil
+	visitors.Put(v)
+}
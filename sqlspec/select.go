@@ -0,0 +1,132 @@
+package sqlspec
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// Select is a structured base query, rendered as
+//
+//	WITH name AS (...) SELECT columns FROM table JOIN ...
+//
+// so joins, projections and common table expressions can be added by
+// independent parts of an application without string concatenation. All
+// parts are trusted SQL, like mapped columns.
+type Select struct {
+	With    []CTE
+	Columns []string
+	From    string
+	Joins   []Join
+}
+
+// CTE is a common table expression.
+type CTE struct {
+	Name  string
+	Query string
+}
+
+// Join is a JOIN clause. Kind is e.g. "JOIN" or "LEFT JOIN".
+type Join struct {
+	Kind  string
+	Table string
+	On    string
+}
+
+// From returns a Select of every column of table.
+func From(table string) *Select {
+	return &Select{From: table}
+}
+
+// Select appends columns to the projection.
+func (s *Select) Select(columns ...string) *Select {
+	s.Columns = append(s.Columns, columns...)
+	return s
+}
+
+// Join adds an inner join on table unless an identical join exists, so
+// that specifications needing a join can request it independently.
+func (s *Select) Join(table, on string) *Select {
+	return s.addJoin(Join{Kind: "JOIN", Table: table, On: on})
+}
+
+// LeftJoin is like Join for a LEFT JOIN.
+func (s *Select) LeftJoin(table, on string) *Select {
+	return s.addJoin(Join{Kind: "LEFT JOIN", Table: table, On: on})
+}
+
+func (s *Select) addJoin(j Join) *Select {
+	for _, existing := range s.Joins {
+		if existing == j {
+			return s
+		}
+	}
+	s.Joins = append(s.Joins, j)
+	return s
+}
+
+// WithCTE adds a common table expression named name.
+func (s *Select) WithCTE(name, query string) *Select {
+	s.With = append(s.With, CTE{Name: name, Query: query})
+	return s
+}
+
+// String renders the base query.
+func (s *Select) String() string {
+	var b strings.Builder
+	for i, cte := range s.With {
+		if i == 0 {
+			b.WriteString("WITH ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(cte.Name)
+		b.WriteString(" AS (")
+		b.WriteString(cte.Query)
+		b.WriteString(")")
+	}
+	if len(s.With) > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString("SELECT ")
+	if len(s.Columns) == 0 {
+		b.WriteString("*")
+	} else {
+		b.WriteString(strings.Join(s.Columns, ", "))
+	}
+	b.WriteString(" FROM ")
+	b.WriteString(s.From)
+	for _, j := range s.Joins {
+		b.WriteString(" ")
+		b.WriteString(j.Kind)
+		b.WriteString(" ")
+		b.WriteString(j.Table)
+		if j.On != "" {
+			b.WriteString(" ON ")
+			b.WriteString(j.On)
+		}
+	}
+	return b.String()
+}
+
+// BuildSelect is like Build with a structured base query.
+func (v *Visitor) BuildSelect(s *Select) (string, []interface{}, error) {
+	return v.Build(s.String())
+}
+
+// BuildGroupCounts is like BuildSelect with a base query selecting from
+// table the grouping columns and the number of rows of each group, as
+// count, e.g. to return the duplicate groups found by
+// specifications.Duplicates:
+//
+//	specifications.Duplicates("Email").Accept(v)
+//	query, args, err := v.BuildGroupCounts("users")
+//	// SELECT email, COUNT(*) AS count FROM users GROUP BY email HAVING COUNT(*) > $1 ORDER BY COUNT(*) DESC
+func (v *Visitor) BuildGroupCounts(table string) (string, []interface{}, error) {
+	if v.err == nil && len(v.groupBy) == 0 {
+		return "", nil, fmt.Errorf("%w: group counts without GroupBy", specifications.ErrInvalidValue)
+	}
+	return v.BuildSelect(From(table).Select(append(slices.Clone(v.groupBy), "COUNT(*) AS count")...))
+}
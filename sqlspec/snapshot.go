@@ -0,0 +1,143 @@
+package sqlspec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/thefabric-io/specifications"
+)
+
+// ErrSnapshotMismatch is returned when restoring a snapshot whose checksum
+// does not match its content, or into a visitor configured differently.
+var ErrSnapshotMismatch = errors.New("sqlspec: snapshot mismatch")
+
+// Snapshot is the configuration of a Visitor as a serializable artifact,
+// with the configuration of the validators of the same filters, e.g. the
+// json.Schema of API filters. Each environment exports it, e.g. as JSON,
+// so that comparing checksums proves staging and production translate
+// specifications alike, and detects drift:
+//
+//	snapshot, err := postgres.NewVisitor(fieldMap, opts...).Snapshot().WithSchema("filters", schema)
+//	data, err := json.Marshal(snapshot)
+//
+// Dialect and ArgTarget are the Go types of the visitor's dialect and
+// argument target, which Restore checks but cannot recreate, like the
+// Validate functions of ColumnTypes.
+type Snapshot struct {
+	Dialect           string                        `json:"dialect"`
+	ArgTarget         string                        `json:"arg_target,omitempty"`
+	FieldMap          map[string]string             `json:"field_map"`
+	Hardened          bool                          `json:"hardened,omitempty"`
+	QuotedIdentifiers bool                          `json:"quoted_identifiers,omitempty"`
+	StrictFields      bool                          `json:"strict_fields,omitempty"`
+	EmptyComposite    specifications.EmptyComposite `json:"empty_composite,omitempty"`
+	TieBreaker        string                        `json:"tie_breaker,omitempty"`
+	DefaultLimit      int                           `json:"default_limit,omitempty"`
+	MaxLimit          int                           `json:"max_limit,omitempty"`
+	MaxLimitPolicy    MaxLimitPolicy                `json:"max_limit_policy,omitempty"`
+	AntiJoins         AntiJoinStyle                 `json:"anti_joins,omitempty"`
+	Relations         map[string]Relation           `json:"relations,omitempty"`
+	ColumnTypes       map[string]ColumnType         `json:"column_types,omitempty"`
+	Schemas           map[string]json.RawMessage    `json:"schemas,omitempty"`
+	// Checksum is the SHA-256 of the other fields as JSON, whose map keys
+	// are sorted.
+	Checksum string `json:"checksum"`
+}
+
+// Snapshot returns the configuration of v: its dialect, field map and
+// options, including those set with SetDefaults.
+func (v *Visitor) Snapshot() Snapshot {
+	s := Snapshot{
+		Dialect:           fmt.Sprintf("%T", v.dialect),
+		FieldMap:          maps.Clone(v.fieldMap),
+		Hardened:          v.cfg.hardened,
+		QuotedIdentifiers: v.cfg.quoted,
+		StrictFields:      v.cfg.strict,
+		EmptyComposite:    v.cfg.empty,
+		TieBreaker:        v.cfg.tieBreaker,
+		DefaultLimit:      v.cfg.defaultLimit,
+		MaxLimit:          v.cfg.maxLimit,
+		MaxLimitPolicy:    v.cfg.maxLimitPolicy,
+		AntiJoins:         v.cfg.antiJoins,
+		Relations:         maps.Clone(v.cfg.relations),
+		ColumnTypes:       maps.Clone(v.cfg.columnTypes),
+	}
+	if v.cfg.target != nil {
+		s.ArgTarget = fmt.Sprintf("%T", v.cfg.target)
+	}
+	if s.FieldMap == nil {
+		s.FieldMap = map[string]string{}
+	}
+	s.Checksum = s.checksum()
+	return s
+}
+
+// WithSchema returns a copy of s also covering schema, the configuration
+// of a validator, as JSON under name.
+func (s Snapshot) WithSchema(name string, schema interface{}) (Snapshot, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("sqlspec: snapshot of schema %s: %w", name, err)
+	}
+	s.Schemas = maps.Clone(s.Schemas)
+	if s.Schemas == nil {
+		s.Schemas = map[string]json.RawMessage{}
+	}
+	s.Schemas[name] = data
+	s.Checksum = s.checksum()
+	return s, nil
+}
+
+func (s Snapshot) checksum() string {
+	s.Checksum = ""
+	// Snapshots hold strings, numbers and valid JSON only.
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify reports ErrSnapshotMismatch when the checksum of s does not match
+// its content, e.g. after an edit by hand.
+func (s Snapshot) Verify() error {
+	if s.checksum() != s.Checksum {
+		return fmt.Errorf("%w: checksum %s of content %s", ErrSnapshotMismatch, s.Checksum, s.checksum())
+	}
+	return nil
+}
+
+// Restore returns a visitor for dialect configured as in s, replacing the
+// defaults set with SetDefaults. opts set what snapshots cannot hold, such
+// as WithArgTarget or WithColumnTypes with Validate functions. It reports
+// ErrSnapshotMismatch when s does not Verify or the visitor's snapshot
+// differs from s, e.g. for another dialect.
+func (s Snapshot) Restore(dialect Dialect, opts ...Option) (*Visitor, error) {
+	if err := s.Verify(); err != nil {
+		return nil, err
+	}
+	restore := func(c *config) {
+		*c = config{
+			hardened:       s.Hardened,
+			quoted:         s.QuotedIdentifiers,
+			strict:         s.StrictFields,
+			empty:          s.EmptyComposite,
+			tieBreaker:     s.TieBreaker,
+			defaultLimit:   s.DefaultLimit,
+			maxLimit:       s.MaxLimit,
+			maxLimitPolicy: s.MaxLimitPolicy,
+			antiJoins:      s.AntiJoins,
+			relations:      maps.Clone(s.Relations),
+			columnTypes:    maps.Clone(s.ColumnTypes),
+		}
+	}
+	v := NewVisitor(dialect, maps.Clone(s.FieldMap), append([]Option{restore}, opts...)...)
+	restored := v.Snapshot()
+	restored.Schemas = s.Schemas
+	if restored.checksum() != s.Checksum {
+		return nil, fmt.Errorf("%w: restored visitor differs, e.g. in dialect %s or argument target %q", ErrSnapshotMismatch, restored.Dialect, restored.ArgTarget)
+	}
+	return v, nil
+}
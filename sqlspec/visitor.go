@@ -0,0 +1,1106 @@
+package sqlspec
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/internal/jsondoc"
+	"github.com/thefabric-io/specifications/internal/sqlsafe"
+)
+
+// Visitor translates specifications into SQL for the Dialect it was created
+// with. Visiting builds a Query, which BuildQuery renders; conditions carry
+// '?' markers that are replaced with the dialect's placeholders then.
+type Visitor struct {
+	dialect      Dialect
+	cfg          *config
+	conditions   []Expr
+	fieldMap     map[string]string
+	orderClauses []OrderTerm
+	limit        int
+	offset       int
+	sample       *Sample
+	distinct     *Distinct
+	groupBy      []string
+	having       []Expr
+	lock         *Lock
+	antiJoins    []AntiJoin
+	// limitSet and offsetSet tell a visited Limit or Offset of zero from
+	// none.
+	limitSet, offsetSet bool
+	// nested is set on the visitors of composites and subqueries, whose
+	// conditions are not those of the top-level conjunction.
+	nested bool
+	// aggregate is applied to the mapped fields while visiting the
+	// specification of an Aggregated.
+	aggregate specifications.AggregateFunction
+	// inHaving is set while visiting the specification of a Having, whose
+	// conditions may be on aggregates.
+	inHaving bool
+	// arena backs the arguments of the conditions, allocated for many
+	// conditions at once.
+	arena []interface{}
+	err   error
+}
+
+type config struct {
+	hardened     bool
+	empty        specifications.EmptyComposite
+	tieBreaker   string
+	target       ArgTarget
+	defaultLimit int
+	quoted       bool
+	strict       bool
+	relations    map[string]Relation
+	antiJoins    AntiJoinStyle
+	// maxLimit bounds the limits of queries, per maxLimitPolicy.
+	maxLimit       int
+	maxLimitPolicy MaxLimitPolicy
+	// tieBreakerColumn is the column tieBreaker is mapped to.
+	tieBreakerColumn string
+	// cache memoizes the SQL of BuildQuery.
+	cache *QueryCache
+	// columnTypes are the types of the columns of fields.
+	columnTypes map[string]ColumnType
+}
+
+// Option configures a Visitor.
+type Option func(*config)
+
+// WithHardening validates every mapped identifier against a strict grammar
+// and fails closed: invalid input is reported by Err and Build, and
+// BuildQuery returns an empty query rather than render it. Hardening is on
+// by default when built with the specifications_hardened tag. The SQL
+// written by the application, such as base queries and relations, is
+// trusted rather than validated.
+func WithHardening() Option {
+	return func(c *config) {
+		c.hardened = true
+	}
+}
+
+// WithQuotedIdentifiers quotes every column with the dialect's
+// NameQuoter, e.g. as "created_at" in PostgreSQL with embedded quotes
+// escaped, so that no field name is interpreted as SQL. Mapped columns must
+// then be names, optionally qualified, rather than expressions, and
+// unquoted names are case-sensitive. Dialects without NameQuoter quote
+// identifiers anyway and are unaffected.
+func WithQuotedIdentifiers() Option {
+	return func(c *config) {
+		c.quoted = true
+	}
+}
+
+// WithStrictFields rejects fields missing from the field map with
+// specifications.ErrInvalidField, rather than using them as column names,
+// for specifications built from API input. The error returned by Build and
+// Err names the first unmapped field and lists the mapped ones, e.g. to be
+// shown to API clients.
+func WithStrictFields() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// Relation describes how the rows of a related entity are found, for
+// specifications.Related: the rows of Table satisfying On, such as
+// "customers.id = orders.customer_id". Table and On are trusted SQL, like
+// the columns of field maps.
+type Relation struct {
+	Table string `json:"table"`
+	On    string `json:"on"`
+	// FieldMap maps the fields of the related entity to columns of Table.
+	FieldMap map[string]string `json:"field_map,omitempty"`
+	// Key is a column of Table that is never NULL, such as its primary
+	// key, for anti-joins written as LEFT JOIN. Relations without Key
+	// keep NOT EXISTS.
+	Key string `json:"key,omitempty"`
+}
+
+// WithRelation resolves the Related specifications on relation as EXISTS
+// subqueries on r. Related specifications on relations without one are
+// reported as unsupported.
+func WithRelation(relation string, r Relation) Option {
+	return func(c *config) {
+		c.relations = maps.Clone(c.relations)
+		if c.relations == nil {
+			c.relations = map[string]Relation{}
+		}
+		c.relations[relation] = r
+	}
+}
+
+// AntiJoinStyle selects how the negations of Related specifications, the
+// entities without any related row matching a specification, are written.
+type AntiJoinStyle int
+
+const (
+	// AntiJoinNotExists writes them as NOT EXISTS subqueries.
+	AntiJoinNotExists AntiJoinStyle = iota
+	// AntiJoinLeftJoin writes those of the top-level conjunction as a LEFT
+	// JOIN on the related table, appended to the base query's FROM clause,
+	// keeping the rows whose relation's Key IS NULL. Some planners execute
+	// it faster. The base query should then select the columns of its own
+	// table, e.g. "SELECT customers.* FROM customers". Each relation's table
+	// is joined once; the other negations keep NOT EXISTS.
+	AntiJoinLeftJoin
+)
+
+// WithAntiJoins sets how negated Related specifications are written. The
+// default is AntiJoinNotExists.
+func WithAntiJoins(style AntiJoinStyle) Option {
+	return func(c *config) {
+		c.antiJoins = style
+	}
+}
+
+// WithEmptyComposite sets how And and Or without children are rendered. The
+// default is specifications.EmptyCompositeSkip.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return func(c *config) {
+		c.empty = mode
+	}
+}
+
+// WithTieBreaker appends an ascending ordering on field, which must be
+// unique, whenever the query is ordered and does not already order by it.
+// This keeps OFFSET and keyset pagination stable across pages.
+func WithTieBreaker(field string) Option {
+	return func(c *config) {
+		c.tieBreaker = field
+	}
+}
+
+// WithDefaultLimit limits queries to limit rows unless the specification
+// sets a Limit, so unbounded listings cannot return a whole table.
+func WithDefaultLimit(limit int) Option {
+	return func(c *config) {
+		c.defaultLimit = limit
+	}
+}
+
+// MaxLimitPolicy selects what WithMaxLimit does with limits above the
+// maximum.
+type MaxLimitPolicy int
+
+const (
+	// MaxLimitClamp lowers them to the maximum.
+	MaxLimitClamp MaxLimitPolicy = iota
+	// MaxLimitReject reports them as ErrInvalidValue.
+	MaxLimitReject
+)
+
+// WithMaxLimit bounds queries to max rows: limits above max are clamped or
+// rejected per policy, and queries without a Limit are limited to the
+// default limit, itself bounded, or to max. Together with WithDefaultLimit
+// it keeps API endpoints from running unbounded queries.
+func WithMaxLimit(max int, policy MaxLimitPolicy) Option {
+	return func(c *config) {
+		c.maxLimit, c.maxLimitPolicy = max, policy
+	}
+}
+
+func NewVisitor(dialect Dialect, fieldMap map[string]string, opts ...Option) *Visitor {
+	v := newVisitor(dialect, fieldMap, newConfig(opts))
+	v.mapTieBreaker()
+	return v
+}
+
+// newConfig returns the defaults with opts applied.
+func newConfig(opts []Option) *config {
+	cfg := baseConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// mapTieBreaker maps the tie-breaker field, if any, reporting its errors,
+// e.g. in strict mode.
+func (v *Visitor) mapTieBreaker() {
+	if v.cfg.tieBreaker != "" {
+		v.cfg.tieBreakerColumn = v.mapField(v.cfg.tieBreaker)
+	}
+}
+
+func newVisitor(dialect Dialect, fieldMap map[string]string, cfg *config) *Visitor {
+	return &Visitor{
+		dialect:      dialect,
+		cfg:          cfg,
+		conditions:   []Expr{},
+		fieldMap:     fieldMap,
+		orderClauses: []OrderTerm{},
+		limit:        0,
+		offset:       0,
+	}
+}
+
+// child returns an empty visitor sharing v's configuration, used for
+// composite specifications.
+func (v *Visitor) child() *Visitor {
+	sub := newVisitor(v.dialect, v.fieldMap, v.cfg)
+	sub.aggregate = v.aggregate
+	sub.inHaving = v.inHaving
+	sub.nested = true
+	return sub
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (v *Visitor) Err() error {
+	return v.err
+}
+
+func (v *Visitor) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+// mapField returns the column of domainField. Columns holding a '?', which
+// would be bound as a placeholder, are reported as ErrInvalidField.
+func (v *Visitor) mapField(domainField string) string {
+	if v.aggregate == specifications.AggregateCount && domainField == "*" {
+		return "COUNT(*)"
+	}
+	if domainField == "" {
+		v.fail(specifications.ErrInvalidField)
+	}
+	dbField := domainField
+	if mapped, ok := v.fieldMap[domainField]; ok {
+		dbField = mapped
+	} else if v.cfg.strict {
+		v.fail(v.unmapped(domainField))
+	}
+	if v.cfg.hardened && !sqlsafe.Identifier(dbField) {
+		v.fail(fmt.Errorf("%w: %q is not a valid identifier", specifications.ErrInvalidField, dbField))
+	}
+	if strings.ContainsRune(dbField, '?') {
+		v.fail(fmt.Errorf("%w: '?' in %q would be bound as a placeholder", specifications.ErrInvalidField, dbField))
+	}
+	quote := v.dialect.QuoteIdentifier
+	if q, ok := v.dialect.(NameQuoter); ok && v.cfg.quoted {
+		if strings.ContainsRune(dbField, 0) {
+			v.fail(fmt.Errorf("%w: NUL character in %q", specifications.ErrInvalidField, dbField))
+		}
+		quote = q.QuoteName
+	}
+	if v.aggregate != "" {
+		return v.aggregated(quote(dbField))
+	}
+	return quote(dbField)
+}
+
+// aggregated applies the aggregate function to column, statistical ones
+// through the dialect's StatisticalAggregator.
+func (v *Visitor) aggregated(column string) string {
+	if _, percentile := v.aggregate.Percentile(); !percentile && v.aggregate != specifications.AggregateStddev {
+		return string(v.aggregate) + "(" + column + ")"
+	}
+	if a, ok := v.dialect.(StatisticalAggregator); ok {
+		if expr, ok := a.StatisticalAggregate(v.aggregate, column); ok {
+			return expr
+		}
+	}
+	v.fail(fmt.Errorf("%w: %s aggregate with this dialect", specifications.ErrUnsupported, v.aggregate))
+	return column
+}
+
+// unmapped returns the error of strict visitors for domainField, listing
+// the fields of the field map.
+func (v *Visitor) unmapped(domainField string) error {
+	if len(v.fieldMap) == 0 {
+		return fmt.Errorf("%w: %q is not a mapped field, no field is mapped", specifications.ErrInvalidField, domainField)
+	}
+	fields := make([]string, 0, len(v.fieldMap))
+	for f := range v.fieldMap {
+		fields = append(fields, strconv.Quote(f))
+	}
+	slices.Sort(fields)
+	return fmt.Errorf("%w: %q is not a mapped field, expected one of %s", specifications.ErrInvalidField, domainField, strings.Join(fields, ", "))
+}
+
+// where adds a condition on dbField with one '?' marker per argument.
+func (v *Visitor) where(sql, dbField string, args ...interface{}) {
+	v.conditions = append(v.conditions, Predicate{SQL: sql, Column: dbField, Args: v.bound(args)})
+}
+
+// bound returns a copy of args backed by v's arena, or nil when empty. The
+// arena grows by chunks, so that conditions do not allocate their
+// arguments one by one.
+func (v *Visitor) bound(args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	if cap(v.arena)-len(v.arena) < len(args) {
+		v.arena = make([]interface{}, 0, max(len(args), 2*cap(v.arena), 8))
+	}
+	n := len(v.arena)
+	v.arena = append(v.arena, args...)
+	return v.arena[n:len(v.arena):len(v.arena)]
+}
+
+func (v *Visitor) VisitEqual(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " = ?", value)
+}
+
+func (v *Visitor) VisitIn(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	if len(values) == 0 {
+		v.where(v.dialect.BoolLiteral(false), dbField)
+		return
+	}
+
+	p := v.placeholder(field, values)
+	qs := make([]string, len(values))
+	for i := range values {
+		qs[i] = p
+	}
+	args := append([]interface{}(nil), values...)
+	v.where(fmt.Sprintf("%s IN (%s)", dbField, strings.Join(qs, ", ")), dbField, args...)
+}
+
+func (v *Visitor) VisitNotIn(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	if len(values) == 0 {
+		v.where(v.dialect.BoolLiteral(true), dbField)
+		return
+	}
+
+	p := v.placeholder(field, values)
+	qs := make([]string, len(values))
+	for i := range values {
+		qs[i] = p
+	}
+	args := append([]interface{}(nil), values...)
+	v.where(fmt.Sprintf("%s NOT IN (%s)", dbField, strings.Join(qs, ", ")), dbField, args...)
+}
+
+func (v *Visitor) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(v.dialect.BoolLiteral(true))
+		return
+	}
+
+	// Children are visited in place rather than through a sub-visitor, and
+	// their conditions are added to v's: every list of conditions, those of
+	// an Or branch included, is combined with AND, so that And needs no
+	// group of its own. A flat And of comparisons, by far the most common
+	// specification, then allocates nothing but its conditions.
+	v.conditions = slices.Grow(v.conditions, len(specs))
+	for _, s := range specs {
+		s.Accept(v)
+	}
+}
+
+func (v *Visitor) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(v.dialect.BoolLiteral(false))
+		return
+	}
+
+	// Like those of And, the branches are visited in place, each one's
+	// conditions folded into a group, rather than through a sub-visitor per
+	// branch. Their conditions are nested, whatever v's.
+	nested := v.nested
+	v.nested = true
+	orParts := make([]Expr, 0, len(specs))
+
+	for _, s := range specs {
+		branch := len(v.conditions)
+
+		s.Accept(v)
+
+		if added := v.conditions[branch:]; len(added) > 0 {
+			orParts = append(orParts, Group{Op: "AND", Exprs: append([]Expr(nil), added...)})
+		}
+		v.conditions = v.conditions[:branch]
+	}
+
+	v.nested = nested
+	if len(orParts) > 0 {
+		v.conditions = append(v.conditions, Group{Op: "OR", Exprs: orParts})
+	}
+}
+
+func (v *Visitor) VisitNot(spec specifications.Specification) {
+	sub := v.child()
+	spec.Accept(sub)
+	if j, ok := v.antiJoin(sub.conditions); ok {
+		v.antiJoins = append(v.antiJoins, j)
+		v.where(j.Key+" IS NULL", j.Key)
+	} else if len(sub.conditions) > 0 {
+		v.conditions = append(v.conditions, Not{Expr: Group{Op: "AND", Exprs: sub.conditions}})
+	}
+	v.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being the
+// condition equivalent to its logical identity.
+func (v *Visitor) visitEmpty(identity string) {
+	switch v.cfg.empty {
+	case specifications.EmptyCompositeIdentity:
+		v.conditions = append(v.conditions, Predicate{SQL: identity})
+	case specifications.EmptyCompositeError:
+		v.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but conditions and args over from a sub-visitor.
+func (v *Visitor) merge(sub *Visitor) {
+	v.orderClauses = append(v.orderClauses, sub.orderClauses...)
+
+	if sub.limitSet {
+		v.limit, v.limitSet = sub.limit, true
+	}
+
+	if sub.offsetSet {
+		v.offset, v.offsetSet = sub.offset, true
+	}
+
+	if sub.sample != nil {
+		v.sample = sub.sample
+	}
+
+	if sub.distinct != nil {
+		v.distinct = sub.distinct
+	}
+
+	if sub.lock != nil {
+		v.lock = sub.lock
+	}
+
+	v.groupBy = append(v.groupBy, sub.groupBy...)
+	v.having = append(v.having, sub.having...)
+
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+}
+
+func (v *Visitor) VisitLimit(limit int) {
+	if limit < 0 {
+		v.fail(fmt.Errorf("%w: negative limit %d", specifications.ErrInvalidValue, limit))
+		return
+	}
+	if max := v.cfg.maxLimit; max > 0 && limit > max {
+		if v.cfg.maxLimitPolicy == MaxLimitReject {
+			v.fail(fmt.Errorf("%w: limit %d exceeds %d", specifications.ErrInvalidValue, limit, max))
+			return
+		}
+		limit = max
+	}
+	v.limit, v.limitSet = limit, true
+}
+
+func (v *Visitor) VisitOrder(field, direction string) {
+	v.VisitOrderNulls(field, direction, specifications.NullsDefault)
+}
+
+func (v *Visitor) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	dbField := v.mapField(field)
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	if _, err := specifications.ParseNullsPosition(string(nulls)); err != nil {
+		v.fail(err)
+		return
+	}
+	v.orderClauses = append(v.orderClauses, OrderTerm{Column: dbField, Direction: string(d), Nulls: nulls})
+}
+
+func (v *Visitor) VisitTextSearch(field, query, language string) {
+	dbField := v.mapField(field)
+	if d, ok := v.textSearcher(language); ok {
+		v.where(d.TextSearch(dbField, language), dbField, query)
+	}
+}
+
+// VisitTextRank orders by decreasing rank, before the orderings visited
+// later.
+func (v *Visitor) VisitTextRank(field, query, language string) {
+	dbField := v.mapField(field)
+	if d, ok := v.textSearcher(language); ok {
+		v.orderClauses = append(v.orderClauses, OrderTerm{Column: d.TextRank(dbField, language), Direction: string(specifications.Descending), Args: []interface{}{query}})
+	}
+}
+
+// VisitSimilar compares the similarity score with threshold, or uses the
+// dialect's similarity operator without one.
+func (v *Visitor) VisitSimilar(field, value string, threshold float64) {
+	dbField := v.mapField(field)
+	d, ok := v.similarer(threshold)
+	switch {
+	case !ok:
+	case threshold == 0:
+		v.where(d.Similar(dbField), dbField, value)
+	default:
+		v.where(d.Similarity(dbField)+" > ?", dbField, value, threshold)
+	}
+}
+
+// VisitSimilarityOrder orders by decreasing similarity, like VisitTextRank.
+func (v *Visitor) VisitSimilarityOrder(field, value string) {
+	dbField := v.mapField(field)
+	if d, ok := v.similarer(0); ok {
+		v.orderClauses = append(v.orderClauses, OrderTerm{Column: d.Similarity(dbField), Direction: string(specifications.Descending), Args: []interface{}{value}})
+	}
+}
+
+func (v *Visitor) similarer(threshold float64) (Similarer, bool) {
+	d, ok := v.dialect.(Similarer)
+	if !ok {
+		v.fail(fmt.Errorf("%w: trigram similarity", specifications.ErrUnsupported))
+		return nil, false
+	}
+	if threshold < 0 || threshold > 1 {
+		v.fail(fmt.Errorf("%w: similarity threshold %v", specifications.ErrInvalidValue, threshold))
+		return nil, false
+	}
+	return d, true
+}
+
+func (v *Visitor) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	dbField := v.mapField(field)
+	if d, ok := v.geographer(); ok {
+		v.where(d.WithinRadius(dbField), dbField, center.Lng, center.Lat, meters)
+	}
+}
+
+func (v *Visitor) VisitWithinBox(field string, box specifications.BoundingBox) {
+	dbField := v.mapField(field)
+	if d, ok := v.geographer(); ok {
+		v.where(d.WithinBox(dbField), dbField, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat)
+	}
+}
+
+// VisitOrderByDistance orders by increasing distance, like VisitTextRank.
+func (v *Visitor) VisitOrderByDistance(field string, from specifications.Point) {
+	dbField := v.mapField(field)
+	if d, ok := v.geographer(); ok {
+		v.orderClauses = append(v.orderClauses, OrderTerm{Column: d.Distance(dbField), Direction: string(specifications.Ascending), Args: []interface{}{from.Lng, from.Lat}})
+	}
+}
+
+func (v *Visitor) geographer() (Geographer, bool) {
+	d, ok := v.dialect.(Geographer)
+	if !ok {
+		v.fail(fmt.Errorf("%w: geospatial conditions", specifications.ErrUnsupported))
+	}
+	return d, ok
+}
+
+func (v *Visitor) textSearcher(language string) (TextSearcher, bool) {
+	d, ok := v.dialect.(TextSearcher)
+	if !ok {
+		v.fail(fmt.Errorf("%w: full-text search", specifications.ErrUnsupported))
+		return nil, false
+	}
+	if language != "" && !sqlsafe.Identifier(language) {
+		v.fail(fmt.Errorf("%w: text search configuration %q", specifications.ErrInvalidValue, language))
+		return nil, false
+	}
+	return d, true
+}
+
+func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " > ?", value)
+}
+
+func (v *Visitor) VisitLowerThan(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " < ?", value)
+}
+
+func (v *Visitor) VisitLike(field string, value interface{}) {
+	dbField := v.mapField(field)
+	// Typically LIKE patterns are expected to include '%' in the value
+	v.where(fragment(dbField, " LIKE ?"), dbField, value)
+}
+
+func (v *Visitor) VisitILike(field string, value interface{}) {
+	dbField := v.mapField(field)
+	if t, ok := v.columnType(field, nil); ok && t.CaseInsensitive {
+		v.where(fragment(dbField, " LIKE ?"), dbField, value)
+		return
+	}
+	condition := fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", dbField)
+	if d, ok := v.dialect.(ILiker); ok {
+		condition = d.ILike(dbField)
+	}
+	v.where(condition, dbField, value)
+}
+
+func (v *Visitor) VisitLikeEscaped(field string, pattern string) {
+	dbField := v.mapField(field)
+	escape := `ESCAPE '\'`
+	if d, ok := v.dialect.(LikeEscaper); ok {
+		escape = d.LikeEscape()
+	}
+	v.where(fmt.Sprintf("%s LIKE ? %s", dbField, escape), dbField, pattern)
+}
+
+func (v *Visitor) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	dbField := v.mapField(field)
+	d, ok := v.dialect.(Regexer)
+	if !ok {
+		v.fail(fmt.Errorf("%w: regular expressions", specifications.ErrUnsupported))
+		return
+	}
+	condition, ok := d.Regex(dbField, caseInsensitive)
+	if !ok {
+		v.fail(fmt.Errorf("%w: regular expressions (case-insensitive: %v)", specifications.ErrUnsupported, caseInsensitive))
+		return
+	}
+	v.where(condition, dbField, pattern)
+}
+
+func (v *Visitor) VisitOffset(offset int) {
+	if offset < 0 {
+		v.fail(fmt.Errorf("%w: negative offset %d", specifications.ErrInvalidValue, offset))
+		return
+	}
+	v.offset, v.offsetSet = offset, true
+}
+
+func (v *Visitor) VisitNotEqual(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " <> ?", value)
+}
+
+func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " >= ?", value)
+}
+
+func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " <= ?", value)
+}
+
+func (v *Visitor) VisitBetween(field string, low, high interface{}) {
+	dbField := v.mapField(field)
+	v.comparison(field, dbField, " BETWEEN ? AND ?", low, high)
+}
+
+func (v *Visitor) VisitIsNull(field string) {
+	dbField := v.mapField(field)
+	v.where(fragment(dbField, " IS NULL"), dbField)
+}
+
+func (v *Visitor) VisitIsNotNull(field string) {
+	dbField := v.mapField(field)
+	v.where(fragment(dbField, " IS NOT NULL"), dbField)
+}
+
+// VisitEqualAny binds values as one array for dialects implementing
+// Arrayer, and renders an IN list otherwise. Without values it matches
+// nothing, like VisitIn.
+func (v *Visitor) VisitEqualAny(field string, values []interface{}) {
+	d, ok := v.dialect.(Arrayer)
+	if !ok || len(values) == 0 {
+		v.VisitIn(field, values)
+		return
+	}
+	dbField := v.mapField(field)
+	v.where(d.EqualAny(dbField), dbField, d.ArrayArg(values))
+}
+
+func (v *Visitor) VisitArrayContains(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	if d, ok := v.arrayer(); ok {
+		v.where(d.ArrayContains(dbField), dbField, d.ArrayArg(values))
+	}
+}
+
+func (v *Visitor) VisitArrayOverlaps(field string, values []interface{}) {
+	dbField := v.mapField(field)
+	if d, ok := v.arrayer(); ok {
+		v.where(d.ArrayOverlaps(dbField), dbField, d.ArrayArg(values))
+	}
+}
+
+func (v *Visitor) arrayer() (Arrayer, bool) {
+	d, ok := v.dialect.(Arrayer)
+	if !ok {
+		v.fail(fmt.Errorf("%w: array conditions", specifications.ErrUnsupported))
+	}
+	return d, ok
+}
+
+// jsoner returns the dialect's JSONer, or reports JSON conditions as
+// unsupported.
+func (v *Visitor) jsoner() (JSONer, bool) {
+	d, ok := v.dialect.(JSONer)
+	if !ok {
+		v.fail(fmt.Errorf("%w: JSON document conditions", specifications.ErrUnsupported))
+	}
+	return d, ok
+}
+
+func (v *Visitor) VisitJSONContains(field string, doc interface{}) {
+	dbField := v.mapField(field)
+	d, ok := v.jsoner()
+	if !ok {
+		return
+	}
+	text, err := jsondoc.Encode(doc)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.where(d.JSONContains(dbField), dbField, text)
+}
+
+// VisitJSONPathEqual compares the text at path with the text form of
+// value, or checks that it is NULL for a nil value.
+func (v *Visitor) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	dbField := v.mapField(field)
+	d, ok := v.jsoner()
+	if !ok {
+		return
+	}
+	args := make([]interface{}, len(path), len(path)+1)
+	for i, key := range path {
+		args[i] = key
+	}
+	if value == nil {
+		v.where(d.JSONPathText(dbField, len(path))+" IS NULL", dbField, args...)
+		return
+	}
+	text, err := jsondoc.Text(value)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.where(d.JSONPathText(dbField, len(path))+" = ?", dbField, append(args, text)...)
+}
+
+func (v *Visitor) VisitJSONKeyExists(field, key string) {
+	dbField := v.mapField(field)
+	if d, ok := v.jsoner(); ok {
+		v.where(d.JSONKeyExists(dbField), dbField, key)
+	}
+}
+
+// VisitSample records a sampling clause for dialects implementing Sampler. It
+// is appended directly after the base query, or before its WHERE clause,
+// which must therefore be preceded by the sampled table reference.
+func (v *Visitor) VisitSample(percent float64, method specifications.SampleMethod) {
+	d, ok := v.dialect.(Sampler)
+	if !ok {
+		v.fail(fmt.Errorf("%w: sampling", specifications.ErrUnsupported))
+		return
+	}
+	if _, ok := d.TableSample(method, "?"); !ok {
+		v.fail(fmt.Errorf("%w: sample method %q", specifications.ErrUnsupported, method))
+		return
+	}
+	if percent < 0 || percent > 100 {
+		v.fail(fmt.Errorf("%w: sample percent %v out of range [0, 100]", specifications.ErrInvalidValue, percent))
+		return
+	}
+	v.sample = &Sample{Percent: percent, Method: method}
+}
+
+// VisitDistinct records a DISTINCT clause, inserted after the first
+// top-level SELECT of the base query. DistinctOn requires a dialect
+// implementing DistinctOner.
+func (v *Visitor) VisitDistinct(fields []string) {
+	if len(fields) == 0 {
+		v.distinct = &Distinct{}
+		return
+	}
+	if _, ok := v.dialect.(DistinctOner); !ok {
+		v.fail(fmt.Errorf("%w: DISTINCT ON", specifications.ErrUnsupported))
+		return
+	}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = v.mapField(f)
+	}
+	v.distinct = &Distinct{On: columns}
+}
+
+// VisitGroupBy records GROUP BY columns, rendered after the WHERE clause.
+func (v *Visitor) VisitGroupBy(fields []string) {
+	if len(fields) == 0 {
+		v.fail(fmt.Errorf("%w: GROUP BY without fields", specifications.ErrInvalidValue))
+		return
+	}
+	for _, f := range fields {
+		v.groupBy = append(v.groupBy, v.mapField(f))
+	}
+}
+
+// VisitHaving records the conditions of spec in the HAVING clause. Its
+// ordering and pagination apply to the query, like in VisitNot.
+func (v *Visitor) VisitHaving(spec specifications.Specification) {
+	sub := v.child()
+	sub.inHaving = true
+	spec.Accept(sub)
+	v.having = append(v.having, sub.conditions...)
+	v.merge(sub)
+}
+
+// VisitAggregate visits spec with function applied to its columns, e.g.
+// SUM("amount") >= $1 within Having, or ORDER BY COUNT(*) DESC. Conditions on
+// aggregates outside Having are reported as unsupported.
+func (v *Visitor) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	f, err := specifications.ParseAggregateFunction(string(function))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	sub := v.child()
+	sub.aggregate = f
+	spec.Accept(sub)
+	if len(sub.conditions) > 0 && !v.inHaving {
+		v.fail(fmt.Errorf("%w: conditions on %s aggregates outside Having", specifications.ErrUnsupported, f))
+		return
+	}
+	v.conditions = append(v.conditions, sub.conditions...)
+	v.merge(sub)
+}
+
+// VisitRelated matches the rows for which a row of the relation's table
+// satisfies the conditions of spec, with an EXISTS subquery. Ordering,
+// pagination and grouping within spec do not apply.
+func (v *Visitor) VisitRelated(relation string, spec specifications.Specification) {
+	r, ok := v.cfg.relations[relation]
+	if !ok {
+		v.fail(fmt.Errorf("%w: relation %q without WithRelation", specifications.ErrUnsupported, relation))
+		return
+	}
+	sub := newVisitor(v.dialect, r.FieldMap, v.cfg)
+	sub.nested = true
+	spec.Accept(sub)
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+	v.conditions = append(v.conditions, Exists{Relation: relation, Table: r.Table, On: r.On, Where: sub.conditions})
+}
+
+// antiJoin returns the LEFT JOIN negating conditions, for a negation of
+// the top-level conjunction whose conditions are a single Exists.
+func (v *Visitor) antiJoin(conditions []Expr) (AntiJoin, bool) {
+	if v.nested || v.cfg.antiJoins != AntiJoinLeftJoin || len(conditions) != 1 {
+		return AntiJoin{}, false
+	}
+	e, ok := conditions[0].(Exists)
+	if !ok {
+		return AntiJoin{}, false
+	}
+	r := v.cfg.relations[e.Relation]
+	if r.Key == "" {
+		return AntiJoin{}, false
+	}
+	for _, j := range v.antiJoins {
+		if j.Table == e.Table {
+			return AntiJoin{}, false
+		}
+	}
+	return AntiJoin{Table: e.Table, On: e.On, Where: e.Where, Key: r.Key}, true
+}
+
+// VisitLock records a row-locking clause for dialects implementing Locker,
+// appended after pagination.
+func (v *Visitor) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	s, w, err := specifications.ParseLock(string(strength), string(wait))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	d, ok := v.dialect.(Locker)
+	if !ok {
+		v.fail(fmt.Errorf("%w: row locking", specifications.ErrUnsupported))
+		return
+	}
+	if _, ok := d.Lock(s, w); !ok {
+		v.fail(fmt.Errorf("%w: FOR %s with wait policy %q", specifications.ErrUnsupported, s, w))
+		return
+	}
+	v.lock = &Lock{Strength: s, Wait: w}
+}
+
+func (v *Visitor) BuildQuery(baseQuery string) (string, []interface{}) {
+	q := v.query()
+	v.checkBase(&q, baseQuery)
+	if v.failed() {
+		return "", nil
+	}
+	if v.cfg.cache != nil {
+		return v.cfg.cache.render(v.dialect, &q, baseQuery)
+	}
+	return q.Render(v.dialect, baseQuery)
+}
+
+// BuildQueryWithArgs is like BuildQuery for a base query already binding
+// existingArgs, e.g. a prepared repository query filtering on tenant_id,
+// and returns them with the arguments of the visited specifications.
+// Numbered placeholders are numbered after existingArgs, which come first.
+// With '?' placeholders, the arguments are in the order of the rendered
+// placeholders, so the visited ones are placed among existingArgs when the
+// base query binds some after its WHERE condition, e.g. in LIMIT ?.
+// existingArgs must then bind the '?' of the base query one for one;
+// otherwise the error is reported by Err and no query is returned.
+func (v *Visitor) BuildQueryWithArgs(baseQuery string, existingArgs []interface{}) (string, []interface{}) {
+	q := v.query()
+	v.checkBase(&q, baseQuery)
+	if v.failed() {
+		return "", nil
+	}
+	query, args, err := q.renderAfter(v.dialect, baseQuery, existingArgs, nil)
+	if err != nil {
+		v.fail(err)
+		return "", nil
+	}
+	return query, args
+}
+
+// BuildNamedQuery is like BuildQuery but emits named parameters such as
+// :status_1, whatever the dialect, and returns the arguments keyed by name, as expected by
+// sqlx.NamedQuery. Names derive from the column the value is compared with.
+func (v *Visitor) BuildNamedQuery(baseQuery string) (string, map[string]interface{}) {
+	q := v.query()
+	v.checkBase(&q, baseQuery)
+	if v.failed() {
+		return "", nil
+	}
+	return q.RenderNamed(v.dialect, baseQuery)
+}
+
+// BuildCountQuery builds the query counting every row matched by the
+// visited specification, for pagination totals:
+//
+//	SELECT COUNT(*) FROM table WHERE ...
+//
+// With GROUP BY or HAVING, it counts the groups instead, and with DISTINCT
+// the distinct rows:
+//
+//	SELECT COUNT(*) FROM (SELECT ... FROM table WHERE ... GROUP BY ... HAVING ...) AS counted
+//
+// Anti-joins are joined as by BuildQuery. Ordering, pagination, sampling
+// and locking are ignored.
+func (v *Visitor) BuildCountQuery(baseTable string) (string, []interface{}) {
+	if v.failed() {
+		return "", nil
+	}
+	q := &Query{Where: v.conditions, GroupBy: v.groupBy, Having: v.having, Distinct: v.distinct, AntiJoins: v.antiJoins}
+	if len(q.GroupBy) == 0 && len(q.Having) == 0 && q.Distinct == nil {
+		return q.Render(v.dialect, "SELECT COUNT(*) FROM "+baseTable)
+	}
+	// The selected columns are those compared by DISTINCT: the grouping
+	// columns of grouped queries, the single group of HAVING without GROUP
+	// BY, and the rows otherwise.
+	columns := "*"
+	switch {
+	case len(q.GroupBy) > 0:
+		columns = strings.Join(q.GroupBy, ", ")
+	case len(q.Having) > 0:
+		columns = "1"
+	}
+	query, args := q.Render(v.dialect, "SELECT "+columns+" FROM "+baseTable)
+	return "SELECT COUNT(*) FROM (" + query + ") AS counted", args
+}
+
+// Query returns the structured form of the visited specifications, for
+// rewriting before rendering it with Query.Render. The returned value is
+// independent of the visitor.
+func (v *Visitor) Query() *Query {
+	q := v.query()
+	q.Where = append([]Expr(nil), q.Where...)
+	q.GroupBy = append([]string(nil), q.GroupBy...)
+	q.Having = append([]Expr(nil), q.Having...)
+	q.OrderBy = append([]OrderTerm(nil), q.OrderBy...)
+	if q.Distinct != nil {
+		q.Distinct = &Distinct{On: append([]string(nil), q.Distinct.On...)}
+	}
+	if q.Lock != nil {
+		l := *q.Lock
+		q.Lock = &l
+	}
+	return &q
+}
+
+func (v *Visitor) query() Query {
+	return Query{
+		Where:     v.conditions,
+		GroupBy:   v.groupBy,
+		Having:    v.having,
+		OrderBy:   v.orderBy(),
+		Limit:     v.queryLimit(),
+		Offset:    v.offset,
+		LimitSet:  v.limitSet,
+		OffsetSet: v.offsetSet,
+		Sample:    v.sample,
+		Distinct:  v.distinct,
+		Lock:      v.lock,
+		AntiJoins: v.antiJoins,
+	}
+}
+
+// queryLimit returns the visited limit or, without one, the default limit
+// bounded by the maximum.
+func (v *Visitor) queryLimit() int {
+	if v.limitSet {
+		return v.limit
+	}
+	limit, max := v.cfg.defaultLimit, v.cfg.maxLimit
+	if max > 0 && (limit <= 0 || limit > max) {
+		return max
+	}
+	return limit
+}
+
+// checkBase reports the clauses of q that SQL requires before those ending
+// baseQuery, such as an ordering after a base query ending with LIMIT.
+func (v *Visitor) checkBase(q *Query, baseQuery string) {
+	c := q.first()
+	if c == noClause {
+		return
+	}
+	if base := scanBase(baseQuery, ""); base.tail >= 0 && c <= base.last {
+		v.fail(fmt.Errorf("%w: %s after a base query ending with %s", specifications.ErrUnsupported, c, base.last))
+	}
+}
+
+// failed reports whether an error was recorded in hardened mode, in which
+// no query is rendered.
+func (v *Visitor) failed() bool {
+	return v.cfg.hardened && v.err != nil
+}
+
+// orderBy returns the order clauses, completed with the tie-breaker when
+// configured.
+func (v *Visitor) orderBy() []OrderTerm {
+	if len(v.orderClauses) == 0 || v.cfg.tieBreakerColumn == "" {
+		return v.orderClauses
+	}
+	for _, o := range v.orderClauses {
+		if o.Column == v.cfg.tieBreakerColumn {
+			return v.orderClauses
+		}
+	}
+	return append(v.orderClauses[:len(v.orderClauses):len(v.orderClauses)], OrderTerm{Column: v.cfg.tieBreakerColumn, Direction: string(specifications.Ascending)})
+}
+
+// Build is like BuildQuery but returns the first error reported while
+// visiting or building, in which case the query must not be executed.
+func (v *Visitor) Build(baseQuery string) (string, []interface{}, error) {
+	if v.err != nil {
+		return "", nil, v.err
+	}
+	query, args := v.BuildQuery(baseQuery)
+	if v.err != nil {
+		return "", nil, v.err
+	}
+	return query, args, nil
+}
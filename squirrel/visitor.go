@@ -0,0 +1,610 @@
+package squirrel
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/thefabric-io/specifications"
+	"github.com/thefabric-io/specifications/internal/jsondoc"
+	"github.com/thefabric-io/specifications/internal/pgarray"
+	"github.com/thefabric-io/specifications/internal/sqlsafe"
+)
+
+// Visitor translates specifications into squirrel predicates and applies
+// ordering and pagination to a squirrel SelectBuilder, so specifications
+// compose with existing squirrel queries. Placeholders are left to the
+// builder's PlaceholderFormat.
+type Visitor struct {
+	predicates []sq.Sqlizer
+	fieldMap   map[string]string
+	orderBys   []sq.Sqlizer
+	distinct   bool
+	distinctOn []string
+	groupBys   []string
+	havings    []sq.Sqlizer
+	lock       string
+	limit      int
+	offset     int
+	empty      specifications.EmptyComposite
+	// aggregate is applied to the mapped fields while visiting the
+	// specification of an Aggregated.
+	aggregate specifications.AggregateFunction
+	// inHaving is set while visiting the specification of a Having, whose
+	// conditions may be on aggregates.
+	inHaving bool
+	// limitSet and offsetSet tell a visited Limit or Offset of zero from
+	// none.
+	limitSet, offsetSet bool
+	// hardened validates the mapped columns, as sqlspec.WithHardening.
+	hardened bool
+	err      error
+}
+
+// Option configures a Visitor.
+type Option func(*Visitor)
+
+// WithHardening validates every mapped column against a strict identifier
+// grammar and fails closed: invalid input is reported by Err and Apply, and
+// Where returns a condition failing to build. Hardening is on by default
+// when built with the specifications_hardened tag.
+func WithHardening() Option {
+	return func(v *Visitor) {
+		v.hardened = true
+	}
+}
+
+// WithEmptyComposite sets how And and Or without children are translated.
+// The default is specifications.EmptyCompositeSkip.
+func WithEmptyComposite(mode specifications.EmptyComposite) Option {
+	return func(v *Visitor) {
+		v.empty = mode
+	}
+}
+
+func NewVisitor(fieldMap map[string]string, opts ...Option) *Visitor {
+	v := &Visitor{
+		predicates: []sq.Sqlizer{},
+		fieldMap:   fieldMap,
+		orderBys:   []sq.Sqlizer{},
+		hardened:   sqlsafe.HardenedByDefault,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// child returns an empty visitor sharing v's configuration, used for
+// composite specifications.
+func (v *Visitor) child() *Visitor {
+	sub := NewVisitor(v.fieldMap, WithEmptyComposite(v.empty))
+	sub.hardened = v.hardened
+	sub.aggregate = v.aggregate
+	sub.inHaving = v.inHaving
+	return sub
+}
+
+// Err returns the first error encountered while visiting specifications.
+func (v *Visitor) Err() error {
+	return v.err
+}
+
+func (v *Visitor) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+func (v *Visitor) mapField(domainField string) string {
+	if v.aggregate == specifications.AggregateCount && domainField == "*" {
+		return "COUNT(*)"
+	}
+	if domainField == "" {
+		v.fail(specifications.ErrInvalidField)
+	}
+	dbField := domainField
+	if mapped, ok := v.fieldMap[domainField]; ok {
+		dbField = mapped
+	}
+	if v.hardened && !sqlsafe.Identifier(dbField) {
+		v.fail(fmt.Errorf("%w: %q is not a valid identifier", specifications.ErrInvalidField, dbField))
+	}
+	if v.aggregate != "" {
+		return aggregated(v.aggregate, dbField)
+	}
+	return dbField
+}
+
+func (v *Visitor) add(p sq.Sqlizer) {
+	v.predicates = append(v.predicates, p)
+}
+
+func (v *Visitor) VisitEqual(field string, value interface{}) {
+	v.add(sq.Eq{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitNotEqual(field string, value interface{}) {
+	v.add(sq.NotEq{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitIn(field string, values []interface{}) {
+	v.add(sq.Eq{v.mapField(field): values})
+}
+
+func (v *Visitor) VisitNotIn(field string, values []interface{}) {
+	v.add(sq.NotEq{v.mapField(field): values})
+}
+
+// VisitEqualAny binds values as one PostgreSQL array with = ANY(?).
+func (v *Visitor) VisitEqualAny(field string, values []interface{}) {
+	if len(values) == 0 {
+		v.VisitIn(field, values)
+		return
+	}
+	v.add(sq.Expr(v.mapField(field)+" = ANY(?)", pgarray.Array(values)))
+}
+
+// VisitArrayContains uses the PostgreSQL array containment operator.
+func (v *Visitor) VisitArrayContains(field string, values []interface{}) {
+	v.add(sq.Expr(v.mapField(field)+" @> ?", pgarray.Array(values)))
+}
+
+// VisitArrayOverlaps uses the PostgreSQL array overlap operator.
+func (v *Visitor) VisitArrayOverlaps(field string, values []interface{}) {
+	v.add(sq.Expr(v.mapField(field)+" && ?", pgarray.Array(values)))
+}
+
+func (v *Visitor) VisitGreaterThan(field string, value interface{}) {
+	v.add(sq.Gt{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitLowerThan(field string, value interface{}) {
+	v.add(sq.Lt{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitGreaterThanOrEqual(field string, value interface{}) {
+	v.add(sq.GtOrEq{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitLowerThanOrEqual(field string, value interface{}) {
+	v.add(sq.LtOrEq{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitBetween(field string, low, high interface{}) {
+	v.add(sq.Expr(v.mapField(field)+" BETWEEN ? AND ?", low, high))
+}
+
+func (v *Visitor) VisitIsNull(field string) {
+	v.add(sq.Eq{v.mapField(field): nil})
+}
+
+func (v *Visitor) VisitIsNotNull(field string) {
+	v.add(sq.NotEq{v.mapField(field): nil})
+}
+
+func (v *Visitor) VisitLike(field string, value interface{}) {
+	v.add(sq.Like{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitILike(field string, value interface{}) {
+	v.add(sq.ILike{v.mapField(field): value})
+}
+
+func (v *Visitor) VisitLikeEscaped(field string, pattern string) {
+	v.add(sq.Expr(v.mapField(field)+` LIKE ? ESCAPE '\'`, pattern))
+}
+
+// VisitRegex uses the PostgreSQL regular expression operators.
+func (v *Visitor) VisitRegex(field string, pattern string, caseInsensitive bool) {
+	op := " ~ ?"
+	if caseInsensitive {
+		op = " ~* ?"
+	}
+	v.add(sq.Expr(v.mapField(field)+op, pattern))
+}
+
+// VisitJSONContains uses the PostgreSQL jsonb containment operator.
+func (v *Visitor) VisitJSONContains(field string, doc interface{}) {
+	column := v.mapField(field)
+	text, err := jsondoc.Encode(doc)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.add(sq.Expr(column+" @> ?::jsonb", text))
+}
+
+// VisitJSONPathEqual compares the text at path, extracted with the
+// PostgreSQL jsonb operators, with the text form of value.
+func (v *Visitor) VisitJSONPathEqual(field string, path []string, value interface{}) {
+	column := v.mapField(field)
+	args := make([]interface{}, len(path), len(path)+1)
+	for i, key := range path {
+		args[i] = key
+	}
+	extract := "(" + column + " ->> ?)"
+	if len(path) != 1 {
+		extract = "(" + column + " #>> ARRAY[" + strings.TrimSuffix(strings.Repeat("?, ", len(path)), ", ") + "]::text[])"
+	}
+	if value == nil {
+		v.add(sq.Expr(extract+" IS NULL", args...))
+		return
+	}
+	text, err := jsondoc.Text(value)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.add(sq.Expr(extract+" = ?", append(args, text)...))
+}
+
+// VisitJSONKeyExists uses jsonb_exists rather than the ? operator, which
+// squirrel would take for a placeholder.
+func (v *Visitor) VisitJSONKeyExists(field, key string) {
+	v.add(sq.Expr("jsonb_exists("+v.mapField(field)+", ?)", key))
+}
+
+// VisitTextSearch uses the PostgreSQL full-text search functions.
+func (v *Visitor) VisitTextSearch(field, query, language string) {
+	column := v.mapField(field)
+	if !v.textSearchConfig(language) {
+		return
+	}
+	v.add(sq.Expr(tsvector(column, language)+" @@ "+tsquery(language), query))
+}
+
+// VisitTextRank orders by the PostgreSQL ts_rank of the rows, most relevant
+// first.
+func (v *Visitor) VisitTextRank(field, query, language string) {
+	column := v.mapField(field)
+	if !v.textSearchConfig(language) {
+		return
+	}
+	v.orderBys = append(v.orderBys, sq.Expr("ts_rank("+tsvector(column, language)+", "+tsquery(language)+") DESC", query))
+}
+
+// VisitSimilar uses the pg_trgm similarity operator, or compares the
+// similarity score with threshold.
+func (v *Visitor) VisitSimilar(field, value string, threshold float64) {
+	column := v.mapField(field)
+	switch {
+	case !v.similarityThreshold(threshold):
+	case threshold == 0:
+		v.add(sq.Expr(column+" % ?", value))
+	default:
+		v.add(sq.Expr("similarity("+column+", ?) > ?", value, threshold))
+	}
+}
+
+// VisitSimilarityOrder orders by the pg_trgm similarity, most similar
+// first.
+func (v *Visitor) VisitSimilarityOrder(field, value string) {
+	v.orderBys = append(v.orderBys, sq.Expr("similarity("+v.mapField(field)+", ?) DESC", value))
+}
+
+// VisitWithinRadius uses PostGIS on a geography column.
+func (v *Visitor) VisitWithinRadius(field string, center specifications.Point, meters float64) {
+	v.add(sq.Expr("ST_DWithin("+v.mapField(field)+", ST_MakePoint(?, ?)::geography, ?)", center.Lng, center.Lat, meters))
+}
+
+func (v *Visitor) VisitWithinBox(field string, box specifications.BoundingBox) {
+	v.add(sq.Expr("ST_Intersects("+v.mapField(field)+", ST_MakeEnvelope(?, ?, ?, ?, 4326)::geography)", box.MinLng, box.MinLat, box.MaxLng, box.MaxLat))
+}
+
+// VisitOrderByDistance orders by the PostGIS distance operator, nearest
+// first.
+func (v *Visitor) VisitOrderByDistance(field string, from specifications.Point) {
+	v.orderBys = append(v.orderBys, sq.Expr(v.mapField(field)+" <-> ST_MakePoint(?, ?)::geography", from.Lng, from.Lat))
+}
+
+// VisitRelated is not supported. Expand it with
+// specifications.ExpandRelated first, or use sqlspec visitors configured
+// with WithRelation.
+func (v *Visitor) VisitRelated(relation string, spec specifications.Specification) {
+	v.fail(fmt.Errorf("%w: related specification on %q", specifications.ErrUnsupported, relation))
+}
+
+func (v *Visitor) similarityThreshold(threshold float64) bool {
+	if threshold < 0 || threshold > 1 {
+		v.fail(fmt.Errorf("%w: similarity threshold %v", specifications.ErrInvalidValue, threshold))
+		return false
+	}
+	return true
+}
+
+// textSearchConfig reports whether language is empty or a valid text search
+// configuration name, which is written in the query rather than bound.
+func (v *Visitor) textSearchConfig(language string) bool {
+	if language != "" && !sqlsafe.Identifier(language) {
+		v.fail(fmt.Errorf("%w: text search configuration %q", specifications.ErrInvalidValue, language))
+		return false
+	}
+	return true
+}
+
+func tsvector(column, language string) string {
+	if language == "" {
+		return "to_tsvector(" + column + ")"
+	}
+	return "to_tsvector('" + language + "', " + column + ")"
+}
+
+func tsquery(language string) string {
+	if language == "" {
+		return "plainto_tsquery(?)"
+	}
+	return "plainto_tsquery('" + language + "', ?)"
+}
+
+func (v *Visitor) VisitAnd(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(sq.Expr("1=1"))
+		return
+	}
+
+	subVisitor := v.child()
+
+	for _, s := range specs {
+		s.Accept(subVisitor)
+	}
+
+	if len(subVisitor.predicates) > 0 {
+		v.add(sq.And(subVisitor.predicates))
+	}
+
+	v.merge(subVisitor)
+}
+
+func (v *Visitor) VisitOr(specs []specifications.Specification) {
+	if len(specs) == 0 {
+		v.visitEmpty(sq.Expr("1=0"))
+		return
+	}
+
+	orParts := sq.Or{}
+
+	for _, s := range specs {
+		temp := v.child()
+
+		s.Accept(temp)
+
+		if len(temp.predicates) > 0 {
+			orParts = append(orParts, sq.And(temp.predicates))
+		}
+
+		v.merge(temp)
+	}
+
+	if len(orParts) > 0 {
+		v.add(orParts)
+	}
+}
+
+func (v *Visitor) VisitNot(spec specifications.Specification) {
+	sub := v.child()
+	spec.Accept(sub)
+	if len(sub.predicates) > 0 {
+		v.add(sq.Expr("NOT ?", sq.And(sub.predicates)))
+	}
+	v.merge(sub)
+}
+
+// visitEmpty handles a composite without children, identity being the
+// predicate equivalent to its logical identity.
+func (v *Visitor) visitEmpty(identity sq.Sqlizer) {
+	switch v.empty {
+	case specifications.EmptyCompositeIdentity:
+		v.add(identity)
+	case specifications.EmptyCompositeError:
+		v.fail(specifications.ErrEmptyComposite)
+	}
+}
+
+// merge carries everything but predicates over from a sub-visitor.
+func (v *Visitor) merge(sub *Visitor) {
+	v.orderBys = append(v.orderBys, sub.orderBys...)
+
+	if sub.distinct {
+		v.distinct, v.distinctOn = true, sub.distinctOn
+	}
+
+	if sub.lock != "" {
+		v.lock = sub.lock
+	}
+
+	v.groupBys = append(v.groupBys, sub.groupBys...)
+	v.havings = append(v.havings, sub.havings...)
+
+	if sub.limitSet {
+		v.limit, v.limitSet = sub.limit, true
+	}
+
+	if sub.offsetSet {
+		v.offset, v.offsetSet = sub.offset, true
+	}
+
+	if sub.err != nil {
+		v.fail(sub.err)
+	}
+}
+
+func (v *Visitor) VisitLimit(limit int) {
+	if limit < 0 {
+		v.fail(fmt.Errorf("%w: negative limit %d", specifications.ErrInvalidValue, limit))
+		return
+	}
+	v.limit, v.limitSet = limit, true
+}
+
+func (v *Visitor) VisitOffset(offset int) {
+	if offset < 0 {
+		v.fail(fmt.Errorf("%w: negative offset %d", specifications.ErrInvalidValue, offset))
+		return
+	}
+	v.offset, v.offsetSet = offset, true
+}
+
+func (v *Visitor) VisitOrder(field, direction string) {
+	v.VisitOrderNulls(field, direction, specifications.NullsDefault)
+}
+
+// VisitOrderNulls orders by whether field is NULL first, which is portable
+// across databases unlike NULLS FIRST / NULLS LAST.
+func (v *Visitor) VisitOrderNulls(field, direction string, nulls specifications.NullsPosition) {
+	column := v.mapField(field)
+	d, err := specifications.ParseOrderDirection(direction)
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	switch nulls {
+	case specifications.NullsDefault:
+	case specifications.NullsFirst:
+		v.orderBys = append(v.orderBys, sq.Expr("CASE WHEN "+column+" IS NULL THEN 0 ELSE 1 END"))
+	case specifications.NullsLast:
+		v.orderBys = append(v.orderBys, sq.Expr("CASE WHEN "+column+" IS NULL THEN 1 ELSE 0 END"))
+	default:
+		v.fail(fmt.Errorf("%w: nulls position %q", specifications.ErrInvalidValue, nulls))
+		return
+	}
+	v.orderBys = append(v.orderBys, sq.Expr(column+" "+string(d)))
+}
+
+// VisitDistinct makes Apply select DISTINCT rows, or with fields DISTINCT ON
+// them, which only PostgreSQL supports.
+func (v *Visitor) VisitDistinct(fields []string) {
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = v.mapField(f)
+	}
+	v.distinct, v.distinctOn = true, columns
+}
+
+func (v *Visitor) VisitGroupBy(fields []string) {
+	if len(fields) == 0 {
+		v.fail(fmt.Errorf("%w: GROUP BY without fields", specifications.ErrInvalidValue))
+		return
+	}
+	for _, f := range fields {
+		v.groupBys = append(v.groupBys, v.mapField(f))
+	}
+}
+
+// VisitHaving makes Apply add the conditions of spec to the HAVING clause.
+// Its ordering and pagination apply to the query, like in VisitNot.
+func (v *Visitor) VisitHaving(spec specifications.Specification) {
+	sub := v.child()
+	sub.inHaving = true
+	spec.Accept(sub)
+	v.havings = append(v.havings, sub.predicates...)
+	v.merge(sub)
+}
+
+// VisitAggregate visits spec with function applied to its columns, e.g.
+// SUM(amount) >= ? within Having, or ORDER BY COUNT(*) DESC. Conditions on
+// aggregates outside Having are reported as unsupported.
+func (v *Visitor) VisitAggregate(function specifications.AggregateFunction, spec specifications.Specification) {
+	f, err := specifications.ParseAggregateFunction(string(function))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	sub := v.child()
+	sub.aggregate = f
+	spec.Accept(sub)
+	if len(sub.predicates) > 0 && !v.inHaving {
+		v.fail(fmt.Errorf("%w: conditions on %s aggregates outside Having", specifications.ErrUnsupported, f))
+		return
+	}
+	v.predicates = append(v.predicates, sub.predicates...)
+	v.merge(sub)
+}
+
+// VisitLock makes Apply append FOR UPDATE or FOR SHARE with its wait
+// policy, as supported by PostgreSQL and MySQL 8.
+func (v *Visitor) VisitLock(strength specifications.LockStrength, wait specifications.LockWait) {
+	s, w, err := specifications.ParseLock(string(strength), string(wait))
+	if err != nil {
+		v.fail(err)
+		return
+	}
+	v.lock = strings.TrimSpace("FOR " + string(s) + " " + string(w))
+}
+
+// VisitSample is not supported: squirrel has no TABLESAMPLE clause.
+func (v *Visitor) VisitSample(percent float64, method specifications.SampleMethod) {
+	v.fail(fmt.Errorf("%w: sampling with squirrel", specifications.ErrUnsupported))
+}
+
+// Where returns the conjunction of the visited conditions, or nil when there
+// are none. In hardened mode, after an error, it returns a condition whose
+// ToSql returns the error.
+func (v *Visitor) Where() sq.Sqlizer {
+	if v.hardened && v.err != nil {
+		return failed{v.err}
+	}
+	if len(v.predicates) == 0 {
+		return nil
+	}
+	return sq.And(v.predicates)
+}
+
+// Apply adds the visited conditions, DISTINCT, grouping, ordering,
+// pagination and row locking to b.
+func (v *Visitor) Apply(b sq.SelectBuilder) (sq.SelectBuilder, error) {
+	if v.err != nil {
+		return b, v.err
+	}
+	switch {
+	case len(v.distinctOn) > 0:
+		b = b.Options("DISTINCT ON (" + strings.Join(v.distinctOn, ", ") + ")")
+	case v.distinct:
+		b = b.Distinct()
+	}
+	if where := v.Where(); where != nil {
+		b = b.Where(where)
+	}
+	if len(v.groupBys) > 0 {
+		b = b.GroupBy(v.groupBys...)
+	}
+	if len(v.havings) > 0 {
+		b = b.Having(sq.And(v.havings))
+	}
+	for _, o := range v.orderBys {
+		b = b.OrderByClause(o)
+	}
+	if v.limitSet {
+		b = b.Limit(uint64(v.limit))
+	}
+	if v.offsetSet {
+		b = b.Offset(uint64(v.offset))
+	}
+	if v.lock != "" {
+		b = b.Suffix(v.lock)
+	}
+	return b, nil
+}
+
+// failed is a condition failing to build with err.
+type failed struct {
+	err error
+}
+
+func (f failed) ToSql() (string, []interface{}, error) {
+	return "", nil, f.err
+}
+
+// aggregated applies function to column, writing statistical aggregates in
+// standard SQL, as supported by PostgreSQL: STDDEV_SAMP, and
+// PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY column).
+func aggregated(function specifications.AggregateFunction, column string) string {
+	if function == specifications.AggregateStddev {
+		return "STDDEV_SAMP(" + column + ")"
+	}
+	if _, ok := function.Percentile(); ok {
+		return string(function) + " WITHIN GROUP (ORDER BY " + column + ")"
+	}
+	return string(function) + "(" + column + ")"
+}
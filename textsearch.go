@@ -0,0 +1,50 @@
+package specifications
+
+type textSearchSpec struct {
+	field    string
+	query    string
+	language string
+	rank     bool
+}
+
+func (s *textSearchSpec) Accept(v SpecificationVisitor) {
+	if s.rank {
+		v.VisitTextRank(s.field, s.query, s.language)
+		return
+	}
+	v.VisitTextSearch(s.field, s.query, s.language)
+}
+
+// TextSearchOption configures TextSearch and OrderByRank.
+type TextSearchOption func(*textSearchSpec)
+
+// Language sets the text search configuration stemming the field and the
+// query, e.g. "english". The default is the database's.
+func Language(config string) TextSearchOption {
+	return func(s *textSearchSpec) {
+		s.language = config
+	}
+}
+
+// TextSearch matches entities whose text field contains every word of
+// query, as plain text with stemming: in PostgreSQL,
+//
+//	to_tsvector(field) @@ plainto_tsquery(query)
+func TextSearch(field, query string, opts ...TextSearchOption) Specification {
+	return newTextSearch(field, query, false, opts)
+}
+
+// OrderByRank orders by decreasing relevance of field to query, as ranked
+// by ts_rank in PostgreSQL. Combine it with TextSearch on the same field
+// and query.
+func OrderByRank(field, query string, opts ...TextSearchOption) Specification {
+	return newTextSearch(field, query, true, opts)
+}
+
+func newTextSearch(field, query string, rank bool, opts []TextSearchOption) Specification {
+	s := &textSearchSpec{field: field, query: query, rank: rank}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
@@ -0,0 +1,54 @@
+package specifications
+
+import "time"
+
+// Scalar lists the value types accepted by the typed constructors. The
+// ~[16]byte term covers UUID types such as github.com/google/uuid.UUID.
+type Scalar interface {
+	~string | ~bool |
+		~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64 |
+		time.Time | ~[16]byte
+}
+
+// The constructors below mirror their untyped counterparts but only accept
+// Scalar values, so passing a struct, a slice or a nil by mistake fails to
+// compile. They produce the same specifications, and therefore work with
+// every visitor.
+
+func EqualT[T Scalar](field string, value T) Specification {
+	return Equal(field, value)
+}
+
+func NotEqualT[T Scalar](field string, value T) Specification {
+	return NotEqual(field, value)
+}
+
+func GreaterThanT[T Scalar](field string, value T) Specification {
+	return GreaterThan(field, value)
+}
+
+func GreaterThanOrEqualT[T Scalar](field string, value T) Specification {
+	return GreaterThanOrEqual(field, value)
+}
+
+func LowerThanT[T Scalar](field string, value T) Specification {
+	return LowerThan(field, value)
+}
+
+func LowerThanOrEqualT[T Scalar](field string, value T) Specification {
+	return LowerThanOrEqual(field, value)
+}
+
+func BetweenT[T Scalar](field string, low, high T) Specification {
+	return Between(field, low, high)
+}
+
+func InT[T Scalar](field string, values ...T) Specification {
+	return In(field, toInterfaces(values)...)
+}
+
+func NotInT[T Scalar](field string, values ...T) Specification {
+	return NotIn(field, toInterfaces(values)...)
+}
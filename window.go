@@ -0,0 +1,57 @@
+package specifications
+
+// Skip is an alias of Offset.
+func Skip(n int) Specification {
+	return Offset(n)
+}
+
+// Take is an alias of Limit.
+func Take(n int) Specification {
+	return Limit(n)
+}
+
+// Slice selects the results from index from up to, but excluding, index
+// to, like a slice expression: Offset(from) and Limit(to-from). to must not
+// be less than from; Slice(from, from) selects no results.
+func Slice(from, to int) Specification {
+	return And(Offset(from), Limit(to-from))
+}
+
+// Window returns the offset and limit spec paginates with, zero when unset,
+// and whether it has a limit, which tells a Limit of zero, matching nothing,
+// from none. Like in the visitors, later Limit and Offset specifications
+// override earlier ones.
+func Window(spec Specification) (offset, limit int, limited bool) {
+	r := &rewriter{window: &window{}}
+	if spec != nil {
+		spec.Accept(r)
+	}
+	return r.window.offset, r.window.limit, r.window.limited
+}
+
+// Rewindow returns spec with its Limit and Offset specifications replaced
+// by offset and limit, a zero offset skipping no results and a negative
+// limit setting none, e.g. to prefetch the next page:
+//
+//	offset, limit, _ := specifications.Window(spec)
+//	next := specifications.Rewindow(spec, offset+limit, limit)
+//
+// The rest of spec, including its ordering, is kept, so that the new
+// window applies to the same sequence of results.
+func Rewindow(spec Specification, offset, limit int) Specification {
+	r := &rewriter{window: &window{}}
+	if spec != nil {
+		spec.Accept(r)
+	}
+	out := r.out
+	if offset > 0 {
+		out = append(out, Offset(offset))
+	}
+	if limit >= 0 {
+		out = append(out, Limit(limit))
+	}
+	if len(out) == 1 {
+		return out[0]
+	}
+	return And(out...)
+}